@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+func runApply(args []string) {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	planOnly := flags.Bool("plan", false, "print the changes that would be made and exit without changing anything")
+	planOut := flags.String("out", "", "with -plan, also save the plan to this file")
+	apply := flags.Bool("apply", false, "print the plan and then apply it (the default)")
+	planFile := flags.String("plan-file", "", "with -apply, only apply if the current plan still matches this saved plan")
+	reboot := flags.String("reboot", rebootAsk, "reboot instances with pending parameter changes: ask, always or never")
+	updatePassword := flags.Bool(
+		"update-password", false, "also set the master password of an existing cluster; see rotate-password",
+	)
+	replace := flags.Bool(
+		"replace", false, "replace the cluster from a snapshot when a change can't be made in place",
+	)
+	snapshotFirst := flags.Bool(
+		"snapshot-before-upgrade", false, "take a snapshot of the cluster before upgrading its engine version in place",
+	)
+	flags.Parse(args)
+
+	if *planOnly && *apply {
+		log.Fatal("-plan and -apply are mutually exclusive")
+	}
+	if *reboot != rebootAsk && *reboot != rebootAlways && *reboot != rebootNever {
+		log.Fatalf("-reboot must be %s, %s or %s", rebootAsk, rebootAlways, rebootNever)
+	}
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.Validate)
+	if err != nil {
+		fatal(err)
+	}
+
+	generated, err := resolvePassword(&req, !*planOnly)
+	if err != nil {
+		fatal(err)
+	}
+	req.UpdateMasterUserPass = *updatePassword
+	req.ReplaceCluster = *replace
+	req.SnapshotBeforeUpgrade = *snapshotFirst
+
+	err = resolveKmsKey(&req)
+	if err == nil {
+		err = resolveSecondaryKmsKeys(&req)
+	}
+	if err == nil {
+		err = checkSubnetZones(req)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	svc := newRDS(req)
+	regions := newRegions(req)
+
+	plan, err := service.BuildPlan(svc, req)
+	if err == nil && req.Global != nil {
+		err = service.PlanGlobal(svc, regions, req, plan)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	// The password of an existing cluster only changes with -update-password.
+	if generated && !plan.SetsMasterUserPassword() {
+		log.Fatalf(
+			"cluster %s already exists, a master password is only generated for a new cluster or with -update-password",
+			req.ClusterId,
+		)
+	}
+	plan.Print(os.Stdout)
+
+	if *planOnly {
+		if *planOut != "" {
+			err = service.WritePlan(*planOut, plan)
+			if err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+
+	if *planFile != "" {
+		saved, err := service.ReadPlan(*planFile)
+		if err != nil {
+			fatal(err)
+		}
+		if !saved.Equal(plan) {
+			log.Fatalf("plan in %s is out of date, run -plan again", *planFile)
+		}
+	}
+
+	waiter := newWaiter(req)
+	err = service.ApplyPlan(svc, req, plan, waiter)
+	if err == nil && req.Global != nil {
+		err = service.ApplyGlobal(svc, regions, req, plan, waiter)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	err = rebootPending(svc, req, waiter, *reboot)
+	if err != nil {
+		fatal(err)
+	}
+	for _, s := range req.SecondaryRequests() {
+		err = rebootPending(regions(s.Region), s, waiter, *reboot)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	log.Info("success")
+}
+
+const (
+	rebootAsk    = "ask"
+	rebootAlways = "always"
+	rebootNever  = "never"
+)
+
+// rebootPending reboots the instances that still have parameter changes to
+// pick up, asking first unless mode says otherwise.
+func rebootPending(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, mode string) error {
+	pending, err := service.PendingReboot(svc, req)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	list := strings.Join(pending, ", ")
+	switch {
+	case mode == rebootNever:
+	case mode == rebootAlways:
+		return service.RebootInstances(svc, req, waiter, pending)
+	case confirm(fmt.Sprintf("Instances %s need a reboot to apply parameter changes. Reboot them now, one at a time?", list)):
+		return service.RebootInstances(svc, req, waiter, pending)
+	}
+
+	log.Warnf("parameter changes on %s take effect after the next reboot", list)
+	return nil
+}
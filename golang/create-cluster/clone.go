@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+func runClone(args []string) {
+	flags := flag.NewFlagSet("clone", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON spec of the clone; environment variables override its fields")
+	sourceId := flags.String("source", "", "identifier of the cluster to clone")
+	restoreTime := flags.String("restore-time", "", "restore the source as it was at this RFC 3339 time")
+	latest := flags.Bool("latest", false, "restore the source at its latest restorable time")
+	fullCopy := flags.Bool("full-copy", false, "copy the storage instead of making a copy-on-write clone")
+	flags.Parse(args)
+
+	if *sourceId == "" {
+		log.Fatal("-source is required")
+	}
+	if (*restoreTime == "") == !*latest {
+		log.Fatal("exactly one of -restore-time or -latest is required")
+	}
+
+	source := service.CloneSource{
+		ClusterId:   *sourceId,
+		RestoreType: factory.RestoreTypeCopyOnWrite,
+	}
+	if *fullCopy {
+		source.RestoreType = factory.RestoreTypeFullCopy
+	}
+	if *restoreTime != "" {
+		t, err := time.Parse(time.RFC3339, *restoreTime)
+		if err != nil {
+			log.Fatalf("-restore-time: %s", err)
+		}
+		source.RestoreTime = &t
+	}
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.Validate)
+	if err != nil {
+		fatal(err)
+	}
+	if req.ClusterId == *sourceId {
+		log.Fatal("the clone needs a cluster id of its own")
+	}
+
+	err = resolveKmsKey(&req)
+	if err != nil {
+		fatal(err)
+	}
+
+	cluster, instances, err := service.CloneCluster(newRDS(req), req, newWaiter(req), source)
+	if err != nil {
+		fatal(err)
+	}
+
+	printEndpoints(cluster, instances)
+	log.Info("success")
+}
+
+func printEndpoints(cluster *rds.DBCluster, instances []*rds.DBInstance) {
+	port := aws.Int64Value(cluster.Port)
+	fmt.Printf("writer  %s:%d\n", aws.StringValue(cluster.Endpoint), port)
+	fmt.Printf("reader  %s:%d\n", aws.StringValue(cluster.ReaderEndpoint), port)
+	for _, i := range instances {
+		if i.Endpoint == nil {
+			continue
+		}
+		fmt.Printf(
+			"%s  %s:%d\n",
+			aws.StringValue(i.DBInstanceIdentifier), aws.StringValue(i.Endpoint.Address), aws.Int64Value(i.Endpoint.Port),
+		)
+	}
+}
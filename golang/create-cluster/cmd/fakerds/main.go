@@ -0,0 +1,69 @@
+// Command fakerds serves the RDS Query API from memory so create-cluster can
+// be run end to end without AWS. Point create-cluster at it with
+// RDS_ENDPOINT=http://127.0.0.1:8787. The same address also answers the
+// Secrets Manager and SSM calls used for the master password, through
+// SECRETSMANAGER_ENDPOINT and SSM_ENDPOINT, the KMS key lookups through
+// KMS_ENDPOINT and the subnet lookups through EC2_ENDPOINT. With -region set
+// to several regions it serves each of them, for the secondary clusters of
+// global databases.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakesecrets"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8787", "address to listen on")
+	regions := flag.String("region", "us-east-1", "comma separated regions to serve, the first one is used for Secrets Manager, SSM and KMS")
+	describes := flag.Int("transition-describes", 1, "describe calls each status transition takes")
+	kmsAliases := flag.String("kms-aliases", "", "comma separated KMS aliases to create keys for, besides alias/aws/rds")
+	debug := flag.Bool("debug", false, "log every request")
+	flag.Parse()
+
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	// Every region gets its own backend, sharing the global clusters.
+	var backends []*fakerds.RDS
+	globalClusters := fakerds.NewGlobalClusters()
+	for _, region := range strings.Split(*regions, ",") {
+		backend := fakerds.New()
+		backend.Region = region
+		backend.TransitionDescribes = *describes
+		backend.GlobalClusters = globalClusters
+		backends = append(backends, backend)
+	}
+	region := backends[0].Region
+
+	secretsManager, parameters := fakesecrets.NewSecretsManager(), fakesecrets.NewSSM()
+	secretsManager.Region, parameters.Region = region, region
+
+	keys := fakesecrets.NewKMS()
+	keys.Region = region
+	for _, alias := range strings.Split(*kmsAliases, ",") {
+		if alias != "" {
+			keys.AddKey(alias)
+		}
+	}
+
+	rdsServer := fakerds.NewServer(backends...)
+	secretsServer := fakesecrets.NewServer(secretsManager, parameters, keys)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fakesecrets.Handles(r) {
+			secretsServer.ServeHTTP(w, r)
+			return
+		}
+		rdsServer.ServeHTTP(w, r)
+	})
+
+	log.Infof("fake RDS listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+func runDestroy(args []string) {
+	flags := flag.NewFlagSet("destroy", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	finalSnapshotId := flags.String("final-snapshot-id", "", "take a final cluster snapshot with this identifier before deleting")
+	skipFinalSnapshot := flags.Bool("skip-final-snapshot", false, "delete the cluster without a final snapshot")
+	keepGroups := flags.Bool(
+		"keep-groups", false, "leave the subnet and parameter groups alone, for example when deleting a replaced cluster",
+	)
+	flags.Parse(args)
+
+	if (*finalSnapshotId == "") == !*skipFinalSnapshot {
+		log.Fatal("exactly one of -final-snapshot-id or -skip-final-snapshot is required")
+	}
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+
+	svc := newRDS(req)
+	regions := newRegions(req)
+
+	plan, err := service.BuildDestroyPlan(svc, req)
+	if err == nil && req.Global != nil {
+		err = service.BuildGlobalDestroyPlan(svc, regions, req, plan)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if *keepGroups {
+		plan.SubnetGroup, plan.ClusterParameterGroup, plan.ParameterGroups = "", "", nil
+		for _, s := range plan.Secondaries {
+			s.Plan.SubnetGroup, s.Plan.ClusterParameterGroup, s.Plan.ParameterGroups = "", "", nil
+		}
+	}
+	if plan.Empty() {
+		log.Info("nothing to destroy")
+		return
+	}
+	plan.Print(os.Stdout)
+
+	if !*yes && !confirm("Destroy these resources?") {
+		log.Fatal("destroy cancelled")
+	}
+
+	waiter := newWaiter(req)
+	if req.Global != nil {
+		err = service.ApplyGlobalDestroyPlan(svc, regions, plan, waiter, *finalSnapshotId, req.DeleteTimeout)
+	} else {
+		err = service.ApplyDestroyPlan(svc, plan, waiter, *finalSnapshotId, req.DeleteTimeout)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	log.Info("success")
+}
@@ -1,12 +1,41 @@
 package factory
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 )
 
+// defaultKmsKeyAlias is the AWS managed key RDS encrypts with when no key is
+// given.
+const defaultKmsKeyAlias = "alias/aws/rds"
+
+// Engine modes. RDS reports no engine mode for some older provisioned
+// clusters. Only Aurora MySQL 5.6 has the global mode, see GlobalEngineMode.
+const (
+	EngineModeProvisioned = "provisioned"
+	EngineModeServerless  = "serverless"
+	EngineModeGlobal      = "global"
+)
+
+// EngineAurora is the engine of Aurora MySQL 5.6.
+const EngineAurora = "aurora"
+
+// GlobalEngineMode is the engine mode of the clusters of a global database
+// running engine. Aurora MySQL 5.6 clusters need the global mode to join
+// one; clusters of later engines join it as provisioned clusters.
+func GlobalEngineMode(engine string) string {
+	if engine == EngineAurora {
+		return EngineModeGlobal
+	}
+	return EngineModeProvisioned
+}
+
 type NewDBClusterFactoryInput struct {
 	ClusterId        string
 	Engine           string
@@ -15,18 +44,101 @@ type NewDBClusterFactoryInput struct {
 	MasterUserPass   string
 	SecurityGroupIds []string
 	SubnetGroupName  *string
+	// ParameterGroupName is empty to keep the engine default group.
+	ParameterGroupName string
+	// UpdateMasterUserPass sends MasterUserPass to an existing cluster too.
+	// Otherwise it is only used on create.
+	UpdateMasterUserPass bool
+	// KmsKeyId should be a key ARN, which is what RDS reports, so it can be
+	// compared. Empty uses the default key when StorageEncrypted is set.
+	// A nil StorageEncrypted leaves the encryption of an existing cluster
+	// alone.
+	StorageEncrypted *bool
+	KmsKeyId         string
+	// SourceSnapshot makes CreateDBCluster restore this snapshot rather than
+	// create an empty cluster.
+	SourceSnapshot string
+	// Zero and empty values leave the backup and maintenance settings to
+	// RDS.
+	BackupRetentionPeriod int64
+	BackupWindow          string
+	MaintenanceWindow     string
+	// Serverless makes an Aurora Serverless cluster that scales between
+	// MinCapacity and MaxCapacity. The capacity settings are unused
+	// otherwise, and SecondsUntilAutoPause is only used with AutoPause.
+	Serverless            bool
+	MinCapacity           int64
+	MaxCapacity           int64
+	AutoPause             bool
+	SecondsUntilAutoPause int64
+	// ServerlessV2 sets the capacity range of the db.serverless instances
+	// of a provisioned cluster. It is left alone on an existing cluster
+	// otherwise.
+	ServerlessV2            bool
+	ServerlessV2MinCapacity float64
+	ServerlessV2MaxCapacity float64
+	// Global makes a cluster that can be part of a global database, in the
+	// engine mode GlobalEngineMode gives for Engine. With
+	// GlobalClusterId, CreateDBCluster adds it to that global cluster as a
+	// secondary cluster, which has no master credentials of its own, so
+	// MasterUsername and MasterUserPass are left empty.
+	Global          bool
+	GlobalClusterId string
 }
 
-func NewDBClusterFactory(input NewDBClusterFactoryInput) *dbClusterFactory {
-	f := &dbClusterFactory{}
+func NewDBClusterFactory(input NewDBClusterFactoryInput) *DBClusterFactory {
+	f := &DBClusterFactory{}
 
 	f.clusterIdentifier = aws.String(input.ClusterId)
 	f.engine = aws.String(input.Engine)
 	f.engineVersion = aws.String(input.EngineVersion)
 	f.masterUsername = aws.String(input.MasterUsername)
 	f.masterUserPass = aws.String(input.MasterUserPass)
+	f.updateMasterUserPass = input.UpdateMasterUserPass
 
 	f.subnetGroupName = input.SubnetGroupName
+	if input.ParameterGroupName != "" {
+		f.parameterGroupName = aws.String(input.ParameterGroupName)
+	}
+	f.storageEncrypted = input.StorageEncrypted
+	if input.KmsKeyId != "" {
+		f.kmsKeyId = aws.String(input.KmsKeyId)
+	}
+	if input.SourceSnapshot != "" {
+		f.sourceSnapshot = aws.String(input.SourceSnapshot)
+	}
+	if input.BackupRetentionPeriod != 0 {
+		f.backupRetentionPeriod = aws.Int64(input.BackupRetentionPeriod)
+	}
+	if input.BackupWindow != "" {
+		f.backupWindow = aws.String(input.BackupWindow)
+	}
+	if input.MaintenanceWindow != "" {
+		f.maintenanceWindow = aws.String(input.MaintenanceWindow)
+	}
+	if input.Global && GlobalEngineMode(input.Engine) == EngineModeGlobal {
+		f.engineMode = aws.String(EngineModeGlobal)
+	}
+	if input.GlobalClusterId != "" {
+		f.globalClusterId = aws.String(input.GlobalClusterId)
+	}
+	if input.Serverless {
+		f.engineMode = aws.String(EngineModeServerless)
+		f.scaling = &rds.ScalingConfiguration{
+			MinCapacity: aws.Int64(input.MinCapacity),
+			MaxCapacity: aws.Int64(input.MaxCapacity),
+			AutoPause:   aws.Bool(input.AutoPause),
+		}
+		if input.AutoPause {
+			f.scaling.SecondsUntilAutoPause = aws.Int64(input.SecondsUntilAutoPause)
+		}
+	}
+	if input.ServerlessV2 {
+		f.serverlessV2 = &rds.ServerlessV2ScalingConfiguration{
+			MinCapacity: aws.Float64(input.ServerlessV2MinCapacity),
+			MaxCapacity: aws.Float64(input.ServerlessV2MaxCapacity),
+		}
+	}
 
 	sIds := make([]*string, 0)
 	for _, i := range input.SecurityGroupIds {
@@ -37,89 +149,257 @@ func NewDBClusterFactory(input NewDBClusterFactoryInput) *dbClusterFactory {
 	return f
 }
 
-func (f *dbClusterFactory) UpdateOrCreateDBCluster(svc *rds.RDS) (*rds.DBCluster, error) {
-	dbCluster, err := findDBCluster(svc, f.clusterIdentifier)
+// FindDBCluster describes the cluster, returning a KindNotFound error when it
+// does not exist yet.
+func (f *DBClusterFactory) FindDBCluster(svc rdsiface.RDSAPI) (*rds.DBCluster, error) {
+	return findDBCluster(svc, f.clusterIdentifier)
+}
+
+func (f *DBClusterFactory) UpdateOrCreateDBCluster(svc rdsiface.RDSAPI) (*rds.DBCluster, error) {
+	dbCluster, err := f.FindDBCluster(svc)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBClusterNotFoundFault:
-				log.Info(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
-				return f.createDBCluster(svc)
-			default:
-				log.Warn(aerr.Error())
-				return nil, aerr
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Warn(err.Error())
-			return nil, err
+		if IsNotFound(err) {
+			return f.CreateDBCluster(svc)
 		}
+		return nil, err
 	}
 
-	dbCluster, err = f.updateDBCluster(svc, dbCluster)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBClusterNotFoundFault:
-				log.Warn(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidDBClusterStateFault:
-				log.Warn(rds.ErrCodeInvalidDBClusterStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeStorageQuotaExceededFault:
-				log.Warn(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBSubnetGroupNotFoundFault:
-				log.Warn(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidVPCNetworkStateFault:
-				log.Warn(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidDBSubnetGroupStateFault:
-				log.Warn(rds.ErrCodeInvalidDBSubnetGroupStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidSubnet:
-				log.Warn(rds.ErrCodeInvalidSubnet, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBClusterParameterGroupNotFoundFault:
-				log.Warn(rds.ErrCodeDBClusterParameterGroupNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidDBSecurityGroupStateFault:
-				log.Warn(rds.ErrCodeInvalidDBSecurityGroupStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidDBInstanceStateFault:
-				log.Warn(rds.ErrCodeInvalidDBInstanceStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBClusterAlreadyExistsFault:
-				log.Warn(rds.ErrCodeDBClusterAlreadyExistsFault, aerr.Error())
-				return nil, aerr
-			default:
-				log.Warn(aerr.Error())
-				return nil, aerr
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Warn(err)
-			return nil, err
+	changes, replace := SplitReplacements(f.Diff(dbCluster))
+	for _, c := range replace {
+		log.Warnf("cluster %s: %s can't be applied without replacing the cluster", *dbCluster.DBClusterIdentifier, c)
+	}
+	if len(changes) == 0 {
+		log.Infof("cluster %s is up to date", *dbCluster.DBClusterIdentifier)
+		return dbCluster, nil
+	}
+
+	return f.ModifyDBCluster(svc, dbCluster, changes)
+}
+
+// ModifyDBCluster applies only the given changes to an existing cluster.
+func (f *DBClusterFactory) ModifyDBCluster(
+	svc rdsiface.RDSAPI, dbCluster *rds.DBCluster, changes []FieldChange,
+) (*rds.DBCluster, error) {
+	return f.updateDBCluster(svc, f.modifyInput(dbCluster, changes))
+}
+
+// UpgradeDBCluster applies changes that include the engine upgrade to an
+// existing cluster. RDS only makes a major upgrade with
+// AllowMajorVersionUpgrade, and the cluster and its instances move to the
+// parameter groups of the upgrade in the same call.
+func (f *DBClusterFactory) UpgradeDBCluster(
+	svc rdsiface.RDSAPI, dbCluster *rds.DBCluster, changes []FieldChange, upgrade *EngineUpgrade,
+) (*rds.DBCluster, error) {
+	input := f.modifyInput(dbCluster, changes)
+	if upgrade.Major {
+		input.AllowMajorVersionUpgrade = aws.Bool(true)
+		input.DBClusterParameterGroupName = aws.String(upgrade.ClusterParameterGroup)
+		input.DBInstanceParameterGroupName = aws.String(upgrade.InstanceParameterGroup)
+	}
+
+	return f.updateDBCluster(svc, input)
+}
+
+type DBClusterFactory struct {
+	clusterIdentifier    *string
+	subnetGroupName      *string
+	parameterGroupName   *string
+	securityGroupIds     []*string
+	engine               *string
+	engineVersion        *string
+	masterUsername       *string
+	masterUserPass       *string
+	updateMasterUserPass bool
+	storageEncrypted     *bool
+	kmsKeyId             *string
+	sourceSnapshot       *string
+
+	backupRetentionPeriod *int64
+	backupWindow          *string
+	maintenanceWindow     *string
+
+	// engineMode is nil for a provisioned cluster and scaling is only set
+	// for a serverless one.
+	engineMode      *string
+	scaling         *rds.ScalingConfiguration
+	globalClusterId *string
+
+	// serverlessV2 is the capacity range of db.serverless instances.
+	serverlessV2 *rds.ServerlessV2ScalingConfiguration
+}
+
+// Diff lists the attributes of dbCluster that differ from the factory
+// settings, classified by their impact. A nil dbCluster yields every
+// attribute that would be set on create.
+func (f *DBClusterFactory) Diff(dbCluster *rds.DBCluster) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	if dbCluster == nil {
+		changes = diffString(changes, fieldDBClusterIdentifier, nil, f.clusterIdentifier)
+		changes = diffString(changes, fieldGlobalClusterIdentifier, nil, f.globalClusterId)
+		changes = diffString(changes, fieldSnapshotIdentifier, nil, f.sourceSnapshot)
+		changes = diffString(changes, fieldEngine, nil, f.engine)
+		changes = diffString(changes, fieldEngineVersion, nil, f.engineVersion)
+		changes = diffString(changes, fieldEngineMode, nil, f.engineMode)
+		changes = f.diffScaling(changes, nil)
+		changes = f.diffServerlessV2(changes, nil)
+		changes = diffString(changes, fieldMasterUsername, nil, f.masterUsername)
+		changes = diffSensitive(changes, fieldMasterUserPassword, f.masterUserPass, false)
+		changes = diffString(changes, fieldDBSubnetGroupName, nil, f.subnetGroupName)
+		changes = diffList(changes, fieldVpcSecurityGroupIds, nil, f.securityGroupIds)
+		changes = diffString(changes, fieldDBClusterParameterGroupName, nil, f.parameterGroupName)
+		changes = diffBool(changes, fieldStorageEncrypted, nil, f.storageEncrypted)
+		changes = diffString(changes, fieldKmsKeyId, nil, f.kmsKeyId)
+		changes = diffInt64(changes, fieldBackupRetentionPeriod, nil, f.backupRetentionPeriod)
+		changes = diffString(changes, fieldPreferredBackupWindow, nil, f.backupWindow)
+		changes = diffString(changes, fieldPreferredMaintenanceWindow, nil, f.maintenanceWindow)
+		return changes
+	}
+
+	sgIds := make([]*string, 0)
+	for _, sg := range dbCluster.VpcSecurityGroups {
+		sgIds = append(sgIds, sg.VpcSecurityGroupId)
+	}
+
+	changes = diffString(changes, fieldEngine, dbCluster.Engine, f.engine)
+	changes = diffString(changes, fieldEngineVersion, dbCluster.EngineVersion, f.engineVersion)
+	changes = diffString(changes, fieldEngineMode, aws.String(EngineMode(dbCluster)), f.desiredEngineMode())
+	// A cluster changing engine mode is replaced with the new capacity.
+	if EngineMode(dbCluster) == EngineModeServerless {
+		changes = f.diffScaling(changes, dbCluster.ScalingConfigurationInfo)
+	}
+	changes = f.diffServerlessV2(changes, dbCluster.ServerlessV2ScalingConfiguration)
+	changes = diffString(changes, fieldMasterUsername, dbCluster.MasterUsername, f.masterUsername)
+	changes = diffList(changes, fieldVpcSecurityGroupIds, sgIds, f.securityGroupIds)
+	changes = diffString(changes, fieldDBClusterParameterGroupName, dbCluster.DBClusterParameterGroup, f.parameterGroupName)
+	changes = diffBool(changes, fieldStorageEncrypted, dbCluster.StorageEncrypted, f.storageEncrypted)
+	changes = diffString(changes, fieldKmsKeyId, dbCluster.KmsKeyId, f.kmsKeyId)
+	changes = diffInt64(changes, fieldBackupRetentionPeriod, dbCluster.BackupRetentionPeriod, f.backupRetentionPeriod)
+	changes = diffString(changes, fieldPreferredBackupWindow, dbCluster.PreferredBackupWindow, f.backupWindow)
+	changes = diffString(
+		changes, fieldPreferredMaintenanceWindow, dbCluster.PreferredMaintenanceWindow, f.maintenanceWindow,
+	)
+	if f.updateMasterUserPass {
+		changes = diffSensitive(changes, fieldMasterUserPassword, f.masterUserPass, true)
+	}
+
+	return classify(changes)
+}
+
+// diffScaling compares the capacity settings of a serverless cluster, where
+// current is nil for a cluster that does not exist yet.
+func (f *DBClusterFactory) diffScaling(changes []FieldChange, current *rds.ScalingConfigurationInfo) []FieldChange {
+	if f.scaling == nil {
+		return changes
+	}
+	if current == nil {
+		current = &rds.ScalingConfigurationInfo{}
+	}
+
+	changes = diffInt64(changes, fieldScalingMinCapacity, current.MinCapacity, f.scaling.MinCapacity)
+	changes = diffInt64(changes, fieldScalingMaxCapacity, current.MaxCapacity, f.scaling.MaxCapacity)
+	changes = diffBool(changes, fieldScalingAutoPause, current.AutoPause, f.scaling.AutoPause)
+	changes = diffInt64(changes, fieldScalingAutoPauseSeconds, current.SecondsUntilAutoPause, f.scaling.SecondsUntilAutoPause)
+
+	return changes
+}
+
+// diffServerlessV2 compares the Serverless v2 capacity range, where current is
+// nil for a cluster that does not exist yet or never had one.
+func (f *DBClusterFactory) diffServerlessV2(
+	changes []FieldChange, current *rds.ServerlessV2ScalingConfigurationInfo,
+) []FieldChange {
+	if f.serverlessV2 == nil {
+		return changes
+	}
+	if current == nil {
+		current = &rds.ServerlessV2ScalingConfigurationInfo{}
+	}
+
+	changes = diffFloat64(changes, fieldServerlessV2MinCapacity, current.MinCapacity, f.serverlessV2.MinCapacity)
+	changes = diffFloat64(changes, fieldServerlessV2MaxCapacity, current.MaxCapacity, f.serverlessV2.MaxCapacity)
+
+	return changes
+}
+
+// desiredEngineMode is the engine mode an existing cluster should have.
+func (f *DBClusterFactory) desiredEngineMode() *string {
+	if f.engineMode == nil {
+		return aws.String(EngineModeProvisioned)
+	}
+	return f.engineMode
+}
+
+// EngineMode returns the engine mode of dbCluster.
+func EngineMode(dbCluster *rds.DBCluster) string {
+	if aws.StringValue(dbCluster.EngineMode) == "" {
+		return EngineModeProvisioned
+	}
+	return *dbCluster.EngineMode
+}
+
+// PostRestoreChanges lists the differences in the backup and maintenance
+// settings of dbCluster, which the restore calls can't set, and in its
+// capacity, which a point in time restore keeps from its source. They are
+// meant for ModifyDBCluster once a restored cluster is available.
+func (f *DBClusterFactory) PostRestoreChanges(dbCluster *rds.DBCluster) []FieldChange {
+	changes := make([]FieldChange, 0)
+	for _, c := range f.Diff(dbCluster) {
+		switch {
+		case c.Field == fieldBackupRetentionPeriod,
+			c.Field == fieldPreferredBackupWindow,
+			c.Field == fieldPreferredMaintenanceWindow,
+			strings.HasPrefix(c.Field, fieldScalingPrefix):
+			changes = append(changes, c)
+		}
+	}
+
+	return changes
+}
+
+// CheckSnapshotReplacement returns an error for replacement changes that a
+// cluster restored from a snapshot of the current one can't have: the
+// snapshot fixes the master username, and encrypted data stays encrypted.
+func CheckSnapshotReplacement(changes []FieldChange) error {
+	for _, c := range changes {
+		if c.Field == fieldMasterUsername || (c.Field == fieldStorageEncrypted && c.To == "false") {
+			return fmt.Errorf("%s can't be changed by restoring a snapshot", c)
 		}
 	}
 
-	return dbCluster, nil
+	return nil
 }
 
-type dbClusterFactory struct {
-	clusterIdentifier *string
-	subnetGroupName   *string
-	securityGroupIds  []*string
-	engine            *string
-	engineVersion     *string
-	masterUsername    *string
-	masterUserPass    *string
+// CheckSourceSnapshot returns an error when the factory cluster can't be
+// restored from snapshot as it is configured. A restored cluster keeps the
+// master username of the snapshot, and encrypted data stays encrypted.
+func (f *DBClusterFactory) CheckSourceSnapshot(snapshot *rds.DBClusterSnapshot) error {
+	id := aws.StringValue(snapshot.DBClusterSnapshotIdentifier)
+
+	if status := aws.StringValue(snapshot.Status); status != statusAvailable {
+		return fmt.Errorf("snapshot %s is %s, not %s", id, status, statusAvailable)
+	}
+	if username := aws.StringValue(snapshot.MasterUsername); username != *f.masterUsername {
+		return fmt.Errorf("snapshot %s has master username %q, which the cluster can't change", id, username)
+	}
+	if aws.BoolValue(snapshot.StorageEncrypted) && f.storageEncrypted != nil && !*f.storageEncrypted {
+		return fmt.Errorf("snapshot %s is encrypted, so the cluster has to be encrypted too", id)
+	}
+
+	return nil
 }
 
-func (f *dbClusterFactory) createDBCluster(svc *rds.RDS) (*rds.DBCluster, error) {
+// CreateDBCluster creates an empty cluster, or restores the source snapshot
+// when there is one.
+func (f *DBClusterFactory) CreateDBCluster(svc rdsiface.RDSAPI) (*rds.DBCluster, error) {
+	if f.sourceSnapshot != nil {
+		snapshot, err := findDBClusterSnapshot(svc, f.sourceSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		return f.RestoreDBClusterFromSnapshot(svc, snapshot)
+	}
+
 	clusterInput := &rds.CreateDBClusterInput{
 		DBClusterIdentifier: f.clusterIdentifier,
 		Engine:              f.engine,
@@ -128,36 +408,202 @@ func (f *dbClusterFactory) createDBCluster(svc *rds.RDS) (*rds.DBCluster, error)
 		MasterUserPassword:  f.masterUserPass,
 		DBSubnetGroupName:   f.subnetGroupName,
 		VpcSecurityGroupIds: f.securityGroupIds,
+
+		DBClusterParameterGroupName: f.parameterGroupName,
+		StorageEncrypted:            f.storageEncrypted,
+		KmsKeyId:                    f.kmsKeyId,
+		BackupRetentionPeriod:       f.backupRetentionPeriod,
+		PreferredBackupWindow:       f.backupWindow,
+		PreferredMaintenanceWindow:  f.maintenanceWindow,
+		EngineMode:                  f.engineMode,
+		ScalingConfiguration:        f.scaling,
+		GlobalClusterIdentifier:     f.globalClusterId,
+
+		ServerlessV2ScalingConfiguration: f.serverlessV2,
+	}
+	if f.globalClusterId != nil {
+		clusterInput.MasterUsername, clusterInput.MasterUserPassword = nil, nil
 	}
 
 	clusterOutput, err := svc.CreateDBCluster(clusterInput)
 	if err != nil {
-		log.Warn(err)
-		return nil, err
+		return nil, newError(*f.clusterIdentifier, err)
 	}
 
 	return clusterOutput.DBCluster, nil
 }
 
-func (f *dbClusterFactory) updateDBCluster(svc *rds.RDS, dbCluster *rds.DBCluster) (*rds.DBCluster, error) {
+// ResetMasterUserPassword sets the master password of an existing cluster to
+// the factory password, whether or not UpdateMasterUserPass was set.
+func (f *DBClusterFactory) ResetMasterUserPassword(svc rdsiface.RDSAPI, dbCluster *rds.DBCluster) (*rds.DBCluster, error) {
+	changes := diffSensitive(make([]FieldChange, 0), fieldMasterUserPassword, f.masterUserPass, true)
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%s: no master password to set", *dbCluster.DBClusterIdentifier)
+	}
+
+	return f.updateDBCluster(svc, f.modifyInput(dbCluster, changes))
+}
+
+// RestoreDBClusterFromSnapshot creates the factory cluster from snapshot,
+// with the factory engine, engine mode, network, parameter group and
+// encryption settings. An unencrypted snapshot is encrypted with the default
+// key when no key is set.
+func (f *DBClusterFactory) RestoreDBClusterFromSnapshot(
+	svc rdsiface.RDSAPI, snapshot *rds.DBClusterSnapshot,
+) (*rds.DBCluster, error) {
+	input := &rds.RestoreDBClusterFromSnapshotInput{
+		DBClusterIdentifier: f.clusterIdentifier,
+		SnapshotIdentifier:  snapshot.DBClusterSnapshotArn,
+		Engine:              f.engine,
+		EngineVersion:       f.engineVersion,
+		DBSubnetGroupName:   f.subnetGroupName,
+		VpcSecurityGroupIds: f.securityGroupIds,
+		KmsKeyId:            f.kmsKeyId,
+		EngineMode:          f.engineMode,
+
+		DBClusterParameterGroupName: f.parameterGroupName,
+		ScalingConfiguration:        f.scaling,
+
+		ServerlessV2ScalingConfiguration: f.serverlessV2,
+	}
+	if aws.BoolValue(f.storageEncrypted) && !aws.BoolValue(snapshot.StorageEncrypted) && f.kmsKeyId == nil {
+		input.KmsKeyId = aws.String(defaultKmsKeyAlias)
+	}
+
+	output, err := svc.RestoreDBClusterFromSnapshot(input)
+	if err != nil {
+		return nil, newError(*f.clusterIdentifier, err)
+	}
+
+	return output.DBCluster, nil
+}
+
+// Restore types for RestoreDBClusterToPointInTime. A copy-on-write clone
+// shares storage with its source until either of them changes a page.
+const (
+	RestoreTypeCopyOnWrite = "copy-on-write"
+	RestoreTypeFullCopy    = "full-copy"
+)
+
+// RestoreDBClusterToPointInTime creates the factory cluster from the state
+// of sourceIdentifier at restoreTime, or at the latest restorable time when
+// restoreTime is nil. The clone keeps the engine, engine mode, master
+// username and password of the source.
+func (f *DBClusterFactory) RestoreDBClusterToPointInTime(
+	svc rdsiface.RDSAPI, sourceIdentifier string, restoreTime *time.Time, restoreType string,
+) (*rds.DBCluster, error) {
+	input := &rds.RestoreDBClusterToPointInTimeInput{
+		DBClusterIdentifier:       f.clusterIdentifier,
+		SourceDBClusterIdentifier: aws.String(sourceIdentifier),
+		RestoreType:               aws.String(restoreType),
+		DBSubnetGroupName:         f.subnetGroupName,
+		VpcSecurityGroupIds:       f.securityGroupIds,
+		KmsKeyId:                  f.kmsKeyId,
+
+		DBClusterParameterGroupName:      f.parameterGroupName,
+		ServerlessV2ScalingConfiguration: f.serverlessV2,
+	}
+	if restoreTime == nil {
+		input.UseLatestRestorableTime = aws.Bool(true)
+	} else {
+		input.RestoreToTime = restoreTime
+	}
+
+	output, err := svc.RestoreDBClusterToPointInTime(input)
+	if err != nil {
+		return nil, newError(*f.clusterIdentifier, err)
+	}
+
+	return output.DBCluster, nil
+}
+
+// RenameDBCluster changes the identifier of a cluster, and with it the
+// endpoints. The cluster is described under the new identifier once RDS
+// has caught up; WaitForClusterRenamed waits for that.
+func RenameDBCluster(svc rdsiface.RDSAPI, clusterIdentifier, newIdentifier string) (*rds.DBCluster, error) {
+	output, err := svc.ModifyDBCluster(&rds.ModifyDBClusterInput{
+		ApplyImmediately:       aws.Bool(true),
+		DBClusterIdentifier:    aws.String(clusterIdentifier),
+		NewDBClusterIdentifier: aws.String(newIdentifier),
+	})
+	if err != nil {
+		return nil, newError(clusterIdentifier, err)
+	}
+
+	return output.DBCluster, nil
+}
+
+// FailoverDBCluster makes a reader of the cluster its writer. RDS picks the
+// reader by promotion tier when targetInstanceIdentifier is empty.
+func FailoverDBCluster(svc rdsiface.RDSAPI, clusterIdentifier, targetInstanceIdentifier string) (*rds.DBCluster, error) {
+	input := &rds.FailoverDBClusterInput{DBClusterIdentifier: aws.String(clusterIdentifier)}
+	if targetInstanceIdentifier != "" {
+		input.TargetDBInstanceIdentifier = aws.String(targetInstanceIdentifier)
+	}
+
+	output, err := svc.FailoverDBCluster(input)
+	if err != nil {
+		return nil, newError(clusterIdentifier, err)
+	}
+
+	return output.DBCluster, nil
+}
+
+// ClusterWriter returns the identifier of the writer of dbCluster, or "" when
+// no member is the writer, for example during a failover.
+func ClusterWriter(dbCluster *rds.DBCluster) string {
+	for _, m := range dbCluster.DBClusterMembers {
+		if aws.BoolValue(m.IsClusterWriter) {
+			return aws.StringValue(m.DBInstanceIdentifier)
+		}
+	}
+
+	return ""
+}
+
+// modifyInput builds the ModifyDBCluster call that applies changes to
+// dbCluster.
+func (f *DBClusterFactory) modifyInput(dbCluster *rds.DBCluster, changes []FieldChange) *rds.ModifyDBClusterInput {
 	input := &rds.ModifyDBClusterInput{
 		ApplyImmediately:    aws.Bool(true),
 		DBClusterIdentifier: dbCluster.DBClusterIdentifier,
-		//MasterUserPassword:  f.masterUserPass,
-		VpcSecurityGroupIds: f.securityGroupIds,
 	}
 
-	if *dbCluster.EngineVersion != *f.engineVersion {
+	if hasChange(changes, fieldEngineVersion) {
 		input.EngineVersion = f.engineVersion
 	}
-
-	if f.masterUserPass != nil && *f.masterUserPass != "" {
+	if hasChange(changes, fieldVpcSecurityGroupIds) {
+		input.VpcSecurityGroupIds = f.securityGroupIds
+	}
+	if hasChange(changes, fieldMasterUserPassword) {
 		input.MasterUserPassword = f.masterUserPass
 	}
+	if hasChange(changes, fieldDBClusterParameterGroupName) {
+		input.DBClusterParameterGroupName = f.parameterGroupName
+	}
+	if hasChange(changes, fieldBackupRetentionPeriod) {
+		input.BackupRetentionPeriod = f.backupRetentionPeriod
+	}
+	if hasChange(changes, fieldPreferredBackupWindow) {
+		input.PreferredBackupWindow = f.backupWindow
+	}
+	if hasChange(changes, fieldPreferredMaintenanceWindow) {
+		input.PreferredMaintenanceWindow = f.maintenanceWindow
+	}
+	if hasChangePrefix(changes, fieldScalingPrefix) {
+		input.ScalingConfiguration = f.scaling
+	}
+	if hasChangePrefix(changes, fieldServerlessV2Prefix) {
+		input.ServerlessV2ScalingConfiguration = f.serverlessV2
+	}
+
+	return input
+}
 
+func (f *DBClusterFactory) updateDBCluster(svc rdsiface.RDSAPI, input *rds.ModifyDBClusterInput) (*rds.DBCluster, error) {
 	result, err := svc.ModifyDBCluster(input)
 	if err != nil {
-		return nil, err
+		return nil, newError(*input.DBClusterIdentifier, err)
 	}
 
 	return result.DBCluster, nil
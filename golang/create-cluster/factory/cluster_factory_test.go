@@ -0,0 +1,386 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+func TestDBClusterFactoryDiff(t *testing.T) {
+	input := NewDBClusterFactoryInput{
+		ClusterId:        "experiments",
+		Engine:           "aurora-mysql",
+		EngineVersion:    "5.7.12",
+		MasterUsername:   "admin",
+		MasterUserPass:   "secret123",
+		SecurityGroupIds: []string{"sg-00000000000000001", "sg-00000000000000002"},
+		SubnetGroupName:  aws.String("experiments"),
+	}
+
+	tests := []struct {
+		name    string
+		cluster *rds.DBCluster
+		want    []string
+	}{
+		{
+			name: "create",
+			want: []string{
+				fieldDBClusterIdentifier, fieldEngine, fieldEngineVersion, fieldMasterUsername,
+				fieldMasterUserPassword, fieldDBSubnetGroupName, fieldVpcSecurityGroupIds,
+			},
+		},
+		{
+			name: "unchanged",
+			cluster: &rds.DBCluster{
+				Engine:           aws.String("aurora-mysql"),
+				EngineVersion:    aws.String("5.7.12"),
+				MasterUsername:   aws.String("admin"),
+				StorageEncrypted: aws.Bool(false),
+				VpcSecurityGroups: []*rds.VpcSecurityGroupMembership{
+					{VpcSecurityGroupId: aws.String("sg-00000000000000002")},
+					{VpcSecurityGroupId: aws.String("sg-00000000000000001")},
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "changed",
+			cluster: &rds.DBCluster{
+				Engine:           aws.String("aurora-mysql"),
+				EngineVersion:    aws.String("5.6.10a"),
+				MasterUsername:   aws.String("admin"),
+				StorageEncrypted: aws.Bool(false),
+				VpcSecurityGroups: []*rds.VpcSecurityGroupMembership{
+					{VpcSecurityGroupId: aws.String("sg-00000000000000001")},
+				},
+			},
+			want: []string{fieldEngineVersion, fieldVpcSecurityGroupIds},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range NewDBClusterFactory(input).Diff(tt.cluster) {
+				got = append(got, c.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBClusterFactoryImpact(t *testing.T) {
+	keyArn := "arn:aws:kms:us-east-1:123456789012:key/00000000-0000-4000-8000-000000000002"
+	base := NewDBClusterFactoryInput{
+		Engine:         "aurora-mysql",
+		EngineVersion:  "5.7.12",
+		MasterUsername: "admin",
+	}
+	cluster := func(change func(*rds.DBCluster)) *rds.DBCluster {
+		c := &rds.DBCluster{
+			Engine:           aws.String("aurora-mysql"),
+			EngineVersion:    aws.String("5.7.12"),
+			MasterUsername:   aws.String("admin"),
+			StorageEncrypted: aws.Bool(false),
+		}
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		change  func(*NewDBClusterFactoryInput)
+		cluster *rds.DBCluster
+		want    map[string]Impact
+	}{
+		{
+			name:    "upgrade",
+			change:  func(i *NewDBClusterFactoryInput) { i.EngineVersion = "5.7.mysql_aurora.2.04.0" },
+			cluster: cluster(func(*rds.DBCluster) {}),
+			want:    map[string]Impact{fieldEngineVersion: ImpactDowntime},
+		},
+		{
+			name:    "default key",
+			change:  func(i *NewDBClusterFactoryInput) { i.StorageEncrypted = aws.Bool(true) },
+			cluster: cluster(func(c *rds.DBCluster) { c.StorageEncrypted, c.KmsKeyId = aws.Bool(true), aws.String(keyArn) }),
+			want:    map[string]Impact{},
+		},
+		{
+			name:    "encryption unset",
+			change:  func(*NewDBClusterFactoryInput) {},
+			cluster: cluster(func(c *rds.DBCluster) { c.StorageEncrypted, c.KmsKeyId = aws.Bool(true), aws.String(keyArn) }),
+			want:    map[string]Impact{},
+		},
+		{
+			name: "encryption added",
+			change: func(i *NewDBClusterFactoryInput) {
+				i.StorageEncrypted = aws.Bool(true)
+				i.KmsKeyId = keyArn
+			},
+			cluster: cluster(func(*rds.DBCluster) {}),
+			want:    map[string]Impact{fieldStorageEncrypted: ImpactReplace, fieldKmsKeyId: ImpactReplace},
+		},
+		{
+			name: "engine and username",
+			change: func(i *NewDBClusterFactoryInput) {
+				i.Engine = "aurora-postgresql"
+				i.MasterUsername = "postgres"
+			},
+			cluster: cluster(func(*rds.DBCluster) {}),
+			want:    map[string]Impact{fieldEngine: ImpactReplace, fieldMasterUsername: ImpactReplace},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := base
+			tt.change(&input)
+			got := map[string]Impact{}
+			for _, c := range NewDBClusterFactory(input).Diff(tt.cluster) {
+				got[c.Field] = c.Impact
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("impacts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSnapshotReplacement(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  FieldChange
+		wantErr bool
+	}{
+		{name: "encrypt", change: FieldChange{Field: fieldStorageEncrypted, From: "false", To: "true"}},
+		{name: "other key", change: FieldChange{Field: fieldKmsKeyId, From: "a", To: "b"}},
+		{name: "decrypt", change: FieldChange{Field: fieldStorageEncrypted, From: "true", To: "false"}, wantErr: true},
+		{name: "username", change: FieldChange{Field: fieldMasterUsername, From: "admin", To: "root"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSnapshotReplacement([]FieldChange{tt.change})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSourceSnapshot(t *testing.T) {
+	input := NewDBClusterFactoryInput{MasterUsername: "admin", SourceSnapshot: "experiments-1"}
+
+	tests := []struct {
+		name      string
+		encrypted *bool
+		snapshot  *rds.DBClusterSnapshot
+		err       string
+	}{
+		{
+			name:     "usable",
+			snapshot: &rds.DBClusterSnapshot{Status: aws.String(statusAvailable), MasterUsername: aws.String("admin")},
+		},
+		{
+			name:     "creating",
+			snapshot: &rds.DBClusterSnapshot{Status: aws.String("creating"), MasterUsername: aws.String("admin")},
+			err:      "snapshot experiments-1 is creating, not available",
+		},
+		{
+			name:     "username",
+			snapshot: &rds.DBClusterSnapshot{Status: aws.String(statusAvailable), MasterUsername: aws.String("root")},
+			err:      `snapshot experiments-1 has master username "root", which the cluster can't change`,
+		},
+		{
+			name:      "encrypted",
+			encrypted: aws.Bool(false),
+			snapshot: &rds.DBClusterSnapshot{
+				Status:           aws.String(statusAvailable),
+				MasterUsername:   aws.String("admin"),
+				StorageEncrypted: aws.Bool(true),
+			},
+			err: "snapshot experiments-1 is encrypted, so the cluster has to be encrypted too",
+		},
+		{
+			name: "encrypted, encryption unset",
+			snapshot: &rds.DBClusterSnapshot{
+				Status:           aws.String(statusAvailable),
+				MasterUsername:   aws.String("admin"),
+				StorageEncrypted: aws.Bool(true),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.snapshot.DBClusterSnapshotIdentifier = aws.String("experiments-1")
+			input := input
+			input.StorageEncrypted = tt.encrypted
+			err := NewDBClusterFactory(input).CheckSourceSnapshot(tt.snapshot)
+			if tt.err == "" {
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("err = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCreateEncryptedDBCluster(t *testing.T) {
+	svc := fakerds.New()
+	input := NewDBClusterFactoryInput{
+		ClusterId:        "experiments",
+		Engine:           "aurora-mysql",
+		EngineVersion:    "5.7.12",
+		MasterUsername:   "admin",
+		MasterUserPass:   "secret123",
+		StorageEncrypted: aws.Bool(true),
+	}
+	cluster, err := NewDBClusterFactory(input).CreateDBCluster(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(cluster.StorageEncrypted) || aws.StringValue(cluster.KmsKeyId) == "" {
+		t.Errorf("cluster = encrypted %v with key %q, want the default key", cluster.StorageEncrypted, aws.StringValue(cluster.KmsKeyId))
+	}
+	// The default key RDS picked is not a change when no key was asked for.
+	if changes := NewDBClusterFactory(input).Diff(cluster); len(changes) != 0 {
+		t.Errorf("changes = %v, want none", changes)
+	}
+
+	input.ClusterId = "experiments-plain"
+	input.StorageEncrypted = aws.Bool(false)
+	input.KmsKeyId = "arn:aws:kms:us-east-1:123456789012:key/00000000-0000-4000-8000-000000000001"
+	if _, err := NewDBClusterFactory(input).CreateDBCluster(svc); err == nil {
+		t.Error("no error creating an unencrypted cluster with a KMS key")
+	}
+}
+
+func TestUpdateOrCreateDBCluster(t *testing.T) {
+	base := NewDBClusterFactoryInput{
+		ClusterId:        "experiments",
+		Engine:           "aurora-mysql",
+		EngineVersion:    "5.7.12",
+		MasterUsername:   "admin",
+		MasterUserPass:   "secret123",
+		SecurityGroupIds: []string{"sg-00000000000000001"},
+	}
+
+	tests := []struct {
+		name     string
+		existing bool
+		change   func(*NewDBClusterFactoryInput)
+		changes  []string
+		// left is what Diff still reports after applying.
+		left []string
+	}{
+		{
+			name:   "create",
+			change: func(*NewDBClusterFactoryInput) {},
+			changes: []string{
+				fieldDBClusterIdentifier, fieldEngine, fieldEngineVersion, fieldMasterUsername,
+				fieldMasterUserPassword, fieldVpcSecurityGroupIds,
+			},
+		},
+		{
+			name:     "no-op",
+			existing: true,
+			change:   func(*NewDBClusterFactoryInput) {},
+		},
+		{
+			name:     "modify",
+			existing: true,
+			change: func(i *NewDBClusterFactoryInput) {
+				i.EngineVersion = "5.7.mysql_aurora.2.04.0"
+				i.SecurityGroupIds = []string{"sg-00000000000000001", "sg-00000000000000002"}
+			},
+			changes: []string{fieldEngineVersion, fieldVpcSecurityGroupIds},
+		},
+		{
+			name:     "update password",
+			existing: true,
+			change:   func(i *NewDBClusterFactoryInput) { i.UpdateMasterUserPass = true },
+			changes:  []string{fieldMasterUserPassword},
+			// The password can't be read back, so it is never up to date.
+			left: []string{fieldMasterUserPassword},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			if tt.existing {
+				if _, err := NewDBClusterFactory(base).CreateDBCluster(svc); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			input := base
+			tt.change(&input)
+			f := NewDBClusterFactory(input)
+
+			current, err := f.FindDBCluster(svc)
+			if err != nil && !IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if got := changedFields(f.Diff(current)); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+
+			if _, err := f.UpdateOrCreateDBCluster(svc); err != nil {
+				t.Fatal(err)
+			}
+
+			current, err = f.FindDBCluster(svc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := changedFields(f.Diff(current)); !reflect.DeepEqual(got, tt.left) {
+				t.Errorf("changes left after applying = %v", got)
+			}
+		})
+	}
+}
+
+func TestResetMasterUserPassword(t *testing.T) {
+	svc := fakerds.New()
+	input := NewDBClusterFactoryInput{
+		ClusterId:      "experiments",
+		Engine:         "aurora-mysql",
+		EngineVersion:  "5.7.12",
+		MasterUsername: "admin",
+		MasterUserPass: "secret123",
+	}
+	cluster, err := NewDBClusterFactory(input).CreateDBCluster(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input.MasterUserPass = ""
+	if _, err := NewDBClusterFactory(input).ResetMasterUserPassword(svc, cluster); err == nil {
+		t.Error("no error resetting to an empty password")
+	}
+
+	input.MasterUserPass = "secret456"
+	f := NewDBClusterFactory(input)
+	// The fake only takes a modification once the cluster is available.
+	if cluster, err = f.FindDBCluster(svc); err != nil {
+		t.Fatal(err)
+	}
+	cluster, err = f.ResetMasterUserPassword(svc, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(cluster.Status); got != fakerds.StatusResettingMasterCredentials {
+		t.Errorf("status = %s, want %s", got, fakerds.StatusResettingMasterCredentials)
+	}
+}
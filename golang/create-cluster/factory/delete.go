@@ -0,0 +1,105 @@
+package factory
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// DeleteDBInstance starts deleting a cluster member. A missing instance is
+// not an error.
+func DeleteDBInstance(svc rdsiface.RDSAPI, instanceIdentifier string) error {
+	input := &rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(instanceIdentifier),
+	}
+
+	_, err := svc.DeleteDBInstance(input)
+	if err != nil {
+		err = newError(instanceIdentifier, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteDBCluster starts deleting a cluster that no longer has members. When
+// finalSnapshotIdentifier is empty no final snapshot is taken. A missing
+// cluster is not an error.
+func DeleteDBCluster(svc rdsiface.RDSAPI, clusterIdentifier, finalSnapshotIdentifier string) error {
+	input := &rds.DeleteDBClusterInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+	}
+
+	if finalSnapshotIdentifier != "" {
+		input.FinalDBSnapshotIdentifier = aws.String(finalSnapshotIdentifier)
+		input.SkipFinalSnapshot = aws.Bool(false)
+	} else {
+		input.SkipFinalSnapshot = aws.Bool(true)
+	}
+
+	_, err := svc.DeleteDBCluster(input)
+	if err != nil {
+		err = newError(clusterIdentifier, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteDBSubnetGroup deletes a subnet group that is no longer used by any
+// cluster. A missing group is not an error.
+func DeleteDBSubnetGroup(svc rdsiface.RDSAPI, groupName string) error {
+	input := &rds.DeleteDBSubnetGroupInput{
+		DBSubnetGroupName: aws.String(groupName),
+	}
+
+	_, err := svc.DeleteDBSubnetGroup(input)
+	if err != nil {
+		err = newError(groupName, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteDBClusterParameterGroup deletes a custom cluster parameter group that
+// no cluster uses any more. A missing group is not an error.
+func DeleteDBClusterParameterGroup(svc rdsiface.RDSAPI, groupName string) error {
+	input := &rds.DeleteDBClusterParameterGroupInput{
+		DBClusterParameterGroupName: aws.String(groupName),
+	}
+
+	_, err := svc.DeleteDBClusterParameterGroup(input)
+	if err != nil {
+		err = newError(groupName, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteDBParameterGroup deletes a custom DB parameter group that no instance
+// uses any more. A missing group is not an error.
+func DeleteDBParameterGroup(svc rdsiface.RDSAPI, groupName string) error {
+	input := &rds.DeleteDBParameterGroupInput{
+		DBParameterGroupName: aws.String(groupName),
+	}
+
+	_, err := svc.DeleteDBParameterGroup(input)
+	if err != nil {
+		err = newError(groupName, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package factory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const sensitiveValue = "(sensitive)"
+
+const (
+	fieldAvailabilityZone            = "AvailabilityZone"
+	fieldBackupRetentionPeriod       = "BackupRetentionPeriod"
+	fieldDBClusterIdentifier         = "DBClusterIdentifier"
+	fieldDBClusterParameterGroupName = "DBClusterParameterGroupName"
+	fieldDBInstanceClass             = "DBInstanceClass"
+	fieldDBInstanceIdentifier        = "DBInstanceIdentifier"
+	fieldDBParameterGroupFamily      = "DBParameterGroupFamily"
+	fieldDBParameterGroupName        = "DBParameterGroupName"
+	fieldDBSubnetGroupDescription    = "DBSubnetGroupDescription"
+	fieldDBSubnetGroupName           = "DBSubnetGroupName"
+	fieldDescription                 = "Description"
+	fieldEngine                      = "Engine"
+	fieldEngineMode                  = "EngineMode"
+	fieldEngineVersion               = "EngineVersion"
+	fieldGlobalClusterIdentifier     = "GlobalClusterIdentifier"
+	fieldKmsKeyId                    = "KmsKeyId"
+	fieldMasterUsername              = "MasterUsername"
+	fieldMasterUserPassword          = "MasterUserPassword"
+	fieldParametersPrefix            = "Parameters."
+	fieldPreferredBackupWindow       = "PreferredBackupWindow"
+	fieldPreferredMaintenanceWindow  = "PreferredMaintenanceWindow"
+	fieldPromotionTier               = "PromotionTier"
+	fieldScalingPrefix               = "ScalingConfiguration."
+	fieldScalingAutoPause            = fieldScalingPrefix + "AutoPause"
+	fieldScalingMaxCapacity          = fieldScalingPrefix + "MaxCapacity"
+	fieldScalingMinCapacity          = fieldScalingPrefix + "MinCapacity"
+	fieldScalingAutoPauseSeconds     = fieldScalingPrefix + "SecondsUntilAutoPause"
+	fieldServerlessV2Prefix          = "ServerlessV2ScalingConfiguration."
+	fieldServerlessV2MaxCapacity     = fieldServerlessV2Prefix + "MaxCapacity"
+	fieldServerlessV2MinCapacity     = fieldServerlessV2Prefix + "MinCapacity"
+	fieldSnapshotIdentifier          = "SnapshotIdentifier"
+	fieldSourceDBClusterIdentifier   = "SourceDBClusterIdentifier"
+	fieldStorageEncrypted            = "StorageEncrypted"
+	fieldSubnetIds                   = "SubnetIds"
+	fieldVpcSecurityGroupIds         = "VpcSecurityGroupIds"
+)
+
+// Impact says what applying a change to an existing resource involves.
+type Impact string
+
+const (
+	ImpactInPlace  Impact = "in-place"
+	ImpactDowntime Impact = "downtime"
+	ImpactReplace  Impact = "replacement"
+)
+
+// fieldImpacts lists the fields whose changes are more than a plain in-place
+// update. Instance class changes and engine upgrades restart the instances;
+// the rest can only be set when a cluster is created.
+var fieldImpacts = map[string]Impact{
+	fieldDBInstanceClass:  ImpactDowntime,
+	fieldEngineVersion:    ImpactDowntime,
+	fieldEngine:           ImpactReplace,
+	fieldEngineMode:       ImpactReplace,
+	fieldMasterUsername:   ImpactReplace,
+	fieldStorageEncrypted: ImpactReplace,
+	fieldKmsKeyId:         ImpactReplace,
+}
+
+// FieldChange is one attribute that differs between a live resource and the
+// desired state. Field uses the RDS API attribute name. For a resource that
+// does not exist yet From and Impact are empty. List fields of an existing
+// resource also name the entries that are added and removed.
+type FieldChange struct {
+	Field     string   `json:"field"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Added     []string `json:"added,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+	Sensitive bool     `json:"sensitive,omitempty"`
+	Impact    Impact   `json:"impact,omitempty"`
+}
+
+func (c FieldChange) String() string {
+	var s string
+	switch {
+	case c.Sensitive:
+		s = fmt.Sprintf("%s: %s", c.Field, sensitiveValue)
+	case c.From == "":
+		s = fmt.Sprintf("%s: %q", c.Field, c.To)
+	default:
+		s = fmt.Sprintf("%s: %q => %q", c.Field, c.From, c.To)
+	}
+
+	if len(c.Added) > 0 || len(c.Removed) > 0 {
+		edits := make([]string, 0)
+		for _, v := range c.Added {
+			edits = append(edits, "+"+v)
+		}
+		for _, v := range c.Removed {
+			edits = append(edits, "-"+v)
+		}
+		s += fmt.Sprintf(" (%s)", strings.Join(edits, " "))
+	}
+
+	switch c.Impact {
+	case ImpactDowntime:
+		s += " (downtime)"
+	case ImpactReplace:
+		s += " (forces replacement)"
+	}
+	return s
+}
+
+// classify sets the impact of changes to an existing resource.
+func classify(changes []FieldChange) []FieldChange {
+	for n, c := range changes {
+		impact, ok := fieldImpacts[c.Field]
+		if !ok {
+			impact = ImpactInPlace
+		}
+		changes[n].Impact = impact
+	}
+
+	return changes
+}
+
+// SplitReplacements separates the changes that can be applied to an existing
+// resource from the ones that force it to be replaced.
+func SplitReplacements(changes []FieldChange) (inPlace, replace []FieldChange) {
+	inPlace, replace = make([]FieldChange, 0), make([]FieldChange, 0)
+	for _, c := range changes {
+		if c.Impact == ImpactReplace {
+			replace = append(replace, c)
+		} else {
+			inPlace = append(inPlace, c)
+		}
+	}
+
+	return inPlace, replace
+}
+
+// ChangesInstanceClass reports whether changes move an instance to another
+// class.
+func ChangesInstanceClass(changes []FieldChange) bool {
+	return hasChange(changes, fieldDBInstanceClass)
+}
+
+// SetsMasterUserPassword reports whether changes send the master password.
+func SetsMasterUserPassword(changes []FieldChange) bool {
+	return hasChange(changes, fieldMasterUserPassword)
+}
+
+func hasChange(changes []FieldChange, field string) bool {
+	for _, c := range changes {
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func hasChangePrefix(changes []FieldChange, prefix string) bool {
+	for _, c := range changes {
+		if strings.HasPrefix(c.Field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffString appends a change when the desired value is set and differs from
+// the current one.
+func diffString(changes []FieldChange, field string, from, to *string) []FieldChange {
+	if to == nil || *to == "" || aws.StringValue(from) == *to {
+		return changes
+	}
+
+	return append(changes, FieldChange{Field: field, From: aws.StringValue(from), To: *to})
+}
+
+func diffInt64(changes []FieldChange, field string, from, to *int64) []FieldChange {
+	if to == nil || (from != nil && *from == *to) {
+		return changes
+	}
+
+	c := FieldChange{Field: field, To: fmt.Sprint(*to)}
+	if from != nil {
+		c.From = fmt.Sprint(*from)
+	}
+	return append(changes, c)
+}
+
+func diffFloat64(changes []FieldChange, field string, from, to *float64) []FieldChange {
+	if to == nil || (from != nil && *from == *to) {
+		return changes
+	}
+
+	c := FieldChange{Field: field, To: fmt.Sprint(*to)}
+	if from != nil {
+		c.From = fmt.Sprint(*from)
+	}
+	return append(changes, c)
+}
+
+func diffBool(changes []FieldChange, field string, from, to *bool) []FieldChange {
+	if to == nil || (from != nil && *from == *to) {
+		return changes
+	}
+
+	c := FieldChange{Field: field, To: fmt.Sprint(*to)}
+	if from != nil {
+		c.From = fmt.Sprint(*from)
+	}
+	return append(changes, c)
+}
+
+// diffList compares two lists ignoring order.
+func diffList(changes []FieldChange, field string, from, to []*string) []FieldChange {
+	if len(to) == 0 {
+		return changes
+	}
+
+	f, t := joinSorted(from), joinSorted(to)
+	if f == t {
+		return changes
+	}
+
+	c := FieldChange{Field: field, From: f, To: t}
+	if len(from) > 0 {
+		c.Added = missing(to, from)
+		c.Removed = missing(from, to)
+	}
+	return append(changes, c)
+}
+
+// missing returns the sorted values of a that are not in b.
+func missing(a, b []*string) []string {
+	in := map[string]bool{}
+	for _, v := range b {
+		in[aws.StringValue(v)] = true
+	}
+
+	values := make([]string, 0)
+	for _, v := range a {
+		if !in[aws.StringValue(v)] {
+			values = append(values, aws.StringValue(v))
+		}
+	}
+	sort.Strings(values)
+
+	return values
+}
+
+// diffSensitive always reports a change for a set value since secrets can't
+// be read back from RDS to compare.
+func diffSensitive(changes []FieldChange, field string, to *string, exists bool) []FieldChange {
+	if to == nil || *to == "" {
+		return changes
+	}
+
+	c := FieldChange{Field: field, To: sensitiveValue, Sensitive: true}
+	if exists {
+		c.From = sensitiveValue
+	}
+	return append(changes, c)
+}
+
+func joinSorted(values []*string) string {
+	s := aws.StringValueSlice(values)
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
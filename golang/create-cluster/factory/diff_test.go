@@ -0,0 +1,135 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestDiffHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		diff func([]FieldChange) []FieldChange
+		want []FieldChange
+	}{
+		{
+			name: "string unchanged",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffString(c, fieldEngineVersion, aws.String("5.7.12"), aws.String("5.7.12"))
+			},
+		},
+		{
+			name: "string unset",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffString(c, fieldEngineVersion, aws.String("5.7.12"), aws.String(""))
+			},
+		},
+		{
+			name: "string changed",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffString(c, fieldEngineVersion, aws.String("5.7.12"), aws.String("5.7.mysql_aurora.2.04.0"))
+			},
+			want: []FieldChange{{Field: fieldEngineVersion, From: "5.7.12", To: "5.7.mysql_aurora.2.04.0"}},
+		},
+		{
+			name: "int64 new",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffInt64(c, fieldPromotionTier, nil, aws.Int64(0))
+			},
+			want: []FieldChange{{Field: fieldPromotionTier, To: "0"}},
+		},
+		{
+			name: "int64 unset",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffInt64(c, fieldPromotionTier, aws.Int64(1), nil)
+			},
+		},
+		{
+			name: "list order",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffList(c, fieldSubnetIds, aws.StringSlice([]string{"b", "a"}), aws.StringSlice([]string{"a", "b"}))
+			},
+		},
+		{
+			name: "list changed",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffList(c, fieldSubnetIds, aws.StringSlice([]string{"b", "a"}), aws.StringSlice([]string{"c", "a"}))
+			},
+			want: []FieldChange{{Field: fieldSubnetIds, From: "a,b", To: "a,c", Added: []string{"c"}, Removed: []string{"b"}}},
+		},
+		{
+			name: "sensitive",
+			diff: func(c []FieldChange) []FieldChange {
+				return diffSensitive(c, fieldMasterUserPassword, aws.String("secret123"), true)
+			},
+			want: []FieldChange{{Field: fieldMasterUserPassword, From: sensitiveValue, To: sensitiveValue, Sensitive: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.diff(make([]FieldChange, 0))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldChangeString(t *testing.T) {
+	tests := []struct {
+		change FieldChange
+		want   string
+	}{
+		{FieldChange{Field: fieldEngine, To: "aurora-mysql"}, `Engine: "aurora-mysql"`},
+		{FieldChange{Field: fieldPromotionTier, From: "1", To: "2"}, `PromotionTier: "1" => "2"`},
+		{
+			FieldChange{Field: fieldSubnetIds, From: "a,b", To: "a,c,d", Added: []string{"c", "d"}, Removed: []string{"b"}},
+			`SubnetIds: "a,b" => "a,c,d" (+c +d -b)`,
+		},
+		{FieldChange{Field: fieldMasterUserPassword, To: sensitiveValue, Sensitive: true}, "MasterUserPassword: (sensitive)"},
+		{FieldChange{Field: fieldVpcSecurityGroupIds, From: "a", To: "b", Impact: ImpactInPlace}, `VpcSecurityGroupIds: "a" => "b"`},
+		{
+			FieldChange{Field: fieldEngineVersion, From: "5.7.12", To: "5.7.mysql_aurora.2.04.0", Impact: ImpactDowntime},
+			`EngineVersion: "5.7.12" => "5.7.mysql_aurora.2.04.0" (downtime)`,
+		},
+		{
+			FieldChange{Field: fieldStorageEncrypted, From: "false", To: "true", Impact: ImpactReplace},
+			`StorageEncrypted: "false" => "true" (forces replacement)`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	changes := classify([]FieldChange{
+		{Field: fieldVpcSecurityGroupIds},
+		{Field: fieldEngineVersion},
+		{Field: fieldDBInstanceClass},
+		{Field: fieldEngine},
+		{Field: fieldKmsKeyId},
+	})
+	want := []Impact{ImpactInPlace, ImpactDowntime, ImpactDowntime, ImpactReplace, ImpactReplace}
+	for n, c := range changes {
+		if c.Impact != want[n] {
+			t.Errorf("%s impact = %s, want %s", c.Field, c.Impact, want[n])
+		}
+	}
+
+	inPlace, replace := SplitReplacements(changes)
+	if got, want := changedFields(inPlace), []string{fieldVpcSecurityGroupIds, fieldEngineVersion, fieldDBInstanceClass}; !reflect.DeepEqual(got, want) {
+		t.Errorf("in place = %v, want %v", got, want)
+	}
+	if got, want := changedFields(replace), []string{fieldEngine, fieldKmsKeyId}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replace = %v, want %v", got, want)
+	}
+}
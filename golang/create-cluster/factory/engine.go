@@ -0,0 +1,149 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// EngineUpgrade is an engine version change that RDS offers as an upgrade.
+// A major upgrade also moves to another parameter group family, and the
+// cluster and its instances to the groups of that family named by
+// ClusterParameterGroup and InstanceParameterGroup.
+type EngineUpgrade struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Major      bool   `json:"major"`
+	FromFamily string `json:"fromFamily"`
+	ToFamily   string `json:"toFamily"`
+
+	ClusterParameterGroup  string `json:"clusterParameterGroup,omitempty"`
+	InstanceParameterGroup string `json:"instanceParameterGroup,omitempty"`
+}
+
+// DefaultParameterGroup is the name of the default cluster and DB parameter
+// group of family, which RDS creates for every family.
+func DefaultParameterGroup(family string) string {
+	return "default." + family
+}
+
+// CheckEngineUpgrade looks up the upgrade of engine from one version to
+// another. It returns an error when to is a downgrade or not among the valid
+// upgrade targets of from. Version strings don't order reliably, so to is a
+// downgrade when from is among its own upgrade targets.
+func CheckEngineUpgrade(svc rdsiface.RDSAPI, engine, from, to string) (*EngineUpgrade, error) {
+	current, err := FindDBEngineVersion(svc, engine, from)
+	if err != nil {
+		return nil, err
+	}
+	next, err := FindDBEngineVersion(svc, engine, to)
+	if err != nil {
+		return nil, err
+	}
+
+	target := findUpgradeTarget(current, to)
+	if target == nil {
+		if findUpgradeTarget(next, from) != nil {
+			return nil, fmt.Errorf("%s can't be downgraded from %s to %s", engine, from, to)
+		}
+
+		valid := make([]string, 0)
+		for _, t := range current.ValidUpgradeTarget {
+			valid = append(valid, aws.StringValue(t.EngineVersion))
+		}
+		if len(valid) == 0 {
+			return nil, fmt.Errorf("%s %s has no upgrade targets", engine, from)
+		}
+		return nil, fmt.Errorf(
+			"%s %s can't be upgraded to %s, valid targets are %s", engine, from, to, strings.Join(valid, ", "),
+		)
+	}
+
+	return &EngineUpgrade{
+		From:       from,
+		To:         to,
+		Major:      aws.BoolValue(target.IsMajorVersionUpgrade),
+		FromFamily: aws.StringValue(current.DBParameterGroupFamily),
+		ToFamily:   aws.StringValue(next.DBParameterGroupFamily),
+	}, nil
+}
+
+func findUpgradeTarget(version *rds.DBEngineVersion, to string) *rds.UpgradeTarget {
+	for _, t := range version.ValidUpgradeTarget {
+		if aws.StringValue(t.EngineVersion) == to {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// FindDBEngineVersion describes one version of engine, returning a
+// KindNotFound error when RDS doesn't offer it.
+func FindDBEngineVersion(svc rdsiface.RDSAPI, engine, version string) (*rds.DBEngineVersion, error) {
+	output, err := svc.DescribeDBEngineVersions(&rds.DescribeDBEngineVersionsInput{
+		Engine:        aws.String(engine),
+		EngineVersion: aws.String(version),
+	})
+	if err != nil {
+		return nil, newError(version, err)
+	}
+	if len(output.DBEngineVersions) == 0 {
+		return nil, &Error{
+			Kind:     KindNotFound,
+			Resource: version,
+			Err:      fmt.Errorf("RDS doesn't offer %s %s", engine, version),
+		}
+	}
+
+	return output.DBEngineVersions[0], nil
+}
+
+// ListDBEngineVersions lists the versions RDS offers for engine, in the
+// order RDS returns them, which is oldest first.
+func ListDBEngineVersions(svc rdsiface.RDSAPI, engine string) ([]*rds.DBEngineVersion, error) {
+	versions := make([]*rds.DBEngineVersion, 0)
+	input := &rds.DescribeDBEngineVersionsInput{Engine: aws.String(engine)}
+
+	for {
+		output, err := svc.DescribeDBEngineVersions(input)
+		if err != nil {
+			return nil, newError(engine, err)
+		}
+		versions = append(versions, output.DBEngineVersions...)
+
+		if aws.StringValue(output.Marker) == "" {
+			return versions, nil
+		}
+		input.Marker = output.Marker
+	}
+}
+
+// ListOrderableDBInstanceOptions lists the instance classes, and the zones
+// they are in, that RDS offers for engine at version. An empty version
+// lists the options of every version.
+func ListOrderableDBInstanceOptions(
+	svc rdsiface.RDSAPI, engine, version string,
+) ([]*rds.OrderableDBInstanceOption, error) {
+	options := make([]*rds.OrderableDBInstanceOption, 0)
+	input := &rds.DescribeOrderableDBInstanceOptionsInput{Engine: aws.String(engine)}
+	if version != "" {
+		input.EngineVersion = aws.String(version)
+	}
+
+	for {
+		output, err := svc.DescribeOrderableDBInstanceOptions(input)
+		if err != nil {
+			return nil, newError(engine, err)
+		}
+		options = append(options, output.OrderableDBInstanceOptions...)
+
+		if aws.StringValue(output.Marker) == "" {
+			return options, nil
+		}
+		input.Marker = output.Marker
+	}
+}
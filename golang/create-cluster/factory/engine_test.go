@@ -0,0 +1,68 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+func TestCheckEngineUpgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		engine   string
+		from, to string
+		want     *EngineUpgrade
+		err      string
+	}{
+		{
+			name:   "minor",
+			engine: "aurora-mysql", from: "5.7.12", to: "5.7.mysql_aurora.2.04.0",
+			want: &EngineUpgrade{
+				From: "5.7.12", To: "5.7.mysql_aurora.2.04.0",
+				FromFamily: "aurora-mysql5.7", ToFamily: "aurora-mysql5.7",
+			},
+		},
+		{
+			name:   "major",
+			engine: "aurora-postgresql", from: "9.6.11", to: "10.6",
+			want: &EngineUpgrade{
+				From: "9.6.11", To: "10.6", Major: true,
+				FromFamily: "aurora-postgresql9.6", ToFamily: "aurora-postgresql10",
+			},
+		},
+		{
+			name:   "downgrade",
+			engine: "aurora-mysql", from: "5.7.mysql_aurora.2.04.0", to: "5.7.12",
+			err: "aurora-mysql can't be downgraded from 5.7.mysql_aurora.2.04.0 to 5.7.12",
+		},
+		{
+			name:   "downgrade with targets",
+			engine: "aurora-mysql", from: "5.7.mysql_aurora.2.03.2", to: "5.7.12",
+			err: "aurora-mysql can't be downgraded from 5.7.mysql_aurora.2.03.2 to 5.7.12",
+		},
+		{
+			name:   "unknown version",
+			engine: "aurora-mysql", from: "5.7.12", to: "5.7.mysql_aurora.9.99.0",
+			err: "5.7.mysql_aurora.9.99.0: RDS doesn't offer aurora-mysql 5.7.mysql_aurora.9.99.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckEngineUpgrade(fakerds.New(), tt.engine, tt.from, tt.to)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("upgrade = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
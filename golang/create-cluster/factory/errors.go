@@ -0,0 +1,139 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorKind groups AWS error codes by what a caller can do about them.
+type ErrorKind string
+
+const (
+	KindNotFound      ErrorKind = "NotFound"
+	KindAlreadyExists ErrorKind = "AlreadyExists"
+	KindInvalidState  ErrorKind = "InvalidState"
+	KindQuotaExceeded ErrorKind = "QuotaExceeded"
+	KindCapacity      ErrorKind = "Capacity"
+	KindValidation    ErrorKind = "Validation"
+	KindThrottled     ErrorKind = "Throttled"
+	KindUnknown       ErrorKind = "Unknown"
+)
+
+// errorKinds maps the RDS error codes this project runs into to a kind.
+// Codes that are not listed fall back to classifyCode.
+var errorKinds = map[string]ErrorKind{
+	rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs:      KindValidation,
+	rds.ErrCodeInvalidSubnet:                           KindValidation,
+	rds.ErrCodeInvalidVPCNetworkStateFault:             KindValidation,
+	rds.ErrCodeStorageTypeNotSupportedFault:            KindValidation,
+	rds.ErrCodeDBUpgradeDependencyFailureFault:         KindInvalidState,
+	rds.ErrCodeInsufficientDBInstanceCapacityFault:     KindCapacity,
+	rds.ErrCodeInsufficientDBClusterCapacityFault:      KindCapacity,
+	rds.ErrCodeInsufficientStorageClusterCapacityFault: KindCapacity,
+	rds.ErrCodeProvisionedIopsNotAvailableInAZFault:    KindCapacity,
+	"InvalidParameterValue":                            KindValidation,
+	"InvalidParameterCombination":                      KindValidation,
+	"MissingParameter":                                 KindValidation,
+	"ValidationError":                                  KindValidation,
+	"Throttling":                                       KindThrottled,
+	"ThrottlingException":                              KindThrottled,
+	"RequestLimitExceeded":                             KindThrottled,
+	"RequestThrottled":                                 KindThrottled,
+}
+
+// Error is returned by the factory functions when an RDS call fails. It
+// keeps the AWS error code and the identifier of the resource involved.
+type Error struct {
+	Kind     ErrorKind
+	Code     string
+	Resource string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s: %s", e.Resource, e.Err)
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Resource, e.Err, e.Kind)
+}
+
+// Retryable reports whether the same call may succeed later without any
+// change to the request.
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case KindThrottled, KindInvalidState, KindCapacity:
+		return true
+	}
+	return false
+}
+
+// ErrorKindOf returns the kind of err, or KindUnknown when err did not come
+// from this package.
+func ErrorKindOf(err error) ErrorKind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+func IsNotFound(err error) bool {
+	return ErrorKindOf(err) == KindNotFound
+}
+
+func IsRetryable(err error) bool {
+	if e, ok := err.(*Error); ok {
+		return e.Retryable()
+	}
+	return false
+}
+
+// newError classifies err from an RDS call on resource and logs it. Errors
+// that are already classified are returned as they are.
+func newError(resource string, err error) error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	e := &Error{Resource: resource, Err: err}
+	e.Code, e.Kind = classifyAWSError(err)
+
+	if e.Kind == KindNotFound {
+		log.Info(e.Error())
+	} else {
+		log.Warn(e.Error())
+	}
+
+	return e
+}
+
+// classifyAWSError returns the code and kind of an error returned by the
+// SDK. Errors that do not carry an AWS code are KindUnknown.
+func classifyAWSError(err error) (string, ErrorKind) {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code(), classifyCode(aerr.Code())
+	}
+	return "", KindUnknown
+}
+
+func classifyCode(code string) ErrorKind {
+	if kind, ok := errorKinds[code]; ok {
+		return kind
+	}
+
+	switch {
+	case strings.Contains(code, "NotFound"):
+		return KindNotFound
+	case strings.Contains(code, "AlreadyExists"):
+		return KindAlreadyExists
+	case strings.Contains(code, "QuotaExceeded"):
+		return KindQuotaExceeded
+	case strings.HasPrefix(code, "Invalid") && strings.Contains(code, "State"):
+		return KindInvalidState
+	}
+
+	return KindUnknown
+}
@@ -0,0 +1,68 @@
+package factory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		code      string
+		kind      ErrorKind
+		retryable bool
+	}{
+		{code: rds.ErrCodeDBClusterNotFoundFault, kind: KindNotFound},
+		{code: rds.ErrCodeDBSubnetGroupNotFoundFault, kind: KindNotFound},
+		{code: rds.ErrCodeDBClusterAlreadyExistsFault, kind: KindAlreadyExists},
+		{code: rds.ErrCodeInvalidDBClusterStateFault, kind: KindInvalidState, retryable: true},
+		{code: rds.ErrCodeInvalidDBInstanceStateFault, kind: KindInvalidState, retryable: true},
+		{code: rds.ErrCodeDBUpgradeDependencyFailureFault, kind: KindInvalidState, retryable: true},
+		{code: rds.ErrCodeStorageQuotaExceededFault, kind: KindQuotaExceeded},
+		{code: rds.ErrCodeInsufficientDBInstanceCapacityFault, kind: KindCapacity, retryable: true},
+		{code: rds.ErrCodeInvalidSubnet, kind: KindValidation},
+		{code: rds.ErrCodeInvalidVPCNetworkStateFault, kind: KindValidation},
+		{code: "InvalidParameterCombination", kind: KindValidation},
+		{code: "Throttling", kind: KindThrottled, retryable: true},
+		{code: "AccessDenied", kind: KindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := newError("experiments", awserr.New(tt.code, "message", nil))
+			if kind := ErrorKindOf(err); kind != tt.kind {
+				t.Errorf("kind = %s, want %s", kind, tt.kind)
+			}
+			if retryable := IsRetryable(err); retryable != tt.retryable {
+				t.Errorf("retryable = %t, want %t", retryable, tt.retryable)
+			}
+			if IsNotFound(err) != (tt.kind == KindNotFound) {
+				t.Errorf("IsNotFound = %t for kind %s", IsNotFound(err), tt.kind)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	err := newError("experiments", awserr.New(rds.ErrCodeInvalidDBClusterStateFault, "not available", nil))
+	if again := newError("other", err); again != err {
+		t.Errorf("classifying an *Error again = %v, want it unchanged", again)
+	}
+	want := "experiments: InvalidDBClusterStateFault: not available (InvalidState)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	err = newError("experiments", errors.New("connection reset"))
+	if kind := ErrorKindOf(err); kind != KindUnknown {
+		t.Errorf("kind of an error that isn't from AWS = %s, want %s", kind, KindUnknown)
+	}
+	if want := "experiments: connection reset"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if kind := ErrorKindOf(errors.New("plain")); kind != KindUnknown {
+		t.Errorf("kind of an unclassified error = %s, want %s", kind, KindUnknown)
+	}
+}
@@ -1,54 +1,129 @@
 package factory
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	notFoundErr error
-)
+const minSubnetGroupZones = 2
+
+// FindDBSubnetGroup describes the subnet group, returning a KindNotFound
+// error when it does not exist yet.
+func FindDBSubnetGroup(svc rdsiface.RDSAPI, groupName string) (*rds.DBSubnetGroup, error) {
+	descGroupsInput := &rds.DescribeDBSubnetGroupsInput{
+		DBSubnetGroupName: aws.String(groupName),
+	}
 
-func init() {
-	notFoundErr = errors.New("not found")
+	descGroupsOutput, err := svc.DescribeDBSubnetGroups(descGroupsInput)
+	if err != nil {
+		return nil, newError(groupName, err)
+	}
+
+	return descGroupsOutput.DBSubnetGroups[0], nil
 }
 
-func UpdateOrCreateDBSubnetGroup(svc *rds.RDS, groupName, groupDescription string, subnets []string) (*rds.DBSubnetGroup, error) {
-	var subnetGroup *rds.DBSubnetGroup
+func UpdateOrCreateDBSubnetGroup(svc rdsiface.RDSAPI, groupName, groupDescription string, subnets []string) (*rds.DBSubnetGroup, error) {
+	subnetGroup, err := FindDBSubnetGroup(svc, groupName)
+	if err != nil {
+		if IsNotFound(err) {
+			return CreateDBSubnetGroup(svc, groupName, groupDescription, subnets)
+		}
+		return nil, err
+	}
 
-	subnetGroupName := aws.String(groupName)
+	changes := DiffDBSubnetGroup(subnetGroup, groupName, groupDescription, subnets)
+	if len(changes) == 0 {
+		log.Infof("subnet group %s is up to date", groupName)
+		return subnetGroup, nil
+	}
 
-	descGroupsInput := &rds.DescribeDBSubnetGroupsInput{
-		DBSubnetGroupName: subnetGroupName,
+	return ModifyDBSubnetGroup(svc, groupName, groupDescription, subnets)
+}
+
+// DiffDBSubnetGroup lists the attributes of subnetGroup that differ from the
+// request. A nil subnetGroup yields every attribute that would be set on
+// create.
+func DiffDBSubnetGroup(subnetGroup *rds.DBSubnetGroup, groupName, groupDescription string, subnets []string) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	if subnetGroup == nil {
+		changes = diffString(changes, fieldDBSubnetGroupName, nil, aws.String(groupName))
+		changes = diffString(changes, fieldDBSubnetGroupDescription, nil, aws.String(groupDescription))
+		changes = diffList(changes, fieldSubnetIds, nil, aws.StringSlice(subnets))
+		return changes
 	}
 
-	descGroupsOutput, err := svc.DescribeDBSubnetGroups(descGroupsInput)
+	current := make([]*string, 0)
+	for _, s := range subnetGroup.Subnets {
+		current = append(current, s.SubnetIdentifier)
+	}
+
+	changes = diffString(changes, fieldDBSubnetGroupDescription, subnetGroup.DBSubnetGroupDescription, aws.String(groupDescription))
+	changes = diffList(changes, fieldSubnetIds, current, aws.StringSlice(subnets))
+
+	return changes
+}
+
+// CheckSubnetZones makes sure subnets cover at least two availability zones,
+// which RDS requires of a subnet group. The zones are looked up in EC2, so
+// subnets that aren't in the group yet are checked as well.
+func CheckSubnetZones(svc ec2iface.EC2API, groupName string, subnets []string) error {
+	output, err := svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(subnets),
+	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBSubnetGroupNotFoundFault:
-				log.Info(rds.ErrCodeDBSubnetGroupNotFoundFault, aerr.Error())
-
-				return createSubnetGroup(svc, subnetGroupName, groupDescription, subnets)
-			default:
-				log.Warn(aerr)
-				return nil, aerr
-			}
-		} else {
-			return nil, err
+		return newError(groupName, err)
+	}
+
+	zones := map[string]bool{}
+	for _, s := range output.Subnets {
+		zones[aws.StringValue(s.AvailabilityZone)] = true
+	}
+
+	if len(zones) < minSubnetGroupZones {
+		return &Error{
+			Kind:     KindValidation,
+			Code:     rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs,
+			Resource: groupName,
+			Err: fmt.Errorf(
+				"subnets %s cover %d availability zone(s), at least %d are required",
+				strings.Join(subnets, ","), len(zones), minSubnetGroupZones,
+			),
 		}
 	}
 
-	subnetGroup = descGroupsOutput.DBSubnetGroups[0]
+	return nil
+}
 
-	return subnetGroup, nil
+func CreateDBSubnetGroup(svc rdsiface.RDSAPI, groupName, groupDescription string, subnets []string) (*rds.DBSubnetGroup, error) {
+	return createSubnetGroup(svc, aws.String(groupName), groupDescription, subnets)
 }
 
-func createSubnetGroup(svc *rds.RDS, subnetGroupName *string, groupDescription string, subnetIds []string) (*rds.DBSubnetGroup, error) {
+// ModifyDBSubnetGroup replaces the description and subnets of an existing
+// group. RDS always needs the full subnet list.
+func ModifyDBSubnetGroup(svc rdsiface.RDSAPI, groupName, groupDescription string, subnets []string) (*rds.DBSubnetGroup, error) {
+	input := &rds.ModifyDBSubnetGroupInput{
+		DBSubnetGroupName:        aws.String(groupName),
+		DBSubnetGroupDescription: aws.String(groupDescription),
+		SubnetIds:                aws.StringSlice(subnets),
+	}
+
+	output, err := svc.ModifyDBSubnetGroup(input)
+	if err != nil {
+		return nil, newError(groupName, err)
+	}
+
+	return output.DBSubnetGroup, nil
+}
+
+func createSubnetGroup(svc rdsiface.RDSAPI, subnetGroupName *string, groupDescription string, subnetIds []string) (*rds.DBSubnetGroup, error) {
 	sIds := make([]*string, 0)
 	for _, i := range subnetIds {
 		sIds = append(sIds, aws.String(i))
@@ -62,73 +137,44 @@ func createSubnetGroup(svc *rds.RDS, subnetGroupName *string, groupDescription s
 
 	groupOutput, err := svc.CreateDBSubnetGroup(groupInput)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBSubnetGroupAlreadyExistsFault:
-				log.Warn(rds.ErrCodeDBSubnetGroupAlreadyExistsFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBSubnetGroupQuotaExceededFault:
-				log.Warn(rds.ErrCodeDBSubnetGroupQuotaExceededFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBSubnetQuotaExceededFault:
-				log.Warn(rds.ErrCodeDBSubnetQuotaExceededFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs:
-				log.Warn(rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidSubnet:
-				log.Warn(rds.ErrCodeInvalidSubnet, aerr.Error())
-				return nil, aerr
-			default:
-				log.Warn(aerr)
-				return nil, aerr
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Warn(err)
-			return nil, aerr
-		}
+		return nil, newError(*subnetGroupName, err)
 	}
 
 	return groupOutput.DBSubnetGroup, nil
 }
 
-func findDBCluster(svc *rds.RDS, clusterIdentifier *string) (*rds.DBCluster, error) {
+// FindDBCluster describes a cluster by identifier, returning a KindNotFound
+// error when it does not exist.
+func FindDBCluster(svc rdsiface.RDSAPI, clusterIdentifier string) (*rds.DBCluster, error) {
+	return findDBCluster(svc, aws.String(clusterIdentifier))
+}
+
+// FindDBInstance is FindDBCluster for an instance.
+func FindDBInstance(svc rdsiface.RDSAPI, instanceIdentifier string) (*rds.DBInstance, error) {
+	return findDBClusterInstance(svc, aws.String(instanceIdentifier))
+}
+
+func findDBCluster(svc rdsiface.RDSAPI, clusterIdentifier *string) (*rds.DBCluster, error) {
 	descClustersInput := &rds.DescribeDBClustersInput{
 		DBClusterIdentifier: clusterIdentifier,
 	}
 
 	descClusterOuput, err := svc.DescribeDBClusters(descClustersInput)
 	if err != nil {
-		return nil, err
+		return nil, newError(*clusterIdentifier, err)
 	}
 
 	return descClusterOuput.DBClusters[0], nil
 }
 
-func findDBClusterInstance(svc *rds.RDS, instanceIdentifier *string) (*rds.DBInstance, error) {
+func findDBClusterInstance(svc rdsiface.RDSAPI, instanceIdentifier *string) (*rds.DBInstance, error) {
 	descInstancesInput := &rds.DescribeDBInstancesInput{
 		DBInstanceIdentifier: instanceIdentifier,
 	}
 
 	descInstancesOuput, err := svc.DescribeDBInstances(descInstancesInput)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeDBInstanceNotFoundFault:
-				log.Info(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
-				return nil, notFoundErr
-			default:
-				log.Warn(aerr.Error())
-				return nil, aerr
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Warn(err.Error())
-			return nil, err
-		}
+		return nil, newError(*instanceIdentifier, err)
 	}
 
 	return descInstancesOuput.DBInstances[0], nil
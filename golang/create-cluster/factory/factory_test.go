@@ -0,0 +1,178 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+// changedFields lists the fields of changes, or nil when there are none.
+func changedFields(changes []FieldChange) []string {
+	var fields []string
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+	}
+
+	return fields
+}
+
+func TestUpdateOrCreateDBSubnetGroup(t *testing.T) {
+	subnets := []string{"subnet-00000000000000001", "subnet-00000000000000002"}
+
+	tests := []struct {
+		name string
+		// existing are the subnets of the group before the test, if it
+		// exists.
+		existing    []string
+		description string
+		subnets     []string
+		changes     []string
+		// kind is that of the error, if any.
+		kind ErrorKind
+	}{
+		{
+			name:        "create",
+			description: "experiments",
+			subnets:     subnets,
+			changes:     []string{fieldDBSubnetGroupName, fieldDBSubnetGroupDescription, fieldSubnetIds},
+		},
+		{
+			name:        "no-op",
+			existing:    subnets,
+			description: "experiments",
+			subnets:     []string{"subnet-00000000000000002", "subnet-00000000000000001"},
+		},
+		{
+			name:        "modify",
+			existing:    subnets,
+			description: "experiments subnets",
+			subnets:     []string{"subnet-00000000000000001", "subnet-00000000000000003"},
+			changes:     []string{fieldDBSubnetGroupDescription, fieldSubnetIds},
+		},
+		{
+			// The fake picks the zone of a subnet from the last character
+			// of its id, so the first and the fourth share a zone.
+			name:        "one zone",
+			existing:    []string{"subnet-00000000000000001", "subnet-00000000000000002", "subnet-00000000000000003", "subnet-00000000000000004"},
+			description: "experiments",
+			subnets:     []string{"subnet-00000000000000001", "subnet-00000000000000004"},
+			changes:     []string{fieldSubnetIds},
+			kind:        KindValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			if tt.existing != nil {
+				if _, err := CreateDBSubnetGroup(svc, "experiments", "experiments", tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			current, err := FindDBSubnetGroup(svc, "experiments")
+			if err != nil && !IsNotFound(err) {
+				t.Fatal(err)
+			}
+			changes := DiffDBSubnetGroup(current, "experiments", tt.description, tt.subnets)
+			if got := changedFields(changes); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+
+			_, err = UpdateOrCreateDBSubnetGroup(svc, "experiments", tt.description, tt.subnets)
+			if tt.kind != "" {
+				if kind := ErrorKindOf(err); kind != tt.kind {
+					t.Fatalf("err = %v, want kind %s", err, tt.kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			current, err = FindDBSubnetGroup(svc, "experiments")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changes := DiffDBSubnetGroup(current, "experiments", tt.description, tt.subnets); len(changes) > 0 {
+				t.Errorf("changes left after applying: %v", changes)
+			}
+		})
+	}
+}
+
+// fakeEC2 serves the subnet lookups of the fake RDS, the only EC2 call the
+// factory makes.
+type fakeEC2 struct {
+	ec2iface.EC2API
+	svc *fakerds.RDS
+}
+
+func (f fakeEC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	return f.svc.DescribeSubnets(input)
+}
+
+func TestCheckSubnetZones(t *testing.T) {
+	// The fake puts subnets ending in 1 and 4 in the same zone.
+	tests := []struct {
+		name    string
+		subnets []string
+		ok      bool
+	}{
+		{name: "two zones", subnets: []string{"subnet-1", "subnet-2"}, ok: true},
+		{name: "one zone", subnets: []string{"subnet-1", "subnet-4"}},
+		{name: "one subnet", subnets: []string{"subnet-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSubnetZones(fakeEC2{svc: fakerds.New()}, "experiments", tt.subnets)
+			if tt.ok && err != nil {
+				t.Errorf("err = %v", err)
+			}
+			if !tt.ok && ErrorKindOf(err) != KindValidation {
+				t.Errorf("err = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	svc := fakerds.New()
+
+	// Resources that are already gone are not an error.
+	if err := DeleteDBInstance(svc, "experiments-0"); err != nil {
+		t.Errorf("deleting a missing instance: %v", err)
+	}
+	if err := DeleteDBCluster(svc, "experiments", ""); err != nil {
+		t.Errorf("deleting a missing cluster: %v", err)
+	}
+	if err := DeleteDBSubnetGroup(svc, "experiments"); err != nil {
+		t.Errorf("deleting a missing subnet group: %v", err)
+	}
+
+	svc.InjectFault("DeleteDBCluster", rds.ErrCodeInvalidDBClusterStateFault, 1)
+	err := DeleteDBCluster(svc, "experiments", "experiments-final")
+	if e, ok := err.(*Error); !ok || e.Code != rds.ErrCodeInvalidDBClusterStateFault || e.Resource != "experiments" {
+		t.Errorf("err = %v, want %s on experiments", err, rds.ErrCodeInvalidDBClusterStateFault)
+	}
+
+	if _, err := CreateDBSubnetGroup(svc, "experiments", "experiments", []string{"subnet-00000000000000001", "subnet-00000000000000002"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteDBSubnetGroup(svc, "experiments"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindDBSubnetGroup(svc, "experiments"); !IsNotFound(err) {
+		t.Errorf("err = %v after deleting the subnet group, want not found", err)
+	}
+
+	if _, err := findDBCluster(svc, aws.String("experiments")); !IsNotFound(err) {
+		t.Errorf("err = %v, want not found", err)
+	}
+}
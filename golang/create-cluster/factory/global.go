@@ -0,0 +1,157 @@
+package factory
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+// FindGlobalCluster describes a global cluster, returning a KindNotFound
+// error when it does not exist.
+func FindGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier string) (*rds.GlobalCluster, error) {
+	return findGlobalCluster(svc, aws.String(globalClusterIdentifier))
+}
+
+func findGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier *string) (*rds.GlobalCluster, error) {
+	output, err := svc.DescribeGlobalClusters(&rds.DescribeGlobalClustersInput{
+		GlobalClusterIdentifier: globalClusterIdentifier,
+	})
+	if err != nil {
+		return nil, newError(*globalClusterIdentifier, err)
+	}
+
+	return output.GlobalClusters[0], nil
+}
+
+// DiffGlobalCluster lists what CreateGlobalCluster would set when
+// globalCluster is nil. A global cluster has nothing that is managed once it
+// exists, so there is never anything to change.
+func DiffGlobalCluster(globalCluster *rds.GlobalCluster, globalClusterIdentifier, sourceClusterIdentifier string) []FieldChange {
+	changes := make([]FieldChange, 0)
+	if globalCluster != nil {
+		return changes
+	}
+
+	changes = diffString(changes, fieldGlobalClusterIdentifier, nil, aws.String(globalClusterIdentifier))
+	return diffString(changes, fieldSourceDBClusterIdentifier, nil, aws.String(sourceClusterIdentifier))
+}
+
+// CreateGlobalCluster creates a global cluster with dbCluster as its primary
+// cluster. The global cluster takes the engine, engine version and storage
+// encryption of dbCluster.
+func CreateGlobalCluster(
+	svc rdsiface.RDSAPI, globalClusterIdentifier string, dbCluster *rds.DBCluster,
+) (*rds.GlobalCluster, error) {
+	output, err := svc.CreateGlobalCluster(&rds.CreateGlobalClusterInput{
+		GlobalClusterIdentifier:   aws.String(globalClusterIdentifier),
+		SourceDBClusterIdentifier: dbCluster.DBClusterArn,
+	})
+	if err != nil {
+		return nil, newError(globalClusterIdentifier, err)
+	}
+
+	return output.GlobalCluster, nil
+}
+
+// GlobalClusterMember returns the member of globalCluster with the cluster
+// ARN clusterArn, or nil when the cluster isn't part of it.
+func GlobalClusterMember(globalCluster *rds.GlobalCluster, clusterArn string) *rds.GlobalClusterMember {
+	for _, m := range globalCluster.GlobalClusterMembers {
+		if aws.StringValue(m.DBClusterArn) == clusterArn {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// RemoveFromGlobalCluster detaches the cluster with the ARN clusterArn from
+// a global cluster. A detached secondary cluster becomes a regional cluster
+// that accepts writes. A cluster that isn't a member is not an error.
+func RemoveFromGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier, clusterArn string) error {
+	_, err := svc.RemoveFromGlobalCluster(&rds.RemoveFromGlobalClusterInput{
+		GlobalClusterIdentifier: aws.String(globalClusterIdentifier),
+		DbClusterIdentifier:     aws.String(clusterArn),
+	})
+	if err != nil {
+		err = newError(globalClusterIdentifier, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SwitchoverGlobalCluster starts making the secondary cluster with the ARN
+// clusterArn the primary cluster of a global cluster. RDS waits for it to
+// catch up with the primary cluster first, so no data is lost, and the
+// previous primary cluster becomes a secondary cluster.
+func SwitchoverGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier, clusterArn string) (*rds.GlobalCluster, error) {
+	output, err := svc.SwitchoverGlobalCluster(&rds.SwitchoverGlobalClusterInput{
+		GlobalClusterIdentifier:   aws.String(globalClusterIdentifier),
+		TargetDbClusterIdentifier: aws.String(clusterArn),
+	})
+	if err != nil {
+		return nil, newError(globalClusterIdentifier, err)
+	}
+
+	return output.GlobalCluster, nil
+}
+
+// FailoverGlobalCluster is SwitchoverGlobalCluster for when the primary
+// cluster is unavailable: the secondary cluster takes over without catching
+// up, so writes it hadn't replicated yet are lost.
+func FailoverGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier, clusterArn string) (*rds.GlobalCluster, error) {
+	output, err := svc.FailoverGlobalCluster(&rds.FailoverGlobalClusterInput{
+		GlobalClusterIdentifier:   aws.String(globalClusterIdentifier),
+		TargetDbClusterIdentifier: aws.String(clusterArn),
+		AllowDataLoss:             aws.Bool(true),
+	})
+	if err != nil {
+		return nil, newError(globalClusterIdentifier, err)
+	}
+
+	return output.GlobalCluster, nil
+}
+
+// GlobalClusterWriter returns the ARN of the primary cluster of
+// globalCluster, or "" while it has none.
+func GlobalClusterWriter(globalCluster *rds.GlobalCluster) string {
+	for _, m := range globalCluster.GlobalClusterMembers {
+		if aws.BoolValue(m.IsWriter) {
+			return aws.StringValue(m.DBClusterArn)
+		}
+	}
+
+	return ""
+}
+
+// ArnRegion returns the region of a resource from its ARN, or "" when arn
+// isn't one.
+func ArnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 5)
+	if len(parts) < 5 || parts[0] != "arn" {
+		return ""
+	}
+
+	return parts[3]
+}
+
+// DeleteGlobalCluster starts deleting a global cluster that no longer has
+// members. A missing global cluster is not an error.
+func DeleteGlobalCluster(svc rdsiface.RDSAPI, globalClusterIdentifier string) error {
+	_, err := svc.DeleteGlobalCluster(&rds.DeleteGlobalClusterInput{
+		GlobalClusterIdentifier: aws.String(globalClusterIdentifier),
+	})
+	if err != nil {
+		err = newError(globalClusterIdentifier, err)
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,21 +2,25 @@ package factory
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 )
 
 type DBInstanceFactory struct {
-	svc                *rds.RDS
+	svc                rdsiface.RDSAPI
 	instanceIdentifier *string
 	clusterIdentifier  *string
 	allocatedStorage   *int64
 	engine             *string
 	instanceClass      *string
+	promotionTier      *int64
+	availabilityZone   *string
+	parameterGroupName *string
+	maintenanceWindow  *string
 }
 
-func (f *DBInstanceFactory) SetSvc(v *rds.RDS) *DBInstanceFactory {
+func (f *DBInstanceFactory) SetSvc(v rdsiface.RDSAPI) *DBInstanceFactory {
 	f.svc = v
 	return f
 }
@@ -46,122 +50,178 @@ func (f *DBInstanceFactory) SetInstanceClass(v string) *DBInstanceFactory {
 	return f
 }
 
+func (f *DBInstanceFactory) SetPromotionTier(v int64) *DBInstanceFactory {
+	f.promotionTier = aws.Int64(v)
+	return f
+}
+
+func (f *DBInstanceFactory) SetAvailabilityZone(v string) *DBInstanceFactory {
+	f.availabilityZone = aws.String(v)
+	return f
+}
+
+// SetDBParameterGroupName attaches a custom DB parameter group. Without it the
+// group of an existing instance is left alone.
+func (f *DBInstanceFactory) SetDBParameterGroupName(v string) *DBInstanceFactory {
+	f.parameterGroupName = aws.String(v)
+	return f
+}
+
+// SetPreferredMaintenanceWindow sets the weekly maintenance window, as
+// ddd:hh24:mi-ddd:hh24:mi in UTC. Without it RDS picks one.
+func (f *DBInstanceFactory) SetPreferredMaintenanceWindow(v string) *DBInstanceFactory {
+	f.maintenanceWindow = aws.String(v)
+	return f
+}
+
+// FindDBClusterInstance describes the instance, returning a KindNotFound
+// error when it does not exist yet.
+func (f *DBInstanceFactory) FindDBClusterInstance() (*rds.DBInstance, error) {
+	return findDBClusterInstance(f.svc, f.instanceIdentifier)
+}
+
 func (f *DBInstanceFactory) UpdateOrCreateDBClusterInstance() (*rds.DBInstance, error) {
 
-	instance, err := findDBClusterInstance(f.svc, f.instanceIdentifier)
+	instance, err := f.FindDBClusterInstance()
 	if err != nil {
-		if err == notFoundErr {
-			log.Info("cluster instance does not exist")
-			return f.createDBClusterInstance()
+		if IsNotFound(err) {
+			log.Infof("cluster instance %s does not exist", *f.instanceIdentifier)
+			return f.CreateDBClusterInstance()
 		}
+		return nil, err
 	}
 
-	instance, err = f.updateDBInstance(instance)
-	if err != nil {
-		return nil, err
+	changes := f.Diff(instance)
+	if len(changes) == 0 {
+		log.Infof("cluster instance %s is up to date", *instance.DBInstanceIdentifier)
+		return instance, nil
 	}
 
-	return instance, nil
+	return f.ModifyDBClusterInstance(instance, changes)
 }
 
-func (f *DBInstanceFactory) createDBClusterInstance() (*rds.DBInstance, error) {
+func (f *DBInstanceFactory) CreateDBClusterInstance() (*rds.DBInstance, error) {
 
 	instanceInput := &rds.CreateDBInstanceInput{
 		DBInstanceIdentifier: f.instanceIdentifier,
 		DBClusterIdentifier:  f.clusterIdentifier,
 		Engine:               f.engine,
 		DBInstanceClass:      f.instanceClass,
+		PromotionTier:        f.promotionTier,
+		AvailabilityZone:     f.availabilityZone,
+		DBParameterGroupName: f.parameterGroupName,
+
+		PreferredMaintenanceWindow: f.maintenanceWindow,
 	}
 
 	instanceOutput, err := f.svc.CreateDBInstance(instanceInput)
 	if err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, newError(*f.instanceIdentifier, err)
 	}
 
 	return instanceOutput.DBInstance, nil
 }
 
-func (f *DBInstanceFactory) updateDBInstance(instance *rds.DBInstance) (
+// Diff lists the attributes of instance that differ from the factory
+// settings. A nil instance yields every attribute that would be set on
+// create.
+func (f *DBInstanceFactory) Diff(instance *rds.DBInstance) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	if instance == nil {
+		changes = diffString(changes, fieldDBInstanceIdentifier, nil, f.instanceIdentifier)
+		changes = diffString(changes, fieldDBClusterIdentifier, nil, f.clusterIdentifier)
+		changes = diffString(changes, fieldEngine, nil, f.engine)
+		changes = diffString(changes, fieldDBInstanceClass, nil, f.instanceClass)
+		changes = diffInt64(changes, fieldPromotionTier, nil, f.promotionTier)
+		changes = diffString(changes, fieldAvailabilityZone, nil, f.availabilityZone)
+		changes = diffString(changes, fieldDBParameterGroupName, nil, f.parameterGroupName)
+		changes = diffString(changes, fieldPreferredMaintenanceWindow, nil, f.maintenanceWindow)
+		return changes
+	}
+
+	if f.availabilityZone != nil && instance.AvailabilityZone != nil &&
+		*f.availabilityZone != *instance.AvailabilityZone {
+		log.Warnf(
+			"instance %s is in %s, not %s; the availability zone of an existing instance cannot be changed",
+			*instance.DBInstanceIdentifier, *instance.AvailabilityZone, *f.availabilityZone,
+		)
+	}
+
+	changes = diffString(changes, fieldDBInstanceClass, instance.DBInstanceClass, f.instanceClass)
+	changes = diffInt64(changes, fieldPromotionTier, instance.PromotionTier, f.promotionTier)
+	changes = diffString(changes, fieldDBParameterGroupName, dbParameterGroupName(instance), f.parameterGroupName)
+	changes = diffString(
+		changes, fieldPreferredMaintenanceWindow, instance.PreferredMaintenanceWindow, f.maintenanceWindow,
+	)
+
+	return classify(changes)
+}
+
+// ModifyDBClusterInstance applies only the given changes to an existing
+// instance.
+func (f *DBInstanceFactory) ModifyDBClusterInstance(
+	instance *rds.DBInstance, changes []FieldChange,
+) (*rds.DBInstance, error) {
+	return f.updateDBInstance(instance, changes)
+}
+
+func (f *DBInstanceFactory) updateDBInstance(instance *rds.DBInstance, changes []FieldChange) (
 	*rds.DBInstance, error,
 ) {
+	// Storage, backups and the master password belong to the cluster in
+	// Aurora, so they are not set here.
 	input := &rds.ModifyDBInstanceInput{
-		//AllocatedStorage:           aws.Int64(10),
-		ApplyImmediately: aws.Bool(true),
-		//BackupRetentionPeriod:      aws.Int64(1),
-		DBInstanceClass:      f.instanceClass,
+		ApplyImmediately:     aws.Bool(true),
 		DBInstanceIdentifier: instance.DBInstanceIdentifier,
-		//MasterUserPassword:         aws.String("mynewpassword"),
-		//PreferredBackupWindow:      aws.String("04:00-04:30"),
-		//PreferredMaintenanceWindow: aws.String("Tue:05:00-Tue:05:30"),
+	}
+
+	if hasChange(changes, fieldDBInstanceClass) {
+		input.DBInstanceClass = f.instanceClass
+	}
+	if hasChange(changes, fieldPromotionTier) {
+		input.PromotionTier = f.promotionTier
+	}
+	if hasChange(changes, fieldDBParameterGroupName) {
+		input.DBParameterGroupName = f.parameterGroupName
+	}
+	if hasChange(changes, fieldPreferredMaintenanceWindow) {
+		input.PreferredMaintenanceWindow = f.maintenanceWindow
 	}
 
 	result, err := f.svc.ModifyDBInstance(input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case rds.ErrCodeInvalidDBInstanceStateFault:
-				log.Warn(rds.ErrCodeInvalidDBInstanceStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidDBSecurityGroupStateFault:
-				log.Warn(rds.ErrCodeInvalidDBSecurityGroupStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBInstanceAlreadyExistsFault:
-				log.Warn(rds.ErrCodeDBInstanceAlreadyExistsFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBInstanceNotFoundFault:
-				log.Warn(rds.ErrCodeDBInstanceNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBSecurityGroupNotFoundFault:
-				log.Warn(rds.ErrCodeDBSecurityGroupNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBParameterGroupNotFoundFault:
-				log.Warn(rds.ErrCodeDBParameterGroupNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInsufficientDBInstanceCapacityFault:
-				log.Warn(rds.ErrCodeInsufficientDBInstanceCapacityFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeStorageQuotaExceededFault:
-				log.Warn(rds.ErrCodeStorageQuotaExceededFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeInvalidVPCNetworkStateFault:
-				log.Warn(rds.ErrCodeInvalidVPCNetworkStateFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeProvisionedIopsNotAvailableInAZFault:
-				log.Warn(rds.ErrCodeProvisionedIopsNotAvailableInAZFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeOptionGroupNotFoundFault:
-				log.Warn(rds.ErrCodeOptionGroupNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDBUpgradeDependencyFailureFault:
-				log.Warn(rds.ErrCodeDBUpgradeDependencyFailureFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeStorageTypeNotSupportedFault:
-				log.Warn(rds.ErrCodeStorageTypeNotSupportedFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeAuthorizationNotFoundFault:
-				log.Warn(rds.ErrCodeAuthorizationNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeCertificateNotFoundFault:
-				log.Warn(rds.ErrCodeCertificateNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeDomainNotFoundFault:
-				log.Warn(rds.ErrCodeDomainNotFoundFault, aerr.Error())
-				return nil, aerr
-			case rds.ErrCodeBackupPolicyNotFoundFault:
-				log.Warn(rds.ErrCodeBackupPolicyNotFoundFault, aerr.Error())
-				return nil, aerr
-			default:
-				log.Warn(aerr.Error())
-				return nil, aerr
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			log.Warn(err.Error())
-			return nil, err
-		}
+		return nil, newError(*instance.DBInstanceIdentifier, err)
+	}
+
+	// A different parameter group is only used after the instance restarts.
+	if hasChange(changes, fieldDBParameterGroupName) || InstancePendingReboot(result.DBInstance) {
+		log.Warnf("instance %s has parameter group changes that take effect after a reboot", *instance.DBInstanceIdentifier)
 	}
 
 	return result.DBInstance, nil
 }
+
+// RenameDBInstance changes the identifier of an instance, which restarts it.
+func RenameDBInstance(svc rdsiface.RDSAPI, instanceIdentifier, newIdentifier string) (*rds.DBInstance, error) {
+	output, err := svc.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+		ApplyImmediately:        aws.Bool(true),
+		DBInstanceIdentifier:    aws.String(instanceIdentifier),
+		NewDBInstanceIdentifier: aws.String(newIdentifier),
+	})
+	if err != nil {
+		return nil, newError(instanceIdentifier, err)
+	}
+
+	return output.DBInstance, nil
+}
+
+// dbParameterGroupName returns the DB parameter group of instance, or nil if
+// it reports none.
+func dbParameterGroupName(instance *rds.DBInstance) *string {
+	if len(instance.DBParameterGroups) == 0 {
+		return nil
+	}
+
+	return instance.DBParameterGroups[0].DBParameterGroupName
+}
@@ -0,0 +1,125 @@
+package factory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+func TestUpdateOrCreateDBClusterInstance(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+		// drift is an instance class set behind the factory's back.
+		drift          string
+		class          string
+		tier           int64
+		parameterGroup string
+		changes        []string
+	}{
+		{
+			name:  "create",
+			class: "db.r4.large",
+			tier:  1,
+			changes: []string{
+				fieldDBInstanceIdentifier, fieldDBClusterIdentifier, fieldEngine, fieldDBInstanceClass, fieldPromotionTier,
+			},
+		},
+		{name: "no-op", existing: true, class: "db.r4.large", tier: 1},
+		{
+			name:     "modify",
+			existing: true,
+			class:    "db.r4.large",
+			tier:     2,
+			changes:  []string{fieldPromotionTier},
+		},
+		{
+			name:           "parameter group",
+			existing:       true,
+			class:          "db.r4.large",
+			tier:           1,
+			parameterGroup: "experiments",
+			changes:        []string{fieldDBParameterGroupName},
+		},
+		{
+			name:     "drift",
+			existing: true,
+			drift:    "db.r5.large",
+			class:    "db.r4.large",
+			tier:     1,
+			changes:  []string{fieldDBInstanceClass},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			cluster := NewDBClusterFactoryInput{ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12"}
+			if _, err := NewDBClusterFactory(cluster).CreateDBCluster(svc); err != nil {
+				t.Fatal(err)
+			}
+			group := ParameterGroupInput{Name: "experiments", Family: "aurora-mysql5.7", Description: "experiments"}
+			if _, err := NewDBParameterGroupFactory(group).CreateDBParameterGroup(svc); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing {
+				f := (&DBInstanceFactory{}).SetSvc(svc).
+					SetInstanceIdentifier("experiments-1").
+					SetClusterIdentifier("experiments").
+					SetEngine("aurora-mysql").
+					SetInstanceClass("db.r4.large").
+					SetPromotionTier(1)
+				if _, err := f.CreateDBClusterInstance(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.drift != "" {
+				// The instance is available after it is described once.
+				if _, err := findDBClusterInstance(svc, aws.String("experiments-1")); err != nil {
+					t.Fatal(err)
+				}
+				_, err := svc.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+					ApplyImmediately:     aws.Bool(true),
+					DBInstanceIdentifier: aws.String("experiments-1"),
+					DBInstanceClass:      aws.String(tt.drift),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f := (&DBInstanceFactory{}).SetSvc(svc).
+				SetInstanceIdentifier("experiments-1").
+				SetClusterIdentifier("experiments").
+				SetEngine("aurora-mysql").
+				SetInstanceClass(tt.class).
+				SetPromotionTier(tt.tier)
+			if tt.parameterGroup != "" {
+				f.SetDBParameterGroupName(tt.parameterGroup)
+			}
+
+			current, err := f.FindDBClusterInstance()
+			if err != nil && !IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if got := changedFields(f.Diff(current)); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %v, want %v", got, tt.changes)
+			}
+
+			if _, err := f.UpdateOrCreateDBClusterInstance(); err != nil {
+				t.Fatal(err)
+			}
+
+			current, err = f.FindDBClusterInstance()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changes := f.Diff(current); len(changes) > 0 {
+				t.Errorf("changes left after applying: %v", changes)
+			}
+		})
+	}
+}
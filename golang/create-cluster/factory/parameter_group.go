@@ -0,0 +1,400 @@
+package factory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxParametersPerCall is the most parameters RDS accepts in one
+	// Modify*ParameterGroup call.
+	maxParametersPerCall = 20
+
+	applyTypeStatic              = "static"
+	parameterStatusPendingReboot = "pending-reboot"
+)
+
+type ParameterGroupInput struct {
+	Name        string
+	Family      string
+	Description string
+	Parameters  map[string]string
+}
+
+// parameterGroup holds the settings shared by cluster and instance parameter
+// groups. Parameters that are not listed are left alone.
+type parameterGroup struct {
+	name        *string
+	family      *string
+	description *string
+	parameters  map[string]string
+}
+
+func newParameterGroup(input ParameterGroupInput) parameterGroup {
+	return parameterGroup{
+		name:        aws.String(input.Name),
+		family:      aws.String(input.Family),
+		description: aws.String(input.Description),
+		parameters:  input.Parameters,
+	}
+}
+
+// diff lists the differences with an existing group, or everything that
+// would be set on create when exists is false. The family and description of
+// an existing group can't be changed, so a mismatch is only logged.
+func (g parameterGroup) diff(
+	nameField string, exists bool, family, description *string, parameters []*rds.Parameter,
+) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	if !exists {
+		changes = diffString(changes, nameField, nil, g.name)
+		changes = diffString(changes, fieldDBParameterGroupFamily, nil, g.family)
+		changes = diffString(changes, fieldDescription, nil, g.description)
+		return diffParameters(changes, nil, g.parameters)
+	}
+
+	warnImmutable(*g.name, fieldDBParameterGroupFamily, family, g.family)
+	warnImmutable(*g.name, fieldDescription, description, g.description)
+
+	return diffParameters(changes, parameters, g.parameters)
+}
+
+// DBClusterParameterGroupFactory reconciles a custom cluster parameter group.
+type DBClusterParameterGroupFactory struct {
+	parameterGroup
+}
+
+func NewDBClusterParameterGroupFactory(input ParameterGroupInput) *DBClusterParameterGroupFactory {
+	return &DBClusterParameterGroupFactory{newParameterGroup(input)}
+}
+
+// FindDBClusterParameterGroup describes the group, returning a KindNotFound
+// error when it does not exist yet.
+func (f *DBClusterParameterGroupFactory) FindDBClusterParameterGroup(
+	svc rdsiface.RDSAPI,
+) (*rds.DBClusterParameterGroup, error) {
+	output, err := svc.DescribeDBClusterParameterGroups(&rds.DescribeDBClusterParameterGroupsInput{
+		DBClusterParameterGroupName: f.name,
+	})
+	if err != nil {
+		return nil, newError(*f.name, err)
+	}
+
+	return output.DBClusterParameterGroups[0], nil
+}
+
+// DescribeParameters lists every parameter in the group.
+func (f *DBClusterParameterGroupFactory) DescribeParameters(svc rdsiface.RDSAPI) ([]*rds.Parameter, error) {
+	parameters := make([]*rds.Parameter, 0)
+	input := &rds.DescribeDBClusterParametersInput{
+		DBClusterParameterGroupName: f.name,
+	}
+
+	for {
+		output, err := svc.DescribeDBClusterParameters(input)
+		if err != nil {
+			return nil, newError(*f.name, err)
+		}
+		parameters = append(parameters, output.Parameters...)
+
+		if aws.StringValue(output.Marker) == "" {
+			return parameters, nil
+		}
+		input.Marker = output.Marker
+	}
+}
+
+// Diff lists the differences between the group and the factory settings. A
+// nil group yields everything that would be set on create.
+func (f *DBClusterParameterGroupFactory) Diff(
+	group *rds.DBClusterParameterGroup, parameters []*rds.Parameter,
+) []FieldChange {
+	if group == nil {
+		return f.diff(fieldDBClusterParameterGroupName, false, nil, nil, nil)
+	}
+
+	return f.diff(fieldDBClusterParameterGroupName, true, group.DBParameterGroupFamily, group.Description, parameters)
+}
+
+func (f *DBClusterParameterGroupFactory) CreateDBClusterParameterGroup(
+	svc rdsiface.RDSAPI,
+) (*rds.DBClusterParameterGroup, error) {
+	output, err := svc.CreateDBClusterParameterGroup(&rds.CreateDBClusterParameterGroupInput{
+		DBClusterParameterGroupName: f.name,
+		DBParameterGroupFamily:      f.family,
+		Description:                 f.description,
+	})
+	if err != nil {
+		return nil, newError(*f.name, err)
+	}
+
+	if len(f.parameters) == 0 {
+		return output.DBClusterParameterGroup, nil
+	}
+
+	// A new group starts with the family defaults, so every listed
+	// parameter is set.
+	err = f.ModifyDBClusterParameterGroup(svc, diffParameters(nil, nil, f.parameters))
+	if err != nil {
+		return nil, err
+	}
+
+	return output.DBClusterParameterGroup, nil
+}
+
+// ModifyDBClusterParameterGroup sets the parameters named in changes. Static
+// parameters only take effect after the instances are rebooted.
+func (f *DBClusterParameterGroupFactory) ModifyDBClusterParameterGroup(
+	svc rdsiface.RDSAPI, changes []FieldChange,
+) error {
+	current, err := f.DescribeParameters(svc)
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range parameterUpdates(current, f.parameters, changes) {
+		_, err = svc.ModifyDBClusterParameterGroup(&rds.ModifyDBClusterParameterGroupInput{
+			DBClusterParameterGroupName: f.name,
+			Parameters:                  batch,
+		})
+		if err != nil {
+			return newError(*f.name, err)
+		}
+	}
+
+	return nil
+}
+
+// DBParameterGroupFactory reconciles a custom DB parameter group, which holds
+// the instance level settings of cluster members.
+type DBParameterGroupFactory struct {
+	parameterGroup
+}
+
+func NewDBParameterGroupFactory(input ParameterGroupInput) *DBParameterGroupFactory {
+	return &DBParameterGroupFactory{newParameterGroup(input)}
+}
+
+// FindDBParameterGroup describes the group, returning a KindNotFound error
+// when it does not exist yet.
+func (f *DBParameterGroupFactory) FindDBParameterGroup(svc rdsiface.RDSAPI) (*rds.DBParameterGroup, error) {
+	output, err := svc.DescribeDBParameterGroups(&rds.DescribeDBParameterGroupsInput{
+		DBParameterGroupName: f.name,
+	})
+	if err != nil {
+		return nil, newError(*f.name, err)
+	}
+
+	return output.DBParameterGroups[0], nil
+}
+
+// DescribeParameters lists every parameter in the group.
+func (f *DBParameterGroupFactory) DescribeParameters(svc rdsiface.RDSAPI) ([]*rds.Parameter, error) {
+	parameters := make([]*rds.Parameter, 0)
+	input := &rds.DescribeDBParametersInput{
+		DBParameterGroupName: f.name,
+	}
+
+	for {
+		output, err := svc.DescribeDBParameters(input)
+		if err != nil {
+			return nil, newError(*f.name, err)
+		}
+		parameters = append(parameters, output.Parameters...)
+
+		if aws.StringValue(output.Marker) == "" {
+			return parameters, nil
+		}
+		input.Marker = output.Marker
+	}
+}
+
+// Diff lists the differences between the group and the factory settings. A
+// nil group yields everything that would be set on create.
+func (f *DBParameterGroupFactory) Diff(group *rds.DBParameterGroup, parameters []*rds.Parameter) []FieldChange {
+	if group == nil {
+		return f.diff(fieldDBParameterGroupName, false, nil, nil, nil)
+	}
+
+	return f.diff(fieldDBParameterGroupName, true, group.DBParameterGroupFamily, group.Description, parameters)
+}
+
+func (f *DBParameterGroupFactory) CreateDBParameterGroup(svc rdsiface.RDSAPI) (*rds.DBParameterGroup, error) {
+	output, err := svc.CreateDBParameterGroup(&rds.CreateDBParameterGroupInput{
+		DBParameterGroupName:   f.name,
+		DBParameterGroupFamily: f.family,
+		Description:            f.description,
+	})
+	if err != nil {
+		return nil, newError(*f.name, err)
+	}
+
+	if len(f.parameters) == 0 {
+		return output.DBParameterGroup, nil
+	}
+
+	err = f.ModifyDBParameterGroup(svc, diffParameters(nil, nil, f.parameters))
+	if err != nil {
+		return nil, err
+	}
+
+	return output.DBParameterGroup, nil
+}
+
+// ModifyDBParameterGroup sets the parameters named in changes. Static
+// parameters only take effect after the instances using the group are
+// rebooted.
+func (f *DBParameterGroupFactory) ModifyDBParameterGroup(svc rdsiface.RDSAPI, changes []FieldChange) error {
+	current, err := f.DescribeParameters(svc)
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range parameterUpdates(current, f.parameters, changes) {
+		_, err = svc.ModifyDBParameterGroup(&rds.ModifyDBParameterGroupInput{
+			DBParameterGroupName: f.name,
+			Parameters:           batch,
+		})
+		if err != nil {
+			return newError(*f.name, err)
+		}
+	}
+
+	return nil
+}
+
+// InstancePendingReboot reports whether instance has DB parameter group
+// changes that wait for a reboot.
+func InstancePendingReboot(instance *rds.DBInstance) bool {
+	for _, g := range instance.DBParameterGroups {
+		if aws.StringValue(g.ParameterApplyStatus) == parameterStatusPendingReboot {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PendingRebootMembers returns the members of dbCluster that still have to be
+// rebooted to pick up cluster parameter changes, or DB parameter changes for
+// any of the described instances, readers before the writer.
+func PendingRebootMembers(dbCluster *rds.DBCluster, instances []*rds.DBInstance) []string {
+	pending := map[string]bool{}
+	for _, i := range instances {
+		pending[aws.StringValue(i.DBInstanceIdentifier)] = InstancePendingReboot(i)
+	}
+
+	readers, writer := make([]string, 0), make([]string, 0)
+	for _, m := range dbCluster.DBClusterMembers {
+		if aws.StringValue(m.DBClusterParameterGroupStatus) != parameterStatusPendingReboot &&
+			!pending[aws.StringValue(m.DBInstanceIdentifier)] {
+			continue
+		}
+		if aws.BoolValue(m.IsClusterWriter) {
+			writer = append(writer, aws.StringValue(m.DBInstanceIdentifier))
+		} else {
+			readers = append(readers, aws.StringValue(m.DBInstanceIdentifier))
+		}
+	}
+	sort.Strings(readers)
+
+	return append(readers, writer...)
+}
+
+func RebootDBInstance(svc rdsiface.RDSAPI, instanceIdentifier string) (*rds.DBInstance, error) {
+	output, err := svc.RebootDBInstance(&rds.RebootDBInstanceInput{
+		DBInstanceIdentifier: aws.String(instanceIdentifier),
+	})
+	if err != nil {
+		return nil, newError(instanceIdentifier, err)
+	}
+
+	return output.DBInstance, nil
+}
+
+func parameterField(name string) string {
+	return fieldParametersPrefix + name
+}
+
+// diffParameters appends a change for every desired parameter whose current
+// value differs, in name order.
+func diffParameters(changes []FieldChange, current []*rds.Parameter, desired map[string]string) []FieldChange {
+	values := map[string]*string{}
+	for _, p := range current {
+		values[aws.StringValue(p.ParameterName)] = p.ParameterValue
+	}
+
+	names := make([]string, 0)
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		from, to := values[name], desired[name]
+		if from != nil && *from == to {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: parameterField(name), From: aws.StringValue(from), To: to})
+	}
+
+	return changes
+}
+
+// parameterUpdates builds the parameters to send for changes, split into
+// batches RDS accepts. Static parameters are applied on the next reboot,
+// dynamic ones immediately.
+func parameterUpdates(current []*rds.Parameter, desired map[string]string, changes []FieldChange) [][]*rds.Parameter {
+	applyTypes := map[string]string{}
+	for _, p := range current {
+		applyTypes[aws.StringValue(p.ParameterName)] = aws.StringValue(p.ApplyType)
+	}
+
+	batches := make([][]*rds.Parameter, 0)
+	batch := make([]*rds.Parameter, 0)
+	for _, c := range changes {
+		if !strings.HasPrefix(c.Field, fieldParametersPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(c.Field, fieldParametersPrefix)
+
+		method := rds.ApplyMethodImmediate
+		if applyTypes[name] == applyTypeStatic {
+			method = rds.ApplyMethodPendingReboot
+		}
+		batch = append(batch, &rds.Parameter{
+			ParameterName:  aws.String(name),
+			ParameterValue: aws.String(desired[name]),
+			ApplyMethod:    aws.String(method),
+		})
+
+		if len(batch) == maxParametersPerCall {
+			batches = append(batches, batch)
+			batch = make([]*rds.Parameter, 0)
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// warnImmutable logs an attribute that differs but can't be changed without
+// replacing the resource.
+func warnImmutable(identifier, field string, current, desired *string) {
+	if desired == nil || *desired == "" || current == nil || *current == *desired {
+		return
+	}
+
+	log.Warnf(
+		"%s has %s %q, not %q; it can't be changed on an existing resource",
+		identifier, field, *current, *desired,
+	)
+}
@@ -0,0 +1,212 @@
+package factory
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+func TestDBClusterParameterGroupFactory(t *testing.T) {
+	svc := fakerds.New()
+	input := ParameterGroupInput{
+		Name:        "experiments",
+		Family:      "aurora-mysql5.7",
+		Description: "experiments",
+		Parameters:  map[string]string{"time_zone": "UTC", "binlog_format": "ROW"},
+	}
+	f := NewDBClusterParameterGroupFactory(input)
+
+	if _, err := f.FindDBClusterParameterGroup(svc); !IsNotFound(err) {
+		t.Fatalf("err = %v, want not found", err)
+	}
+	want := []string{
+		fieldDBClusterParameterGroupName, fieldDBParameterGroupFamily, fieldDescription,
+		parameterField("binlog_format"), parameterField("time_zone"),
+	}
+	if got := changedFields(f.Diff(nil, nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+
+	if _, err := f.CreateDBClusterParameterGroup(svc); err != nil {
+		t.Fatal(err)
+	}
+	group, err := f.FindDBClusterParameterGroup(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameters, err := f.DescribeParameters(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := f.Diff(group, parameters); len(changes) > 0 {
+		t.Errorf("changes left after creating: %v", changes)
+	}
+
+	cluster := NewDBClusterFactoryInput{
+		ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12", ParameterGroupName: "experiments",
+	}
+	if _, err := NewDBClusterFactory(cluster).CreateDBCluster(svc); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"experiments-0", "experiments-1"} {
+		f := (&DBInstanceFactory{}).SetSvc(svc).
+			SetInstanceIdentifier(id).
+			SetClusterIdentifier("experiments").
+			SetEngine("aurora-mysql").
+			SetInstanceClass("db.r5.large")
+		if _, err := f.CreateDBClusterInstance(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// binlog_format is static, so the members have to be rebooted.
+	input.Parameters = map[string]string{"time_zone": "UTC", "binlog_format": "MIXED"}
+	f = NewDBClusterParameterGroupFactory(input)
+	changes := f.Diff(group, parameters)
+	want = []string{parameterField("binlog_format")}
+	if got := changedFields(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if err := f.ModifyDBClusterParameterGroup(svc, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	dbCluster, err := findDBCluster(svc, aws.String("experiments"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"experiments-1", "experiments-0"}
+	if got := PendingRebootMembers(dbCluster, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("pending reboot = %v, want the reader before the writer %v", got, want)
+	}
+
+	for _, id := range want {
+		// Instances are available after they are described once.
+		if _, err := findDBClusterInstance(svc, aws.String(id)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RebootDBInstance(svc, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbCluster, err = findDBCluster(svc, aws.String("experiments"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := PendingRebootMembers(dbCluster, nil); len(got) > 0 {
+		t.Errorf("pending reboot after rebooting = %v", got)
+	}
+}
+
+func TestDBParameterGroupFactory(t *testing.T) {
+	svc := fakerds.New()
+	input := ParameterGroupInput{
+		Name:        "experiments-instances",
+		Family:      "aurora-mysql5.7",
+		Description: "experiments instances",
+		Parameters:  map[string]string{"slow_query_log": "1"},
+	}
+	f := NewDBParameterGroupFactory(input)
+
+	if _, err := f.FindDBParameterGroup(svc); !IsNotFound(err) {
+		t.Fatalf("err = %v, want not found", err)
+	}
+	if _, err := f.CreateDBParameterGroup(svc); err != nil {
+		t.Fatal(err)
+	}
+	group, err := f.FindDBParameterGroup(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parameters, err := f.DescribeParameters(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := f.Diff(group, parameters); len(changes) > 0 {
+		t.Errorf("changes left after creating: %v", changes)
+	}
+
+	cluster := NewDBClusterFactoryInput{ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12"}
+	if _, err := NewDBClusterFactory(cluster).CreateDBCluster(svc); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"experiments-0", "experiments-1"} {
+		f := (&DBInstanceFactory{}).SetSvc(svc).
+			SetInstanceIdentifier(id).
+			SetClusterIdentifier("experiments").
+			SetEngine("aurora-mysql").
+			SetInstanceClass("db.r5.large")
+		if id == "experiments-1" {
+			f.SetDBParameterGroupName("experiments-instances")
+		}
+		if _, err := f.CreateDBClusterInstance(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// performance_schema is static, so only the instance using the group
+	// has to be rebooted.
+	input.Parameters = map[string]string{"slow_query_log": "1", "performance_schema": "1"}
+	f = NewDBParameterGroupFactory(input)
+	changes := f.Diff(group, parameters)
+	if got, want := changedFields(changes), []string{parameterField("performance_schema")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	if err := f.ModifyDBParameterGroup(svc, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	dbCluster, err := findDBCluster(svc, aws.String("experiments"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := make([]*rds.DBInstance, 0)
+	for _, m := range dbCluster.DBClusterMembers {
+		instance, err := findDBClusterInstance(svc, m.DBInstanceIdentifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instances = append(instances, instance)
+	}
+	if got, want := PendingRebootMembers(dbCluster, instances), []string{"experiments-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pending reboot = %v, want %v", got, want)
+	}
+}
+
+func TestParameterUpdates(t *testing.T) {
+	current := []*rds.Parameter{
+		{ParameterName: aws.String("binlog_format"), ApplyType: aws.String(applyTypeStatic)},
+		{ParameterName: aws.String("time_zone"), ApplyType: aws.String("dynamic")},
+	}
+	desired := map[string]string{"binlog_format": "ROW", "time_zone": "UTC"}
+	for n := 0; n < 23; n++ {
+		desired[fmt.Sprintf("p%02d", n)] = "1"
+	}
+
+	batches := parameterUpdates(current, desired, diffParameters(nil, current, desired))
+	if len(batches) != 2 || len(batches[0]) != maxParametersPerCall || len(batches[1]) != 5 {
+		t.Fatalf("got %d batches, want %d parameters split into 20 and 5", len(batches), len(desired))
+	}
+
+	methods := map[string]string{}
+	for _, batch := range batches {
+		for _, p := range batch {
+			methods[aws.StringValue(p.ParameterName)] = aws.StringValue(p.ApplyMethod)
+		}
+	}
+	if m := methods["binlog_format"]; m != rds.ApplyMethodPendingReboot {
+		t.Errorf("static parameter applied with %s, want %s", m, rds.ApplyMethodPendingReboot)
+	}
+	if m := methods["time_zone"]; m != rds.ApplyMethodImmediate {
+		t.Errorf("dynamic parameter applied with %s, want %s", m, rds.ApplyMethodImmediate)
+	}
+
+	// Changes to other fields are not parameters.
+	if batches := parameterUpdates(current, desired, []FieldChange{{Field: fieldEngineVersion}}); len(batches) != 0 {
+		t.Errorf("batches for a non-parameter change = %v", batches)
+	}
+}
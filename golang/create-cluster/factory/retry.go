@@ -0,0 +1,637 @@
+package factory
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+const statusAvailable = "available"
+
+// RetryPolicy controls how RDS calls that fail because of throttling or
+// because the resource is busy are retried.
+type RetryPolicy struct {
+	// InitialDelay is the backoff before the first retry. It doubles with
+	// every attempt up to MaxDelay, and a random part of up to half of it
+	// is taken off so concurrent callers spread out.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+
+	// Timeout bounds the time spent on one call, including any time spent
+	// waiting for the resource to become available. Zero disables retries.
+	Timeout time.Duration
+}
+
+var (
+	jitterMu sync.Mutex
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialDelay
+	for n := 0; n < attempt && d < p.MaxDelay; n++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+
+	return d - time.Duration(jitter.Int63n(half+1))
+}
+
+// sleepUntil sleeps for d, or until deadline if that comes first, and
+// reports whether there is time left afterwards.
+func sleepUntil(d time.Duration, deadline time.Time) bool {
+	if left := time.Until(deadline); left < d {
+		d = left
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+
+	return time.Now().Before(deadline)
+}
+
+// retryRDS retries the calls made by this package according to policy.
+// Everything else is passed through to the embedded client.
+type retryRDS struct {
+	rdsiface.RDSAPI
+	policy RetryPolicy
+}
+
+// WithRetry wraps svc so the RDS calls made by the factories and waiters are
+// retried with exponential backoff. Throttling is retried for every call.
+// Mutating calls rejected because the cluster or instance is in a state
+// such as modifying or backing-up first wait for it to become available
+// again and are then retried.
+func WithRetry(svc rdsiface.RDSAPI, policy RetryPolicy) rdsiface.RDSAPI {
+	if policy.Timeout <= 0 {
+		return svc
+	}
+
+	return &retryRDS{RDSAPI: svc, policy: policy}
+}
+
+// do runs call until it succeeds, fails with an error that is not worth
+// retrying or the policy timeout passes. ready is called after a state
+// conflict to wait for the resource; calls without one only retry
+// throttling.
+func (r *retryRDS) do(operation, resource string, call func() error, ready func(deadline time.Time)) error {
+	deadline := time.Now().Add(r.policy.Timeout)
+
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		code, kind := classifyAWSError(err)
+		if kind != KindThrottled && (kind != KindInvalidState || ready == nil) {
+			return err
+		}
+
+		delay := r.policy.backoff(attempt)
+		if time.Now().Add(delay).After(deadline) {
+			log.Warnf("%s %s: giving up after %d attempts", operation, resource, attempt+1)
+			return err
+		}
+
+		log.Infof("%s %s: %s, retrying in %s", operation, resource, code, delay)
+		time.Sleep(delay)
+
+		if kind == KindInvalidState {
+			ready(deadline)
+		}
+	}
+}
+
+// waitAvailable polls status until it returns available, a describe error
+// other than throttling occurs or deadline passes. The call that follows
+// reports whatever is still wrong.
+func (r *retryRDS) waitAvailable(resource string, status func() (string, error), deadline time.Time) {
+	for attempt := 0; ; attempt++ {
+		s, err := status()
+		if err == nil && s == statusAvailable {
+			return
+		}
+		if err != nil {
+			if _, kind := classifyAWSError(err); kind != KindThrottled {
+				return
+			}
+		} else {
+			log.Infof("waiting for %s to become available: status %s", resource, s)
+		}
+
+		if !sleepUntil(r.policy.backoff(attempt), deadline) {
+			return
+		}
+	}
+}
+
+func (r *retryRDS) waitClusterAvailable(clusterIdentifier *string, deadline time.Time) {
+	r.waitAvailable(aws.StringValue(clusterIdentifier), func() (string, error) {
+		output, err := r.RDSAPI.DescribeDBClusters(&rds.DescribeDBClustersInput{
+			DBClusterIdentifier: clusterIdentifier,
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(output.DBClusters[0].Status), nil
+	}, deadline)
+}
+
+func (r *retryRDS) waitGlobalClusterAvailable(globalClusterIdentifier *string, deadline time.Time) {
+	r.waitAvailable(aws.StringValue(globalClusterIdentifier), func() (string, error) {
+		output, err := r.RDSAPI.DescribeGlobalClusters(&rds.DescribeGlobalClustersInput{
+			GlobalClusterIdentifier: globalClusterIdentifier,
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(output.GlobalClusters[0].Status), nil
+	}, deadline)
+}
+
+// waitInstanceAvailable waits for the instance and then for its cluster,
+// since either being busy can block a change to the instance.
+func (r *retryRDS) waitInstanceAvailable(instanceIdentifier *string, deadline time.Time) {
+	var clusterIdentifier *string
+
+	r.waitAvailable(aws.StringValue(instanceIdentifier), func() (string, error) {
+		output, err := r.RDSAPI.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: instanceIdentifier,
+		})
+		if err != nil {
+			return "", err
+		}
+		clusterIdentifier = output.DBInstances[0].DBClusterIdentifier
+		return aws.StringValue(output.DBInstances[0].DBInstanceStatus), nil
+	}, deadline)
+
+	if clusterIdentifier != nil {
+		r.waitClusterAvailable(clusterIdentifier, deadline)
+	}
+}
+
+func (r *retryRDS) DescribeDBSubnetGroups(input *rds.DescribeDBSubnetGroupsInput) (*rds.DescribeDBSubnetGroupsOutput, error) {
+	var output *rds.DescribeDBSubnetGroupsOutput
+	err := r.do("DescribeDBSubnetGroups", aws.StringValue(input.DBSubnetGroupName), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBSubnetGroups(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBSubnetGroup(input *rds.CreateDBSubnetGroupInput) (*rds.CreateDBSubnetGroupOutput, error) {
+	var output *rds.CreateDBSubnetGroupOutput
+	err := r.do("CreateDBSubnetGroup", aws.StringValue(input.DBSubnetGroupName), func() (err error) {
+		output, err = r.RDSAPI.CreateDBSubnetGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) ModifyDBSubnetGroup(input *rds.ModifyDBSubnetGroupInput) (*rds.ModifyDBSubnetGroupOutput, error) {
+	var output *rds.ModifyDBSubnetGroupOutput
+	err := r.do("ModifyDBSubnetGroup", aws.StringValue(input.DBSubnetGroupName), func() (err error) {
+		output, err = r.RDSAPI.ModifyDBSubnetGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBSubnetGroup(input *rds.DeleteDBSubnetGroupInput) (*rds.DeleteDBSubnetGroupOutput, error) {
+	var output *rds.DeleteDBSubnetGroupOutput
+	err := r.do("DeleteDBSubnetGroup", aws.StringValue(input.DBSubnetGroupName), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBSubnetGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBClusterParameterGroups(
+	input *rds.DescribeDBClusterParameterGroupsInput,
+) (*rds.DescribeDBClusterParameterGroupsOutput, error) {
+	var output *rds.DescribeDBClusterParameterGroupsOutput
+	err := r.do("DescribeDBClusterParameterGroups", aws.StringValue(input.DBClusterParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBClusterParameterGroups(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBClusterParameters(
+	input *rds.DescribeDBClusterParametersInput,
+) (*rds.DescribeDBClusterParametersOutput, error) {
+	var output *rds.DescribeDBClusterParametersOutput
+	err := r.do("DescribeDBClusterParameters", aws.StringValue(input.DBClusterParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBClusterParameters(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBClusterParameterGroup(
+	input *rds.CreateDBClusterParameterGroupInput,
+) (*rds.CreateDBClusterParameterGroupOutput, error) {
+	var output *rds.CreateDBClusterParameterGroupOutput
+	err := r.do("CreateDBClusterParameterGroup", aws.StringValue(input.DBClusterParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.CreateDBClusterParameterGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+// ModifyDBClusterParameterGroup is retried after a plain backoff when the
+// group is still applying an earlier change.
+func (r *retryRDS) ModifyDBClusterParameterGroup(
+	input *rds.ModifyDBClusterParameterGroupInput,
+) (*rds.DBClusterParameterGroupNameMessage, error) {
+	var output *rds.DBClusterParameterGroupNameMessage
+	err := r.do("ModifyDBClusterParameterGroup", aws.StringValue(input.DBClusterParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.ModifyDBClusterParameterGroup(input)
+		return err
+	}, func(time.Time) {})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBClusterParameterGroup(
+	input *rds.DeleteDBClusterParameterGroupInput,
+) (*rds.DeleteDBClusterParameterGroupOutput, error) {
+	var output *rds.DeleteDBClusterParameterGroupOutput
+	err := r.do("DeleteDBClusterParameterGroup", aws.StringValue(input.DBClusterParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBClusterParameterGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBParameterGroups(
+	input *rds.DescribeDBParameterGroupsInput,
+) (*rds.DescribeDBParameterGroupsOutput, error) {
+	var output *rds.DescribeDBParameterGroupsOutput
+	err := r.do("DescribeDBParameterGroups", aws.StringValue(input.DBParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBParameterGroups(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBParameters(input *rds.DescribeDBParametersInput) (*rds.DescribeDBParametersOutput, error) {
+	var output *rds.DescribeDBParametersOutput
+	err := r.do("DescribeDBParameters", aws.StringValue(input.DBParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBParameters(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBParameterGroup(
+	input *rds.CreateDBParameterGroupInput,
+) (*rds.CreateDBParameterGroupOutput, error) {
+	var output *rds.CreateDBParameterGroupOutput
+	err := r.do("CreateDBParameterGroup", aws.StringValue(input.DBParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.CreateDBParameterGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+// ModifyDBParameterGroup is retried after a plain backoff, like its cluster
+// counterpart.
+func (r *retryRDS) ModifyDBParameterGroup(
+	input *rds.ModifyDBParameterGroupInput,
+) (*rds.DBParameterGroupNameMessage, error) {
+	var output *rds.DBParameterGroupNameMessage
+	err := r.do("ModifyDBParameterGroup", aws.StringValue(input.DBParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.ModifyDBParameterGroup(input)
+		return err
+	}, func(time.Time) {})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBParameterGroup(
+	input *rds.DeleteDBParameterGroupInput,
+) (*rds.DeleteDBParameterGroupOutput, error) {
+	var output *rds.DeleteDBParameterGroupOutput
+	err := r.do("DeleteDBParameterGroup", aws.StringValue(input.DBParameterGroupName), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBParameterGroup(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBEngineVersions(
+	input *rds.DescribeDBEngineVersionsInput,
+) (*rds.DescribeDBEngineVersionsOutput, error) {
+	var output *rds.DescribeDBEngineVersionsOutput
+	err := r.do("DescribeDBEngineVersions", aws.StringValue(input.EngineVersion), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBEngineVersions(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeOrderableDBInstanceOptions(
+	input *rds.DescribeOrderableDBInstanceOptionsInput,
+) (*rds.DescribeOrderableDBInstanceOptionsOutput, error) {
+	var output *rds.DescribeOrderableDBInstanceOptionsOutput
+	err := r.do("DescribeOrderableDBInstanceOptions", aws.StringValue(input.Engine), func() (err error) {
+		output, err = r.RDSAPI.DescribeOrderableDBInstanceOptions(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	var output *rds.DescribeDBClustersOutput
+	err := r.do("DescribeDBClusters", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBClusters(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBCluster(input *rds.CreateDBClusterInput) (*rds.CreateDBClusterOutput, error) {
+	var output *rds.CreateDBClusterOutput
+	err := r.do("CreateDBCluster", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.CreateDBCluster(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) ModifyDBCluster(input *rds.ModifyDBClusterInput) (*rds.ModifyDBClusterOutput, error) {
+	var output *rds.ModifyDBClusterOutput
+	err := r.do("ModifyDBCluster", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.ModifyDBCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.DBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBCluster(input *rds.DeleteDBClusterInput) (*rds.DeleteDBClusterOutput, error) {
+	var output *rds.DeleteDBClusterOutput
+	err := r.do("DeleteDBCluster", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.DBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) FailoverDBCluster(input *rds.FailoverDBClusterInput) (*rds.FailoverDBClusterOutput, error) {
+	var output *rds.FailoverDBClusterOutput
+	err := r.do("FailoverDBCluster", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.FailoverDBCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.DBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBClusterSnapshots(
+	input *rds.DescribeDBClusterSnapshotsInput,
+) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	var output *rds.DescribeDBClusterSnapshotsOutput
+	err := r.do("DescribeDBClusterSnapshots", aws.StringValue(input.DBClusterSnapshotIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBClusterSnapshots(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBClusterSnapshot(
+	input *rds.CreateDBClusterSnapshotInput,
+) (*rds.CreateDBClusterSnapshotOutput, error) {
+	var output *rds.CreateDBClusterSnapshotOutput
+	err := r.do("CreateDBClusterSnapshot", aws.StringValue(input.DBClusterSnapshotIdentifier), func() (err error) {
+		output, err = r.RDSAPI.CreateDBClusterSnapshot(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.DBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBClusterSnapshot(
+	input *rds.DeleteDBClusterSnapshotInput,
+) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	var output *rds.DeleteDBClusterSnapshotOutput
+	err := r.do("DeleteDBClusterSnapshot", aws.StringValue(input.DBClusterSnapshotIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBClusterSnapshot(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) ListTagsForResource(input *rds.ListTagsForResourceInput) (*rds.ListTagsForResourceOutput, error) {
+	var output *rds.ListTagsForResourceOutput
+	err := r.do("ListTagsForResource", aws.StringValue(input.ResourceName), func() (err error) {
+		output, err = r.RDSAPI.ListTagsForResource(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) RestoreDBClusterFromSnapshot(
+	input *rds.RestoreDBClusterFromSnapshotInput,
+) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	var output *rds.RestoreDBClusterFromSnapshotOutput
+	err := r.do("RestoreDBClusterFromSnapshot", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.RestoreDBClusterFromSnapshot(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) RestoreDBClusterToPointInTime(
+	input *rds.RestoreDBClusterToPointInTimeInput,
+) (*rds.RestoreDBClusterToPointInTimeOutput, error) {
+	var output *rds.RestoreDBClusterToPointInTimeOutput
+	err := r.do("RestoreDBClusterToPointInTime", aws.StringValue(input.DBClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.RestoreDBClusterToPointInTime(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.SourceDBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeGlobalClusters(
+	input *rds.DescribeGlobalClustersInput,
+) (*rds.DescribeGlobalClustersOutput, error) {
+	var output *rds.DescribeGlobalClustersOutput
+	err := r.do("DescribeGlobalClusters", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DescribeGlobalClusters(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateGlobalCluster(input *rds.CreateGlobalClusterInput) (*rds.CreateGlobalClusterOutput, error) {
+	var output *rds.CreateGlobalClusterOutput
+	err := r.do("CreateGlobalCluster", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.CreateGlobalCluster(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) RemoveFromGlobalCluster(
+	input *rds.RemoveFromGlobalClusterInput,
+) (*rds.RemoveFromGlobalClusterOutput, error) {
+	var output *rds.RemoveFromGlobalClusterOutput
+	err := r.do("RemoveFromGlobalCluster", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.RemoveFromGlobalCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitGlobalClusterAvailable(input.GlobalClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) SwitchoverGlobalCluster(
+	input *rds.SwitchoverGlobalClusterInput,
+) (*rds.SwitchoverGlobalClusterOutput, error) {
+	var output *rds.SwitchoverGlobalClusterOutput
+	err := r.do("SwitchoverGlobalCluster", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.SwitchoverGlobalCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitGlobalClusterAvailable(input.GlobalClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) FailoverGlobalCluster(
+	input *rds.FailoverGlobalClusterInput,
+) (*rds.FailoverGlobalClusterOutput, error) {
+	var output *rds.FailoverGlobalClusterOutput
+	err := r.do("FailoverGlobalCluster", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.FailoverGlobalCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitGlobalClusterAvailable(input.GlobalClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteGlobalCluster(input *rds.DeleteGlobalClusterInput) (*rds.DeleteGlobalClusterOutput, error) {
+	var output *rds.DeleteGlobalClusterOutput
+	err := r.do("DeleteGlobalCluster", aws.StringValue(input.GlobalClusterIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DeleteGlobalCluster(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitGlobalClusterAvailable(input.GlobalClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	var output *rds.DescribeDBInstancesOutput
+	err := r.do("DescribeDBInstances", aws.StringValue(input.DBInstanceIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DescribeDBInstances(input)
+		return err
+	}, nil)
+
+	return output, err
+}
+
+func (r *retryRDS) CreateDBInstance(input *rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	var output *rds.CreateDBInstanceOutput
+	err := r.do("CreateDBInstance", aws.StringValue(input.DBInstanceIdentifier), func() (err error) {
+		output, err = r.RDSAPI.CreateDBInstance(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitClusterAvailable(input.DBClusterIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	var output *rds.ModifyDBInstanceOutput
+	err := r.do("ModifyDBInstance", aws.StringValue(input.DBInstanceIdentifier), func() (err error) {
+		output, err = r.RDSAPI.ModifyDBInstance(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitInstanceAvailable(input.DBInstanceIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) RebootDBInstance(input *rds.RebootDBInstanceInput) (*rds.RebootDBInstanceOutput, error) {
+	var output *rds.RebootDBInstanceOutput
+	err := r.do("RebootDBInstance", aws.StringValue(input.DBInstanceIdentifier), func() (err error) {
+		output, err = r.RDSAPI.RebootDBInstance(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitInstanceAvailable(input.DBInstanceIdentifier, deadline)
+	})
+
+	return output, err
+}
+
+func (r *retryRDS) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	var output *rds.DeleteDBInstanceOutput
+	err := r.do("DeleteDBInstance", aws.StringValue(input.DBInstanceIdentifier), func() (err error) {
+		output, err = r.RDSAPI.DeleteDBInstance(input)
+		return err
+	}, func(deadline time.Time) {
+		r.waitInstanceAvailable(input.DBInstanceIdentifier, deadline)
+	})
+
+	return output, err
+}
@@ -0,0 +1,113 @@
+package factory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+// testRetryPolicy retries quickly enough to keep the tests fast.
+var testRetryPolicy = RetryPolicy{
+	InitialDelay: time.Millisecond,
+	MaxDelay:     4 * time.Millisecond,
+	Timeout:      time.Second,
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: 100 * time.Millisecond},
+		{attempt: 1, max: 200 * time.Millisecond},
+		{attempt: 3, max: 800 * time.Millisecond},
+		{attempt: 4, max: time.Second},
+		{attempt: 20, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		for n := 0; n < 10; n++ {
+			if d := policy.backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	svc := fakerds.New()
+	if WithRetry(svc, RetryPolicy{}) != svc {
+		t.Error("a policy without a timeout wraps the client")
+	}
+}
+
+func TestRetryThrottling(t *testing.T) {
+	svc := fakerds.New()
+	svc.InjectFault("DescribeDBSubnetGroups", "Throttling", 3)
+
+	_, err := FindDBSubnetGroup(WithRetry(svc, testRetryPolicy), "experiments")
+	if !IsNotFound(err) {
+		t.Errorf("err = %v, want the not found error after the throttling passed", err)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		// policy is the retry policy; the call must fail with code
+		// anyway.
+		policy RetryPolicy
+	}{
+		{
+			name:   "timeout",
+			code:   "Throttling",
+			policy: RetryPolicy{InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, Timeout: 20 * time.Millisecond},
+		},
+		{name: "not retryable", code: rds.ErrCodeStorageQuotaExceededFault, policy: testRetryPolicy},
+		// Describe calls have no resource to wait for.
+		{name: "state conflict on describe", code: rds.ErrCodeInvalidDBClusterStateFault, policy: testRetryPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			svc.InjectFault("DescribeDBClusters", tt.code, 1000)
+
+			_, err := WithRetry(svc, tt.policy).DescribeDBClusters(&rds.DescribeDBClustersInput{})
+			if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != tt.code {
+				t.Fatalf("err = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestRetryWaitsForAvailable(t *testing.T) {
+	svc := fakerds.New()
+	svc.TransitionDescribes = 3
+	input := NewDBClusterFactoryInput{ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12"}
+	dbCluster, err := NewDBClusterFactory(input).CreateDBCluster(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cluster is still creating, so RDS rejects the change until the
+	// retry has waited for it.
+	output, err := WithRetry(svc, testRetryPolicy).ModifyDBCluster(&rds.ModifyDBClusterInput{
+		ApplyImmediately:    aws.Bool(true),
+		DBClusterIdentifier: dbCluster.DBClusterIdentifier,
+		EngineVersion:       aws.String("5.7.mysql_aurora.2.04.0"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := aws.StringValue(output.DBCluster.EngineVersion); v != "5.7.mysql_aurora.2.04.0" {
+		t.Errorf("engine version = %s, want 5.7.mysql_aurora.2.04.0", v)
+	}
+}
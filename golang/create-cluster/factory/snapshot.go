@@ -0,0 +1,125 @@
+package factory
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+const (
+	snapshotTypeManual = "manual"
+
+	// Snapshots taken by this tool carry this tag, so pruning never touches
+	// snapshots someone took by hand.
+	createdByTagKey   = "created-by"
+	createdByTagValue = "rds-aurora-experiments"
+	// Safety snapshots, taken before a change that can't be undone, also
+	// carry this tag with the reason they were taken. Pruning keeps them.
+	purposeTagKey = "purpose"
+)
+
+// CreateDBClusterSnapshot starts a manual snapshot of a cluster, tagged as
+// created by this tool. A non-empty purpose makes it a safety snapshot. It
+// is usable once WaitForClusterSnapshotAvailable returns.
+func CreateDBClusterSnapshot(
+	svc rdsiface.RDSAPI, clusterIdentifier, snapshotIdentifier, purpose string,
+) (*rds.DBClusterSnapshot, error) {
+	tags := []*rds.Tag{{
+		Key:   aws.String(createdByTagKey),
+		Value: aws.String(createdByTagValue),
+	}}
+	if purpose != "" {
+		tags = append(tags, &rds.Tag{Key: aws.String(purposeTagKey), Value: aws.String(purpose)})
+	}
+
+	output, err := svc.CreateDBClusterSnapshot(&rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String(clusterIdentifier),
+		DBClusterSnapshotIdentifier: aws.String(snapshotIdentifier),
+		Tags:                        tags,
+	})
+	if err != nil {
+		return nil, newError(snapshotIdentifier, err)
+	}
+
+	return output.DBClusterSnapshot, nil
+}
+
+// FindDBClusterSnapshot describes a snapshot, returning a KindNotFound error
+// when it does not exist.
+func FindDBClusterSnapshot(svc rdsiface.RDSAPI, snapshotIdentifier string) (*rds.DBClusterSnapshot, error) {
+	return findDBClusterSnapshot(svc, aws.String(snapshotIdentifier))
+}
+
+func findDBClusterSnapshot(svc rdsiface.RDSAPI, snapshotIdentifier *string) (*rds.DBClusterSnapshot, error) {
+	output, err := svc.DescribeDBClusterSnapshots(&rds.DescribeDBClusterSnapshotsInput{
+		DBClusterSnapshotIdentifier: snapshotIdentifier,
+	})
+	if err != nil {
+		return nil, newError(*snapshotIdentifier, err)
+	}
+
+	return output.DBClusterSnapshots[0], nil
+}
+
+// ListDBClusterSnapshots lists the manual snapshots of a cluster, whoever
+// took them. Automated backups are left out.
+func ListDBClusterSnapshots(svc rdsiface.RDSAPI, clusterIdentifier string) ([]*rds.DBClusterSnapshot, error) {
+	snapshots := make([]*rds.DBClusterSnapshot, 0)
+	input := &rds.DescribeDBClusterSnapshotsInput{
+		DBClusterIdentifier: aws.String(clusterIdentifier),
+		SnapshotType:        aws.String(snapshotTypeManual),
+	}
+
+	for {
+		output, err := svc.DescribeDBClusterSnapshots(input)
+		if err != nil {
+			return nil, newError(clusterIdentifier, err)
+		}
+		snapshots = append(snapshots, output.DBClusterSnapshots...)
+
+		if aws.StringValue(output.Marker) == "" {
+			return snapshots, nil
+		}
+		input.Marker = output.Marker
+	}
+}
+
+// SnapshotOrigin reports whether snapshot carries the tag that
+// CreateDBClusterSnapshot sets and, for a safety snapshot, its purpose.
+// Describing a snapshot doesn't return its tags, so they are listed
+// separately.
+func SnapshotOrigin(svc rdsiface.RDSAPI, snapshot *rds.DBClusterSnapshot) (createdByTool bool, purpose string, err error) {
+	output, err := svc.ListTagsForResource(&rds.ListTagsForResourceInput{
+		ResourceName: snapshot.DBClusterSnapshotArn,
+	})
+	if err != nil {
+		return false, "", newError(aws.StringValue(snapshot.DBClusterSnapshotIdentifier), err)
+	}
+
+	for _, t := range output.TagList {
+		switch aws.StringValue(t.Key) {
+		case createdByTagKey:
+			createdByTool = aws.StringValue(t.Value) == createdByTagValue
+		case purposeTagKey:
+			purpose = aws.StringValue(t.Value)
+		}
+	}
+	if !createdByTool {
+		purpose = ""
+	}
+
+	return createdByTool, purpose, nil
+}
+
+// DeleteDBClusterSnapshot deletes a manual snapshot. The snapshot is gone
+// once WaitForClusterSnapshotDeleted returns.
+func DeleteDBClusterSnapshot(svc rdsiface.RDSAPI, snapshotIdentifier string) (*rds.DBClusterSnapshot, error) {
+	output, err := svc.DeleteDBClusterSnapshot(&rds.DeleteDBClusterSnapshotInput{
+		DBClusterSnapshotIdentifier: aws.String(snapshotIdentifier),
+	})
+	if err != nil {
+		return nil, newError(snapshotIdentifier, err)
+	}
+
+	return output.DBClusterSnapshot, nil
+}
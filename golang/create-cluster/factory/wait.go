@@ -2,95 +2,428 @@ package factory
 
 import (
 	"context"
+	"fmt"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	waitSleepTime = 10
-	requiredReady = 4
+	defaultPollInterval = 10 * time.Second
+	defaultStableCount  = 4
+
+	ResourceCluster         = "cluster"
+	ResourceInstance        = "instance"
+	ResourceClusterSnapshot = "cluster snapshot"
+	ResourceGlobalCluster   = "global cluster"
+
+	// statusRenaming is reported while a renamed resource can't be
+	// described under its new identifier yet.
+	statusRenaming = "renaming"
+
+	// statusSwitchingWriter is reported while a cluster is available but
+	// the writer hasn't moved yet.
+	statusSwitchingWriter = "switching-writer"
 )
 
-func WaitForClusterReady(ctx context.Context, svc *rds.RDS, cluster *rds.DBCluster) bool {
-	var readyCount int
+// DefaultFailureStatuses are statuses a cluster or instance does not leave
+// without someone stepping in, so waiting for them to pass is pointless.
+var DefaultFailureStatuses = []string{
+	"failed",
+	"incompatible-parameters",
+	"inaccessible-encryption-credentials",
+}
 
-	clusterIdentifier := cluster.DBClusterIdentifier
-	for {
-		select {
-		case <-ctx.Done():
-			log.Warn("context expired")
-			return false
-		default:
-			dbCluster, err := findDBCluster(svc, clusterIdentifier)
-			if err != nil {
-				if aerr, ok := err.(awserr.Error); ok {
-					switch aerr.Code() {
-					case rds.ErrCodeDBClusterNotFoundFault:
-						log.Info(rds.ErrCodeDBClusterNotFoundFault, aerr.Error())
-						return false
-					default:
-						log.Warn(aerr.Error())
-						return false
-					}
-				} else {
-					// Print the error, cast err to awserr.Error to get the Code and
-					// Message from an error.
-					log.Warn(err.Error())
-					return false
-				}
-			}
+// WaitReason says why a wait ended without reaching the target.
+type WaitReason string
 
-			if *dbCluster.Status == "available" {
-				log.Infof("cluster ready test %d/%d", readyCount+1, requiredReady)
-				readyCount++
-			} else {
-				readyCount = 0
-				log.Infof("cluster not ready: status %s", *dbCluster.Status)
-			}
+const (
+	WaitTimeout       WaitReason = "timed out"
+	WaitStatusTimeout WaitReason = "stayed in one status too long"
+	WaitFailureStatus WaitReason = "reached a failure status"
+	WaitNotFound      WaitReason = "does not exist"
+	WaitDescribeError WaitReason = "could not be described"
+)
 
-			if readyCount == requiredReady {
-				log.Info("cluster ready and stable")
-				return true
-			}
+// WaitError is returned when a wait fails. Status is the last status seen,
+// which is empty if the resource was never described successfully.
+type WaitError struct {
+	Resource   string
+	Identifier string
+	Reason     WaitReason
+	Status     string
+	Elapsed    time.Duration
+	Err        error
+}
+
+func (e *WaitError) Error() string {
+	msg := fmt.Sprintf("%s %s %s after %s", e.Resource, e.Identifier, e.Reason, e.Elapsed.Round(time.Second))
+	if e.Status != "" {
+		msg += fmt.Sprintf(" (status %s)", e.Status)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+// WaitProgress is reported each time a waited-on resource changes status,
+// and once more with Done set when the wait succeeds.
+type WaitProgress struct {
+	Resource   string
+	Identifier string
+	Previous   string
+	Status     string
+	Elapsed    time.Duration
+	Done       bool
+}
+
+// Waiter polls clusters and instances until they reach a status. The zero
+// value is not usable; start from NewWaiter.
+type Waiter struct {
+	// PollInterval is the time between describe calls.
+	PollInterval time.Duration
+
+	// StableCount is how many polls in a row must see the target status
+	// before the wait succeeds.
+	StableCount int
+
+	// StatusTimeouts bounds how long the resource may stay in a single
+	// status, for example "modifying". Unlisted statuses are bounded only
+	// by the context.
+	StatusTimeouts map[string]time.Duration
+
+	// FailureStatuses end the wait immediately.
+	FailureStatuses []string
+
+	// Progress, when set, receives status transitions. Otherwise they are
+	// logged.
+	Progress func(WaitProgress)
+}
+
+func NewWaiter() *Waiter {
+	return &Waiter{
+		PollInterval:    defaultPollInterval,
+		StableCount:     defaultStableCount,
+		StatusTimeouts:  map[string]time.Duration{},
+		FailureStatuses: DefaultFailureStatuses,
+	}
+}
+
+// WaitForClusterAvailable waits until the cluster has been available for
+// StableCount polls in a row.
+func (w *Waiter) WaitForClusterAvailable(ctx context.Context, svc rdsiface.RDSAPI, clusterIdentifier string) (*rds.DBCluster, error) {
+	var dbCluster *rds.DBCluster
+
+	err := w.wait(ctx, ResourceCluster, clusterIdentifier, false, func() (string, error) {
+		var err error
+		dbCluster, err = findDBCluster(svc, aws.String(clusterIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(dbCluster.Status), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dbCluster, nil
+}
+
+// WaitForInstanceAvailable waits until the instance has been available for
+// StableCount polls in a row.
+func (w *Waiter) WaitForInstanceAvailable(ctx context.Context, svc rdsiface.RDSAPI, instanceIdentifier string) (*rds.DBInstance, error) {
+	var instance *rds.DBInstance
+
+	err := w.wait(ctx, ResourceInstance, instanceIdentifier, false, func() (string, error) {
+		var err error
+		instance, err = findDBClusterInstance(svc, aws.String(instanceIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(instance.DBInstanceStatus), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// WaitForClusterRenamed waits until the cluster can be described under its
+// new identifier and has been available for StableCount polls in a row.
+func (w *Waiter) WaitForClusterRenamed(ctx context.Context, svc rdsiface.RDSAPI, newIdentifier string) (*rds.DBCluster, error) {
+	var dbCluster *rds.DBCluster
+
+	err := w.wait(ctx, ResourceCluster, newIdentifier, false, func() (string, error) {
+		var err error
+		dbCluster, err = findDBCluster(svc, aws.String(newIdentifier))
+		if IsNotFound(err) {
+			return statusRenaming, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(dbCluster.Status), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dbCluster, nil
+}
+
+// WaitForInstanceRenamed is WaitForClusterRenamed for an instance.
+func (w *Waiter) WaitForInstanceRenamed(ctx context.Context, svc rdsiface.RDSAPI, newIdentifier string) (*rds.DBInstance, error) {
+	var instance *rds.DBInstance
+
+	err := w.wait(ctx, ResourceInstance, newIdentifier, false, func() (string, error) {
+		var err error
+		instance, err = findDBClusterInstance(svc, aws.String(newIdentifier))
+		if IsNotFound(err) {
+			return statusRenaming, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(instance.DBInstanceStatus), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// WaitForClusterWriter waits until the cluster is available with a writer
+// other than previousWriter, which must be writer unless writer is empty, for
+// StableCount polls in a row.
+func (w *Waiter) WaitForClusterWriter(
+	ctx context.Context, svc rdsiface.RDSAPI, clusterIdentifier, previousWriter, writer string,
+) (*rds.DBCluster, error) {
+	var dbCluster *rds.DBCluster
+
+	err := w.wait(ctx, ResourceCluster, clusterIdentifier, false, func() (string, error) {
+		var err error
+		dbCluster, err = findDBCluster(svc, aws.String(clusterIdentifier))
+		if err != nil {
+			return "", err
+		}
+
+		status := aws.StringValue(dbCluster.Status)
+		current := ClusterWriter(dbCluster)
+		if status == statusAvailable && (current == "" || current == previousWriter || (writer != "" && current != writer)) {
+			return statusSwitchingWriter, nil
+		}
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dbCluster, nil
+}
+
+// WaitForClusterSnapshotAvailable waits until the snapshot has been available
+// for StableCount polls in a row.
+func (w *Waiter) WaitForClusterSnapshotAvailable(
+	ctx context.Context, svc rdsiface.RDSAPI, snapshotIdentifier string,
+) (*rds.DBClusterSnapshot, error) {
+	var snapshot *rds.DBClusterSnapshot
+
+	err := w.wait(ctx, ResourceClusterSnapshot, snapshotIdentifier, false, func() (string, error) {
+		var err error
+		snapshot, err = findDBClusterSnapshot(svc, aws.String(snapshotIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(snapshot.Status), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// WaitForGlobalClusterAvailable waits until the global cluster has been
+// available for StableCount polls in a row.
+func (w *Waiter) WaitForGlobalClusterAvailable(
+	ctx context.Context, svc rdsiface.RDSAPI, globalClusterIdentifier string,
+) (*rds.GlobalCluster, error) {
+	var globalCluster *rds.GlobalCluster
 
-			time.Sleep(waitSleepTime * time.Second)
+	err := w.wait(ctx, ResourceGlobalCluster, globalClusterIdentifier, false, func() (string, error) {
+		var err error
+		globalCluster, err = findGlobalCluster(svc, aws.String(globalClusterIdentifier))
+		if err != nil {
+			return "", err
 		}
+		return aws.StringValue(globalCluster.Status), nil
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return globalCluster, nil
 }
 
-func WaitForInstanceReady(ctx context.Context, svc *rds.RDS, instance *rds.DBInstance) bool {
-	var readyCount int
+// WaitForGlobalClusterWriter waits until the global cluster is available
+// with the cluster with the ARN writerArn as its primary cluster, for
+// StableCount polls in a row.
+func (w *Waiter) WaitForGlobalClusterWriter(
+	ctx context.Context, svc rdsiface.RDSAPI, globalClusterIdentifier, writerArn string,
+) (*rds.GlobalCluster, error) {
+	var globalCluster *rds.GlobalCluster
+
+	err := w.wait(ctx, ResourceGlobalCluster, globalClusterIdentifier, false, func() (string, error) {
+		var err error
+		globalCluster, err = findGlobalCluster(svc, aws.String(globalClusterIdentifier))
+		if err != nil {
+			return "", err
+		}
+
+		status := aws.StringValue(globalCluster.Status)
+		if status == statusAvailable && GlobalClusterWriter(globalCluster) != writerArn {
+			return statusSwitchingWriter, nil
+		}
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return globalCluster, nil
+}
 
-	identifier := instance.DBInstanceIdentifier
+// WaitForGlobalClusterDeleted waits until describing the global cluster
+// reports that it does not exist.
+func (w *Waiter) WaitForGlobalClusterDeleted(ctx context.Context, svc rdsiface.RDSAPI, globalClusterIdentifier string) error {
+	return w.wait(ctx, ResourceGlobalCluster, globalClusterIdentifier, true, func() (string, error) {
+		globalCluster, err := findGlobalCluster(svc, aws.String(globalClusterIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(globalCluster.Status), nil
+	})
+}
+
+// WaitForClusterDeleted waits until describing the cluster reports that it
+// does not exist.
+func (w *Waiter) WaitForClusterDeleted(ctx context.Context, svc rdsiface.RDSAPI, clusterIdentifier string) error {
+	return w.wait(ctx, ResourceCluster, clusterIdentifier, true, func() (string, error) {
+		dbCluster, err := findDBCluster(svc, aws.String(clusterIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(dbCluster.Status), nil
+	})
+}
+
+// WaitForClusterSnapshotDeleted waits until describing the snapshot reports
+// that it does not exist.
+func (w *Waiter) WaitForClusterSnapshotDeleted(ctx context.Context, svc rdsiface.RDSAPI, snapshotIdentifier string) error {
+	return w.wait(ctx, ResourceClusterSnapshot, snapshotIdentifier, true, func() (string, error) {
+		snapshot, err := findDBClusterSnapshot(svc, aws.String(snapshotIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(snapshot.Status), nil
+	})
+}
+
+// WaitForInstanceDeleted waits until describing the instance reports that it
+// does not exist.
+func (w *Waiter) WaitForInstanceDeleted(ctx context.Context, svc rdsiface.RDSAPI, instanceIdentifier string) error {
+	return w.wait(ctx, ResourceInstance, instanceIdentifier, true, func() (string, error) {
+		instance, err := findDBClusterInstance(svc, aws.String(instanceIdentifier))
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(instance.DBInstanceStatus), nil
+	})
+}
+
+// wait polls status until the resource is available and stable, or gone
+// when deleted is set.
+func (w *Waiter) wait(
+	ctx context.Context, resource, identifier string, deleted bool, status func() (string, error),
+) error {
+	start := time.Now()
+	var current string
+	var since time.Time
+	var stable int
+
+	fail := func(reason WaitReason, err error) error {
+		return &WaitError{
+			Resource:   resource,
+			Identifier: identifier,
+			Reason:     reason,
+			Status:     current,
+			Elapsed:    time.Since(start),
+			Err:        err,
+		}
+	}
 
 	for {
-		select {
-		case <-ctx.Done():
-			log.Warn("context expired")
-			return false
-		default:
-			instance, err := findDBClusterInstance(svc, identifier)
-			if err != nil {
-				return false
+		s, err := status()
+		if err != nil {
+			if IsNotFound(err) {
+				if deleted {
+					w.report(WaitProgress{resource, identifier, current, "deleted", time.Since(start), true})
+					return nil
+				}
+				return fail(WaitNotFound, nil)
 			}
+			return fail(WaitDescribeError, err)
+		}
+
+		if s != current {
+			w.report(WaitProgress{resource, identifier, current, s, time.Since(start), false})
+			current, since, stable = s, time.Now(), 0
+		}
 
-			if *instance.DBInstanceStatus == "available" {
-				log.Infof("instance ready test %d/%d", readyCount+1, requiredReady)
-				readyCount++
-			} else {
-				readyCount = 0
-				log.Infof("instance not ready: status %s", *instance.DBInstanceStatus)
+		for _, f := range w.FailureStatuses {
+			if s == f {
+				return fail(WaitFailureStatus, nil)
 			}
+		}
+		if limit, ok := w.StatusTimeouts[s]; ok && time.Since(since) > limit {
+			return fail(WaitStatusTimeout, nil)
+		}
 
-			if readyCount == requiredReady {
-				log.Info("instance ready and stable")
-				return true
+		if !deleted && s == statusAvailable {
+			stable++
+			if stable >= w.StableCount {
+				w.report(WaitProgress{resource, identifier, s, s, time.Since(start), true})
+				return nil
 			}
+		}
 
-			time.Sleep(waitSleepTime * time.Second)
+		select {
+		case <-ctx.Done():
+			return fail(WaitTimeout, nil)
+		case <-time.After(w.PollInterval):
 		}
 	}
 }
+
+func (w *Waiter) report(p WaitProgress) {
+	if w.Progress != nil {
+		w.Progress(p)
+		return
+	}
+
+	switch {
+	case p.Done:
+		log.Infof("%s %s %s after %s", p.Resource, p.Identifier, p.Status, p.Elapsed.Round(time.Second))
+	case p.Previous == "":
+		log.Infof("%s %s is %s", p.Resource, p.Identifier, p.Status)
+	default:
+		log.Infof("%s %s: %s -> %s", p.Resource, p.Identifier, p.Previous, p.Status)
+	}
+}
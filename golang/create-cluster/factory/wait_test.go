@@ -0,0 +1,167 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+// testWaiter polls every millisecond and succeeds on the first available
+// status.
+func testWaiter() *Waiter {
+	w := NewWaiter()
+	w.PollInterval = time.Millisecond
+	w.StableCount = 1
+	w.Progress = func(WaitProgress) {}
+
+	return w
+}
+
+func TestWaitForClusterAvailable(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is held for describes calls before the cluster is
+		// available again.
+		status         string
+		describes      int
+		statusTimeouts map[string]time.Duration
+		timeout        time.Duration
+		reason         WaitReason
+	}{
+		{name: "available", status: "modifying", describes: 3, timeout: time.Second},
+		{name: "timeout", status: "modifying", describes: 1 << 20, timeout: 20 * time.Millisecond, reason: WaitTimeout},
+		{
+			name:           "status timeout",
+			status:         "modifying",
+			describes:      1 << 20,
+			statusTimeouts: map[string]time.Duration{"modifying": 5 * time.Millisecond},
+			timeout:        time.Second,
+			reason:         WaitStatusTimeout,
+		},
+		{
+			name:      "failure status",
+			status:    "incompatible-parameters",
+			describes: 1 << 20,
+			timeout:   time.Second,
+			reason:    WaitFailureStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			input := NewDBClusterFactoryInput{ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12"}
+			if _, err := NewDBClusterFactory(input).CreateDBCluster(svc); err != nil {
+				t.Fatal(err)
+			}
+			if err := svc.SetClusterStatus("experiments", tt.status, tt.describes); err != nil {
+				t.Fatal(err)
+			}
+
+			w := testWaiter()
+			if tt.statusTimeouts != nil {
+				w.StatusTimeouts = tt.statusTimeouts
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			_, err := w.WaitForClusterAvailable(ctx, svc, "experiments")
+
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			waitErr, ok := err.(*WaitError)
+			if !ok {
+				t.Fatalf("err = %v, want a WaitError", err)
+			}
+			if waitErr.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", waitErr.Reason, tt.reason)
+			}
+			if waitErr.Status != tt.status {
+				t.Errorf("status = %q, want %q", waitErr.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestWaitStableCount(t *testing.T) {
+	var polls int
+	status := func() (string, error) {
+		polls++
+		if polls == 2 || polls == 4 {
+			return "backing-up", nil
+		}
+		return statusAvailable, nil
+	}
+
+	var seen []string
+	w := testWaiter()
+	w.StableCount = 3
+	w.Progress = func(p WaitProgress) { seen = append(seen, p.Status) }
+	if err := w.wait(context.Background(), ResourceCluster, "experiments", false, status); err != nil {
+		t.Fatal(err)
+	}
+	// Available, backing-up, available and backing-up again restart the
+	// count, then three available polls in a row.
+	if polls != 7 {
+		t.Errorf("polled %d times, want 7", polls)
+	}
+	want := []string{statusAvailable, "backing-up", statusAvailable, "backing-up", statusAvailable, statusAvailable}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("progress = %v, want %v", seen, want)
+	}
+}
+
+func TestWaitDescribeError(t *testing.T) {
+	describeErr := errors.New("connection reset")
+	err := testWaiter().wait(context.Background(), ResourceInstance, "experiments-0", false, func() (string, error) {
+		return "", describeErr
+	})
+	if waitErr, ok := err.(*WaitError); !ok || waitErr.Reason != WaitDescribeError || waitErr.Err != describeErr {
+		t.Errorf("err = %v, want %q wrapping the describe error", err, WaitDescribeError)
+	}
+}
+
+func TestWaitForDeleted(t *testing.T) {
+	svc := fakerds.New()
+	w := testWaiter()
+
+	_, err := w.WaitForClusterAvailable(context.Background(), svc, "experiments")
+	if waitErr, ok := err.(*WaitError); !ok || waitErr.Reason != WaitNotFound {
+		t.Errorf("waiting for a missing cluster: err = %v, want %q", err, WaitNotFound)
+	}
+	if err := w.WaitForClusterDeleted(context.Background(), svc, "experiments"); err != nil {
+		t.Errorf("waiting for a missing cluster to be deleted: %v", err)
+	}
+
+	input := NewDBClusterFactoryInput{ClusterId: "experiments", Engine: "aurora-mysql", EngineVersion: "5.7.12"}
+	if _, err := NewDBClusterFactory(input).CreateDBCluster(svc); err != nil {
+		t.Fatal(err)
+	}
+	f := (&DBInstanceFactory{}).SetSvc(svc).
+		SetInstanceIdentifier("experiments-0").
+		SetClusterIdentifier("experiments").
+		SetEngine("aurora-mysql").
+		SetInstanceClass("db.r5.large")
+	if _, err := f.CreateDBClusterInstance(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WaitForInstanceAvailable(context.Background(), svc, "experiments-0"); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.TransitionDescribes = 3
+	if err := DeleteDBInstance(svc, "experiments-0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WaitForInstanceDeleted(context.Background(), svc, "experiments-0"); err != nil {
+		t.Errorf("waiting for a deleted instance: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+func runFailover(args []string) {
+	flags := flag.NewFlagSet("failover", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	target := flags.String("target", "", "reader instance to make the writer; RDS picks one by promotion tier by default")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	flags.Parse(args)
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+
+	if !*yes && !confirm(fmt.Sprintf("Fail over cluster %s? Open connections to the writer will be dropped.", req.ClusterId)) {
+		log.Fatal("failover cancelled")
+	}
+
+	failover, err := service.FailoverCluster(newRDS(req), req, newWaiter(req), *target)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf(
+		"cluster %s: writer moved from %s to %s, no writer for at most %s\n",
+		failover.Cluster, failover.PreviousWriter, failover.Writer, failover.Unavailable.Round(time.Second),
+	)
+}
+
+func runSwitchover(args []string) {
+	flags := flag.NewFlagSet("switchover", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	to := flags.String("to", "", "region of the cluster to make the primary cluster of the global database")
+	allowDataLoss := flags.Bool(
+		"allow-data-loss", false,
+		"fail over without waiting for the cluster to catch up, when the primary cluster is unavailable",
+	)
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	flags.Parse(args)
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+	if req.Global == nil {
+		log.Fatalf("cluster %s has no global block, there is no global database to switch over", req.ClusterId)
+	}
+	if *to == "" {
+		log.Fatal("-to is required")
+	}
+
+	question := fmt.Sprintf("Switch the primary cluster of %s over to %s?", req.Global.Identifier, *to)
+	if *allowDataLoss {
+		question = fmt.Sprintf(
+			"Fail %s over to %s? Writes not replicated to %s yet will be lost.", req.Global.Identifier, *to, *to,
+		)
+	}
+	if !*yes && !confirm(question) {
+		log.Fatal("switchover cancelled")
+	}
+
+	switchover, err := service.SwitchoverGlobalCluster(
+		newRDS(req), newRegions(req), req, newWaiter(req), *to, *allowDataLoss,
+	)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf(
+		"global cluster %s: primary cluster moved from %s to %s in %s\n",
+		switchover.GlobalCluster, switchover.PreviousPrimary, switchover.Primary, switchover.Elapsed.Round(time.Second),
+	)
+}
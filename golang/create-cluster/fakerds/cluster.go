@@ -0,0 +1,519 @@
+package fakerds
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func (f *RDS) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBClusters"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBClustersOutput{}
+
+	if input.DBClusterIdentifier != nil {
+		id := *input.DBClusterIdentifier
+		c, ok := f.clusters[id]
+		if !ok {
+			return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", id)
+		}
+		if tick(&c.state, &c.cluster.Status) {
+			delete(f.clusters, id)
+			return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", id)
+		}
+		c.finishFailover()
+		output.DBClusters = append(output.DBClusters, copyCluster(c.cluster))
+		return output, nil
+	}
+
+	for id, c := range f.clusters {
+		if tick(&c.state, &c.cluster.Status) {
+			delete(f.clusters, id)
+			continue
+		}
+		c.finishFailover()
+		output.DBClusters = append(output.DBClusters, copyCluster(c.cluster))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBCluster(input *rds.CreateDBClusterInput) (*rds.CreateDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBCluster"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	if _, ok := f.clusters[id]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterAlreadyExistsFault, "DBCluster already exists.", nil)
+	}
+	if input.DBSubnetGroupName != nil {
+		if _, ok := f.subnetGroups[*input.DBSubnetGroupName]; !ok {
+			return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", *input.DBSubnetGroupName)
+		}
+	}
+	mode, scaling, err := engineMode(
+		aws.StringValue(input.Engine), aws.StringValue(input.EngineVersion), input.EngineMode, input.ScalingConfiguration,
+	)
+	if err != nil {
+		return nil, err
+	}
+	scalingV2, err := scaleV2(mode, nil, input.ServerlessV2ScalingConfiguration)
+	if err != nil {
+		return nil, err
+	}
+	// Serverless clusters are always encrypted.
+	encrypted := aws.BoolValue(input.StorageEncrypted) || *mode == engineModeServerless
+	if input.KmsKeyId != nil && !encrypted {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"KmsKeyId is only valid when StorageEncrypted is true.",
+			nil,
+		)
+	}
+	kmsKeyId := input.KmsKeyId
+	if encrypted && kmsKeyId == nil {
+		kmsKeyId = aws.String(fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", f.Region, f.AccountId, defaultKmsKeyId))
+	}
+
+	parameterGroup := aws.String("default." + aws.StringValue(input.Engine))
+	if input.DBClusterParameterGroupName != nil {
+		if _, ok := f.clusterParameterGroups[*input.DBClusterParameterGroupName]; !ok {
+			return nil, notFound(
+				rds.ErrCodeDBClusterParameterGroupNotFoundFault, "DBClusterParameterGroup", *input.DBClusterParameterGroupName,
+			)
+		}
+		parameterGroup = input.DBClusterParameterGroupName
+	}
+
+	cluster := &rds.DBCluster{
+		DBClusterIdentifier:     input.DBClusterIdentifier,
+		DBClusterArn:            f.arn("cluster", id),
+		DBSubnetGroup:           input.DBSubnetGroupName,
+		DBClusterParameterGroup: parameterGroup,
+		Engine:                  input.Engine,
+		EngineVersion:           input.EngineVersion,
+		MasterUsername:          input.MasterUsername,
+		Status:                  aws.String(StatusCreating),
+		ClusterCreateTime:       now(),
+		Port:                    aws.Int64(3306),
+		DBClusterMembers:        []*rds.DBClusterMember{},
+		VpcSecurityGroups:       securityGroups(input.VpcSecurityGroupIds),
+		StorageEncrypted:        aws.Bool(encrypted),
+		KmsKeyId:                kmsKeyId,
+		EngineMode:              mode,
+
+		ScalingConfigurationInfo:   scaling,
+		BackupRetentionPeriod:      input.BackupRetentionPeriod,
+		PreferredBackupWindow:      input.PreferredBackupWindow,
+		PreferredMaintenanceWindow: input.PreferredMaintenanceWindow,
+
+		ServerlessV2ScalingConfiguration: scalingV2,
+	}
+	setBackupDefaults(cluster)
+	cluster.Endpoint, cluster.ReaderEndpoint = f.clusterEndpoints(id)
+	if input.GlobalClusterIdentifier != nil {
+		err := f.joinGlobalCluster(input, cluster)
+		if err != nil {
+			return nil, err
+		}
+	}
+	f.clusters[id] = &clusterState{
+		state:   f.transition(StatusAvailable),
+		cluster: cluster,
+	}
+
+	return &rds.CreateDBClusterOutput{DBCluster: copyCluster(cluster)}, nil
+}
+
+// isDefaultParameterGroup reports whether name is the default cluster or DB
+// parameter group of family, which RDS always has.
+func isDefaultParameterGroup(name, family string) bool {
+	return name == "default."+family
+}
+
+// checkUpgradeParameterGroups returns the error RDS gives when a major
+// upgrade would leave the cluster, or move its instances, on a parameter
+// group of another family than the new version. Instances on a custom
+// group need DBInstanceParameterGroupName to say where they move to.
+func (f *RDS) checkUpgradeParameterGroups(cluster *rds.DBCluster, input *rds.ModifyDBClusterInput) error {
+	family := engineFamily(aws.StringValue(cluster.Engine), aws.StringValue(input.EngineVersion))
+
+	name := cluster.DBClusterParameterGroup
+	if input.DBClusterParameterGroupName != nil {
+		name = input.DBClusterParameterGroupName
+	}
+	if g, ok := f.clusterParameterGroups[aws.StringValue(name)]; ok && aws.StringValue(g.group.DBParameterGroupFamily) != family {
+		return awserr.New(
+			"InvalidParameterCombination",
+			fmt.Sprintf("The DB cluster parameter group %s is not of family %s.", aws.StringValue(name), family),
+			nil,
+		)
+	}
+
+	if input.DBInstanceParameterGroupName == nil {
+		for _, m := range cluster.DBClusterMembers {
+			i, ok := f.instances[aws.StringValue(m.DBInstanceIdentifier)]
+			if !ok {
+				continue
+			}
+			if name := aws.StringValue(i.parameterGroupName()); f.dbParameterGroups[name] != nil {
+				return awserr.New(
+					"InvalidParameterCombination",
+					fmt.Sprintf(
+						"The current DB instance parameter group %s is custom. You must explicitly specify "+
+							"a new DB instance parameter group, either default or custom, for the engine version upgrade.",
+						name,
+					),
+					nil,
+				)
+			}
+		}
+	}
+	if name := input.DBInstanceParameterGroupName; name != nil && !isDefaultParameterGroup(*name, family) {
+		g, ok := f.dbParameterGroups[*name]
+		if !ok {
+			return notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", *name)
+		}
+		if aws.StringValue(g.group.DBParameterGroupFamily) != family {
+			return awserr.New(
+				"InvalidParameterCombination",
+				fmt.Sprintf("The DB parameter group %s is not of family %s.", *name, family),
+				nil,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (f *RDS) ModifyDBCluster(input *rds.ModifyDBClusterInput) (*rds.ModifyDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ModifyDBCluster"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", id)
+	}
+	if aws.StringValue(c.cluster.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("DBCluster %s is not currently in the available state.", id),
+			nil,
+		)
+	}
+
+	allowMajor := aws.BoolValue(input.AllowMajorVersionUpgrade)
+	if input.DBInstanceParameterGroupName != nil && !allowMajor {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"DBInstanceParameterGroupName can only be specified with AllowMajorVersionUpgrade.",
+			nil,
+		)
+	}
+
+	status := StatusModifying
+	if input.EngineVersion != nil && *input.EngineVersion != aws.StringValue(c.cluster.EngineVersion) {
+		engine := aws.StringValue(c.cluster.Engine)
+		major, err := checkUpgrade(engine, aws.StringValue(c.cluster.EngineVersion), *input.EngineVersion, allowMajor)
+		if err == nil && major {
+			err = f.checkUpgradeParameterGroups(c.cluster, input)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.cluster.EngineVersion = input.EngineVersion
+		status = StatusUpgrading
+		// The members are upgraded along with the cluster, and move to the
+		// instance parameter group of the new version with it.
+		for _, m := range c.cluster.DBClusterMembers {
+			if i, ok := f.instances[aws.StringValue(m.DBInstanceIdentifier)]; ok {
+				i.instance.EngineVersion = input.EngineVersion
+				i.instance.DBInstanceStatus = aws.String(StatusUpgrading)
+				i.state = f.transition(StatusAvailable)
+				if name := input.DBInstanceParameterGroupName; name != nil {
+					i.instance.DBParameterGroups = []*rds.DBParameterGroupStatus{{
+						DBParameterGroupName: name,
+						ParameterApplyStatus: aws.String(parameterStatusInSync),
+					}}
+				}
+			}
+		}
+	}
+	if input.MasterUserPassword != nil && status == StatusModifying {
+		status = StatusResettingMasterCredentials
+	}
+	if input.VpcSecurityGroupIds != nil {
+		c.cluster.VpcSecurityGroups = securityGroups(input.VpcSecurityGroupIds)
+	}
+	if name := input.DBClusterParameterGroupName; name != nil && *name != aws.StringValue(c.cluster.DBClusterParameterGroup) {
+		family := engineFamily(aws.StringValue(c.cluster.Engine), aws.StringValue(c.cluster.EngineVersion))
+		if _, ok := f.clusterParameterGroups[*name]; !ok && !isDefaultParameterGroup(*name, family) {
+			return nil, notFound(rds.ErrCodeDBClusterParameterGroupNotFoundFault, "DBClusterParameterGroup", *name)
+		}
+		// A new group only takes effect on the members after a reboot.
+		c.cluster.DBClusterParameterGroup = name
+		for _, m := range c.cluster.DBClusterMembers {
+			m.DBClusterParameterGroupStatus = aws.String(parameterStatusPendingReboot)
+		}
+	}
+	if input.ScalingConfiguration != nil {
+		if !isServerless(c.cluster) {
+			return nil, awserr.New(
+				"InvalidParameterCombination",
+				"ScalingConfiguration is only supported for serverless clusters.",
+				nil,
+			)
+		}
+		scaling, err := scale(aws.StringValue(c.cluster.Engine), c.cluster.ScalingConfigurationInfo, input.ScalingConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		c.cluster.ScalingConfigurationInfo = scaling
+	}
+	if input.ServerlessV2ScalingConfiguration != nil {
+		scaling, err := scaleV2(
+			c.cluster.EngineMode, c.cluster.ServerlessV2ScalingConfiguration, input.ServerlessV2ScalingConfiguration,
+		)
+		if err != nil {
+			return nil, err
+		}
+		c.cluster.ServerlessV2ScalingConfiguration = scaling
+	}
+	if input.BackupRetentionPeriod != nil {
+		c.cluster.BackupRetentionPeriod = input.BackupRetentionPeriod
+	}
+	if input.PreferredBackupWindow != nil {
+		c.cluster.PreferredBackupWindow = input.PreferredBackupWindow
+	}
+	if input.PreferredMaintenanceWindow != nil {
+		c.cluster.PreferredMaintenanceWindow = input.PreferredMaintenanceWindow
+	}
+	if input.NewDBClusterIdentifier != nil {
+		// The members of a global cluster are known by their ARN.
+		err := f.checkNotGlobalMember(c.cluster)
+		if err != nil {
+			return nil, err
+		}
+		newId := *input.NewDBClusterIdentifier
+		c.cluster.DBClusterIdentifier = input.NewDBClusterIdentifier
+		c.cluster.DBClusterArn = f.arn("cluster", newId)
+		c.cluster.Endpoint, c.cluster.ReaderEndpoint = f.clusterEndpoints(newId)
+		delete(f.clusters, id)
+		f.clusters[newId] = c
+		for _, i := range f.instances {
+			if aws.StringValue(i.instance.DBClusterIdentifier) == id {
+				i.instance.DBClusterIdentifier = input.NewDBClusterIdentifier
+			}
+		}
+	}
+
+	c.cluster.Status = aws.String(status)
+	c.state = f.transition(StatusAvailable)
+
+	return &rds.ModifyDBClusterOutput{DBCluster: copyCluster(c.cluster)}, nil
+}
+
+func (f *RDS) DeleteDBCluster(input *rds.DeleteDBClusterInput) (*rds.DeleteDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBCluster"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", id)
+	}
+	if len(c.cluster.DBClusterMembers) > 0 {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("Cluster %s still has member instances.", id),
+			nil,
+		)
+	}
+	if err := f.checkNotGlobalMember(c.cluster); err != nil {
+		return nil, err
+	}
+	if !aws.BoolValue(input.SkipFinalSnapshot) && input.FinalDBSnapshotIdentifier == nil {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"FinalDBSnapshotIdentifier is required unless SkipFinalSnapshot is specified.",
+			nil,
+		)
+	}
+
+	if !aws.BoolValue(input.SkipFinalSnapshot) {
+		_, err := f.snapshotCluster(c.cluster, *input.FinalDBSnapshotIdentifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.cluster.Status = aws.String(StatusDeleting)
+	c.state = f.transition("")
+
+	return &rds.DeleteDBClusterOutput{DBCluster: copyCluster(c.cluster)}, nil
+}
+
+// RestoreDBClusterToPointInTime clones a cluster without its members. The
+// restorable window runs from the creation of the source to now, and the
+// clone keeps the master username, engine, engine mode and encryption of
+// the source.
+func (f *RDS) RestoreDBClusterToPointInTime(
+	input *rds.RestoreDBClusterToPointInTimeInput,
+) (*rds.RestoreDBClusterToPointInTimeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("RestoreDBClusterToPointInTime"); err != nil {
+		return nil, err
+	}
+
+	sourceId := aws.StringValue(input.SourceDBClusterIdentifier)
+	source, ok := f.clusters[sourceId]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", sourceId)
+	}
+	if (input.RestoreToTime == nil) == !aws.BoolValue(input.UseLatestRestorableTime) {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"Exactly one of RestoreToTime or UseLatestRestorableTime must be specified.",
+			nil,
+		)
+	}
+	if t := input.RestoreToTime; t != nil && (t.Before(*source.cluster.ClusterCreateTime) || t.After(*now())) {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidRestoreFault,
+			fmt.Sprintf("RestoreToTime is outside the restorable window of %s.", sourceId),
+			nil,
+		)
+	}
+	switch restoreType := aws.StringValue(input.RestoreType); restoreType {
+	case "", "full-copy", "copy-on-write":
+	default:
+		return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("Invalid RestoreType %s.", restoreType), nil)
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	if _, ok := f.clusters[id]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterAlreadyExistsFault, "DBCluster already exists.", nil)
+	}
+	if input.DBSubnetGroupName != nil {
+		if _, ok := f.subnetGroups[*input.DBSubnetGroupName]; !ok {
+			return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", *input.DBSubnetGroupName)
+		}
+	}
+	parameterGroup := aws.String("default." + aws.StringValue(source.cluster.Engine))
+	if input.DBClusterParameterGroupName != nil {
+		if _, ok := f.clusterParameterGroups[*input.DBClusterParameterGroupName]; !ok {
+			return nil, notFound(
+				rds.ErrCodeDBClusterParameterGroupNotFoundFault, "DBClusterParameterGroup", *input.DBClusterParameterGroupName,
+			)
+		}
+		parameterGroup = input.DBClusterParameterGroupName
+	}
+	kmsKeyId := source.cluster.KmsKeyId
+	if input.KmsKeyId != nil {
+		kmsKeyId = input.KmsKeyId
+	}
+	scalingV2, err := scaleV2(
+		source.cluster.EngineMode, source.cluster.ServerlessV2ScalingConfiguration, input.ServerlessV2ScalingConfiguration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &rds.DBCluster{
+		DBClusterIdentifier:     input.DBClusterIdentifier,
+		DBClusterArn:            f.arn("cluster", id),
+		DBSubnetGroup:           input.DBSubnetGroupName,
+		DBClusterParameterGroup: parameterGroup,
+		Engine:                  source.cluster.Engine,
+		EngineVersion:           source.cluster.EngineVersion,
+		MasterUsername:          source.cluster.MasterUsername,
+		Status:                  aws.String(StatusCreating),
+		ClusterCreateTime:       now(),
+		Port:                    source.cluster.Port,
+		DBClusterMembers:        []*rds.DBClusterMember{},
+		VpcSecurityGroups:       securityGroups(input.VpcSecurityGroupIds),
+		StorageEncrypted:        source.cluster.StorageEncrypted,
+		KmsKeyId:                kmsKeyId,
+		EngineMode:              source.cluster.EngineMode,
+
+		ScalingConfigurationInfo: source.cluster.ScalingConfigurationInfo,
+
+		ServerlessV2ScalingConfiguration: scalingV2,
+	}
+	setBackupDefaults(cluster)
+	cluster.Endpoint, cluster.ReaderEndpoint = f.clusterEndpoints(id)
+	f.clusters[id] = &clusterState{
+		state:   f.transition(StatusAvailable),
+		cluster: cluster,
+	}
+
+	return &rds.RestoreDBClusterToPointInTimeOutput{DBCluster: copyCluster(cluster)}, nil
+}
+
+// setBackupDefaults fills in the backup and maintenance settings RDS picks
+// when none are given.
+func setBackupDefaults(cluster *rds.DBCluster) {
+	if cluster.BackupRetentionPeriod == nil {
+		cluster.BackupRetentionPeriod = aws.Int64(defaultBackupRetention)
+	}
+	if cluster.PreferredBackupWindow == nil {
+		cluster.PreferredBackupWindow = aws.String(defaultBackupWindow)
+	}
+	if cluster.PreferredMaintenanceWindow == nil {
+		cluster.PreferredMaintenanceWindow = aws.String(defaultMaintenanceWindow)
+	}
+}
+
+// clusterEndpoints returns the writer and reader endpoints of cluster id.
+func (f *RDS) clusterEndpoints(id string) (*string, *string) {
+	return aws.String(fmt.Sprintf("%s.cluster-fake.%s.rds.amazonaws.com", id, f.Region)),
+		aws.String(fmt.Sprintf("%s.cluster-ro-fake.%s.rds.amazonaws.com", id, f.Region))
+}
+
+func securityGroups(ids []*string) []*rds.VpcSecurityGroupMembership {
+	groups := make([]*rds.VpcSecurityGroupMembership, 0)
+	for _, id := range ids {
+		groups = append(groups, &rds.VpcSecurityGroupMembership{
+			VpcSecurityGroupId: id,
+			Status:             aws.String("active"),
+		})
+	}
+
+	return groups
+}
+
+func copyCluster(cluster *rds.DBCluster) *rds.DBCluster {
+	c := *cluster
+	c.EarliestRestorableTime = cluster.ClusterCreateTime
+	c.LatestRestorableTime = now()
+	c.DBClusterMembers = make([]*rds.DBClusterMember, 0)
+	for _, m := range cluster.DBClusterMembers {
+		member := *m
+		c.DBClusterMembers = append(c.DBClusterMembers, &member)
+	}
+	c.VpcSecurityGroups = append([]*rds.VpcSecurityGroupMembership{}, cluster.VpcSecurityGroups...)
+
+	return &c
+}
@@ -0,0 +1,108 @@
+package fakerds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const ec2XmlNamespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+
+// DescribeSubnets reports the availability zone the subnet groups of f put
+// each subnet in. Every subnet id exists.
+func (f *RDS) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeSubnets"); err != nil {
+		return nil, err
+	}
+
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, id := range input.SubnetIds {
+		output.Subnets = append(output.Subnets, &ec2.Subnet{
+			SubnetId:         id,
+			AvailabilityZone: aws.String(f.subnetZone(aws.StringValue(id))),
+			State:            aws.String(ec2.SubnetStateAvailable),
+			VpcId:            aws.String("vpc-00000000"),
+		})
+	}
+
+	return output, nil
+}
+
+// handleEC2 answers DescribeSubnets, the only EC2 action the server speaks.
+// The EC2 Query protocol flattens lists, leaves out the result element and
+// has an error document of its own.
+func (s *Server) handleEC2(w http.ResponseWriter, r *http.Request, requestId string) {
+	action := r.Form.Get("Action")
+	if action != "DescribeSubnets" {
+		writeEC2Error(w, requestId, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("%s is not supported.", action))
+		return
+	}
+
+	backend := s.backend(signedRegion(r))
+	if backend == nil {
+		writeEC2Error(w, requestId, http.StatusBadRequest, "AuthFailure", fmt.Sprintf("Region %s is not served.", signedRegion(r)))
+		return
+	}
+
+	input := &ec2.DescribeSubnetsInput{}
+	for n := 1; r.Form.Get(fmt.Sprintf("SubnetId.%d", n)) != ""; n++ {
+		input.SubnetIds = append(input.SubnetIds, aws.String(r.Form.Get(fmt.Sprintf("SubnetId.%d", n))))
+	}
+
+	output, err := backend.DescribeSubnets(input)
+	if err != nil {
+		code, message := "InternalError", err.Error()
+		if aerr, ok := err.(awserr.Error); ok {
+			code, message = aerr.Code(), aerr.Message()
+		}
+		writeEC2Error(w, requestId, http.StatusBadRequest, code, message)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	e := xml.NewEncoder(buf)
+	response := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: ec2XmlNamespace}},
+	}
+	err = e.EncodeToken(response)
+	if err == nil {
+		err = encodeText(e, "requestId", requestId)
+	}
+	if err == nil {
+		err = encodeFields(e, reflect.ValueOf(output).Elem())
+	}
+	if err == nil {
+		err = e.EncodeToken(response.End())
+	}
+	if err == nil {
+		err = e.Flush()
+	}
+	if err != nil {
+		writeEC2Error(w, requestId, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(buf.Bytes())
+}
+
+func writeEC2Error(w http.ResponseWriter, requestId string, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(
+		w,
+		"<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>%s</RequestID></Response>",
+		escape(code), escape(message), requestId,
+	)
+}
@@ -0,0 +1,323 @@
+package fakerds
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// engineVersion is an entry of the fixed engine catalog. majorTargets are
+// upgrade targets that need AllowMajorVersionUpgrade, and classes are the
+// instance classes that can be ordered for the version. Only versions marked
+// serverless run in the serverless engine mode, and only versions marked
+// global can be part of a global database, in the global engine mode for
+// the aurora engine.
+type engineVersion struct {
+	engine       string
+	version      string
+	family       string
+	targets      []string
+	majorTargets []string
+	classes      []string
+	serverless   bool
+	global       bool
+}
+
+var (
+	mysql56Classes      = []string{"db.t2.small", "db.t2.medium", "db.r4.large", "db.r4.xlarge"}
+	mysql57Classes      = []string{"db.t2.small", "db.t2.medium", "db.r4.large", "db.r4.xlarge", "db.r5.large"}
+	mysql57LaterClasses = []string{"db.t3.small", "db.t3.medium", "db.r4.large", "db.r4.xlarge", "db.r5.large"}
+	postgresqlClasses   = []string{"db.r4.large", "db.r4.xlarge", "db.r5.large"}
+	mysql80Classes      = []string{"db.serverless", "db.t3.medium", "db.r5.large", "db.r5.xlarge"}
+)
+
+// zoneLimitedClasses are only offered in the first two zones of a region.
+var zoneLimitedClasses = map[string]bool{"db.r5.large": true}
+
+// engineVersions is a small slice of what RDS offers, enough to plan minor
+// and major upgrades and to order instances against.
+var engineVersions = []engineVersion{
+	{
+		engine:     "aurora",
+		version:    "5.6.10a",
+		family:     "aurora5.6",
+		targets:    []string{"5.6.mysql_aurora.1.19.0"},
+		classes:    mysql56Classes,
+		serverless: true,
+		global:     true,
+	},
+	{
+		engine:  "aurora",
+		version: "5.6.mysql_aurora.1.19.0",
+		family:  "aurora5.6",
+		classes: mysql56Classes,
+	},
+	{
+		engine:  "aurora-mysql",
+		version: "5.7.12",
+		family:  "aurora-mysql5.7",
+		targets: []string{"5.7.mysql_aurora.2.03.2", "5.7.mysql_aurora.2.04.0"},
+		classes: mysql57Classes,
+		global:  true,
+	},
+	{
+		engine:  "aurora-mysql",
+		version: "5.7.mysql_aurora.2.03.2",
+		family:  "aurora-mysql5.7",
+		targets: []string{"5.7.mysql_aurora.2.04.0"},
+		classes: mysql57Classes,
+		global:  true,
+	},
+	{
+		engine:  "aurora-mysql",
+		version: "5.7.mysql_aurora.2.04.0",
+		family:  "aurora-mysql5.7",
+		classes: mysql57LaterClasses,
+		global:  true,
+	},
+	{
+		engine:  "aurora-mysql",
+		version: "8.0.mysql_aurora.3.02.0",
+		family:  "aurora-mysql8.0",
+		classes: mysql80Classes,
+		global:  true,
+	},
+	{
+		engine:       "aurora-postgresql",
+		version:      "9.6.11",
+		family:       "aurora-postgresql9.6",
+		majorTargets: []string{"10.6"},
+		classes:      postgresqlClasses,
+	},
+	{
+		engine:  "aurora-postgresql",
+		version: "10.6",
+		family:  "aurora-postgresql10",
+		classes: postgresqlClasses,
+		global:  true,
+	},
+}
+
+func (f *RDS) DescribeDBEngineVersions(
+	input *rds.DescribeDBEngineVersionsInput,
+) (*rds.DescribeDBEngineVersionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBEngineVersions"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBEngineVersionsOutput{DBEngineVersions: []*rds.DBEngineVersion{}}
+	for _, v := range engineVersions {
+		if input.Engine != nil && *input.Engine != v.engine {
+			continue
+		}
+		if input.EngineVersion != nil && *input.EngineVersion != v.version {
+			continue
+		}
+		if input.DBParameterGroupFamily != nil && *input.DBParameterGroupFamily != v.family {
+			continue
+		}
+		output.DBEngineVersions = append(output.DBEngineVersions, v.describe())
+	}
+
+	return output, nil
+}
+
+func (f *RDS) DescribeOrderableDBInstanceOptions(
+	input *rds.DescribeOrderableDBInstanceOptionsInput,
+) (*rds.DescribeOrderableDBInstanceOptionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeOrderableDBInstanceOptions"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeOrderableDBInstanceOptionsOutput{
+		OrderableDBInstanceOptions: []*rds.OrderableDBInstanceOption{},
+	}
+	for _, v := range engineVersions {
+		if aws.StringValue(input.Engine) != v.engine {
+			continue
+		}
+		if input.EngineVersion != nil && *input.EngineVersion != v.version {
+			continue
+		}
+		for _, class := range v.classes {
+			if input.DBInstanceClass != nil && *input.DBInstanceClass != class {
+				continue
+			}
+			output.OrderableDBInstanceOptions = append(output.OrderableDBInstanceOptions, &rds.OrderableDBInstanceOption{
+				Engine:            aws.String(v.engine),
+				EngineVersion:     aws.String(v.version),
+				DBInstanceClass:   aws.String(class),
+				AvailabilityZones: f.classZones(class),
+				Vpc:               aws.Bool(true),
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// classZones lists the zones of the region that offer class.
+func (f *RDS) classZones(class string) []*rds.AvailabilityZone {
+	zones := []string{"a", "b", "c"}
+	if zoneLimitedClasses[class] {
+		zones = zones[:2]
+	}
+
+	list := make([]*rds.AvailabilityZone, 0)
+	for _, z := range zones {
+		list = append(list, &rds.AvailabilityZone{Name: aws.String(f.Region + z)})
+	}
+
+	return list
+}
+
+// checkOrderable returns the error RDS gives for an instance of class in
+// zone, which may be nil, in a cluster running engine and version. Versions
+// outside the catalog take any class. Callers hold f.mu.
+func (f *RDS) checkOrderable(engine, version, class string, zone *string) error {
+	for _, v := range engineVersions {
+		if v.engine != engine || v.version != version {
+			continue
+		}
+
+		for _, c := range v.classes {
+			if c != class {
+				continue
+			}
+			if zone == nil {
+				return nil
+			}
+			for _, z := range f.classZones(class) {
+				if aws.StringValue(z.Name) == *zone {
+					return nil
+				}
+			}
+			return awserr.New(
+				"InvalidParameterCombination",
+				fmt.Sprintf("Cannot create a %s instance in availability zone %s.", class, *zone),
+				nil,
+			)
+		}
+
+		return awserr.New(
+			"InvalidParameterCombination",
+			fmt.Sprintf(
+				"RDS does not support creating a DB instance with the following combination: "+
+					"DBInstanceClass=%s, Engine=%s, EngineVersion=%s.",
+				class, engine, version,
+			),
+			nil,
+		)
+	}
+
+	return nil
+}
+
+func (v engineVersion) hasEngineMode(mode string) bool {
+	for _, m := range v.describe().SupportedEngineModes {
+		if aws.StringValue(m) == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// supportsGlobalDatabases reports whether a version of engine can be part of
+// a global database. Versions outside the catalog can.
+func supportsGlobalDatabases(engine, version string) bool {
+	for _, v := range engineVersions {
+		if v.engine == engine && v.version == version {
+			return v.global
+		}
+	}
+
+	return true
+}
+
+func (v engineVersion) describe() *rds.DBEngineVersion {
+	version := &rds.DBEngineVersion{
+		Engine:                 aws.String(v.engine),
+		EngineVersion:          aws.String(v.version),
+		DBParameterGroupFamily: aws.String(v.family),
+		ValidUpgradeTarget:     []*rds.UpgradeTarget{},
+		SupportedEngineModes:   []*string{aws.String(engineModeProvisioned)},
+	}
+	if v.serverless {
+		version.SupportedEngineModes = append(version.SupportedEngineModes, aws.String(engineModeServerless))
+	}
+	if v.global && v.engine == engineAurora {
+		version.SupportedEngineModes = append(version.SupportedEngineModes, aws.String(engineModeGlobal))
+	}
+	version.SupportsGlobalDatabases = aws.Bool(v.global)
+
+	add := func(targets []string, major bool) {
+		for _, t := range targets {
+			version.ValidUpgradeTarget = append(version.ValidUpgradeTarget, &rds.UpgradeTarget{
+				Engine:                aws.String(v.engine),
+				EngineVersion:         aws.String(t),
+				IsMajorVersionUpgrade: aws.Bool(major),
+				AutoUpgrade:           aws.Bool(false),
+			})
+		}
+	}
+	add(v.targets, false)
+	add(v.majorTargets, true)
+
+	return version
+}
+
+// checkUpgrade reports whether moving a cluster running engine and from to
+// version to with ModifyDBCluster is a major upgrade, or returns the error
+// RDS gives when it can't be made. Major upgrades need allowMajor.
+func checkUpgrade(engine, from, to string, allowMajor bool) (bool, error) {
+	for _, v := range engineVersions {
+		if v.engine != engine || v.version != from {
+			continue
+		}
+		for _, t := range v.targets {
+			if t == to {
+				return false, nil
+			}
+		}
+		for _, t := range v.majorTargets {
+			if t != to {
+				continue
+			}
+			if !allowMajor {
+				return false, awserr.New(
+					"InvalidParameterCombination",
+					"The AllowMajorVersionUpgrade flag must be present when upgrading to a new major version.",
+					nil,
+				)
+			}
+			return true, nil
+		}
+	}
+
+	return false, awserr.New(
+		"InvalidParameterCombination",
+		fmt.Sprintf("Cannot upgrade %s from %s to %s", engine, from, to),
+		nil,
+	)
+}
+
+// engineFamily returns the parameter group family of version of engine, or
+// "" when the catalog doesn't have it.
+func engineFamily(engine, version string) string {
+	for _, v := range engineVersions {
+		if v.engine == engine && v.version == version {
+			return v.family
+		}
+	}
+
+	return ""
+}
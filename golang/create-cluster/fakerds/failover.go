@@ -0,0 +1,259 @@
+package fakerds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	StatusFailingOver   = "failing-over"
+	StatusSwitchingOver = "switching-over"
+)
+
+// FailoverDBCluster takes the writer away from the cluster while it is
+// failing over, restarts the previous and the new writer, and hands the
+// writer to the target once the cluster is available again. Without a
+// target the reader with the lowest promotion tier is picked.
+func (f *RDS) FailoverDBCluster(input *rds.FailoverDBClusterInput) (*rds.FailoverDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("FailoverDBCluster"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", id)
+	}
+	if aws.StringValue(c.cluster.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("DBCluster %s is not currently in the available state.", id),
+			nil,
+		)
+	}
+	if err := f.checkNotGlobalReader(c.cluster); err != nil {
+		return nil, err
+	}
+
+	var writer *rds.DBClusterMember
+	readers := make([]*rds.DBClusterMember, 0)
+	for _, m := range c.cluster.DBClusterMembers {
+		if aws.BoolValue(m.IsClusterWriter) {
+			writer = m
+		} else {
+			readers = append(readers, m)
+		}
+	}
+	if writer == nil || len(readers) == 0 {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("DBCluster %s has no reader to fail over to.", id),
+			nil,
+		)
+	}
+
+	var target *rds.DBClusterMember
+	if input.TargetDBInstanceIdentifier != nil {
+		for _, m := range readers {
+			if aws.StringValue(m.DBInstanceIdentifier) == *input.TargetDBInstanceIdentifier {
+				target = m
+			}
+		}
+		if target == nil {
+			return nil, awserr.New(
+				"InvalidParameterValue",
+				fmt.Sprintf("%s is not a reader of DBCluster %s.", *input.TargetDBInstanceIdentifier, id),
+				nil,
+			)
+		}
+	} else {
+		sort.Slice(readers, func(i, j int) bool {
+			ti, tj := aws.Int64Value(readers[i].PromotionTier), aws.Int64Value(readers[j].PromotionTier)
+			if ti != tj {
+				return ti < tj
+			}
+			return aws.StringValue(readers[i].DBInstanceIdentifier) < aws.StringValue(readers[j].DBInstanceIdentifier)
+		})
+		target = readers[0]
+	}
+
+	writer.IsClusterWriter = aws.Bool(false)
+	for _, m := range []*rds.DBClusterMember{writer, target} {
+		if i, ok := f.instances[aws.StringValue(m.DBInstanceIdentifier)]; ok {
+			i.instance.DBInstanceStatus = aws.String(StatusRebooting)
+			i.state = f.transition(StatusAvailable)
+		}
+	}
+	c.cluster.Status = aws.String(StatusFailingOver)
+	c.state = f.transition(StatusAvailable)
+	c.failoverTarget = aws.StringValue(target.DBInstanceIdentifier)
+
+	return &rds.FailoverDBClusterOutput{DBCluster: copyCluster(c.cluster)}, nil
+}
+
+// finishFailover makes the failover target the writer once the cluster is
+// available again. Callers hold f.mu.
+func (c *clusterState) finishFailover() {
+	if c.failoverTarget == "" || aws.StringValue(c.cluster.Status) != StatusAvailable {
+		return
+	}
+
+	for _, m := range c.cluster.DBClusterMembers {
+		if aws.StringValue(m.DBInstanceIdentifier) == c.failoverTarget {
+			m.IsClusterWriter = aws.Bool(true)
+		}
+	}
+	c.failoverTarget = ""
+}
+
+// checkNotGlobalReader rejects a failover of a secondary cluster of a
+// global database, whose instances are all readers. Callers hold f.mu.
+func (f *RDS) checkNotGlobalReader(cluster *rds.DBCluster) error {
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	arn := aws.StringValue(cluster.DBClusterArn)
+	s := g.memberOf(arn)
+	if s == nil || aws.BoolValue(member(s.global, arn).IsWriter) {
+		return nil
+	}
+
+	return awserr.New(
+		rds.ErrCodeInvalidDBClusterStateFault,
+		fmt.Sprintf("DBCluster %s is a secondary cluster of global cluster %s.",
+			aws.StringValue(cluster.DBClusterIdentifier), aws.StringValue(s.global.GlobalClusterIdentifier)),
+		nil,
+	)
+}
+
+// SwitchoverGlobalCluster makes a secondary cluster the primary cluster of a
+// global cluster once the global cluster is available again. The previous
+// primary cluster becomes a secondary cluster.
+func (f *RDS) SwitchoverGlobalCluster(
+	input *rds.SwitchoverGlobalClusterInput,
+) (*rds.SwitchoverGlobalClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("SwitchoverGlobalCluster"); err != nil {
+		return nil, err
+	}
+
+	global, err := f.moveGlobalWriter(
+		aws.StringValue(input.GlobalClusterIdentifier), aws.StringValue(input.TargetDbClusterIdentifier),
+		StatusSwitchingOver,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rds.SwitchoverGlobalClusterOutput{GlobalCluster: global}, nil
+}
+
+// FailoverGlobalCluster is SwitchoverGlobalCluster without waiting for the
+// secondary cluster to catch up when AllowDataLoss is set, and a switchover
+// otherwise. The fake loses no data either way.
+func (f *RDS) FailoverGlobalCluster(
+	input *rds.FailoverGlobalClusterInput,
+) (*rds.FailoverGlobalClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("FailoverGlobalCluster"); err != nil {
+		return nil, err
+	}
+	if aws.BoolValue(input.AllowDataLoss) && aws.BoolValue(input.Switchover) {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"AllowDataLoss and Switchover can't both be set.",
+			nil,
+		)
+	}
+
+	status := StatusSwitchingOver
+	if aws.BoolValue(input.AllowDataLoss) {
+		status = StatusFailingOver
+	}
+	global, err := f.moveGlobalWriter(
+		aws.StringValue(input.GlobalClusterIdentifier), aws.StringValue(input.TargetDbClusterIdentifier), status,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rds.FailoverGlobalClusterOutput{GlobalCluster: global}, nil
+}
+
+// moveGlobalWriter starts moving the primary cluster of the global cluster
+// id to the secondary cluster targetArn. Callers hold f.mu.
+func (f *RDS) moveGlobalWriter(id, targetArn, status string) (*rds.GlobalCluster, error) {
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeGlobalClusterNotFoundFault, "GlobalCluster", id)
+	}
+	if aws.StringValue(s.global.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidGlobalClusterStateFault,
+			fmt.Sprintf("Global cluster %s is not currently in the available state.", id),
+			nil,
+		)
+	}
+	m := member(s.global, targetArn)
+	if m == nil {
+		return nil, awserr.New(
+			rds.ErrCodeDBClusterNotFoundFault,
+			fmt.Sprintf("DBCluster %s is not a member of global cluster %s.", targetArn, id),
+			nil,
+		)
+	}
+	if aws.BoolValue(m.IsWriter) {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidGlobalClusterStateFault,
+			fmt.Sprintf("DBCluster %s is already the primary cluster of global cluster %s.", targetArn, id),
+			nil,
+		)
+	}
+
+	s.global.Status = aws.String(status)
+	s.state = f.transition(StatusAvailable)
+	s.writerTarget = targetArn
+
+	return copyGlobalCluster(s.global), nil
+}
+
+// finishWriterMove makes the target of a switchover or failover the primary
+// cluster once the global cluster is available again, with every other
+// member as its readers. Callers hold the lock of the global clusters.
+func (s *globalClusterState) finishWriterMove() {
+	if s.writerTarget == "" || aws.StringValue(s.global.Status) != StatusAvailable {
+		return
+	}
+
+	readers := make([]*string, 0)
+	for _, m := range s.global.GlobalClusterMembers {
+		if aws.StringValue(m.DBClusterArn) != s.writerTarget {
+			readers = append(readers, m.DBClusterArn)
+		}
+	}
+	for _, m := range s.global.GlobalClusterMembers {
+		writer := aws.StringValue(m.DBClusterArn) == s.writerTarget
+		m.IsWriter = aws.Bool(writer)
+		m.Readers = []*string{}
+		if writer {
+			m.Readers = readers
+		}
+	}
+	s.writerTarget = ""
+}
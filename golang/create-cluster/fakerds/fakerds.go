@@ -0,0 +1,200 @@
+// Package fakerds is an in-memory stand-in for the parts of the RDS API that
+// this project uses. It models the status lifecycle of clusters and instances
+// so factories and waiters can be exercised without an AWS account.
+package fakerds
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+const (
+	StatusAvailable = "available"
+	StatusCreating  = "creating"
+	StatusModifying = "modifying"
+	StatusUpgrading = "upgrading"
+	StatusDeleting  = "deleting"
+	StatusRebooting = "rebooting"
+
+	StatusResettingMasterCredentials = "resetting-master-credentials"
+)
+
+const (
+	defaultRegion    = "us-east-1"
+	defaultAccountId = "123456789012"
+	// defaultKmsKeyId stands in for the aws/rds key, which encrypts clusters
+	// created without a KmsKeyId. fakesecrets gives that alias the same id.
+	defaultKmsKeyId = "00000000-0000-4000-8000-000000000001"
+
+	// RDS picks windows at random within a block of time per region.
+	defaultBackupRetention   = 1
+	defaultBackupWindow      = "07:00-07:30"
+	defaultMaintenanceWindow = "sun:05:00-sun:05:30"
+)
+
+// RDS implements rdsiface.RDSAPI. Calling an operation that is not modelled
+// panics through the embedded nil interface.
+type RDS struct {
+	rdsiface.RDSAPI
+
+	// Region and AccountId are used to build ARNs and endpoints.
+	Region    string
+	AccountId string
+
+	// TransitionDescribes is the number of describe calls a resource stays in
+	// a transitional status such as creating or modifying before it moves on.
+	TransitionDescribes int
+
+	// GlobalClusters holds the global clusters, which backends standing in
+	// for the regions of one account share.
+	GlobalClusters *GlobalClusters
+
+	mu                     sync.Mutex
+	subnetGroups           map[string]*rds.DBSubnetGroup
+	clusterParameterGroups map[string]*clusterParameterGroupState
+	dbParameterGroups      map[string]*dbParameterGroupState
+	clusters               map[string]*clusterState
+	instances              map[string]*instanceState
+	clusterSnapshots       map[string]*clusterSnapshotState
+	faults                 map[string][]error
+}
+
+// state tracks a transitional status. When remaining reaches zero the status
+// becomes next, or the resource is removed if next is empty.
+type state struct {
+	remaining int
+	next      string
+}
+
+type clusterState struct {
+	state
+	cluster *rds.DBCluster
+	// failoverTarget is the instance that becomes the writer when a
+	// failover in progress ends.
+	failoverTarget string
+}
+
+type instanceState struct {
+	state
+	instance *rds.DBInstance
+}
+
+func New() *RDS {
+	return &RDS{
+		Region:                 defaultRegion,
+		AccountId:              defaultAccountId,
+		TransitionDescribes:    1,
+		GlobalClusters:         NewGlobalClusters(),
+		subnetGroups:           map[string]*rds.DBSubnetGroup{},
+		clusterParameterGroups: map[string]*clusterParameterGroupState{},
+		dbParameterGroups:      map[string]*dbParameterGroupState{},
+		clusters:               map[string]*clusterState{},
+		instances:              map[string]*instanceState{},
+		clusterSnapshots:       map[string]*clusterSnapshotState{},
+		faults:                 map[string][]error{},
+	}
+}
+
+// InjectFault makes the next times calls to operation (for example
+// "ModifyDBCluster") fail with an awserr carrying code.
+func (f *RDS) InjectFault(operation, code string, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for n := 0; n < times; n++ {
+		f.faults[operation] = append(f.faults[operation], awserr.New(code, "injected fault", nil))
+	}
+}
+
+// SetTransitionDescribes changes TransitionDescribes for transitions that
+// start after the call.
+func (f *RDS) SetTransitionDescribes(describes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.TransitionDescribes = describes
+}
+
+// SetClusterStatus forces a cluster into status for the given number of
+// describe calls before it becomes available again.
+func (f *RDS) SetClusterStatus(clusterIdentifier, status string, describes int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.clusters[clusterIdentifier]
+	if !ok {
+		return notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", clusterIdentifier)
+	}
+	c.cluster.Status = aws.String(status)
+	c.state = state{remaining: describes, next: StatusAvailable}
+
+	return nil
+}
+
+// SetInstanceStatus forces an instance into status for the given number of
+// describe calls before it becomes available again.
+func (f *RDS) SetInstanceStatus(instanceIdentifier, status string, describes int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, ok := f.instances[instanceIdentifier]
+	if !ok {
+		return notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", instanceIdentifier)
+	}
+	i.instance.DBInstanceStatus = aws.String(status)
+	i.state = state{remaining: describes, next: StatusAvailable}
+
+	return nil
+}
+
+// fault pops the next injected error for operation. Callers hold f.mu.
+func (f *RDS) fault(operation string) error {
+	errs := f.faults[operation]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.faults[operation] = errs[1:]
+
+	return errs[0]
+}
+
+func (f *RDS) transition(status string) state {
+	return state{remaining: f.TransitionDescribes, next: status}
+}
+
+// tick advances s by one describe call and reports whether the resource
+// should be removed.
+func tick(s *state, status **string) bool {
+	if s.remaining <= 0 {
+		return false
+	}
+
+	s.remaining--
+	if s.remaining > 0 {
+		return false
+	}
+	if s.next == "" {
+		return true
+	}
+	*status = aws.String(s.next)
+
+	return false
+}
+
+func (f *RDS) arn(resourceType, identifier string) *string {
+	return aws.String(fmt.Sprintf("arn:aws:rds:%s:%s:%s:%s", f.Region, f.AccountId, resourceType, identifier))
+}
+
+func notFound(code, resourceType, identifier string) error {
+	return awserr.New(code, fmt.Sprintf("%s %s not found.", resourceType, identifier), nil)
+}
+
+func now() *time.Time {
+	return aws.Time(time.Now().UTC())
+}
@@ -0,0 +1,444 @@
+package fakerds
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	engineModeGlobal = "global"
+
+	// engineAurora is Aurora MySQL 5.6, the only engine with the global
+	// engine mode.
+	engineAurora = "aurora"
+)
+
+// GlobalClusters holds the global clusters of an account. Unlike everything
+// else they aren't regional: the backends of the regions of one account
+// share them, and their members are clusters in any of those regions.
+type GlobalClusters struct {
+	mu       sync.Mutex
+	clusters map[string]*globalClusterState
+}
+
+type globalClusterState struct {
+	state
+	global *rds.GlobalCluster
+	// masterUsername is the master user of the primary cluster, which the
+	// secondary clusters share.
+	masterUsername *string
+	// writerTarget is the ARN of the member a switchover or failover makes
+	// the primary cluster.
+	writerTarget string
+}
+
+func NewGlobalClusters() *GlobalClusters {
+	return &GlobalClusters{clusters: map[string]*globalClusterState{}}
+}
+
+// memberOf returns the global cluster that the cluster with clusterArn is a
+// member of, or nil. Callers hold g.mu.
+func (g *GlobalClusters) memberOf(clusterArn string) *globalClusterState {
+	for _, s := range g.clusters {
+		if member(s.global, clusterArn) != nil {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (f *RDS) DescribeGlobalClusters(
+	input *rds.DescribeGlobalClustersInput,
+) (*rds.DescribeGlobalClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeGlobalClusters"); err != nil {
+		return nil, err
+	}
+
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	output := &rds.DescribeGlobalClustersOutput{GlobalClusters: []*rds.GlobalCluster{}}
+
+	ids := make([]string, 0)
+	if input.GlobalClusterIdentifier != nil {
+		ids = append(ids, *input.GlobalClusterIdentifier)
+	} else {
+		for id := range g.clusters {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+
+	for _, id := range ids {
+		s, ok := g.clusters[id]
+		if ok && tick(&s.state, &s.global.Status) {
+			delete(g.clusters, id)
+			ok = false
+		}
+		if !ok {
+			if input.GlobalClusterIdentifier != nil {
+				return nil, notFound(rds.ErrCodeGlobalClusterNotFoundFault, "GlobalCluster", id)
+			}
+			continue
+		}
+		s.finishWriterMove()
+		output.GlobalClusters = append(output.GlobalClusters, copyGlobalCluster(s.global))
+	}
+
+	return output, nil
+}
+
+// CreateGlobalCluster creates a global cluster, either empty or with a
+// cluster of this region as its primary cluster. The primary cluster has to
+// run in the global engine mode and gives the global cluster its engine and
+// encryption.
+func (f *RDS) CreateGlobalCluster(input *rds.CreateGlobalClusterInput) (*rds.CreateGlobalClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateGlobalCluster"); err != nil {
+		return nil, err
+	}
+
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := aws.StringValue(input.GlobalClusterIdentifier)
+	if id == "" {
+		return nil, awserr.New("MissingParameter", "GlobalClusterIdentifier is required.", nil)
+	}
+	if _, ok := g.clusters[id]; ok {
+		return nil, awserr.New(rds.ErrCodeGlobalClusterAlreadyExistsFault, "GlobalCluster already exists.", nil)
+	}
+
+	global := &rds.GlobalCluster{
+		GlobalClusterIdentifier: input.GlobalClusterIdentifier,
+		GlobalClusterArn:        aws.String(fmt.Sprintf("arn:aws:rds::%s:global-cluster:%s", f.AccountId, id)),
+		GlobalClusterResourceId: aws.String("cluster-" + id),
+		Status:                  aws.String(StatusCreating),
+		Engine:                  input.Engine,
+		EngineVersion:           input.EngineVersion,
+		StorageEncrypted:        aws.Bool(aws.BoolValue(input.StorageEncrypted)),
+		DeletionProtection:      aws.Bool(aws.BoolValue(input.DeletionProtection)),
+		DatabaseName:            input.DatabaseName,
+		GlobalClusterMembers:    []*rds.GlobalClusterMember{},
+	}
+	var masterUsername *string
+
+	if input.SourceDBClusterIdentifier != nil {
+		c := f.clusterByArn(*input.SourceDBClusterIdentifier)
+		if c == nil {
+			return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", *input.SourceDBClusterIdentifier)
+		}
+		if err := checkGlobalEngine(c.cluster); err != nil {
+			return nil, err
+		}
+		if aws.StringValue(c.cluster.Status) != StatusAvailable || g.memberOf(*c.cluster.DBClusterArn) != nil {
+			return nil, awserr.New(
+				rds.ErrCodeInvalidDBClusterStateFault,
+				fmt.Sprintf("DBCluster %s can't be the source of a global cluster.", aws.StringValue(c.cluster.DBClusterIdentifier)),
+				nil,
+			)
+		}
+
+		global.Engine, global.EngineVersion = c.cluster.Engine, c.cluster.EngineVersion
+		global.StorageEncrypted = c.cluster.StorageEncrypted
+		global.GlobalClusterMembers = append(global.GlobalClusterMembers, &rds.GlobalClusterMember{
+			DBClusterArn: c.cluster.DBClusterArn,
+			IsWriter:     aws.Bool(true),
+			Readers:      []*string{},
+		})
+		masterUsername = c.cluster.MasterUsername
+	} else if global.Engine == nil {
+		return nil, awserr.New("MissingParameter", "Engine is required without SourceDBClusterIdentifier.", nil)
+	} else if !supportsGlobalDatabases(*global.Engine, aws.StringValue(global.EngineVersion)) {
+		return nil, unsupportedGlobalVersion(*global.Engine, aws.StringValue(global.EngineVersion))
+	}
+
+	g.clusters[id] = &globalClusterState{
+		state:          f.transition(StatusAvailable),
+		global:         global,
+		masterUsername: masterUsername,
+	}
+
+	return &rds.CreateGlobalClusterOutput{GlobalCluster: copyGlobalCluster(global)}, nil
+}
+
+// RemoveFromGlobalCluster detaches a member. The primary cluster can only be
+// detached once it is the last member.
+func (f *RDS) RemoveFromGlobalCluster(
+	input *rds.RemoveFromGlobalClusterInput,
+) (*rds.RemoveFromGlobalClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("RemoveFromGlobalCluster"); err != nil {
+		return nil, err
+	}
+
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := aws.StringValue(input.GlobalClusterIdentifier)
+	s, ok := g.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeGlobalClusterNotFoundFault, "GlobalCluster", id)
+	}
+	arn := aws.StringValue(input.DbClusterIdentifier)
+	m := member(s.global, arn)
+	if m == nil {
+		return nil, awserr.New(
+			rds.ErrCodeDBClusterNotFoundFault,
+			fmt.Sprintf("DBCluster %s is not a member of global cluster %s.", arn, id),
+			nil,
+		)
+	}
+	if aws.BoolValue(m.IsWriter) && len(s.global.GlobalClusterMembers) > 1 {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidGlobalClusterStateFault,
+			fmt.Sprintf("The primary cluster of global cluster %s can't be removed while it has secondary clusters.", id),
+			nil,
+		)
+	}
+
+	members := make([]*rds.GlobalClusterMember, 0)
+	for _, other := range s.global.GlobalClusterMembers {
+		if other == m {
+			continue
+		}
+		readers := make([]*string, 0)
+		for _, r := range other.Readers {
+			if aws.StringValue(r) != arn {
+				readers = append(readers, r)
+			}
+		}
+		other.Readers = readers
+		members = append(members, other)
+	}
+	s.global.GlobalClusterMembers = members
+
+	return &rds.RemoveFromGlobalClusterOutput{GlobalCluster: copyGlobalCluster(s.global)}, nil
+}
+
+func (f *RDS) DeleteGlobalCluster(input *rds.DeleteGlobalClusterInput) (*rds.DeleteGlobalClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteGlobalCluster"); err != nil {
+		return nil, err
+	}
+
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := aws.StringValue(input.GlobalClusterIdentifier)
+	s, ok := g.clusters[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeGlobalClusterNotFoundFault, "GlobalCluster", id)
+	}
+	if len(s.global.GlobalClusterMembers) > 0 {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidGlobalClusterStateFault,
+			fmt.Sprintf("Global cluster %s still has member clusters.", id),
+			nil,
+		)
+	}
+
+	s.global.Status = aws.String(StatusDeleting)
+	s.state = f.transition("")
+
+	return &rds.DeleteGlobalClusterOutput{GlobalCluster: copyGlobalCluster(s.global)}, nil
+}
+
+// joinGlobalCluster adds cluster, which is being created from input, to the
+// global cluster input names. Without a primary cluster yet, it becomes the
+// primary cluster. Otherwise it becomes a read-only secondary cluster that
+// takes its master user from the primary cluster; it can't set one itself,
+// and there is at most one cluster of a global cluster per region. Callers
+// hold f.mu.
+func (f *RDS) joinGlobalCluster(input *rds.CreateDBClusterInput, cluster *rds.DBCluster) error {
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := aws.StringValue(input.GlobalClusterIdentifier)
+	s, ok := g.clusters[id]
+	if !ok {
+		return notFound(rds.ErrCodeGlobalClusterNotFoundFault, "GlobalCluster", id)
+	}
+	if aws.StringValue(s.global.Status) != StatusAvailable {
+		return awserr.New(
+			rds.ErrCodeInvalidGlobalClusterStateFault,
+			fmt.Sprintf("Global cluster %s is not currently in the available state.", id),
+			nil,
+		)
+	}
+	if err := checkGlobalEngine(cluster); err != nil {
+		return err
+	}
+	if aws.StringValue(cluster.Engine) != aws.StringValue(s.global.Engine) ||
+		aws.StringValue(cluster.EngineVersion) != aws.StringValue(s.global.EngineVersion) {
+		return awserr.New(
+			"InvalidParameterCombination",
+			fmt.Sprintf(
+				"Global cluster %s runs %s %s.",
+				id, aws.StringValue(s.global.Engine), aws.StringValue(s.global.EngineVersion),
+			),
+			nil,
+		)
+	}
+
+	var writer *rds.GlobalClusterMember
+	for _, m := range s.global.GlobalClusterMembers {
+		if aws.BoolValue(m.IsWriter) {
+			writer = m
+		}
+		if arnRegion(aws.StringValue(m.DBClusterArn)) == f.Region {
+			return awserr.New(
+				"InvalidParameterCombination",
+				fmt.Sprintf("Global cluster %s already has a cluster in %s.", id, f.Region),
+				nil,
+			)
+		}
+	}
+
+	if writer == nil {
+		if input.MasterUsername == nil {
+			return awserr.New("MissingParameter", "MasterUsername is required for the primary cluster.", nil)
+		}
+		s.masterUsername = input.MasterUsername
+	} else {
+		if input.MasterUsername != nil || input.MasterUserPassword != nil {
+			return awserr.New(
+				"InvalidParameterCombination",
+				"A secondary cluster can't set a master username or password.",
+				nil,
+			)
+		}
+		cluster.MasterUsername = s.masterUsername
+		writer.Readers = append(writer.Readers, cluster.DBClusterArn)
+	}
+
+	s.global.GlobalClusterMembers = append(s.global.GlobalClusterMembers, &rds.GlobalClusterMember{
+		DBClusterArn: cluster.DBClusterArn,
+		IsWriter:     aws.Bool(writer == nil),
+		Readers:      []*string{},
+	})
+
+	return nil
+}
+
+// checkNotGlobalMember fails when cluster is a member of a global cluster,
+// which keeps it from being deleted or renamed. Callers hold f.mu.
+func (f *RDS) checkNotGlobalMember(cluster *rds.DBCluster) error {
+	g := f.GlobalClusters
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if s := g.memberOf(aws.StringValue(cluster.DBClusterArn)); s != nil {
+		return awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf(
+				"DBCluster %s is a member of global cluster %s, remove it from the global cluster first.",
+				aws.StringValue(cluster.DBClusterIdentifier), aws.StringValue(s.global.GlobalClusterIdentifier),
+			),
+			nil,
+		)
+	}
+
+	return nil
+}
+
+// clusterByArn finds a cluster of this region by ARN or identifier. Callers
+// hold f.mu.
+func (f *RDS) clusterByArn(arn string) *clusterState {
+	for id, c := range f.clusters {
+		if id == arn || aws.StringValue(c.cluster.DBClusterArn) == arn {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// arnRegion returns the region of an ARN, which is empty for the ARNs of
+// global resources.
+func arnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 5)
+	if len(parts) < 5 {
+		return ""
+	}
+
+	return parts[3]
+}
+
+func member(global *rds.GlobalCluster, clusterArn string) *rds.GlobalClusterMember {
+	for _, m := range global.GlobalClusterMembers {
+		if aws.StringValue(m.DBClusterArn) == clusterArn {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func copyGlobalCluster(global *rds.GlobalCluster) *rds.GlobalCluster {
+	g := *global
+	g.GlobalClusterMembers = make([]*rds.GlobalClusterMember, 0)
+	for _, m := range global.GlobalClusterMembers {
+		member := *m
+		member.Readers = append([]*string{}, m.Readers...)
+		g.GlobalClusterMembers = append(g.GlobalClusterMembers, &member)
+	}
+
+	return &g
+}
+
+// checkGlobalEngine returns the error RDS gives for cluster joining a global
+// cluster. Aurora MySQL 5.6 clusters need the global engine mode, clusters of
+// later engines the provisioned one, and either needs a version that
+// supports global databases.
+func checkGlobalEngine(cluster *rds.DBCluster) error {
+	engine, version := aws.StringValue(cluster.Engine), aws.StringValue(cluster.EngineVersion)
+	mode := engineModeProvisioned
+	if engine == engineAurora {
+		mode = engineModeGlobal
+	}
+	if aws.StringValue(cluster.EngineMode) != mode {
+		return awserr.New(
+			"InvalidParameterCombination",
+			fmt.Sprintf(
+				"Cluster %s must have the %s engine mode to be part of a global cluster.",
+				aws.StringValue(cluster.DBClusterIdentifier), mode,
+			),
+			nil,
+		)
+	}
+	if !supportsGlobalDatabases(engine, version) {
+		return unsupportedGlobalVersion(engine, version)
+	}
+
+	return nil
+}
+
+func unsupportedGlobalVersion(engine, version string) error {
+	return awserr.New(
+		"InvalidParameterCombination",
+		fmt.Sprintf("The engine version %s %s doesn't support global databases.", engine, version),
+		nil,
+	)
+}
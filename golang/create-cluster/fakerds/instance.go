@@ -0,0 +1,334 @@
+package fakerds
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const defaultPromotionTier = 1
+
+func (f *RDS) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBInstances"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBInstancesOutput{}
+
+	if input.DBInstanceIdentifier != nil {
+		id := *input.DBInstanceIdentifier
+		i, ok := f.instances[id]
+		if !ok {
+			return nil, notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", id)
+		}
+		if tick(&i.state, &i.instance.DBInstanceStatus) {
+			f.removeInstance(id)
+			return nil, notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", id)
+		}
+		output.DBInstances = append(output.DBInstances, copyInstance(i.instance))
+		return output, nil
+	}
+
+	for id, i := range f.instances {
+		if tick(&i.state, &i.instance.DBInstanceStatus) {
+			f.removeInstance(id)
+			continue
+		}
+		output.DBInstances = append(output.DBInstances, copyInstance(i.instance))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBInstance(input *rds.CreateDBInstanceInput) (*rds.CreateDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBInstance"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBInstanceIdentifier)
+	if _, ok := f.instances[id]; ok {
+		return nil, awserr.New(rds.ErrCodeDBInstanceAlreadyExistsFault, "DB instance already exists", nil)
+	}
+
+	clusterId := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[clusterId]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", clusterId)
+	}
+
+	if isServerless(c.cluster) {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("DBCluster %s is serverless and can't have DB instances.", clusterId),
+			nil,
+		)
+	}
+
+	err := f.checkOrderable(
+		aws.StringValue(c.cluster.Engine), aws.StringValue(c.cluster.EngineVersion),
+		aws.StringValue(input.DBInstanceClass), input.AvailabilityZone,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = checkServerlessV2(c.cluster, aws.StringValue(input.DBInstanceClass))
+	if err != nil {
+		return nil, err
+	}
+
+	promotionTier := input.PromotionTier
+	if promotionTier == nil {
+		promotionTier = aws.Int64(defaultPromotionTier)
+	}
+	zone := input.AvailabilityZone
+	if zone == nil {
+		zone = aws.String(f.Region + "a")
+	}
+	maintenanceWindow := input.PreferredMaintenanceWindow
+	if maintenanceWindow == nil {
+		maintenanceWindow = aws.String(defaultMaintenanceWindow)
+	}
+	parameterGroup := aws.String("default." + aws.StringValue(input.Engine))
+	if input.DBParameterGroupName != nil {
+		if _, ok := f.dbParameterGroups[*input.DBParameterGroupName]; !ok {
+			return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", *input.DBParameterGroupName)
+		}
+		parameterGroup = input.DBParameterGroupName
+	}
+
+	instance := &rds.DBInstance{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		DBInstanceArn:        f.arn("db", id),
+		DBClusterIdentifier:  input.DBClusterIdentifier,
+		DBInstanceClass:      input.DBInstanceClass,
+		Engine:               input.Engine,
+		EngineVersion:        c.cluster.EngineVersion,
+		AvailabilityZone:     zone,
+		PromotionTier:        promotionTier,
+		DBInstanceStatus:     aws.String(StatusCreating),
+		InstanceCreateTime:   now(),
+
+		PreferredMaintenanceWindow: maintenanceWindow,
+		DBParameterGroups: []*rds.DBParameterGroupStatus{{
+			DBParameterGroupName: parameterGroup,
+			ParameterApplyStatus: aws.String(parameterStatusInSync),
+		}},
+		Endpoint: &rds.Endpoint{
+			Address: f.instanceAddress(id),
+			Port:    c.cluster.Port,
+		},
+	}
+	f.instances[id] = &instanceState{
+		state:    f.transition(StatusAvailable),
+		instance: instance,
+	}
+
+	c.cluster.DBClusterMembers = append(c.cluster.DBClusterMembers, &rds.DBClusterMember{
+		DBInstanceIdentifier: input.DBInstanceIdentifier,
+		IsClusterWriter:      aws.Bool(len(c.cluster.DBClusterMembers) == 0),
+		PromotionTier:        promotionTier,
+
+		DBClusterParameterGroupStatus: aws.String(parameterStatusInSync),
+	})
+
+	return &rds.CreateDBInstanceOutput{DBInstance: copyInstance(instance)}, nil
+}
+
+func (f *RDS) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ModifyDBInstance"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", id)
+	}
+	if aws.StringValue(i.instance.DBInstanceStatus) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBInstanceStateFault,
+			fmt.Sprintf("Database instance %s is not in available state.", id),
+			nil,
+		)
+	}
+
+	if input.DBInstanceClass != nil {
+		if c, ok := f.clusters[aws.StringValue(i.instance.DBClusterIdentifier)]; ok {
+			err := checkServerlessV2(c.cluster, *input.DBInstanceClass)
+			if err != nil {
+				return nil, err
+			}
+		}
+		i.instance.DBInstanceClass = input.DBInstanceClass
+	}
+	if input.PromotionTier != nil {
+		i.instance.PromotionTier = input.PromotionTier
+		if m := f.member(i.instance); m != nil {
+			m.PromotionTier = input.PromotionTier
+		}
+	}
+	if name := input.DBParameterGroupName; name != nil && *name != aws.StringValue(i.parameterGroupName()) {
+		if _, ok := f.dbParameterGroups[*name]; !ok {
+			return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", *name)
+		}
+		// Like a new cluster parameter group, it is picked up on reboot.
+		i.instance.DBParameterGroups = []*rds.DBParameterGroupStatus{{
+			DBParameterGroupName: name,
+			ParameterApplyStatus: aws.String(parameterStatusPendingReboot),
+		}}
+	}
+	if input.PreferredMaintenanceWindow != nil {
+		i.instance.PreferredMaintenanceWindow = input.PreferredMaintenanceWindow
+	}
+	if input.NewDBInstanceIdentifier != nil {
+		newId := *input.NewDBInstanceIdentifier
+		if m := f.member(i.instance); m != nil {
+			m.DBInstanceIdentifier = input.NewDBInstanceIdentifier
+		}
+		i.instance.DBInstanceIdentifier = input.NewDBInstanceIdentifier
+		i.instance.DBInstanceArn = f.arn("db", newId)
+		i.instance.Endpoint.Address = f.instanceAddress(newId)
+		delete(f.instances, id)
+		f.instances[newId] = i
+	}
+
+	i.instance.DBInstanceStatus = aws.String(StatusModifying)
+	i.state = f.transition(StatusAvailable)
+
+	return &rds.ModifyDBInstanceOutput{DBInstance: copyInstance(i.instance)}, nil
+}
+
+// RebootDBInstance applies any pending parameter changes of the instance.
+func (f *RDS) RebootDBInstance(input *rds.RebootDBInstanceInput) (*rds.RebootDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("RebootDBInstance"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", id)
+	}
+	if aws.StringValue(i.instance.DBInstanceStatus) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBInstanceStateFault,
+			fmt.Sprintf("Database instance %s is not in available state.", id),
+			nil,
+		)
+	}
+
+	if m := f.member(i.instance); m != nil {
+		m.DBClusterParameterGroupStatus = aws.String(parameterStatusInSync)
+	}
+	for _, s := range i.instance.DBParameterGroups {
+		s.ParameterApplyStatus = aws.String(parameterStatusInSync)
+	}
+	i.instance.DBInstanceStatus = aws.String(StatusRebooting)
+	i.state = f.transition(StatusAvailable)
+
+	return &rds.RebootDBInstanceOutput{DBInstance: copyInstance(i.instance)}, nil
+}
+
+func (f *RDS) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBInstance"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance", id)
+	}
+
+	i.instance.DBInstanceStatus = aws.String(StatusDeleting)
+	i.state = f.transition("")
+
+	return &rds.DeleteDBInstanceOutput{DBInstance: copyInstance(i.instance)}, nil
+}
+
+func (f *RDS) instanceAddress(id string) *string {
+	return aws.String(fmt.Sprintf("%s.fake.%s.rds.amazonaws.com", id, f.Region))
+}
+
+// member finds the cluster membership entry for instance. Callers hold f.mu.
+func (f *RDS) member(instance *rds.DBInstance) *rds.DBClusterMember {
+	c, ok := f.clusters[aws.StringValue(instance.DBClusterIdentifier)]
+	if !ok {
+		return nil
+	}
+	for _, m := range c.cluster.DBClusterMembers {
+		if aws.StringValue(m.DBInstanceIdentifier) == aws.StringValue(instance.DBInstanceIdentifier) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// removeInstance drops a deleted instance and its cluster membership,
+// promoting another member if it was the writer. Callers hold f.mu.
+func (f *RDS) removeInstance(id string) {
+	i := f.instances[id]
+	delete(f.instances, id)
+
+	c, ok := f.clusters[aws.StringValue(i.instance.DBClusterIdentifier)]
+	if !ok {
+		return
+	}
+
+	wasWriter := false
+	members := make([]*rds.DBClusterMember, 0)
+	for _, m := range c.cluster.DBClusterMembers {
+		if aws.StringValue(m.DBInstanceIdentifier) == id {
+			wasWriter = aws.BoolValue(m.IsClusterWriter)
+			continue
+		}
+		members = append(members, m)
+	}
+	if wasWriter && len(members) > 0 {
+		members[0].IsClusterWriter = aws.Bool(true)
+	}
+	c.cluster.DBClusterMembers = members
+}
+
+// parameterGroupName returns the name of the DB parameter group of the
+// instance.
+func (i *instanceState) parameterGroupName() *string {
+	if len(i.instance.DBParameterGroups) == 0 {
+		return nil
+	}
+
+	return i.instance.DBParameterGroups[0].DBParameterGroupName
+}
+
+func copyInstance(instance *rds.DBInstance) *rds.DBInstance {
+	i := *instance
+	if instance.Endpoint != nil {
+		endpoint := *instance.Endpoint
+		i.Endpoint = &endpoint
+	}
+	i.DBParameterGroups = make([]*rds.DBParameterGroupStatus, 0)
+	for _, g := range instance.DBParameterGroups {
+		status := *g
+		i.DBParameterGroups = append(i.DBParameterGroups, &status)
+	}
+
+	return &i
+}
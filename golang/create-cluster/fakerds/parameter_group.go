@@ -0,0 +1,413 @@
+package fakerds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	applyTypeStatic  = "static"
+	applyTypeDynamic = "dynamic"
+
+	parameterStatusInSync        = "in-sync"
+	parameterStatusPendingReboot = "pending-reboot"
+
+	maxParametersPerCall = 20
+)
+
+// defaultClusterParameters is the small catalog every fake cluster
+// parameter group starts from, whatever its family.
+var defaultClusterParameters = []rds.Parameter{
+	{ParameterName: aws.String("binlog_format"), ParameterValue: aws.String("OFF"), ApplyType: aws.String(applyTypeStatic)},
+	{ParameterName: aws.String("character_set_server"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("server_audit_logging"), ParameterValue: aws.String("0"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("time_zone"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("rds.force_ssl"), ParameterValue: aws.String("0"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("shared_preload_libraries"), ParameterValue: aws.String("pg_stat_statements"), ApplyType: aws.String(applyTypeStatic)},
+}
+
+// defaultDBParameters is the catalog for fake DB parameter groups, which
+// hold the instance level settings.
+var defaultDBParameters = []rds.Parameter{
+	{ParameterName: aws.String("long_query_time"), ParameterValue: aws.String("10"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("slow_query_log"), ParameterValue: aws.String("0"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("performance_schema"), ParameterValue: aws.String("0"), ApplyType: aws.String(applyTypeStatic)},
+	{ParameterName: aws.String("log_min_duration_statement"), ParameterValue: aws.String("-1"), ApplyType: aws.String(applyTypeDynamic)},
+	{ParameterName: aws.String("max_connections"), ApplyType: aws.String(applyTypeDynamic)},
+}
+
+type clusterParameterGroupState struct {
+	group      *rds.DBClusterParameterGroup
+	parameters map[string]*rds.Parameter
+}
+
+type dbParameterGroupState struct {
+	group      *rds.DBParameterGroup
+	parameters map[string]*rds.Parameter
+}
+
+func (f *RDS) DescribeDBClusterParameterGroups(
+	input *rds.DescribeDBClusterParameterGroupsInput,
+) (*rds.DescribeDBClusterParameterGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBClusterParameterGroups"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBClusterParameterGroupsOutput{}
+
+	if input.DBClusterParameterGroupName != nil {
+		name := *input.DBClusterParameterGroupName
+		g, ok := f.clusterParameterGroups[name]
+		if !ok {
+			return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBClusterParameterGroup", name)
+		}
+		output.DBClusterParameterGroups = append(output.DBClusterParameterGroups, copyClusterParameterGroup(g.group))
+		return output, nil
+	}
+
+	for _, g := range f.clusterParameterGroups {
+		output.DBClusterParameterGroups = append(output.DBClusterParameterGroups, copyClusterParameterGroup(g.group))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBClusterParameterGroup(
+	input *rds.CreateDBClusterParameterGroupInput,
+) (*rds.CreateDBClusterParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBClusterParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	if _, ok := f.clusterParameterGroups[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupAlreadyExistsFault, "Parameter group already exists.", nil)
+	}
+
+	group := &rds.DBClusterParameterGroup{
+		DBClusterParameterGroupName: input.DBClusterParameterGroupName,
+		DBClusterParameterGroupArn:  f.arn("cluster-pg", name),
+		DBParameterGroupFamily:      input.DBParameterGroupFamily,
+		Description:                 input.Description,
+	}
+	f.clusterParameterGroups[name] = &clusterParameterGroupState{
+		group:      group,
+		parameters: newParameters(defaultClusterParameters),
+	}
+
+	return &rds.CreateDBClusterParameterGroupOutput{DBClusterParameterGroup: copyClusterParameterGroup(group)}, nil
+}
+
+func (f *RDS) DescribeDBClusterParameters(
+	input *rds.DescribeDBClusterParametersInput,
+) (*rds.DescribeDBClusterParametersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBClusterParameters"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	g, ok := f.clusterParameterGroups[name]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBClusterParameterGroup", name)
+	}
+
+	return &rds.DescribeDBClusterParametersOutput{
+		Parameters: listParameters(g.parameters, aws.StringValue(input.Source)),
+	}, nil
+}
+
+// ModifyDBClusterParameterGroup marks every member of the clusters using the
+// group pending-reboot when a static parameter changes.
+func (f *RDS) ModifyDBClusterParameterGroup(
+	input *rds.ModifyDBClusterParameterGroupInput,
+) (*rds.DBClusterParameterGroupNameMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ModifyDBClusterParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	g, ok := f.clusterParameterGroups[name]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBClusterParameterGroup", name)
+	}
+
+	static, err := modifyParameters(g.parameters, input.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if static {
+		for _, c := range f.clusters {
+			if aws.StringValue(c.cluster.DBClusterParameterGroup) != name {
+				continue
+			}
+			for _, m := range c.cluster.DBClusterMembers {
+				m.DBClusterParameterGroupStatus = aws.String(parameterStatusPendingReboot)
+			}
+		}
+	}
+
+	return &rds.DBClusterParameterGroupNameMessage{DBClusterParameterGroupName: input.DBClusterParameterGroupName}, nil
+}
+
+func (f *RDS) DeleteDBClusterParameterGroup(
+	input *rds.DeleteDBClusterParameterGroupInput,
+) (*rds.DeleteDBClusterParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBClusterParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	if _, ok := f.clusterParameterGroups[name]; !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBClusterParameterGroup", name)
+	}
+	for _, c := range f.clusters {
+		if aws.StringValue(c.cluster.DBClusterParameterGroup) == name {
+			return nil, awserr.New(
+				rds.ErrCodeInvalidDBParameterGroupStateFault,
+				fmt.Sprintf("Parameter group %s is in use by %s.", name, *c.cluster.DBClusterIdentifier),
+				nil,
+			)
+		}
+	}
+	delete(f.clusterParameterGroups, name)
+
+	return &rds.DeleteDBClusterParameterGroupOutput{}, nil
+}
+
+func (f *RDS) DescribeDBParameterGroups(
+	input *rds.DescribeDBParameterGroupsInput,
+) (*rds.DescribeDBParameterGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBParameterGroups"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBParameterGroupsOutput{}
+
+	if input.DBParameterGroupName != nil {
+		name := *input.DBParameterGroupName
+		g, ok := f.dbParameterGroups[name]
+		if !ok {
+			return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", name)
+		}
+		output.DBParameterGroups = append(output.DBParameterGroups, copyDBParameterGroup(g.group))
+		return output, nil
+	}
+
+	for _, g := range f.dbParameterGroups {
+		output.DBParameterGroups = append(output.DBParameterGroups, copyDBParameterGroup(g.group))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBParameterGroup(input *rds.CreateDBParameterGroupInput) (*rds.CreateDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	if _, ok := f.dbParameterGroups[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBParameterGroupAlreadyExistsFault, "Parameter group already exists.", nil)
+	}
+
+	group := &rds.DBParameterGroup{
+		DBParameterGroupName:   input.DBParameterGroupName,
+		DBParameterGroupArn:    f.arn("pg", name),
+		DBParameterGroupFamily: input.DBParameterGroupFamily,
+		Description:            input.Description,
+	}
+	f.dbParameterGroups[name] = &dbParameterGroupState{
+		group:      group,
+		parameters: newParameters(defaultDBParameters),
+	}
+
+	return &rds.CreateDBParameterGroupOutput{DBParameterGroup: copyDBParameterGroup(group)}, nil
+}
+
+func (f *RDS) DescribeDBParameters(input *rds.DescribeDBParametersInput) (*rds.DescribeDBParametersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBParameters"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	g, ok := f.dbParameterGroups[name]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", name)
+	}
+
+	return &rds.DescribeDBParametersOutput{
+		Parameters: listParameters(g.parameters, aws.StringValue(input.Source)),
+	}, nil
+}
+
+// ModifyDBParameterGroup marks every instance using the group pending-reboot
+// when a static parameter changes.
+func (f *RDS) ModifyDBParameterGroup(input *rds.ModifyDBParameterGroupInput) (*rds.DBParameterGroupNameMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ModifyDBParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	g, ok := f.dbParameterGroups[name]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", name)
+	}
+
+	static, err := modifyParameters(g.parameters, input.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if static {
+		for _, i := range f.instances {
+			for _, s := range i.instance.DBParameterGroups {
+				if aws.StringValue(s.DBParameterGroupName) == name {
+					s.ParameterApplyStatus = aws.String(parameterStatusPendingReboot)
+				}
+			}
+		}
+	}
+
+	return &rds.DBParameterGroupNameMessage{DBParameterGroupName: input.DBParameterGroupName}, nil
+}
+
+func (f *RDS) DeleteDBParameterGroup(input *rds.DeleteDBParameterGroupInput) (*rds.DeleteDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBParameterGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBParameterGroupName)
+	if _, ok := f.dbParameterGroups[name]; !ok {
+		return nil, notFound(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup", name)
+	}
+	for _, i := range f.instances {
+		for _, s := range i.instance.DBParameterGroups {
+			if aws.StringValue(s.DBParameterGroupName) == name {
+				return nil, awserr.New(
+					rds.ErrCodeInvalidDBParameterGroupStateFault,
+					fmt.Sprintf("Parameter group %s is in use by %s.", name, *i.instance.DBInstanceIdentifier),
+					nil,
+				)
+			}
+		}
+	}
+	delete(f.dbParameterGroups, name)
+
+	return &rds.DeleteDBParameterGroupOutput{}, nil
+}
+
+func newParameters(defaults []rds.Parameter) map[string]*rds.Parameter {
+	parameters := map[string]*rds.Parameter{}
+	for _, p := range defaults {
+		p := p
+		p.Source = aws.String("engine-default")
+		p.IsModifiable = aws.Bool(true)
+		parameters[*p.ParameterName] = &p
+	}
+
+	return parameters
+}
+
+// listParameters returns copies sorted by name, optionally only those from
+// source.
+func listParameters(parameters map[string]*rds.Parameter, source string) []*rds.Parameter {
+	list := make([]*rds.Parameter, 0)
+	for _, p := range parameters {
+		if source != "" && aws.StringValue(p.Source) != source {
+			continue
+		}
+		c := *p
+		list = append(list, &c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return *list[i].ParameterName < *list[j].ParameterName
+	})
+
+	return list
+}
+
+// modifyParameters validates and applies updates the way RDS does and
+// reports whether a static parameter changed. Nothing is applied if any
+// update is rejected.
+func modifyParameters(parameters map[string]*rds.Parameter, updates []*rds.Parameter) (bool, error) {
+	if len(updates) == 0 || len(updates) > maxParametersPerCall {
+		return false, awserr.New(
+			"InvalidParameterValue",
+			fmt.Sprintf("Between 1 and %d parameters can be modified in one call.", maxParametersPerCall),
+			nil,
+		)
+	}
+
+	for _, u := range updates {
+		p, ok := parameters[aws.StringValue(u.ParameterName)]
+		if !ok {
+			return false, awserr.New(
+				"InvalidParameterValue",
+				fmt.Sprintf("Unknown parameter %s.", aws.StringValue(u.ParameterName)),
+				nil,
+			)
+		}
+		if aws.StringValue(p.ApplyType) == applyTypeStatic && aws.StringValue(u.ApplyMethod) == rds.ApplyMethodImmediate {
+			return false, awserr.New(
+				"InvalidParameterCombination",
+				fmt.Sprintf("cannot use immediate apply method for static parameter %s", *u.ParameterName),
+				nil,
+			)
+		}
+	}
+
+	static := false
+	for _, u := range updates {
+		p := parameters[*u.ParameterName]
+		p.ParameterValue = u.ParameterValue
+		p.Source = aws.String("user")
+		if aws.StringValue(p.ApplyType) == applyTypeStatic {
+			static = true
+		}
+	}
+
+	return static, nil
+}
+
+func copyClusterParameterGroup(group *rds.DBClusterParameterGroup) *rds.DBClusterParameterGroup {
+	g := *group
+	return &g
+}
+
+func copyDBParameterGroup(group *rds.DBParameterGroup) *rds.DBParameterGroup {
+	g := *group
+	return &g
+}
@@ -0,0 +1,152 @@
+package fakerds
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeQuery fills the input struct v from an RDS Query request body. It is
+// the reverse of the SDK's queryutil.Parse for the shapes RDS uses.
+func decodeQuery(values url.Values, v interface{}) error {
+	return decodeStruct(values, reflect.ValueOf(v).Elem(), "")
+}
+
+func decodeStruct(values url.Values, value reflect.Value, prefix string) error {
+	t := value.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if field.PkgPath != "" || field.Name == "_" {
+			continue
+		}
+
+		name := field.Tag.Get("locationName")
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		err := decodeValue(values, value.Field(n), name, field.Tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeValue(values url.Values, value reflect.Value, name string, tag reflect.StructTag) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		return decodeList(values, value, name, tag)
+	case reflect.Ptr:
+		elemType := value.Type().Elem()
+		if elemType.Kind() == reflect.Struct && elemType != reflect.TypeOf(time.Time{}) {
+			if !hasPrefix(values, name+".") {
+				return nil
+			}
+			elem := reflect.New(elemType)
+			err := decodeStruct(values, elem.Elem(), name)
+			if err != nil {
+				return err
+			}
+			value.Set(elem)
+			return nil
+		}
+
+		if _, ok := values[name]; !ok {
+			return nil
+		}
+		elem := reflect.New(elemType)
+		err := decodeScalar(values.Get(name), elem.Elem(), name)
+		if err != nil {
+			return err
+		}
+		value.Set(elem)
+	}
+
+	return nil
+}
+
+func decodeList(values url.Values, value reflect.Value, name string, tag reflect.StructTag) error {
+	member := tag.Get("locationNameList")
+	if member == "" {
+		member = "member"
+	}
+
+	if _, ok := values[name]; ok {
+		// An explicitly empty list.
+		value.Set(reflect.MakeSlice(value.Type(), 0, 0))
+		return nil
+	}
+
+	list := reflect.MakeSlice(value.Type(), 0, 0)
+	for n := 1; ; n++ {
+		key := fmt.Sprintf("%s.%s.%d", name, member, n)
+		if _, ok := values[key]; !ok && !hasPrefix(values, key+".") {
+			break
+		}
+
+		elem := reflect.New(value.Type().Elem()).Elem()
+		err := decodeValue(values, elem, key, "")
+		if err != nil {
+			return err
+		}
+		list = reflect.Append(list, elem)
+	}
+
+	if list.Len() > 0 {
+		value.Set(list)
+	}
+
+	return nil
+}
+
+func decodeScalar(s string, value reflect.Value, name string) error {
+	switch value.Interface().(type) {
+	case string:
+		value.SetString(s)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		value.SetBool(b)
+	case int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		value.SetInt(i)
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		value.SetFloat(f)
+	case time.Time:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		value.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("%s: unsupported type %s", name, value.Type())
+	}
+
+	return nil
+}
+
+func hasPrefix(values url.Values, prefix string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package fakerds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	log "github.com/sirupsen/logrus"
+)
+
+// serverActions are the RDS Query API actions the server answers. Each one
+// must be implemented directly on *RDS.
+var serverActions = map[string]bool{
+	"DescribeDBSubnetGroups":             true,
+	"CreateDBSubnetGroup":                true,
+	"ModifyDBSubnetGroup":                true,
+	"DeleteDBSubnetGroup":                true,
+	"DescribeDBClusterParameterGroups":   true,
+	"CreateDBClusterParameterGroup":      true,
+	"DescribeDBClusterParameters":        true,
+	"ModifyDBClusterParameterGroup":      true,
+	"DeleteDBClusterParameterGroup":      true,
+	"DescribeDBParameterGroups":          true,
+	"CreateDBParameterGroup":             true,
+	"DescribeDBParameters":               true,
+	"ModifyDBParameterGroup":             true,
+	"DeleteDBParameterGroup":             true,
+	"DescribeDBClusters":                 true,
+	"CreateDBCluster":                    true,
+	"ModifyDBCluster":                    true,
+	"DeleteDBCluster":                    true,
+	"FailoverDBCluster":                  true,
+	"DescribeDBInstances":                true,
+	"CreateDBInstance":                   true,
+	"ModifyDBInstance":                   true,
+	"DeleteDBInstance":                   true,
+	"RebootDBInstance":                   true,
+	"DescribeDBClusterSnapshots":         true,
+	"CreateDBClusterSnapshot":            true,
+	"DeleteDBClusterSnapshot":            true,
+	"ListTagsForResource":                true,
+	"RestoreDBClusterFromSnapshot":       true,
+	"RestoreDBClusterToPointInTime":      true,
+	"DescribeDBEngineVersions":           true,
+	"DescribeOrderableDBInstanceOptions": true,
+	"DescribeGlobalClusters":             true,
+	"CreateGlobalCluster":                true,
+	"RemoveFromGlobalCluster":            true,
+	"DeleteGlobalCluster":                true,
+	"SwitchoverGlobalCluster":            true,
+	"FailoverGlobalCluster":              true,
+}
+
+// Server speaks the RDS Query/XML protocol on top of an in-memory RDS so the
+// real binary can be pointed at it with a custom endpoint. It also answers
+// the EC2 DescribeSubnets calls signed for the ec2 service.
+//
+// Besides the RDS actions it serves a few control endpoints for tests:
+//
+//	POST /_fake/fault       operation=ModifyDBCluster&code=InvalidDBClusterStateFault&times=1
+//	POST /_fake/status      cluster=ID or instance=ID, status=backing-up&describes=3
+//	POST /_fake/transition  describes=5
+//
+// With more than one backend, each one serves its own region: RDS actions go
+// to the region they are signed for, and the control endpoints take an
+// optional region=REGION, defaulting to the first backend.
+type Server struct {
+	backends  []*RDS
+	mux       *http.ServeMux
+	requestId uint64
+}
+
+func NewServer(backends ...*RDS) *Server {
+	s := &Server{
+		backends: backends,
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/_fake/fault", s.handleFault)
+	s.mux.HandleFunc("/_fake/status", s.handleStatus)
+	s.mux.HandleFunc("/_fake/transition", s.handleTransition)
+	s.mux.HandleFunc("/", s.handleAction)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
+	requestId := fmt.Sprintf("fake-%d", atomic.AddUint64(&s.requestId, 1))
+
+	err := r.ParseForm()
+	if err != nil {
+		writeError(w, requestId, http.StatusBadRequest, "MalformedQueryString", err.Error())
+		return
+	}
+
+	if signedService(r) == "ec2" {
+		s.handleEC2(w, r, requestId)
+		return
+	}
+
+	action := r.Form.Get("Action")
+	if !serverActions[action] {
+		writeError(w, requestId, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("%s is not supported.", action))
+		return
+	}
+	log.Debugf("fakerds: %s %v", action, r.Form)
+
+	backend := s.backend(signedRegion(r))
+	if backend == nil {
+		writeError(w, requestId, http.StatusBadRequest, "InvalidClientTokenId", fmt.Sprintf("Region %s is not served.", signedRegion(r)))
+		return
+	}
+
+	method := reflect.ValueOf(backend).MethodByName(action)
+	input := reflect.New(method.Type().In(0).Elem())
+
+	err = decodeQuery(r.Form, input.Interface())
+	if err != nil {
+		writeError(w, requestId, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+
+	results := method.Call([]reflect.Value{input})
+	if !results[1].IsNil() {
+		err := results[1].Interface().(error)
+		code, status, message := "InternalFailure", http.StatusInternalServerError, err.Error()
+		if aerr, ok := err.(awserr.Error); ok {
+			code, status, message = aerr.Code(), http.StatusBadRequest, aerr.Message()
+			if strings.Contains(code, "NotFound") {
+				status = http.StatusNotFound
+			}
+		}
+		writeError(w, requestId, status, code, message)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	err = encodeResponse(xml.NewEncoder(buf), action, requestId, results[0].Interface())
+	if err != nil {
+		writeError(w, requestId, http.StatusInternalServerError, "InternalFailure", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(buf.Bytes())
+}
+
+// backend returns the backend of region, or the first one when region is
+// empty or there is only one. It returns nil for a region that isn't served.
+func (s *Server) backend(region string) *RDS {
+	if region == "" || len(s.backends) == 1 {
+		return s.backends[0]
+	}
+	for _, b := range s.backends {
+		if b.Region == region {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// signedRegion returns the region in the credential scope of a Signature
+// Version 4 Authorization header, or "" if r isn't signed.
+func signedRegion(r *http.Request) string {
+	return credentialScope(r, 2)
+}
+
+// signedService returns the service in the credential scope of r, or "" if
+// r isn't signed.
+func signedService(r *http.Request) string {
+	return credentialScope(r, 3)
+}
+
+// credentialScope returns part n of the credential scope of a Signature
+// Version 4 Authorization header: key id, date, region, service and
+// terminator.
+func credentialScope(r *http.Request, n int) string {
+	auth := r.Header.Get("Authorization")
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return ""
+	}
+	scope := strings.Split(strings.SplitN(auth[i+len("Credential="):], ",", 2)[0], "/")
+	if len(scope) <= n {
+		return ""
+	}
+
+	return scope[n]
+}
+
+// controlBackend returns the backend a control endpoint request is for,
+// writing an error if its region isn't served.
+func (s *Server) controlBackend(w http.ResponseWriter, r *http.Request) *RDS {
+	region := r.FormValue("region")
+	backend := s.backend(region)
+	if backend == nil {
+		http.Error(w, fmt.Sprintf("region %s is not served", region), http.StatusBadRequest)
+	}
+
+	return backend
+}
+
+func (s *Server) handleFault(w http.ResponseWriter, r *http.Request) {
+	times, err := strconv.Atoi(r.FormValue("times"))
+	if err != nil {
+		times = 1
+	}
+	operation, code := r.FormValue("operation"), r.FormValue("code")
+	if operation == "" || code == "" {
+		http.Error(w, "operation and code are required", http.StatusBadRequest)
+		return
+	}
+
+	if backend := s.controlBackend(w, r); backend != nil {
+		backend.InjectFault(operation, code, times)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	describes, err := strconv.Atoi(r.FormValue("describes"))
+	if err != nil {
+		describes = 1
+	}
+	status := r.FormValue("status")
+	backend := s.controlBackend(w, r)
+	if backend == nil {
+		return
+	}
+
+	if id := r.FormValue("cluster"); id != "" {
+		err = backend.SetClusterStatus(id, status, describes)
+	} else if id := r.FormValue("instance"); id != "" {
+		err = backend.SetInstanceStatus(id, status, describes)
+	} else {
+		http.Error(w, "cluster or instance is required", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleTransition(w http.ResponseWriter, r *http.Request) {
+	describes, err := strconv.Atoi(r.FormValue("describes"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, b := range s.backends {
+		b.SetTransitionDescribes(describes)
+	}
+}
+
+func writeError(w http.ResponseWriter, requestId string, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(
+		w,
+		"<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>%s</RequestId></ErrorResponse>",
+		escape(code), escape(message), requestId,
+	)
+}
+
+func escape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
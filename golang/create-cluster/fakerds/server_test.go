@@ -0,0 +1,166 @@
+package fakerds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// newTestServer starts a Server and returns an SDK client pointed at it.
+func newTestServer(t *testing.T) (*httptest.Server, *rds.RDS) {
+	t.Helper()
+
+	server := httptest.NewServer(NewServer(New()))
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(defaultRegion),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return server, rds.New(sess)
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	server, svc := newTestServer(t)
+	defer server.Close()
+
+	_, err := svc.CreateDBSubnetGroup(&rds.CreateDBSubnetGroupInput{
+		DBSubnetGroupName:        aws.String("experiments"),
+		DBSubnetGroupDescription: aws.String("experiments"),
+		SubnetIds:                aws.StringSlice([]string{"subnet-00000000000000001", "subnet-00000000000000002"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.CreateDBCluster(&rds.CreateDBClusterInput{
+		DBClusterIdentifier: aws.String("experiments"),
+		Engine:              aws.String("aurora-mysql"),
+		EngineVersion:       aws.String("5.7.12"),
+		MasterUsername:      aws.String("admin"),
+		MasterUserPassword:  aws.String("secret123"),
+		DBSubnetGroupName:   aws.String("experiments"),
+		VpcSecurityGroupIds: aws.StringSlice([]string{"sg-00000000000000001", "sg-00000000000000002"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := svc.DescribeDBSubnetGroups(&rds.DescribeDBSubnetGroupsInput{
+		DBSubnetGroupName: aws.String("experiments"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(groups.DBSubnetGroups[0].Subnets); n != 2 {
+		t.Errorf("subnet group has %d subnets, want 2", n)
+	}
+
+	clusters, err := svc.DescribeDBClusters(&rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String("experiments"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := clusters.DBClusters[0]
+	if aws.StringValue(cluster.Status) != StatusAvailable {
+		t.Errorf("status = %s, want %s", aws.StringValue(cluster.Status), StatusAvailable)
+	}
+	if aws.Int64Value(cluster.Port) != 3306 {
+		t.Errorf("port = %d, want 3306", aws.Int64Value(cluster.Port))
+	}
+	if cluster.ClusterCreateTime == nil || cluster.ClusterCreateTime.IsZero() {
+		t.Error("cluster has no create time")
+	}
+	if n := len(cluster.VpcSecurityGroups); n != 2 {
+		t.Errorf("cluster has %d security groups, want 2", n)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	server, svc := newTestServer(t)
+	defer server.Close()
+
+	_, err := svc.DescribeDBClusters(&rds.DescribeDBClustersInput{DBClusterIdentifier: aws.String("missing")})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != rds.ErrCodeDBClusterNotFoundFault {
+		t.Errorf("err = %v, want %s", err, rds.ErrCodeDBClusterNotFoundFault)
+	}
+
+	resp, err := http.PostForm(server.URL+"/_fake/fault", url.Values{
+		"operation": {"DescribeDBClusters"},
+		"code":      {"Throttling"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	_, err = svc.DescribeDBClusters(&rds.DescribeDBClustersInput{})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "Throttling" {
+		t.Errorf("err = %v, want the injected Throttling fault", err)
+	}
+	if _, err := svc.DescribeDBClusters(&rds.DescribeDBClustersInput{}); err != nil {
+		t.Errorf("fault injected once still fails: %v", err)
+	}
+
+	_, err = svc.CopyDBClusterSnapshot(&rds.CopyDBClusterSnapshotInput{
+		SourceDBClusterSnapshotIdentifier: aws.String("a"),
+		TargetDBClusterSnapshotIdentifier: aws.String("b"),
+	})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "InvalidAction" {
+		t.Errorf("err = %v, want InvalidAction", err)
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	server, svc := newTestServer(t)
+	defer server.Close()
+
+	_, err := svc.CreateDBCluster(&rds.CreateDBClusterInput{
+		DBClusterIdentifier: aws.String("experiments"),
+		Engine:              aws.String("aurora-mysql"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.PostForm(server.URL+"/_fake/status", url.Values{
+		"cluster":   {"experiments"},
+		"status":    {"backing-up"},
+		"describes": {"2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for _, want := range []string{"backing-up", StatusAvailable} {
+		clusters, err := svc.DescribeDBClusters(&rds.DescribeDBClustersInput{DBClusterIdentifier: aws.String("experiments")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := aws.StringValue(clusters.DBClusters[0].Status); got != want {
+			t.Errorf("status = %s, want %s", got, want)
+		}
+	}
+
+	resp, err = http.PostForm(server.URL+"/_fake/status", url.Values{"instance": {"missing"}, "status": {"rebooting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status code for a missing instance = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,222 @@
+package fakerds
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	engineModeProvisioned = "provisioned"
+	engineModeServerless  = "serverless"
+
+	// The capacity RDS gives a serverless cluster that doesn't set one.
+	defaultMinCapacity           = 2
+	defaultMaxCapacity           = 16
+	defaultSecondsUntilAutoPause = 300
+
+	// serverlessV2Class is the instance class of Aurora Serverless v2, whose
+	// capacity range is set on the cluster.
+	serverlessV2Class = "db.serverless"
+
+	minServerlessV2Capacity = 0.5
+	maxServerlessV2Capacity = 256.0
+)
+
+// serverlessCapacities are the capacities, in Aurora capacity units, that a
+// serverless cluster of each engine scales between.
+var serverlessCapacities = map[string][]int64{
+	"aurora":            {1, 2, 4, 8, 16, 32, 64, 128, 256},
+	"aurora-mysql":      {1, 2, 4, 8, 16, 32, 64, 128, 256},
+	"aurora-postgresql": {2, 4, 8, 16, 32, 64, 192, 384},
+}
+
+// engineMode returns the engine mode and the scaling settings RDS reports for
+// a new cluster of engine and version. Versions outside the catalog run in
+// any mode their engine has.
+func engineMode(
+	engine, version string, mode *string, scaling *rds.ScalingConfiguration,
+) (*string, *rds.ScalingConfigurationInfo, error) {
+	if mode == nil {
+		mode = aws.String(engineModeProvisioned)
+	}
+	switch *mode {
+	case engineModeProvisioned, engineModeServerless, engineModeGlobal:
+	default:
+		return nil, nil, awserr.New("InvalidParameterValue", fmt.Sprintf("Invalid EngineMode %s.", *mode), nil)
+	}
+	if *mode == engineModeGlobal && engine != engineAurora {
+		return nil, nil, awserr.New(
+			"InvalidParameterCombination",
+			fmt.Sprintf("The engine mode %s you requested is currently unavailable for %s.", *mode, engine),
+			nil,
+		)
+	}
+
+	for _, v := range engineVersions {
+		if v.engine != engine || v.version != version {
+			continue
+		}
+		if !v.hasEngineMode(*mode) {
+			return nil, nil, awserr.New(
+				"InvalidParameterCombination",
+				fmt.Sprintf("The engine mode %s you requested is currently unavailable for %s %s.", *mode, engine, version),
+				nil,
+			)
+		}
+	}
+
+	if *mode != engineModeServerless {
+		if scaling != nil {
+			return nil, nil, awserr.New(
+				"InvalidParameterCombination",
+				"ScalingConfiguration is only supported for serverless clusters.",
+				nil,
+			)
+		}
+		return mode, nil, nil
+	}
+
+	info, err := scale(engine, &rds.ScalingConfigurationInfo{
+		MinCapacity:           aws.Int64(defaultMinCapacity),
+		MaxCapacity:           aws.Int64(defaultMaxCapacity),
+		AutoPause:             aws.Bool(true),
+		SecondsUntilAutoPause: aws.Int64(defaultSecondsUntilAutoPause),
+	}, scaling)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mode, info, nil
+}
+
+// scale returns info with the settings in scaling applied, checking the
+// capacities against what engine scales between.
+func scale(
+	engine string, info *rds.ScalingConfigurationInfo, scaling *rds.ScalingConfiguration,
+) (*rds.ScalingConfigurationInfo, error) {
+	next := *info
+	if scaling == nil {
+		return &next, nil
+	}
+
+	if scaling.MinCapacity != nil {
+		next.MinCapacity = scaling.MinCapacity
+	}
+	if scaling.MaxCapacity != nil {
+		next.MaxCapacity = scaling.MaxCapacity
+	}
+	if scaling.AutoPause != nil {
+		next.AutoPause = scaling.AutoPause
+	}
+	if scaling.SecondsUntilAutoPause != nil {
+		next.SecondsUntilAutoPause = scaling.SecondsUntilAutoPause
+	}
+
+	for _, c := range []int64{*next.MinCapacity, *next.MaxCapacity} {
+		if !validCapacity(engine, c) {
+			return nil, awserr.New(
+				"InvalidParameterValue",
+				fmt.Sprintf("Capacity %d is not valid for %s, valid values are %v.", c, engine, serverlessCapacities[engine]),
+				nil,
+			)
+		}
+	}
+	if *next.MinCapacity > *next.MaxCapacity {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"MinCapacity must be less than or equal to MaxCapacity.",
+			nil,
+		)
+	}
+
+	return &next, nil
+}
+
+func validCapacity(engine string, capacity int64) bool {
+	for _, c := range serverlessCapacities[engine] {
+		if c == capacity {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scaleV2 returns info with the Serverless v2 settings in scaling applied.
+// Only provisioned clusters have Serverless v2 instances.
+func scaleV2(
+	mode *string, info *rds.ServerlessV2ScalingConfigurationInfo, scaling *rds.ServerlessV2ScalingConfiguration,
+) (*rds.ServerlessV2ScalingConfigurationInfo, error) {
+	if scaling == nil {
+		return info, nil
+	}
+	if aws.StringValue(mode) != engineModeProvisioned {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"ServerlessV2ScalingConfiguration is only supported for provisioned clusters.",
+			nil,
+		)
+	}
+
+	next := rds.ServerlessV2ScalingConfigurationInfo{}
+	if info != nil {
+		next = *info
+	}
+	if scaling.MinCapacity != nil {
+		next.MinCapacity = scaling.MinCapacity
+	}
+	if scaling.MaxCapacity != nil {
+		next.MaxCapacity = scaling.MaxCapacity
+	}
+	if next.MinCapacity == nil || next.MaxCapacity == nil {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"ServerlessV2ScalingConfiguration needs both MinCapacity and MaxCapacity.",
+			nil,
+		)
+	}
+
+	for _, c := range []float64{*next.MinCapacity, *next.MaxCapacity} {
+		if c < minServerlessV2Capacity || c > maxServerlessV2Capacity || math.Mod(c, 0.5) != 0 {
+			return nil, awserr.New(
+				"InvalidParameterValue",
+				fmt.Sprintf(
+					"Capacity %g is not valid, it must be between %g and %g in increments of 0.5.",
+					c, minServerlessV2Capacity, maxServerlessV2Capacity,
+				),
+				nil,
+			)
+		}
+	}
+	if *next.MinCapacity > *next.MaxCapacity {
+		return nil, awserr.New(
+			"InvalidParameterCombination",
+			"MinCapacity must be less than or equal to MaxCapacity.",
+			nil,
+		)
+	}
+
+	return &next, nil
+}
+
+// checkServerlessV2 returns the error RDS gives for an instance of class in
+// a cluster without a Serverless v2 capacity range.
+func checkServerlessV2(cluster *rds.DBCluster, class string) error {
+	if class != serverlessV2Class || cluster.ServerlessV2ScalingConfiguration != nil {
+		return nil
+	}
+
+	return awserr.New(
+		"InvalidParameterCombination",
+		"Set the Serverless v2 scaling configuration on the parent DB cluster before creating a Serverless v2 DB instance.",
+		nil,
+	)
+}
+
+func isServerless(cluster *rds.DBCluster) bool {
+	return aws.StringValue(cluster.EngineMode) == engineModeServerless
+}
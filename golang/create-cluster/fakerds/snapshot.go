@@ -0,0 +1,302 @@
+package fakerds
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	snapshotTypeManual = "manual"
+
+	// defaultKmsKeyAlias is the alias of the key with defaultKmsKeyId.
+	defaultKmsKeyAlias = "alias/aws/rds"
+)
+
+type clusterSnapshotState struct {
+	state
+	snapshot *rds.DBClusterSnapshot
+	tags     []*rds.Tag
+}
+
+func (f *RDS) DescribeDBClusterSnapshots(
+	input *rds.DescribeDBClusterSnapshotsInput,
+) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBClusterSnapshots"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBClusterSnapshotsOutput{}
+
+	if input.DBClusterSnapshotIdentifier != nil {
+		id, s, err := f.findClusterSnapshot(*input.DBClusterSnapshotIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		if tick(&s.state, &s.snapshot.Status) {
+			delete(f.clusterSnapshots, id)
+			return nil, notFound(rds.ErrCodeDBClusterSnapshotNotFoundFault, "DBClusterSnapshot", id)
+		}
+		output.DBClusterSnapshots = append(output.DBClusterSnapshots, copyClusterSnapshot(s.snapshot))
+		return output, nil
+	}
+
+	for id, s := range f.clusterSnapshots {
+		if input.DBClusterIdentifier != nil && *input.DBClusterIdentifier != aws.StringValue(s.snapshot.DBClusterIdentifier) {
+			continue
+		}
+		if input.SnapshotType != nil && *input.SnapshotType != aws.StringValue(s.snapshot.SnapshotType) {
+			continue
+		}
+		if tick(&s.state, &s.snapshot.Status) {
+			delete(f.clusterSnapshots, id)
+			continue
+		}
+		output.DBClusterSnapshots = append(output.DBClusterSnapshots, copyClusterSnapshot(s.snapshot))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBClusterSnapshot(input *rds.CreateDBClusterSnapshotInput) (*rds.CreateDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBClusterSnapshot"); err != nil {
+		return nil, err
+	}
+
+	clusterId := aws.StringValue(input.DBClusterIdentifier)
+	c, ok := f.clusters[clusterId]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterNotFoundFault, "DBCluster", clusterId)
+	}
+	if aws.StringValue(c.cluster.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterStateFault,
+			fmt.Sprintf("DBCluster %s is not currently in the available state.", clusterId),
+			nil,
+		)
+	}
+
+	snapshotId := aws.StringValue(input.DBClusterSnapshotIdentifier)
+	snapshot, err := f.snapshotCluster(c.cluster, snapshotId)
+	if err != nil {
+		return nil, err
+	}
+	f.clusterSnapshots[snapshotId].tags = input.Tags
+
+	return &rds.CreateDBClusterSnapshotOutput{DBClusterSnapshot: copyClusterSnapshot(snapshot)}, nil
+}
+
+func (f *RDS) DeleteDBClusterSnapshot(input *rds.DeleteDBClusterSnapshotInput) (*rds.DeleteDBClusterSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBClusterSnapshot"); err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.DBClusterSnapshotIdentifier)
+	s, ok := f.clusterSnapshots[id]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBClusterSnapshotNotFoundFault, "DBClusterSnapshot", id)
+	}
+	if aws.StringValue(s.snapshot.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterSnapshotStateFault,
+			fmt.Sprintf("DBClusterSnapshot %s is not currently in the available state.", id),
+			nil,
+		)
+	}
+
+	s.snapshot.Status = aws.String(StatusDeleting)
+	s.state = f.transition("")
+
+	return &rds.DeleteDBClusterSnapshotOutput{DBClusterSnapshot: copyClusterSnapshot(s.snapshot)}, nil
+}
+
+// ListTagsForResource only knows the tags of cluster snapshots.
+func (f *RDS) ListTagsForResource(input *rds.ListTagsForResourceInput) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ListTagsForResource"); err != nil {
+		return nil, err
+	}
+
+	arn := aws.StringValue(input.ResourceName)
+	for _, s := range f.clusterSnapshots {
+		if aws.StringValue(s.snapshot.DBClusterSnapshotArn) == arn {
+			return &rds.ListTagsForResourceOutput{TagList: append([]*rds.Tag{}, s.tags...)}, nil
+		}
+	}
+
+	return nil, notFound(rds.ErrCodeDBClusterSnapshotNotFoundFault, "DBClusterSnapshot", arn)
+}
+
+// RestoreDBClusterFromSnapshot creates a cluster without members from a
+// snapshot. The master username and, unless given, the engine version and
+// encryption key come from the snapshot.
+func (f *RDS) RestoreDBClusterFromSnapshot(
+	input *rds.RestoreDBClusterFromSnapshotInput,
+) (*rds.RestoreDBClusterFromSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("RestoreDBClusterFromSnapshot"); err != nil {
+		return nil, err
+	}
+
+	snapshotId, s, err := f.findClusterSnapshot(aws.StringValue(input.SnapshotIdentifier))
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(s.snapshot.Status) != StatusAvailable {
+		return nil, awserr.New(
+			rds.ErrCodeInvalidDBClusterSnapshotStateFault,
+			fmt.Sprintf("DBClusterSnapshot %s is not currently in the available state.", snapshotId),
+			nil,
+		)
+	}
+
+	id := aws.StringValue(input.DBClusterIdentifier)
+	if _, ok := f.clusters[id]; ok {
+		return nil, awserr.New(rds.ErrCodeDBClusterAlreadyExistsFault, "DBCluster already exists.", nil)
+	}
+	if input.DBSubnetGroupName != nil {
+		if _, ok := f.subnetGroups[*input.DBSubnetGroupName]; !ok {
+			return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", *input.DBSubnetGroupName)
+		}
+	}
+
+	engine := s.snapshot.Engine
+	if input.Engine != nil {
+		engine = input.Engine
+	}
+	engineVersion := s.snapshot.EngineVersion
+	if input.EngineVersion != nil {
+		engineVersion = input.EngineVersion
+	}
+	parameterGroup := aws.String("default." + aws.StringValue(engine))
+	if input.DBClusterParameterGroupName != nil {
+		if _, ok := f.clusterParameterGroups[*input.DBClusterParameterGroupName]; !ok {
+			return nil, notFound(
+				rds.ErrCodeDBClusterParameterGroupNotFoundFault, "DBClusterParameterGroup", *input.DBClusterParameterGroupName,
+			)
+		}
+		parameterGroup = input.DBClusterParameterGroupName
+	}
+
+	mode, scaling, err := engineMode(
+		aws.StringValue(engine), aws.StringValue(engineVersion), input.EngineMode, input.ScalingConfiguration,
+	)
+	if err != nil {
+		return nil, err
+	}
+	scalingV2, err := scaleV2(mode, nil, input.ServerlessV2ScalingConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key encrypts an unencrypted snapshot or re-encrypts an encrypted one.
+	// Serverless clusters are always encrypted, with the default key unless
+	// there is another.
+	encrypted := aws.BoolValue(s.snapshot.StorageEncrypted) || input.KmsKeyId != nil || *mode == engineModeServerless
+	kmsKeyId := s.snapshot.KmsKeyId
+	if input.KmsKeyId != nil {
+		kmsKeyId = input.KmsKeyId
+	}
+	if encrypted && (kmsKeyId == nil || *kmsKeyId == defaultKmsKeyAlias) {
+		kmsKeyId = aws.String(fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", f.Region, f.AccountId, defaultKmsKeyId))
+	}
+
+	cluster := &rds.DBCluster{
+		DBClusterIdentifier:     input.DBClusterIdentifier,
+		DBClusterArn:            f.arn("cluster", id),
+		DBSubnetGroup:           input.DBSubnetGroupName,
+		DBClusterParameterGroup: parameterGroup,
+		Engine:                  engine,
+		EngineVersion:           engineVersion,
+		MasterUsername:          s.snapshot.MasterUsername,
+		Status:                  aws.String(StatusCreating),
+		ClusterCreateTime:       now(),
+		Port:                    s.snapshot.Port,
+		DBClusterMembers:        []*rds.DBClusterMember{},
+		VpcSecurityGroups:       securityGroups(input.VpcSecurityGroupIds),
+		StorageEncrypted:        aws.Bool(encrypted),
+		KmsKeyId:                kmsKeyId,
+		EngineMode:              mode,
+
+		ScalingConfigurationInfo: scaling,
+
+		ServerlessV2ScalingConfiguration: scalingV2,
+	}
+	setBackupDefaults(cluster)
+	cluster.Endpoint, cluster.ReaderEndpoint = f.clusterEndpoints(id)
+	f.clusters[id] = &clusterState{
+		state:   f.transition(StatusAvailable),
+		cluster: cluster,
+	}
+
+	return &rds.RestoreDBClusterFromSnapshotOutput{DBCluster: copyCluster(cluster)}, nil
+}
+
+// findClusterSnapshot looks a snapshot up by identifier or ARN, the way the
+// API accepts either. Callers hold f.mu.
+func (f *RDS) findClusterSnapshot(idOrArn string) (string, *clusterSnapshotState, error) {
+	if s, ok := f.clusterSnapshots[idOrArn]; ok {
+		return idOrArn, s, nil
+	}
+	for id, s := range f.clusterSnapshots {
+		if aws.StringValue(s.snapshot.DBClusterSnapshotArn) == idOrArn {
+			return id, s, nil
+		}
+	}
+
+	return "", nil, notFound(rds.ErrCodeDBClusterSnapshotNotFoundFault, "DBClusterSnapshot", idOrArn)
+}
+
+// snapshotCluster records a manual snapshot of cluster that becomes
+// available after the usual transition. Callers hold f.mu.
+func (f *RDS) snapshotCluster(cluster *rds.DBCluster, id string) (*rds.DBClusterSnapshot, error) {
+	if _, ok := f.clusterSnapshots[id]; ok {
+		return nil, awserr.New(
+			rds.ErrCodeDBClusterSnapshotAlreadyExistsFault,
+			fmt.Sprintf("Cannot create the cluster snapshot because one with the identifier %s already exists.", id),
+			nil,
+		)
+	}
+
+	snapshot := &rds.DBClusterSnapshot{
+		DBClusterSnapshotIdentifier: aws.String(id),
+		DBClusterSnapshotArn:        f.arn("cluster-snapshot", id),
+		DBClusterIdentifier:         cluster.DBClusterIdentifier,
+		Engine:                      cluster.Engine,
+		EngineVersion:               cluster.EngineVersion,
+		MasterUsername:              cluster.MasterUsername,
+		Port:                        cluster.Port,
+		StorageEncrypted:            cluster.StorageEncrypted,
+		KmsKeyId:                    cluster.KmsKeyId,
+		ClusterCreateTime:           cluster.ClusterCreateTime,
+		SnapshotCreateTime:          now(),
+		SnapshotType:                aws.String(snapshotTypeManual),
+		Status:                      aws.String(StatusCreating),
+	}
+	f.clusterSnapshots[id] = &clusterSnapshotState{
+		state:    f.transition(StatusAvailable),
+		snapshot: snapshot,
+	}
+
+	return snapshot, nil
+}
+
+func copyClusterSnapshot(snapshot *rds.DBClusterSnapshot) *rds.DBClusterSnapshot {
+	s := *snapshot
+	return &s
+}
@@ -0,0 +1,164 @@
+package fakerds
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func (f *RDS) DescribeDBSubnetGroups(input *rds.DescribeDBSubnetGroupsInput) (*rds.DescribeDBSubnetGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DescribeDBSubnetGroups"); err != nil {
+		return nil, err
+	}
+
+	output := &rds.DescribeDBSubnetGroupsOutput{}
+
+	if input.DBSubnetGroupName != nil {
+		group, ok := f.subnetGroups[*input.DBSubnetGroupName]
+		if !ok {
+			return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", *input.DBSubnetGroupName)
+		}
+		output.DBSubnetGroups = append(output.DBSubnetGroups, copySubnetGroup(group))
+		return output, nil
+	}
+
+	for _, group := range f.subnetGroups {
+		output.DBSubnetGroups = append(output.DBSubnetGroups, copySubnetGroup(group))
+	}
+
+	return output, nil
+}
+
+func (f *RDS) CreateDBSubnetGroup(input *rds.CreateDBSubnetGroupInput) (*rds.CreateDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("CreateDBSubnetGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBSubnetGroupName)
+	if _, ok := f.subnetGroups[name]; ok {
+		return nil, awserr.New(rds.ErrCodeDBSubnetGroupAlreadyExistsFault, "DBSubnetGroup already exists.", nil)
+	}
+	subnets, err := f.subnets(input.SubnetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &rds.DBSubnetGroup{
+		DBSubnetGroupName:        input.DBSubnetGroupName,
+		DBSubnetGroupDescription: input.DBSubnetGroupDescription,
+		DBSubnetGroupArn:         f.arn("subgrp", name),
+		SubnetGroupStatus:        aws.String("Complete"),
+		VpcId:                    aws.String("vpc-00000000"),
+		Subnets:                  subnets,
+	}
+	f.subnetGroups[name] = group
+
+	return &rds.CreateDBSubnetGroupOutput{DBSubnetGroup: copySubnetGroup(group)}, nil
+}
+
+func (f *RDS) ModifyDBSubnetGroup(input *rds.ModifyDBSubnetGroupInput) (*rds.ModifyDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("ModifyDBSubnetGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBSubnetGroupName)
+	group, ok := f.subnetGroups[name]
+	if !ok {
+		return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", name)
+	}
+
+	subnets, err := f.subnets(input.SubnetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.DBSubnetGroupDescription != nil {
+		group.DBSubnetGroupDescription = input.DBSubnetGroupDescription
+	}
+	group.Subnets = subnets
+
+	return &rds.ModifyDBSubnetGroupOutput{DBSubnetGroup: copySubnetGroup(group)}, nil
+}
+
+func (f *RDS) DeleteDBSubnetGroup(input *rds.DeleteDBSubnetGroupInput) (*rds.DeleteDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fault("DeleteDBSubnetGroup"); err != nil {
+		return nil, err
+	}
+
+	name := aws.StringValue(input.DBSubnetGroupName)
+	if _, ok := f.subnetGroups[name]; !ok {
+		return nil, notFound(rds.ErrCodeDBSubnetGroupNotFoundFault, "DBSubnetGroup", name)
+	}
+
+	for _, c := range f.clusters {
+		if aws.StringValue(c.cluster.DBSubnetGroup) == name {
+			return nil, awserr.New(
+				rds.ErrCodeInvalidDBSubnetGroupStateFault,
+				fmt.Sprintf("DBSubnetGroup %s is in use by %s.", name, *c.cluster.DBClusterIdentifier),
+				nil,
+			)
+		}
+	}
+	delete(f.subnetGroups, name)
+
+	return &rds.DeleteDBSubnetGroupOutput{}, nil
+}
+
+// subnets places subnet ids in their availability zones. Like RDS it
+// refuses subnets that don't span at least two zones.
+func (f *RDS) subnets(ids []*string) ([]*rds.Subnet, error) {
+	subnets := make([]*rds.Subnet, 0)
+	zones := map[string]bool{}
+	for _, id := range ids {
+		zone := f.subnetZone(aws.StringValue(id))
+		zones[zone] = true
+		subnets = append(subnets, &rds.Subnet{
+			SubnetIdentifier:       id,
+			SubnetStatus:           aws.String("Active"),
+			SubnetAvailabilityZone: &rds.AvailabilityZone{Name: aws.String(zone)},
+		})
+	}
+
+	if len(zones) < 2 {
+		return nil, awserr.New(
+			rds.ErrCodeDBSubnetGroupDoesNotCoverEnoughAZs,
+			"The DB subnet group doesn't meet Availability Zone (AZ) coverage requirement. "+
+				"Add subnets to cover at least 2 AZs.",
+			nil,
+		)
+	}
+
+	return subnets, nil
+}
+
+// subnetZone puts a subnet in zone a, b or c of the region, picked by the
+// last character of its id, so that ids ending in consecutive digits land
+// in different zones and the same id always lands in the same one.
+func (f *RDS) subnetZone(id string) string {
+	var last byte
+	if id != "" {
+		last = id[len(id)-1]
+	}
+
+	return fmt.Sprintf("%s%c", f.Region, 'a'+last%3)
+}
+
+func copySubnetGroup(group *rds.DBSubnetGroup) *rds.DBSubnetGroup {
+	c := *group
+	c.Subnets = append([]*rds.Subnet{}, group.Subnets...)
+	return &c
+}
@@ -0,0 +1,141 @@
+package fakerds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	xmlNamespace  = "http://rds.amazonaws.com/doc/2014-10-31/"
+	xmlTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+// encodeResponse writes output wrapped the way the SDK's query unmarshaler
+// expects: <ActionResponse><ActionResult>...</ActionResult></ActionResponse>.
+func encodeResponse(e *xml.Encoder, action, requestId string, output interface{}) error {
+	response := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: xmlNamespace}},
+	}
+	result := xml.StartElement{Name: xml.Name{Local: action + "Result"}}
+
+	err := e.EncodeToken(response)
+	if err != nil {
+		return err
+	}
+	err = e.EncodeToken(result)
+	if err != nil {
+		return err
+	}
+	err = encodeFields(e, reflect.ValueOf(output).Elem())
+	if err != nil {
+		return err
+	}
+	err = e.EncodeToken(result.End())
+	if err != nil {
+		return err
+	}
+	err = encodeElement(e, "ResponseMetadata", func() error {
+		return encodeText(e, "RequestId", requestId)
+	})
+	if err != nil {
+		return err
+	}
+	err = e.EncodeToken(response.End())
+	if err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+func encodeFields(e *xml.Encoder, value reflect.Value) error {
+	t := value.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if field.PkgPath != "" || field.Name == "_" {
+			continue
+		}
+
+		name := field.Tag.Get("locationName")
+		if name == "" {
+			name = field.Name
+		}
+
+		err := encodeValue(e, name, value.Field(n), field.Tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeValue(e *xml.Encoder, name string, value reflect.Value, tag reflect.StructTag) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+
+		member := tag.Get("locationNameList")
+		if member == "" {
+			member = "member"
+		}
+		return encodeElement(e, name, func() error {
+			for n := 0; n < value.Len(); n++ {
+				err := encodeValue(e, member, value.Index(n), "")
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return encodeValue(e, name, value.Elem(), tag)
+	case reflect.Struct:
+		if t, ok := value.Interface().(time.Time); ok {
+			return encodeText(e, name, t.UTC().Format(xmlTimeFormat))
+		}
+		return encodeElement(e, name, func() error {
+			return encodeFields(e, value)
+		})
+	case reflect.String:
+		return encodeText(e, name, value.String())
+	case reflect.Bool:
+		return encodeText(e, name, strconv.FormatBool(value.Bool()))
+	case reflect.Int64:
+		return encodeText(e, name, strconv.FormatInt(value.Int(), 10))
+	case reflect.Float64:
+		return encodeText(e, name, strconv.FormatFloat(value.Float(), 'f', -1, 64))
+	}
+
+	return fmt.Errorf("%s: unsupported type %s", name, value.Type())
+}
+
+func encodeElement(e *xml.Encoder, name string, body func() error) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	err = body()
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func encodeText(e *xml.Encoder, name, text string) error {
+	return encodeElement(e, name, func() error {
+		return e.EncodeToken(xml.CharData(text))
+	})
+}
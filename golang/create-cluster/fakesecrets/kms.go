@@ -0,0 +1,86 @@
+package fakesecrets
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// defaultRDSAlias is the AWS managed key RDS uses when no key is given.
+const defaultRDSAlias = "alias/aws/rds"
+
+// KMS resolves key ids and aliases for the keys added with AddKey. Anything
+// else panics through the embedded nil interface.
+type KMS struct {
+	kmsiface.KMSAPI
+
+	Region    string
+	AccountId string
+
+	mu      sync.Mutex
+	keys    map[string]bool
+	aliases map[string]string
+}
+
+func NewKMS() *KMS {
+	f := &KMS{
+		Region:    defaultRegion,
+		AccountId: defaultAccountId,
+		keys:      map[string]bool{},
+		aliases:   map[string]string{},
+	}
+	f.AddKey(defaultRDSAlias)
+
+	return f
+}
+
+// AddKey creates a key, named by alias when it isn't empty, and returns its
+// id.
+func (f *KMS) AddKey(alias string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := fmt.Sprintf("00000000-0000-4000-8000-%012d", len(f.keys)+1)
+	f.keys[id] = true
+	if alias != "" {
+		f.aliases[alias] = id
+	}
+
+	return id
+}
+
+// DescribeKey accepts a key id, key ARN, alias name or alias ARN.
+func (f *KMS) DescribeKey(input *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keyId := aws.StringValue(input.KeyId)
+	id := keyId
+	if i := strings.Index(id, ":alias/"); i >= 0 {
+		id = id[i+1:]
+	} else if i := strings.Index(id, ":key/"); i >= 0 {
+		id = id[i+len(":key/"):]
+	}
+	if strings.HasPrefix(id, "alias/") {
+		id = f.aliases[id]
+	}
+	if !f.keys[id] {
+		return nil, awserr.New(kms.ErrCodeNotFoundException, fmt.Sprintf("Key '%s' does not exist", keyId), nil)
+	}
+
+	return &kms.DescribeKeyOutput{
+		KeyMetadata: &kms.KeyMetadata{
+			AWSAccountId: aws.String(f.AccountId),
+			Arn:          aws.String(fmt.Sprintf("arn:aws:kms:%s:%s:key/%s", f.Region, f.AccountId, id)),
+			Enabled:      aws.Bool(true),
+			KeyId:        aws.String(id),
+			KeyState:     aws.String(kms.KeyStateEnabled),
+			KeyUsage:     aws.String(kms.KeyUsageTypeEncryptDecrypt),
+		},
+	}, nil
+}
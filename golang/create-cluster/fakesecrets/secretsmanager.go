@@ -0,0 +1,245 @@
+// Package fakesecrets holds in-memory Secrets Manager, SSM Parameter Store
+// and KMS implementations, for running create-cluster against fakerds.
+package fakesecrets
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+const (
+	defaultRegion    = "us-east-1"
+	defaultAccountId = "123456789012"
+
+	stageCurrent  = "AWSCURRENT"
+	stagePrevious = "AWSPREVIOUS"
+)
+
+// SecretsManager implements the secret operations create-cluster uses.
+// Anything else panics through the embedded nil interface.
+type SecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	Region    string
+	AccountId string
+
+	mu       sync.Mutex
+	secrets  map[string]*secretState
+	versions int
+}
+
+type secretState struct {
+	name     string
+	arn      string
+	values   map[string]string
+	stages   map[string]string
+	versions []string
+}
+
+func NewSecretsManager() *SecretsManager {
+	return &SecretsManager{
+		Region:    defaultRegion,
+		AccountId: defaultAccountId,
+		secrets:   map[string]*secretState{},
+	}
+}
+
+func (f *SecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.StringValue(input.Name)
+	if _, ok := f.secrets[name]; ok {
+		return nil, awserr.New(
+			secretsmanager.ErrCodeResourceExistsException,
+			fmt.Sprintf("The operation failed because the secret %s already exists.", name),
+			nil,
+		)
+	}
+
+	s := &secretState{
+		name:   name,
+		arn:    fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s-fake", f.Region, f.AccountId, name),
+		values: map[string]string{},
+		stages: map[string]string{},
+	}
+	f.secrets[name] = s
+
+	output := &secretsmanager.CreateSecretOutput{ARN: aws.String(s.arn), Name: aws.String(name)}
+	if input.SecretString != nil {
+		output.VersionId = aws.String(f.putVersion(s, input.ClientRequestToken, *input.SecretString, nil))
+	}
+
+	return output, nil
+}
+
+// GetSecretValue returns the AWSCURRENT version unless a version id or stage
+// is given.
+func (f *SecretsManager) GetSecretValue(
+	input *secretsmanager.GetSecretValueInput,
+) (*secretsmanager.GetSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, err := f.secret(aws.StringValue(input.SecretId))
+	if err != nil {
+		return nil, err
+	}
+
+	id := aws.StringValue(input.VersionId)
+	if id == "" {
+		stage := stageCurrent
+		if input.VersionStage != nil {
+			stage = *input.VersionStage
+		}
+		id = s.stages[stage]
+	}
+	value, ok := s.values[id]
+	if !ok {
+		return nil, awserr.New(
+			secretsmanager.ErrCodeResourceNotFoundException,
+			"Secrets Manager can't find the specified secret value for the requested version or stage.",
+			nil,
+		)
+	}
+
+	return &secretsmanager.GetSecretValueOutput{
+		ARN:           aws.String(s.arn),
+		Name:          aws.String(s.name),
+		SecretString:  aws.String(value),
+		VersionId:     aws.String(id),
+		VersionStages: aws.StringSlice(s.stagesOf(id)),
+	}, nil
+}
+
+// PutSecretValue adds a version, labelled AWSCURRENT unless other stages are
+// given.
+func (f *SecretsManager) PutSecretValue(
+	input *secretsmanager.PutSecretValueInput,
+) (*secretsmanager.PutSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, err := f.secret(aws.StringValue(input.SecretId))
+	if err != nil {
+		return nil, err
+	}
+
+	stages := aws.StringValueSlice(input.VersionStages)
+	id := f.putVersion(s, input.ClientRequestToken, aws.StringValue(input.SecretString), stages)
+
+	return &secretsmanager.PutSecretValueOutput{
+		ARN:           aws.String(s.arn),
+		Name:          aws.String(s.name),
+		VersionId:     aws.String(id),
+		VersionStages: aws.StringSlice(s.stagesOf(id)),
+	}, nil
+}
+
+// UpdateSecretVersionStage moves a stage to MoveToVersionId, or only removes
+// it from RemoveFromVersionId when no target is given. A stage that is
+// attached elsewhere has to be removed from the version holding it.
+func (f *SecretsManager) UpdateSecretVersionStage(
+	input *secretsmanager.UpdateSecretVersionStageInput,
+) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, err := f.secret(aws.StringValue(input.SecretId))
+	if err != nil {
+		return nil, err
+	}
+
+	stage := aws.StringValue(input.VersionStage)
+	holder, attached := s.stages[stage]
+	from := aws.StringValue(input.RemoveFromVersionId)
+	to := aws.StringValue(input.MoveToVersionId)
+	if attached && holder != to && holder != from {
+		return nil, awserr.New(
+			secretsmanager.ErrCodeInvalidParameterException,
+			fmt.Sprintf(
+				"The staging label %s is currently attached to version %s, so you must explicitly "+
+					"reference that version in RemoveFromVersionId.",
+				stage, holder,
+			),
+			nil,
+		)
+	}
+	if to != "" {
+		if _, ok := s.values[to]; !ok {
+			return nil, awserr.New(
+				secretsmanager.ErrCodeResourceNotFoundException,
+				"Secrets Manager can't find the specified secret value for the requested version.",
+				nil,
+			)
+		}
+		s.moveStage(stage, to)
+	} else if attached {
+		delete(s.stages, stage)
+	}
+
+	return &secretsmanager.UpdateSecretVersionStageOutput{ARN: aws.String(s.arn), Name: aws.String(s.name)}, nil
+}
+
+// secret finds a secret by name or ARN. Callers hold f.mu.
+func (f *SecretsManager) secret(id string) (*secretState, error) {
+	for _, s := range f.secrets {
+		if s.name == id || s.arn == id {
+			return s, nil
+		}
+	}
+
+	return nil, awserr.New(
+		secretsmanager.ErrCodeResourceNotFoundException,
+		"Secrets Manager can't find the specified secret.",
+		nil,
+	)
+}
+
+// putVersion stores value as a new version and moves stages, AWSCURRENT by
+// default, onto it. Callers hold f.mu.
+func (f *SecretsManager) putVersion(s *secretState, token *string, value string, stages []string) string {
+	f.versions++
+	id := aws.StringValue(token)
+	if id == "" {
+		id = fmt.Sprintf("fake-version-%d", f.versions)
+	}
+	s.values[id] = value
+	s.versions = append(s.versions, id)
+
+	if len(stages) == 0 {
+		stages = []string{stageCurrent}
+	}
+	for _, stage := range stages {
+		s.moveStage(stage, id)
+	}
+
+	return id
+}
+
+// moveStage labels version id with stage. Moving AWSCURRENT labels the
+// version that had it AWSPREVIOUS, as Secrets Manager does.
+func (s *secretState) moveStage(stage, id string) {
+	if stage == stageCurrent {
+		if previous, ok := s.stages[stageCurrent]; ok && previous != id {
+			s.stages[stagePrevious] = previous
+		}
+	}
+	s.stages[stage] = id
+}
+
+func (s *secretState) stagesOf(id string) []string {
+	stages := make([]string, 0)
+	for stage, versionId := range s.stages {
+		if versionId == id {
+			stages = append(stages, stage)
+		}
+	}
+
+	return stages
+}
@@ -0,0 +1,93 @@
+package fakesecrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// targetHeader names the operation in the JSON protocol both services use,
+// for example "secretsmanager.GetSecretValue" or "AmazonSSM.GetParameter".
+const targetHeader = "X-Amz-Target"
+
+// serverTargets are the operations the server answers, by target prefix.
+var serverTargets = map[string]map[string]bool{
+	"secretsmanager": {
+		"CreateSecret": true, "GetSecretValue": true, "PutSecretValue": true, "UpdateSecretVersionStage": true,
+	},
+	"AmazonSSM":    {"DeleteParameter": true, "GetParameter": true, "PutParameter": true},
+	"TrentService": {"DescribeKey": true},
+}
+
+// Server speaks the AWS JSON protocol for the in-memory Secrets Manager, SSM
+// and KMS.
+type Server struct {
+	backends map[string]interface{}
+}
+
+func NewServer(secrets *SecretsManager, parameters *SSM, keys *KMS) *Server {
+	return &Server{
+		backends: map[string]interface{}{
+			"secretsmanager": secrets,
+			"AmazonSSM":      parameters,
+			"TrentService":   keys,
+		},
+	}
+}
+
+// Handles reports whether r is a request for this server rather than for
+// the RDS Query API.
+func Handles(r *http.Request) bool {
+	return r.Header.Get(targetHeader) != ""
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := strings.SplitN(r.Header.Get(targetHeader), ".", 2)
+	if len(target) != 2 || !serverTargets[target[0]][target[1]] {
+		writeError(w, http.StatusBadRequest, "UnknownOperationException", fmt.Sprintf("%s is not supported.", target))
+		return
+	}
+	service, operation := target[0], target[1]
+	log.Debugf("fakesecrets: %s.%s", service, operation)
+
+	method := reflect.ValueOf(s.backends[service]).MethodByName(operation)
+	input := reflect.New(method.Type().In(0).Elem())
+
+	err := jsonutil.UnmarshalJSON(input.Interface(), r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+
+	results := method.Call([]reflect.Value{input})
+	if !results[1].IsNil() {
+		err := results[1].Interface().(error)
+		code, status, message := "InternalFailure", http.StatusInternalServerError, err.Error()
+		if aerr, ok := err.(awserr.Error); ok {
+			code, status, message = aerr.Code(), http.StatusBadRequest, aerr.Message()
+		}
+		writeError(w, status, code, message)
+		return
+	}
+
+	b, err := jsonutil.BuildJSON(results[0].Interface())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalFailure", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message})
+}
@@ -0,0 +1,87 @@
+package fakesecrets
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// SSM implements the Parameter Store operations create-cluster uses. Values
+// are kept in the clear, decrypted or not.
+type SSM struct {
+	ssmiface.SSMAPI
+
+	Region    string
+	AccountId string
+
+	mu         sync.Mutex
+	parameters map[string]*ssm.Parameter
+}
+
+func NewSSM() *SSM {
+	return &SSM{
+		Region:     defaultRegion,
+		AccountId:  defaultAccountId,
+		parameters: map[string]*ssm.Parameter{},
+	}
+}
+
+func (f *SSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.StringValue(input.Name)
+	p, ok := f.parameters[name]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("Parameter %s not found.", name), nil)
+	}
+	parameter := *p
+
+	return &ssm.GetParameterOutput{Parameter: &parameter}, nil
+}
+
+func (f *SSM) DeleteParameter(input *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.StringValue(input.Name)
+	if _, ok := f.parameters[name]; !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("Parameter %s not found.", name), nil)
+	}
+	delete(f.parameters, name)
+
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func (f *SSM) PutParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.StringValue(input.Name)
+	p, ok := f.parameters[name]
+	if ok && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(
+			ssm.ErrCodeParameterAlreadyExists,
+			"The parameter already exists. To overwrite this value, set the overwrite option in the request to true.",
+			nil,
+		)
+	}
+	if !ok {
+		p = &ssm.Parameter{
+			Name:    input.Name,
+			ARN:     aws.String(fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/%s", f.Region, f.AccountId, name)),
+			Version: aws.Int64(0),
+		}
+		f.parameters[name] = p
+	}
+
+	p.Type = input.Type
+	p.Value = input.Value
+	p.Version = aws.Int64(aws.Int64Value(p.Version) + 1)
+
+	return &ssm.PutParameterOutput{Version: p.Version}, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+const kmsEndpointVar = "KMS_ENDPOINT"
+
+// resolveKmsKey replaces an alias or key id in req with the key ARN, which is
+// what RDS reports for an existing cluster. KMS_ENDPOINT overrides the
+// endpoint.
+func resolveKmsKey(req *request.ClusterRequest) error {
+	if req.KmsKeyId == "" || strings.Contains(req.KmsKeyId, ":key/") {
+		return nil
+	}
+
+	svc := kms.New(newSession(*req, kmsEndpointVar))
+	output, err := svc.DescribeKey(&kms.DescribeKeyInput{KeyId: aws.String(req.KmsKeyId)})
+	if err != nil {
+		return fmt.Errorf("KMS key %s: %s", req.KmsKeyId, err)
+	}
+
+	arn := aws.StringValue(output.KeyMetadata.Arn)
+	log.Infof("KMS key %s is %s", req.KmsKeyId, arn)
+	req.KmsKeyId = arn
+
+	return nil
+}
+
+// resolveSecondaryKmsKeys resolves the KMS key of each secondary cluster of
+// a global database in the region of that cluster.
+func resolveSecondaryKmsKeys(req *request.ClusterRequest) error {
+	if req.Global == nil {
+		return nil
+	}
+
+	for n := range req.Global.Secondaries {
+		s := &req.Global.Secondaries[n]
+
+		regional := *req
+		regional.Region, regional.KmsKeyId = s.Region, s.KmsKeyId
+		err := resolveKmsKey(&regional)
+		if err != nil {
+			return err
+		}
+		s.KmsKeyId = regional.KmsKeyId
+	}
+
+	return nil
+}
@@ -1,28 +1,167 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
 	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
 	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
 )
 
+const (
+	rdsEndpointVar    = "RDS_ENDPOINT"
+	retryInitialDelay = 2 * time.Second
+)
+
+// Exit codes. Usage errors exit with 2 as well, matching the flag package.
+const (
+	exitFailure   = 1
+	exitInvalid   = 2
+	exitRetryable = 3
+)
+
 func main() {
-	req := request.NewRequest()
+	// Running without a command reconciles the cluster, as it always has.
+	command, args := "apply", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "apply":
+		runApply(args)
+	case "destroy":
+		runDestroy(args)
+	case "rotate-password":
+		runRotatePassword(args)
+	case "snapshot":
+		runSnapshot(args)
+	case "clone":
+		runClone(args)
+	case "failover":
+		runFailover(args)
+	case "switchover":
+		runSwitchover(args)
+	default:
+		log.Fatalf("unknown command %q", command)
+	}
+}
+
+// fatal logs err and exits with a code that tells a caller whether running
+// again unchanged may succeed.
+func fatal(err error) {
+	log.Error(err)
+	os.Exit(exitCode(err))
+}
+
+func exitCode(err error) int {
+	if _, ok := err.(request.ValidationError); ok {
+		return exitInvalid
+	}
+	if factory.IsRetryable(err) {
+		return exitRetryable
+	}
+	if factory.ErrorKindOf(err) == factory.KindValidation {
+		return exitInvalid
+	}
+
+	return exitFailure
+}
+
+// loadRequest builds the request from specFile, or from the environment only
+// when specFile is empty, and checks it with validate.
+func loadRequest(specFile string, validate func(request.ClusterRequest) error) (request.ClusterRequest, error) {
+	var req request.ClusterRequest
+
+	if specFile == "" {
+		req = request.NewRequest()
+	} else {
+		var err error
+		req, err = request.NewRequestFromFile(specFile)
+		if err != nil {
+			return req, err
+		}
+	}
+
+	return req, validate(req)
+}
+
+// newSession creates a session for req whose endpoint is overridden by
+// endpointVar when it is set, for example to point at cmd/fakerds.
+func newSession(req request.ClusterRequest, endpointVar string) *session.Session {
+	config := aws.Config{Region: aws.String(req.Region)}
+	if endpoint := os.Getenv(endpointVar); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
 
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		Config:  aws.Config{Region: aws.String(req.Region)},
+	return session.Must(session.NewSessionWithOptions(session.Options{
+		Config:  config,
 		Profile: req.Profile,
 	}))
-	svc := rds.New(sess)
+}
+
+// newRDS creates the client for req. RDS_ENDPOINT overrides the endpoint.
+// Calls are retried according to the retry settings in req.
+func newRDS(req request.ClusterRequest) rdsiface.RDSAPI {
+	sess := newSession(req, rdsEndpointVar)
+
+	return factory.WithRetry(rds.New(sess), factory.RetryPolicy{
+		InitialDelay: retryInitialDelay,
+		MaxDelay:     time.Duration(req.RetryMaxDelay) * time.Second,
+		Timeout:      time.Duration(req.RetryTimeout) * time.Minute,
+	})
+}
+
+// newRegions returns the clients for the secondary regions of a global
+// database, each built like the client for req.
+func newRegions(req request.ClusterRequest) service.Regions {
+	return func(region string) rdsiface.RDSAPI {
+		regional := req
+		regional.Region = region
+		return newRDS(regional)
+	}
+}
+
+// newWaiter builds the waiter for req and prints each status change as it
+// happens.
+func newWaiter(req request.ClusterRequest) *factory.Waiter {
+	waiter := service.NewWaiter(req)
+	waiter.Progress = printProgress
+
+	return waiter
+}
+
+func printProgress(p factory.WaitProgress) {
+	elapsed := p.Elapsed.Round(time.Second)
+	switch {
+	case p.Done:
+		fmt.Printf("  %s %s: %s (%s)\n", p.Resource, p.Identifier, p.Status, elapsed)
+	case p.Previous == "":
+		fmt.Printf("  %s %s: %s\n", p.Resource, p.Identifier, p.Status)
+	default:
+		fmt.Printf("  %s %s: %s -> %s (%s)\n", p.Resource, p.Identifier, p.Previous, p.Status, elapsed)
+	}
+}
+
+// confirm asks a yes/no question on stdin. Anything other than "yes" is a
+// no.
+func confirm(question string) bool {
+	fmt.Printf("%s Only 'yes' will be accepted: ", question)
 
-	err := service.HandleRequest(svc, req)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
-		log.Fatal(err)
+		return false
 	}
 
-	log.Info("success")
+	return strings.TrimSpace(answer) == "yes"
 }
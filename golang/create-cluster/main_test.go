@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "invalid request", err: request.ValidationError{"region is required"}, want: exitInvalid},
+		{name: "throttled", err: &factory.Error{Kind: factory.KindThrottled}, want: exitRetryable},
+		{name: "invalid state", err: &factory.Error{Kind: factory.KindInvalidState}, want: exitRetryable},
+		{name: "rejected by RDS", err: &factory.Error{Kind: factory.KindValidation}, want: exitInvalid},
+		{name: "already exists", err: &factory.Error{Kind: factory.KindAlreadyExists}, want: exitFailure},
+		{name: "unclassified", err: errors.New("cluster not ready within timeout"), want: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
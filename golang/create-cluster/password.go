@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
+)
+
+const (
+	secretsManagerEndpointVar = "SECRETSMANAGER_ENDPOINT"
+	ssmEndpointVar            = "SSM_ENDPOINT"
+)
+
+// resolvePassword fills in the master password from the source named in
+// req, if any, and reports whether it was generated. A generated password is
+// kept in memory; with save set, req.SaveMasterUserPass stores it once the
+// cluster holds it.
+func resolvePassword(req *request.ClusterRequest, save bool) (bool, error) {
+	src := req.MasterUserPassSource
+	if src == nil {
+		return false, nil
+	}
+
+	source := secret.Source{
+		Store:    newPasswordStore(*req),
+		Generate: src.Generate,
+		Length:   src.Length,
+	}
+	password, generated, err := source.Password()
+	if err == secret.ErrNotStored {
+		return false, fmt.Errorf("%s holds no master password; set generate to create one", source.Store)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case generated && save:
+		req.SaveMasterUserPass = func() error {
+			err := source.Store.Put(password)
+			if err != nil {
+				return fmt.Errorf("cluster %s has a new master password that couldn't be stored in %s: %v",
+					req.ClusterId, source.Store, err)
+			}
+			log.Infof("stored the new master password in %s", source.Store)
+			return nil
+		}
+		log.Infof("generated a new master password, it is stored in %s once the cluster has it", source.Store)
+	case generated:
+		log.Infof("a new master password will be generated and stored in %s", source.Store)
+	}
+
+	req.MasterUserPass = password
+	return generated, nil
+}
+
+func newPasswordStore(req request.ClusterRequest) secret.Store {
+	src := req.MasterUserPassSource
+
+	switch {
+	case src.SecretId != "":
+		return secret.SecretsManagerStore{
+			Svc:      secretsmanager.New(newSession(req, secretsManagerEndpointVar)),
+			SecretId: src.SecretId,
+			Username: req.MasterUsername,
+		}
+	case src.Parameter != "":
+		return secret.SSMStore{
+			Svc:  ssm.New(newSession(req, ssmEndpointVar)),
+			Name: src.Parameter,
+		}
+	case src.Stdin:
+		return secret.ReaderStore{Reader: os.Stdin, Name: "stdin"}
+	default:
+		return secret.FileStore{Path: src.File}
+	}
+}
@@ -0,0 +1,103 @@
+package request
+
+import "fmt"
+
+// GlobalRequest makes the cluster the primary of the Aurora global database
+// Identifier, with a read-only secondary cluster in each of Secondaries.
+type GlobalRequest struct {
+	Identifier  string
+	Secondaries []SecondaryRequest
+}
+
+// SecondaryRequest describes a secondary cluster of a global database. Only
+// what is regional is declared; the engine and the other settings are those
+// of the primary cluster. An empty KmsKeyId means the default key of the
+// region.
+type SecondaryRequest struct {
+	Region           string
+	GroupName        string
+	GroupDescription string
+	Subnets          []string
+	ClusterId        string
+	SgIds            []string
+	KmsKeyId         string
+	Instances        []InstanceRequest
+}
+
+// SecondaryRequests returns a request for each secondary cluster of the
+// global database, in the order they were declared. They are built from r,
+// without the master credentials and the source snapshot, which a secondary
+// cluster takes from the global cluster.
+func (r ClusterRequest) SecondaryRequests() []ClusterRequest {
+	if r.Global == nil {
+		return nil
+	}
+
+	requests := make([]ClusterRequest, 0)
+	for _, s := range r.Global.Secondaries {
+		req := r
+		req.Region = s.Region
+		req.GroupName = s.GroupName
+		req.GroupDescription = s.GroupDescription
+		req.Subnets = s.Subnets
+		req.ClusterId = s.ClusterId
+		req.SgIds = s.SgIds
+		req.KmsKeyId = s.KmsKeyId
+		req.Instances = s.Instances
+
+		req.MasterUsername = ""
+		req.MasterUserPass = ""
+		req.MasterUserPassSource = nil
+		req.UpdateMasterUserPass = false
+		req.SaveMasterUserPass = nil
+		req.SourceSnapshot = ""
+		req.ReplaceCluster = false
+		req.Global = nil
+		req.GlobalClusterId = r.Global.Identifier
+
+		requests = append(requests, req)
+	}
+
+	return requests
+}
+
+func (r ClusterRequest) validateGlobal(check func(bool, string, ...interface{})) {
+	g := r.Global
+
+	check(isIdentifier(g.Identifier), "invalid global cluster id %q", g.Identifier)
+	check(r.Serverless == nil, "a serverless cluster can't be part of a global database")
+	check(len(g.Secondaries) > 0, "a global database needs at least one secondary cluster")
+
+	regions := map[string]bool{r.Region: true}
+	for _, s := range g.Secondaries {
+		if s.Region == "" {
+			continue
+		}
+		check(!regions[s.Region], "region %s has more than one cluster of the global database", s.Region)
+		regions[s.Region] = true
+	}
+}
+
+// validateSecondaries checks the request of each secondary cluster with
+// validate. Problems with the settings copied from the primary cluster are
+// only reported once, for the primary.
+func (r ClusterRequest) validateSecondaries(errs *ValidationError, validate func(ClusterRequest) error) {
+	reported := map[string]bool{}
+	for _, e := range *errs {
+		reported[e] = true
+	}
+
+	for n, s := range r.SecondaryRequests() {
+		label := fmt.Sprintf("secondary cluster %d", n+1)
+		if s.Region != "" {
+			label = "secondary cluster in " + s.Region
+		}
+
+		err, _ := validate(s).(ValidationError)
+		for _, e := range err {
+			if !reported[e] {
+				*errs = append(*errs, label+": "+e)
+			}
+		}
+	}
+}
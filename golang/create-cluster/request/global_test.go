@@ -0,0 +1,55 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecondaryRequests(t *testing.T) {
+	spec := testSpec + `
+global:
+  id: aurora-experiments-global
+  secondaries:
+    - region: us-east-1
+      subnetGroup:
+        name: aurora-experiments
+        description: aurora experiments subnet group
+        subnets:
+          - subnet-00000000000000011
+          - subnet-00000000000000012
+      clusterId: aurora-experiments-east
+      securityGroupIds:
+        - sg-00000000000000011
+      instances:
+        - id: aurora-experiments-east-0
+          class: db.r4.large
+`
+	path, cleanup := writeSpec(t, "cluster.yaml", spec)
+	defer cleanup()
+	s, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := s.ClusterRequest()
+	req.MasterUserPass = "secret123"
+
+	secondaries := req.SecondaryRequests()
+	if len(secondaries) != 1 {
+		t.Fatalf("%d secondary requests, want 1", len(secondaries))
+	}
+	got := secondaries[0]
+
+	want := req
+	want.Region = "us-east-1"
+	want.Subnets = []string{"subnet-00000000000000011", "subnet-00000000000000012"}
+	want.ClusterId = "aurora-experiments-east"
+	want.SgIds = []string{"sg-00000000000000011"}
+	want.Instances = []InstanceRequest{{Identifier: "aurora-experiments-east-0", Class: "db.r4.large"}}
+	want.MasterUsername = ""
+	want.MasterUserPass = ""
+	want.Global = nil
+	want.GlobalClusterId = "aurora-experiments-global"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("secondary request = %+v, want %+v", got, want)
+	}
+}
@@ -1,11 +1,12 @@
 package request
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
 )
 
 const (
@@ -15,68 +16,445 @@ const (
 	engineVersionVar    = "ENGINE_VERSION"
 	masterUsernameVar   = "MASTER_USERNAME"
 	masterUserPassVar   = "MASTER_USER_PASSWORD"
+	passwordFileVar     = "MASTER_USER_PASSWORD_FILE"
+	passwordSecretVar   = "MASTER_USER_PASSWORD_SECRET_ID"
+	passwordParamVar    = "MASTER_USER_PASSWORD_SSM_PARAMETER"
+	passwordGenerateVar = "MASTER_USER_PASSWORD_GENERATE"
+	passwordLengthVar   = "MASTER_USER_PASSWORD_LENGTH"
+	storageEncryptedVar = "STORAGE_ENCRYPTED"
+	kmsKeyIdVar         = "KMS_KEY_ID"
+	sourceSnapshotVar   = "SOURCE_SNAPSHOT_ID"
+	backupRetentionVar  = "BACKUP_RETENTION_PERIOD"
+	backupWindowVar     = "BACKUP_WINDOW"
+	maintenanceWinVar   = "MAINTENANCE_WINDOW"
+	minCapacityVar      = "SERVERLESS_MIN_CAPACITY"
+	maxCapacityVar      = "SERVERLESS_MAX_CAPACITY"
+	autoPauseVar        = "SERVERLESS_AUTO_PAUSE"
+	autoPauseSecondsVar = "SERVERLESS_SECONDS_UNTIL_AUTO_PAUSE"
+	minV2CapacityVar    = "SERVERLESS_V2_MIN_CAPACITY"
+	maxV2CapacityVar    = "SERVERLESS_V2_MAX_CAPACITY"
 	clusterIdVar        = "CLUSTER_ID"
 	awsRegionVar        = "AWS_REGION"
 	awsProfileVar       = "AWS_PROFILE"
 	readyTimeoutVar     = "READY_TIMEOUT_MINUTES"
+	upgradeTimeoutVar   = "UPGRADE_TIMEOUT_MINUTES"
+	deleteTimeoutVar    = "DELETE_TIMEOUT_MINUTES"
 	instanceIdVar       = "INSTANCE_ID"
 	instanceClassVar    = "INSTANCE_CLASS"
 	sgIdsVar            = "SECURITY_GROUP_IDS"
 	subnetsVar          = "SUBNETS"
+	retryTimeoutVar     = "RETRY_TIMEOUT_MINUTES"
+	retryMaxDelayVar    = "RETRY_MAX_DELAY_SECONDS"
+	waitPollIntervalVar = "WAIT_POLL_INTERVAL_SECONDS"
+	waitStableCountVar  = "WAIT_STABLE_COUNT"
+
+	defaultReadyTimeout   = 1
+	defaultUpgradeTimeout = 60
+	defaultDeleteTimeout  = 30
+	defaultRetryTimeout   = 10
+	defaultRetryMaxDelay  = 30
+	defaultPollInterval   = 10
+	defaultStableCount    = 4
+	defaultAutoPause      = 300
 )
 
+// InstanceRequest describes one cluster member. PromotionTier is nil when
+// the RDS default should be used and ParameterGroup when the instance keeps
+// its current DB parameter group. An empty MaintenanceWindow leaves the
+// window RDS picked.
+type InstanceRequest struct {
+	Identifier        string
+	Class             string
+	PromotionTier     *int64
+	AvailabilityZone  string
+	ParameterGroup    *ParameterGroupRequest
+	MaintenanceWindow string
+}
+
+// ParameterGroupRequest declares a custom parameter group. Only the listed
+// parameters are managed; every other parameter keeps the family default.
+type ParameterGroupRequest struct {
+	Name        string
+	Family      string
+	Description string
+	Parameters  map[string]string
+}
+
+// PasswordSource says where the master password is read from instead of
+// being given directly. Exactly one of File, Stdin, SecretId and Parameter is
+// set. With Generate, a password of Length characters is generated and
+// stored there when it holds none yet.
+type PasswordSource struct {
+	File      string
+	Stdin     bool
+	SecretId  string
+	Parameter string
+	Generate  bool
+	Length    int
+}
+
+// ServerlessRequest makes the cluster an Aurora Serverless (v1) one, which
+// scales between MinCapacity and MaxCapacity Aurora capacity units instead
+// of running instances. With AutoPause it pauses after
+// SecondsUntilAutoPause without connections.
+type ServerlessRequest struct {
+	MinCapacity           int
+	MaxCapacity           int
+	AutoPause             bool
+	SecondsUntilAutoPause int
+}
+
+// ServerlessV2Request is the capacity range of the Aurora Serverless v2
+// instances of a provisioned cluster, the ones of class db.serverless. Each
+// of them scales between MinCapacity and MaxCapacity Aurora capacity units,
+// in steps of half a unit.
+type ServerlessV2Request struct {
+	MinCapacity float64
+	MaxCapacity float64
+}
+
 type ClusterRequest struct {
-	Region             string
-	Profile            string
-	InstanceIdentifier string
-	InstanceClass      string
-	ClusterId          string
-	Engine             string
-	EngineVersion      string
-	MasterUsername     string
-	MasterUserPass     string
-	GroupDescription   string
-	GroupName          string
-	ReadyTimeout       int
+	Region           string
+	Profile          string
+	ClusterId        string
+	Engine           string
+	EngineVersion    string
+	MasterUsername   string
+	MasterUserPass   string
+	GroupDescription string
+	GroupName        string
+	ReadyTimeout     int
+	RetryTimeout     int
+	RetryMaxDelay    int
+	// WaitPollInterval is in seconds and WaitStatusTimeouts in minutes.
+	WaitPollInterval   int
+	WaitStableCount    int
+	WaitStatusTimeouts map[string]int
 	SgIds              []string
 	Subnets            []string
+	Instances          []InstanceRequest
+
+	// ClusterParameterGroup is nil when the engine default group is used.
+	ClusterParameterGroup *ParameterGroupRequest
+	// MasterUserPassSource is resolved into MasterUserPass before use.
+	MasterUserPassSource *PasswordSource
+	// UpdateMasterUserPass also sets MasterUserPass on an existing cluster,
+	// which otherwise only gets it on create.
+	UpdateMasterUserPass bool
+	// SaveMasterUserPass, when set, stores a generated MasterUserPass. It is
+	// called once the cluster was created or modified with the password, so
+	// a failed apply doesn't leave a password that the cluster never got.
+	SaveMasterUserPass func() error
+	// StorageEncrypted is nil to leave the encryption of an existing cluster
+	// alone, and the RDS default for a new one. KmsKeyId is a key id, key
+	// ARN or alias, resolved to the key ARN before use. Empty means the
+	// account default key when StorageEncrypted is set.
+	StorageEncrypted *bool
+	KmsKeyId         string
+	// SourceSnapshot is a cluster snapshot identifier or ARN that a new
+	// cluster is restored from. It is ignored once the cluster exists.
+	SourceSnapshot string
+	// BackupRetentionPeriod is in days. Zero, like an empty window, leaves
+	// the setting alone. Windows are in UTC, as hh24:mi-hh24:mi for backups
+	// and ddd:hh24:mi-ddd:hh24:mi for maintenance.
+	BackupRetentionPeriod int
+	BackupWindow          string
+	MaintenanceWindow     string
+	// ReplaceCluster allows changes that can't be made to an existing
+	// cluster to be applied by replacing it from a snapshot.
+	ReplaceCluster bool
+	// UpgradeTimeout is used instead of ReadyTimeout while the engine
+	// version is upgraded, which takes much longer than other changes.
+	UpgradeTimeout int
+	// DeleteTimeout bounds each wait for a cluster, instance, global cluster
+	// or snapshot to be deleted.
+	DeleteTimeout int
+	// SnapshotBeforeUpgrade takes a snapshot of the cluster before its
+	// engine version is upgraded in place.
+	SnapshotBeforeUpgrade bool
+	// Serverless is nil for a provisioned cluster. A serverless cluster has
+	// no Instances.
+	Serverless *ServerlessRequest
+	// ServerlessV2 is nil to leave the Serverless v2 capacity of an existing
+	// cluster alone. It is required for db.serverless instances, which can
+	// be mixed with provisioned ones.
+	ServerlessV2 *ServerlessV2Request
+	// Global makes the cluster the primary of a global database. It is nil
+	// for a regional cluster and on the requests of secondary clusters.
+	Global *GlobalRequest
+	// GlobalClusterId is only set on the request of a secondary cluster,
+	// which joins that global cluster and gets its master credentials from
+	// it.
+	GlobalClusterId string
+
+	// envErrors are the environment variables that couldn't be parsed. They
+	// are reported by the Validate methods.
+	envErrors []string
 }
 
+// NewRequest builds a ClusterRequest from the environment only.
 func NewRequest() ClusterRequest {
 	req := ClusterRequest{}
-	req.Region = os.Getenv(awsRegionVar)
-	req.Profile = os.Getenv(awsProfileVar)
-	req.InstanceIdentifier = os.Getenv(instanceIdVar)
-	req.InstanceClass = os.Getenv(instanceClassVar)
-	req.ClusterId = os.Getenv(clusterIdVar)
-	req.Engine = os.Getenv(engineVar)
-	req.EngineVersion = os.Getenv(engineVersionVar)
-	req.MasterUsername = os.Getenv(masterUsernameVar)
-	req.MasterUserPass = os.Getenv(masterUserPassVar)
-	req.GroupDescription = os.Getenv(groupDescriptionVar)
-	req.GroupName = os.Getenv(groupNameVar)
+	applyEnv(&req)
+	applyDefaults(&req)
+
+	return req
+}
+
+// NewRequestFromFile loads a cluster spec file and applies any environment
+// variables on top of it, so a value set in the environment always wins.
+func NewRequestFromFile(path string) (ClusterRequest, error) {
+	spec, err := LoadSpec(path)
+	if err != nil {
+		return ClusterRequest{}, err
+	}
+
+	req := spec.ClusterRequest()
+	applyEnv(&req)
+	applyDefaults(&req)
+
+	return req, nil
+}
+
+func applyEnv(req *ClusterRequest) {
+	setString(&req.Region, awsRegionVar)
+	setString(&req.Profile, awsProfileVar)
+	setString(&req.ClusterId, clusterIdVar)
+	setString(&req.Engine, engineVar)
+	setString(&req.EngineVersion, engineVersionVar)
+	setString(&req.MasterUsername, masterUsernameVar)
+	setString(&req.MasterUserPass, masterUserPassVar)
+	setString(&req.GroupDescription, groupDescriptionVar)
+	setString(&req.GroupName, groupNameVar)
+	req.setOptionalBool(&req.StorageEncrypted, storageEncryptedVar)
+	setString(&req.KmsKeyId, kmsKeyIdVar)
+	setString(&req.SourceSnapshot, sourceSnapshotVar)
+	req.setInt(&req.BackupRetentionPeriod, backupRetentionVar)
+	setString(&req.BackupWindow, backupWindowVar)
+	setString(&req.MaintenanceWindow, maintenanceWinVar)
+
+	if v := os.Getenv(sgIdsVar); v != "" {
+		req.SgIds = splitList(v)
+	}
+	if v := os.Getenv(subnetsVar); v != "" {
+		req.Subnets = splitList(v)
+	}
+
+	// INSTANCE_ID and INSTANCE_CLASS describe the first (writer) instance.
+	instanceId := os.Getenv(instanceIdVar)
+	instanceClass := os.Getenv(instanceClassVar)
+	if instanceId != "" || instanceClass != "" {
+		if len(req.Instances) == 0 {
+			req.Instances = append(req.Instances, InstanceRequest{})
+		}
+		if instanceId != "" {
+			req.Instances[0].Identifier = instanceId
+		}
+		if instanceClass != "" {
+			req.Instances[0].Class = instanceClass
+		}
+	}
+
+	req.setInt(&req.ReadyTimeout, readyTimeoutVar)
+	req.setInt(&req.UpgradeTimeout, upgradeTimeoutVar)
+	req.setInt(&req.DeleteTimeout, deleteTimeoutVar)
+	req.setInt(&req.RetryTimeout, retryTimeoutVar)
+	req.setInt(&req.RetryMaxDelay, retryMaxDelayVar)
+	req.setInt(&req.WaitPollInterval, waitPollIntervalVar)
+	req.setInt(&req.WaitStableCount, waitStableCountVar)
+
+	applyPasswordSourceEnv(req)
+	applyServerlessEnv(req)
+	applyServerlessV2Env(req)
+}
+
+// applyPasswordSourceEnv lets the environment pick the password backend. A
+// backend set there replaces the one in the spec; a file named "-" is stdin.
+func applyPasswordSourceEnv(req *ClusterRequest) {
+	backend := PasswordSource{}
+	setString(&backend.File, passwordFileVar)
+	setString(&backend.SecretId, passwordSecretVar)
+	setString(&backend.Parameter, passwordParamVar)
+	if backend.File == "-" {
+		backend.File, backend.Stdin = "", true
+	}
+
+	if backend != (PasswordSource{}) {
+		if req.MasterUserPassSource != nil {
+			backend.Generate = req.MasterUserPassSource.Generate
+			backend.Length = req.MasterUserPassSource.Length
+		}
+		req.MasterUserPassSource = &backend
+	}
+
+	if req.MasterUserPassSource != nil {
+		req.setBool(&req.MasterUserPassSource.Generate, passwordGenerateVar)
+		req.setInt(&req.MasterUserPassSource.Length, passwordLengthVar)
+	}
+}
+
+// applyServerlessEnv makes the cluster serverless when any of the capacity
+// variables is set.
+func applyServerlessEnv(req *ClusterRequest) {
+	serverless := ServerlessRequest{}
+	if req.Serverless != nil {
+		serverless = *req.Serverless
+	}
+	req.setInt(&serverless.MinCapacity, minCapacityVar)
+	req.setInt(&serverless.MaxCapacity, maxCapacityVar)
+	req.setBool(&serverless.AutoPause, autoPauseVar)
+	req.setInt(&serverless.SecondsUntilAutoPause, autoPauseSecondsVar)
+
+	if req.Serverless != nil || serverless != (ServerlessRequest{}) {
+		req.Serverless = &serverless
+	}
+}
 
-	readyTimeout := os.Getenv(readyTimeoutVar)
+// applyServerlessV2Env sets the Serverless v2 capacity range when either of
+// its variables is set.
+func applyServerlessV2Env(req *ClusterRequest) {
+	serverless := ServerlessV2Request{}
+	if req.ServerlessV2 != nil {
+		serverless = *req.ServerlessV2
+	}
+	req.setFloat(&serverless.MinCapacity, minV2CapacityVar)
+	req.setFloat(&serverless.MaxCapacity, maxV2CapacityVar)
 
-	sgIds := make([]string, 0)
-	subnets := make([]string, 0)
+	if req.ServerlessV2 != nil || serverless != (ServerlessV2Request{}) {
+		req.ServerlessV2 = &serverless
+	}
+}
 
-	for _, sgI := range strings.Split(os.Getenv(sgIdsVar), ",") {
-		sgIds = append(sgIds, sgI)
+func applyDefaults(req *ClusterRequest) {
+	if req.ReadyTimeout == 0 {
+		req.ReadyTimeout = defaultReadyTimeout
+	}
+	if req.UpgradeTimeout == 0 {
+		req.UpgradeTimeout = defaultUpgradeTimeout
+	}
+	if req.DeleteTimeout == 0 {
+		req.DeleteTimeout = defaultDeleteTimeout
+	}
+	if req.RetryTimeout == 0 {
+		req.RetryTimeout = defaultRetryTimeout
+	}
+	if req.RetryMaxDelay == 0 {
+		req.RetryMaxDelay = defaultRetryMaxDelay
+	}
+	if req.WaitPollInterval == 0 {
+		req.WaitPollInterval = defaultPollInterval
+	}
+	if req.WaitStableCount == 0 {
+		req.WaitStableCount = defaultStableCount
 	}
-	for _, subnet := range strings.Split(os.Getenv(subnetsVar), ",") {
-		subnets = append(subnets, subnet)
+	if req.MasterUserPassSource != nil && req.MasterUserPassSource.Length == 0 {
+		req.MasterUserPassSource.Length = secret.DefaultLength
 	}
+	if s := req.Serverless; s != nil && s.AutoPause && s.SecondsUntilAutoPause == 0 {
+		s.SecondsUntilAutoPause = defaultAutoPause
+	}
+
+	// RDS reports maintenance windows with lower case days.
+	req.MaintenanceWindow = strings.ToLower(req.MaintenanceWindow)
+	lowerWindows(req.Instances)
+	if req.Global != nil {
+		for n := range req.Global.Secondaries {
+			lowerWindows(req.Global.Secondaries[n].Instances)
+		}
+	}
+}
+
+func lowerWindows(instances []InstanceRequest) {
+	for n := range instances {
+		instances[n].MaintenanceWindow = strings.ToLower(instances[n].MaintenanceWindow)
+	}
+}
 
-	req.SgIds = sgIds
-	req.Subnets = subnets
+// DBParameterGroups returns the DB parameter groups declared by the
+// instances, once each and in instance order. Instances may share a group.
+func (r ClusterRequest) DBParameterGroups() []ParameterGroupRequest {
+	groups := make([]ParameterGroupRequest, 0)
+	seen := map[string]bool{}
+	for _, i := range r.Instances {
+		if i.ParameterGroup == nil || seen[i.ParameterGroup.Name] {
+			continue
+		}
+		seen[i.ParameterGroup.Name] = true
+		groups = append(groups, *i.ParameterGroup)
+	}
+
+	return groups
+}
 
-	rTimeout, err := strconv.Atoi(readyTimeout)
+func setString(field *string, envVar string) {
+	if v := os.Getenv(envVar); v != "" {
+		*field = v
+	}
+}
+
+func (r *ClusterRequest) setInt(field *int, envVar string) {
+	if v := os.Getenv(envVar); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			r.envError(envVar, v, "an integer")
+		} else {
+			*field = i
+		}
+	}
+}
+
+func (r *ClusterRequest) setFloat(field *float64, envVar string) {
+	if v := os.Getenv(envVar); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			r.envError(envVar, v, "a number")
+		} else {
+			*field = f
+		}
+	}
+}
+
+func (r *ClusterRequest) setBool(field *bool, envVar string) {
+	if v := os.Getenv(envVar); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.envError(envVar, v, "true or false")
+		} else {
+			*field = b
+		}
+	}
+}
+
+// setOptionalBool is setBool for a setting that is nil when it isn't given.
+func (r *ClusterRequest) setOptionalBool(field **bool, envVar string) {
+	v := os.Getenv(envVar)
+	if v == "" {
+		return
+	}
+
+	b, err := strconv.ParseBool(v)
 	if err != nil {
-		log.Warn(err)
-		rTimeout = 1
+		r.envError(envVar, v, "true or false")
+		return
+	}
+	*field = &b
+}
+
+func (r *ClusterRequest) envError(envVar, value, expected string) {
+	r.envErrors = append(r.envErrors, fmt.Sprintf("%s %q must be %s", envVar, value, expected))
+}
+
+// splitList splits a comma separated list, dropping blank entries and
+// surrounding whitespace.
+func splitList(v string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		items = append(items, item)
 	}
-	req.ReadyTimeout = rTimeout
 
-	return req
+	return items
 }
@@ -0,0 +1,88 @@
+package request
+
+import "math"
+
+const (
+	minAutoPauseSeconds = 300
+	maxAutoPauseSeconds = 86400
+
+	// serverlessV2Class is the instance class of Aurora Serverless v2,
+	// whose capacity is set on the cluster.
+	serverlessV2Class = "db.serverless"
+
+	minServerlessV2Capacity  = 0.5
+	maxServerlessV2Capacity  = 256.0
+	serverlessV2CapacityStep = 0.5
+)
+
+// serverlessCapacities are the capacities, in Aurora capacity units, that a
+// serverless cluster of each engine can scale between.
+var serverlessCapacities = map[string][]int{
+	"aurora":            {1, 2, 4, 8, 16, 32, 64, 128, 256},
+	"aurora-mysql":      {1, 2, 4, 8, 16, 32, 64, 128, 256},
+	"aurora-postgresql": {2, 4, 8, 16, 32, 64, 192, 384},
+}
+
+func (r ClusterRequest) validateServerless(check func(bool, string, ...interface{})) {
+	s := r.Serverless
+
+	capacities, ok := serverlessCapacities[r.Engine]
+	check(ok, "engine %q has no serverless mode", r.Engine)
+	if ok {
+		check(hasCapacity(capacities, s.MinCapacity), "invalid minimum capacity %d, expected one of %v", s.MinCapacity, capacities)
+		check(hasCapacity(capacities, s.MaxCapacity), "invalid maximum capacity %d, expected one of %v", s.MaxCapacity, capacities)
+	}
+	check(
+		s.MinCapacity <= s.MaxCapacity,
+		"minimum capacity %d is above maximum capacity %d", s.MinCapacity, s.MaxCapacity,
+	)
+	if s.AutoPause {
+		check(
+			s.SecondsUntilAutoPause >= minAutoPauseSeconds && s.SecondsUntilAutoPause <= maxAutoPauseSeconds,
+			"seconds until auto pause must be between %d and %d, got %d",
+			minAutoPauseSeconds, maxAutoPauseSeconds, s.SecondsUntilAutoPause,
+		)
+	} else {
+		check(s.SecondsUntilAutoPause == 0, "seconds until auto pause is only used with auto pause")
+	}
+
+	// RDS encrypts every serverless cluster, so anything else would show up
+	// as a change on every plan.
+	check(
+		r.StorageEncrypted == nil || *r.StorageEncrypted,
+		"serverless clusters are always encrypted, storage encryption can't be turned off",
+	)
+	check(len(r.Instances) == 0, "serverless clusters have no instances, got %d", len(r.Instances))
+}
+
+func (r ClusterRequest) validateServerlessV2(check func(bool, string, ...interface{})) {
+	s := r.ServerlessV2
+
+	// Aurora MySQL 5.6 has no Serverless v2.
+	check(
+		r.Engine == "aurora-mysql" || r.Engine == "aurora-postgresql",
+		"engine %q has no Serverless v2 instances", r.Engine,
+	)
+	check(r.Serverless == nil, "serverless and serverlessV2 are mutually exclusive")
+	for _, c := range []float64{s.MinCapacity, s.MaxCapacity} {
+		check(
+			c >= minServerlessV2Capacity && c <= maxServerlessV2Capacity && math.Mod(c, serverlessV2CapacityStep) == 0,
+			"invalid Serverless v2 capacity %g, expected %g to %g in steps of %g",
+			c, minServerlessV2Capacity, maxServerlessV2Capacity, serverlessV2CapacityStep,
+		)
+	}
+	check(
+		s.MinCapacity <= s.MaxCapacity,
+		"minimum Serverless v2 capacity %g is above maximum capacity %g", s.MinCapacity, s.MaxCapacity,
+	)
+}
+
+func hasCapacity(capacities []int, capacity int) bool {
+	for _, c := range capacities {
+		if c == capacity {
+			return true
+		}
+	}
+
+	return false
+}
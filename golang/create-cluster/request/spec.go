@@ -0,0 +1,279 @@
+package request
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Spec is the on-disk description of a cluster. JSON is a subset of YAML so
+// the same decoder handles both formats.
+type Spec struct {
+	Region                string          `yaml:"region"`
+	Profile               string          `yaml:"profile"`
+	ReadyTimeoutMinutes   int             `yaml:"readyTimeoutMinutes"`
+	UpgradeTimeoutMinutes int             `yaml:"upgradeTimeoutMinutes"`
+	DeleteTimeoutMinutes  int             `yaml:"deleteTimeoutMinutes"`
+	Retry                 RetrySpec       `yaml:"retry"`
+	Wait                  WaitSpec        `yaml:"wait"`
+	SubnetGroup           SubnetGroupSpec `yaml:"subnetGroup"`
+	Cluster               ClusterSpec     `yaml:"cluster"`
+	Instances             []InstanceSpec  `yaml:"instances"`
+	Global                *GlobalSpec     `yaml:"global"`
+}
+
+// RetrySpec bounds how long a failed RDS call is retried and the longest
+// backoff between attempts.
+type RetrySpec struct {
+	TimeoutMinutes  int `yaml:"timeoutMinutes"`
+	MaxDelaySeconds int `yaml:"maxDelaySeconds"`
+}
+
+// WaitSpec tunes how resources are polled until they are ready.
+// StatusTimeoutMinutes limits the time spent in a single status, for example
+// {modifying: 30}.
+type WaitSpec struct {
+	PollIntervalSeconds  int            `yaml:"pollIntervalSeconds"`
+	StableCount          int            `yaml:"stableCount"`
+	StatusTimeoutMinutes map[string]int `yaml:"statusTimeoutMinutes"`
+}
+
+type SubnetGroupSpec struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Subnets     []string `yaml:"subnets"`
+}
+
+type ClusterSpec struct {
+	Id                 string   `yaml:"id"`
+	Engine             string   `yaml:"engine"`
+	EngineVersion      string   `yaml:"engineVersion"`
+	MasterUsername     string   `yaml:"masterUsername"`
+	MasterUserPassword string   `yaml:"masterUserPassword"`
+	SecurityGroupIds   []string `yaml:"securityGroupIds"`
+
+	MasterUserPasswordFrom *PasswordSourceSpec `yaml:"masterUserPasswordFrom"`
+
+	ParameterGroup *ParameterGroupSpec `yaml:"parameterGroup"`
+
+	StorageEncrypted *bool  `yaml:"storageEncrypted"`
+	KmsKeyId         string `yaml:"kmsKeyId"`
+
+	SourceSnapshot string `yaml:"sourceSnapshot"`
+
+	BackupRetentionPeriod int    `yaml:"backupRetentionPeriod"`
+	BackupWindow          string `yaml:"backupWindow"`
+	MaintenanceWindow     string `yaml:"maintenanceWindow"`
+
+	Serverless   *ServerlessSpec   `yaml:"serverless"`
+	ServerlessV2 *ServerlessV2Spec `yaml:"serverlessV2"`
+}
+
+// ServerlessSpec makes the cluster an Aurora Serverless (v1) one. Capacities
+// are in Aurora capacity units.
+type ServerlessSpec struct {
+	MinCapacity           int  `yaml:"minCapacity"`
+	MaxCapacity           int  `yaml:"maxCapacity"`
+	AutoPause             bool `yaml:"autoPause"`
+	SecondsUntilAutoPause int  `yaml:"secondsUntilAutoPause"`
+}
+
+func (s *ServerlessSpec) request() *ServerlessRequest {
+	if s == nil {
+		return nil
+	}
+
+	return &ServerlessRequest{
+		MinCapacity:           s.MinCapacity,
+		MaxCapacity:           s.MaxCapacity,
+		AutoPause:             s.AutoPause,
+		SecondsUntilAutoPause: s.SecondsUntilAutoPause,
+	}
+}
+
+// ServerlessV2Spec is the capacity range, in Aurora capacity units, of the
+// db.serverless instances of the cluster.
+type ServerlessV2Spec struct {
+	MinCapacity float64 `yaml:"minCapacity"`
+	MaxCapacity float64 `yaml:"maxCapacity"`
+}
+
+func (s *ServerlessV2Spec) request() *ServerlessV2Request {
+	if s == nil {
+		return nil
+	}
+
+	return &ServerlessV2Request{MinCapacity: s.MinCapacity, MaxCapacity: s.MaxCapacity}
+}
+
+// GlobalSpec makes the cluster the primary of an Aurora global database
+// with a read-only secondary cluster in each of Secondaries.
+type GlobalSpec struct {
+	Id          string          `yaml:"id"`
+	Secondaries []SecondarySpec `yaml:"secondaries"`
+}
+
+// SecondarySpec declares the regional parts of a secondary cluster. The
+// engine, parameter groups and settings are those of the primary cluster.
+type SecondarySpec struct {
+	Region           string          `yaml:"region"`
+	SubnetGroup      SubnetGroupSpec `yaml:"subnetGroup"`
+	ClusterId        string          `yaml:"clusterId"`
+	SecurityGroupIds []string        `yaml:"securityGroupIds"`
+	KmsKeyId         string          `yaml:"kmsKeyId"`
+	Instances        []InstanceSpec  `yaml:"instances"`
+}
+
+func (s *GlobalSpec) request() *GlobalRequest {
+	if s == nil {
+		return nil
+	}
+
+	global := &GlobalRequest{Identifier: s.Id}
+	for _, sec := range s.Secondaries {
+		global.Secondaries = append(global.Secondaries, SecondaryRequest{
+			Region:           sec.Region,
+			GroupName:        sec.SubnetGroup.Name,
+			GroupDescription: sec.SubnetGroup.Description,
+			Subnets:          sec.SubnetGroup.Subnets,
+			ClusterId:        sec.ClusterId,
+			SgIds:            sec.SecurityGroupIds,
+			KmsKeyId:         sec.KmsKeyId,
+			Instances:        instanceRequests(sec.Instances),
+		})
+	}
+
+	return global
+}
+
+// PasswordSourceSpec names one place to read the master password from:
+// file, stdin, secretsManager (a secret id or ARN) or ssmParameter.
+type PasswordSourceSpec struct {
+	File           string `yaml:"file"`
+	Stdin          bool   `yaml:"stdin"`
+	SecretsManager string `yaml:"secretsManager"`
+	SSMParameter   string `yaml:"ssmParameter"`
+	Generate       bool   `yaml:"generate"`
+	Length         int    `yaml:"length"`
+}
+
+func (s *PasswordSourceSpec) request() *PasswordSource {
+	if s == nil {
+		return nil
+	}
+
+	return &PasswordSource{
+		File:      s.File,
+		Stdin:     s.Stdin,
+		SecretId:  s.SecretsManager,
+		Parameter: s.SSMParameter,
+		Generate:  s.Generate,
+		Length:    s.Length,
+	}
+}
+
+type ParameterGroupSpec struct {
+	Name        string            `yaml:"name"`
+	Family      string            `yaml:"family"`
+	Description string            `yaml:"description"`
+	Parameters  map[string]string `yaml:"parameters"`
+}
+
+func (s *ParameterGroupSpec) request() *ParameterGroupRequest {
+	if s == nil {
+		return nil
+	}
+
+	return &ParameterGroupRequest{
+		Name:        s.Name,
+		Family:      s.Family,
+		Description: s.Description,
+		Parameters:  s.Parameters,
+	}
+}
+
+type InstanceSpec struct {
+	Id               string `yaml:"id"`
+	Class            string `yaml:"class"`
+	PromotionTier    *int64 `yaml:"promotionTier"`
+	AvailabilityZone string `yaml:"availabilityZone"`
+
+	ParameterGroup *ParameterGroupSpec `yaml:"parameterGroup"`
+
+	MaintenanceWindow string `yaml:"maintenanceWindow"`
+}
+
+// LoadSpec reads and decodes a spec file. Unknown keys are rejected so typos
+// surface before anything is sent to AWS.
+func LoadSpec(path string) (Spec, error) {
+	spec := Spec{}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+
+	err = yaml.UnmarshalStrict(b, &spec)
+	if err != nil {
+		return spec, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return spec, nil
+}
+
+func (s Spec) ClusterRequest() ClusterRequest {
+	req := ClusterRequest{
+		Region:             s.Region,
+		Profile:            s.Profile,
+		ReadyTimeout:       s.ReadyTimeoutMinutes,
+		UpgradeTimeout:     s.UpgradeTimeoutMinutes,
+		DeleteTimeout:      s.DeleteTimeoutMinutes,
+		RetryTimeout:       s.Retry.TimeoutMinutes,
+		RetryMaxDelay:      s.Retry.MaxDelaySeconds,
+		WaitPollInterval:   s.Wait.PollIntervalSeconds,
+		WaitStableCount:    s.Wait.StableCount,
+		WaitStatusTimeouts: s.Wait.StatusTimeoutMinutes,
+		GroupName:          s.SubnetGroup.Name,
+		GroupDescription:   s.SubnetGroup.Description,
+		Subnets:            s.SubnetGroup.Subnets,
+		ClusterId:          s.Cluster.Id,
+		Engine:             s.Cluster.Engine,
+		EngineVersion:      s.Cluster.EngineVersion,
+		MasterUsername:     s.Cluster.MasterUsername,
+		MasterUserPass:     s.Cluster.MasterUserPassword,
+		SgIds:              s.Cluster.SecurityGroupIds,
+
+		MasterUserPassSource:  s.Cluster.MasterUserPasswordFrom.request(),
+		ClusterParameterGroup: s.Cluster.ParameterGroup.request(),
+		StorageEncrypted:      s.Cluster.StorageEncrypted,
+		KmsKeyId:              s.Cluster.KmsKeyId,
+		SourceSnapshot:        s.Cluster.SourceSnapshot,
+		BackupRetentionPeriod: s.Cluster.BackupRetentionPeriod,
+		BackupWindow:          s.Cluster.BackupWindow,
+		MaintenanceWindow:     s.Cluster.MaintenanceWindow,
+		Serverless:            s.Cluster.Serverless.request(),
+		ServerlessV2:          s.Cluster.ServerlessV2.request(),
+		Instances:             instanceRequests(s.Instances),
+		Global:                s.Global.request(),
+	}
+
+	return req
+}
+
+func instanceRequests(specs []InstanceSpec) []InstanceRequest {
+	var instances []InstanceRequest
+	for _, i := range specs {
+		instances = append(instances, InstanceRequest{
+			Identifier:       i.Id,
+			Class:            i.Class,
+			PromotionTier:    i.PromotionTier,
+			AvailabilityZone: i.AvailabilityZone,
+			ParameterGroup:   i.ParameterGroup.request(),
+
+			MaintenanceWindow: i.MaintenanceWindow,
+		})
+	}
+
+	return instances
+}
@@ -0,0 +1,394 @@
+package request
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
+)
+
+const testSpec = `region: us-west-2
+profile: default
+readyTimeoutMinutes: 5
+retry:
+  timeoutMinutes: 10
+  maxDelaySeconds: 30
+wait:
+  pollIntervalSeconds: 10
+  stableCount: 4
+  statusTimeoutMinutes:
+    modifying: 30
+
+subnetGroup:
+  name: aurora-experiments
+  description: aurora experiments subnet group
+  subnets:
+    - subnet-00000000000000001
+    - subnet-00000000000000002
+
+cluster:
+  id: aurora-experiments
+  engine: aurora-mysql
+  engineVersion: 5.7.12
+  masterUsername: admin
+  securityGroupIds:
+    - sg-00000000000000001
+
+instances:
+  - id: aurora-experiments-0
+    class: db.t2.small
+    promotionTier: 0
+  - id: aurora-experiments-1
+    class: db.t2.small
+    availabilityZone: us-west-2b
+`
+
+// setenv sets the environment variables in vars, unsetting the ones that are
+// empty, and returns a func that restores them.
+func setenv(vars map[string]string) func() {
+	saved := map[string]*string{}
+	for k, v := range vars {
+		if old, ok := os.LookupEnv(k); ok {
+			saved[k] = &old
+		} else {
+			saved[k] = nil
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+
+	return func() {
+		for k, v := range saved {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// writeSpec writes content to a spec file in a new temporary directory and
+// returns its path along with a func that removes the directory.
+func writeSpec(t *testing.T, name, content string) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadSpec(t *testing.T) {
+	want := ClusterRequest{
+		Region:             "us-west-2",
+		Profile:            "default",
+		ReadyTimeout:       5,
+		RetryTimeout:       10,
+		RetryMaxDelay:      30,
+		WaitPollInterval:   10,
+		WaitStableCount:    4,
+		WaitStatusTimeouts: map[string]int{"modifying": 30},
+		GroupName:          "aurora-experiments",
+		GroupDescription:   "aurora experiments subnet group",
+		Subnets:            []string{"subnet-00000000000000001", "subnet-00000000000000002"},
+		ClusterId:          "aurora-experiments",
+		Engine:             "aurora-mysql",
+		EngineVersion:      "5.7.12",
+		MasterUsername:     "admin",
+		SgIds:              []string{"sg-00000000000000001"},
+		Instances: []InstanceRequest{
+			{Identifier: "aurora-experiments-0", Class: "db.t2.small", PromotionTier: aws.Int64(0)},
+			{Identifier: "aurora-experiments-1", Class: "db.t2.small", AvailabilityZone: "us-west-2b"},
+		},
+	}
+
+	json := `{
+  "region": "us-west-2",
+  "profile": "default",
+  "readyTimeoutMinutes": 5,
+  "retry": {"timeoutMinutes": 10, "maxDelaySeconds": 30},
+  "wait": {"pollIntervalSeconds": 10, "stableCount": 4, "statusTimeoutMinutes": {"modifying": 30}},
+  "subnetGroup": {
+    "name": "aurora-experiments",
+    "description": "aurora experiments subnet group",
+    "subnets": ["subnet-00000000000000001", "subnet-00000000000000002"]
+  },
+  "cluster": {
+    "id": "aurora-experiments",
+    "engine": "aurora-mysql",
+    "engineVersion": "5.7.12",
+    "masterUsername": "admin",
+    "securityGroupIds": ["sg-00000000000000001"]
+  },
+  "instances": [
+    {"id": "aurora-experiments-0", "class": "db.t2.small", "promotionTier": 0},
+    {"id": "aurora-experiments-1", "class": "db.t2.small", "availabilityZone": "us-west-2b"}
+  ]
+}`
+	yamlPath, cleanup := writeSpec(t, "cluster.yaml", testSpec)
+	defer cleanup()
+	jsonPath, cleanup := writeSpec(t, "cluster.json", json)
+	defer cleanup()
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		spec, err := LoadSpec(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if got := spec.ClusterRequest(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: request = %+v, want %+v", path, got, want)
+		}
+	}
+}
+
+func TestLoadSpecErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "unknown key",
+			content: "region: us-west-2\ncluster:\n  identifier: aurora-experiments\n",
+			err:     "field identifier not found",
+		},
+		{
+			name:    "wrong type",
+			content: "readyTimeoutMinutes: five\n",
+			err:     "cannot unmarshal",
+		},
+		{
+			name:    "not yaml",
+			content: "region: [us-west-2\n",
+			err:     "yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := writeSpec(t, "cluster.yaml", tt.content)
+			defer cleanup()
+
+			_, err := LoadSpec(path)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want one containing %q", err, tt.err)
+			}
+			if !strings.HasPrefix(err.Error(), path+": ") {
+				t.Errorf("err = %v, want it to start with the path", err)
+			}
+		})
+	}
+
+	if _, err := LoadSpec("does-not-exist.yaml"); err == nil {
+		t.Error("loading a missing spec file succeeded")
+	}
+}
+
+func TestSampleSpec(t *testing.T) {
+	spec, err := LoadSpec("../cluster.yaml.sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := spec.ClusterRequest()
+	applyDefaults(&req)
+	if err := req.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewRequestFromFileEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, req ClusterRequest)
+	}{
+		{
+			name: "spec only",
+			env:  map[string]string{},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.ClusterId != "aurora-experiments" || req.ReadyTimeout != 5 {
+					t.Errorf("cluster id %q and ready timeout %d, want the spec's", req.ClusterId, req.ReadyTimeout)
+				}
+			},
+		},
+		{
+			name: "strings and lists",
+			env: map[string]string{
+				clusterIdVar: "from-env",
+				subnetsVar:   " subnet-0a, ,subnet-0b ",
+			},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.ClusterId != "from-env" {
+					t.Errorf("cluster id = %q, want from-env", req.ClusterId)
+				}
+				if want := []string{"subnet-0a", "subnet-0b"}; !reflect.DeepEqual(req.Subnets, want) {
+					t.Errorf("subnets = %q, want %q", req.Subnets, want)
+				}
+			},
+		},
+		{
+			name: "first instance",
+			env:  map[string]string{instanceClassVar: "db.r5.large"},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.Instances[0].Class != "db.r5.large" || req.Instances[1].Class != "db.t2.small" {
+					t.Errorf("instance classes %q and %q, want only the first from the environment",
+						req.Instances[0].Class, req.Instances[1].Class)
+				}
+			},
+		},
+		{
+			name: "timeouts",
+			env:  map[string]string{readyTimeoutVar: "30", upgradeTimeoutVar: "90", deleteTimeoutVar: "45"},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.ReadyTimeout != 30 {
+					t.Errorf("ready timeout = %d, want 30", req.ReadyTimeout)
+				}
+				if req.UpgradeTimeout != 90 {
+					t.Errorf("upgrade timeout = %d, want 90", req.UpgradeTimeout)
+				}
+				if req.DeleteTimeout != 45 {
+					t.Errorf("delete timeout = %d, want 45", req.DeleteTimeout)
+				}
+			},
+		},
+		{
+			name: "password source",
+			env:  map[string]string{passwordFileVar: "-", passwordGenerateVar: "true"},
+			check: func(t *testing.T, req ClusterRequest) {
+				want := &PasswordSource{Stdin: true, Generate: true, Length: secret.DefaultLength}
+				if !reflect.DeepEqual(req.MasterUserPassSource, want) {
+					t.Errorf("password source = %+v, want %+v", req.MasterUserPassSource, want)
+				}
+			},
+		},
+		{
+			name: "encryption",
+			env:  map[string]string{storageEncryptedVar: "true", kmsKeyIdVar: "alias/experiments"},
+			check: func(t *testing.T, req ClusterRequest) {
+				if !aws.BoolValue(req.StorageEncrypted) || req.KmsKeyId != "alias/experiments" {
+					t.Errorf("encrypted %v with key %q, want alias/experiments", req.StorageEncrypted, req.KmsKeyId)
+				}
+			},
+		},
+		{
+			name: "serverless",
+			env:  map[string]string{minCapacityVar: "2", autoPauseVar: "true"},
+			check: func(t *testing.T, req ClusterRequest) {
+				want := &ServerlessRequest{MinCapacity: 2, AutoPause: true, SecondsUntilAutoPause: defaultAutoPause}
+				if !reflect.DeepEqual(req.Serverless, want) {
+					t.Errorf("serverless = %+v, want %+v", req.Serverless, want)
+				}
+			},
+		},
+		{
+			name: "serverless v2",
+			env:  map[string]string{minV2CapacityVar: "0.5", maxV2CapacityVar: "16"},
+			check: func(t *testing.T, req ClusterRequest) {
+				want := &ServerlessV2Request{MinCapacity: 0.5, MaxCapacity: 16}
+				if !reflect.DeepEqual(req.ServerlessV2, want) || req.Serverless != nil {
+					t.Errorf("serverless v2 = %+v and serverless = %+v, want %+v and nil",
+						req.ServerlessV2, req.Serverless, want)
+				}
+			},
+		},
+		{
+			name: "malformed",
+			env: map[string]string{
+				readyTimeoutVar: "abc", storageEncryptedVar: "yes", maxV2CapacityVar: "lots",
+			},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.ReadyTimeout != 5 || req.StorageEncrypted != nil {
+					t.Errorf("ready timeout %d and encrypted %v, want the spec's", req.ReadyTimeout, req.StorageEncrypted)
+				}
+				err, ok := req.ValidateTarget().(ValidationError)
+				if !ok {
+					t.Fatalf("ValidateTarget() = %v, want a ValidationError", err)
+				}
+				want := []string{
+					`READY_TIMEOUT_MINUTES "abc" must be an integer`,
+					`STORAGE_ENCRYPTED "yes" must be true or false`,
+					`SERVERLESS_V2_MAX_CAPACITY "lots" must be a number`,
+				}
+				for _, w := range want {
+					if !strings.Contains(err.Error(), w) {
+						t.Errorf("ValidateTarget() = %v, want %q", err, w)
+					}
+				}
+			},
+		},
+		{
+			name: "retry defaults",
+			env:  map[string]string{retryMaxDelayVar: "5"},
+			check: func(t *testing.T, req ClusterRequest) {
+				if req.RetryTimeout != defaultRetryTimeout || req.RetryMaxDelay != 5 {
+					t.Errorf("retry timeout %d and max delay %d, want %d and 5",
+						req.RetryTimeout, req.RetryMaxDelay, defaultRetryTimeout)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Variables the test doesn't set must not leak in from the
+			// environment running it.
+			env := map[string]string{
+				clusterIdVar: "", subnetsVar: "", instanceIdVar: "", instanceClassVar: "", readyTimeoutVar: "",
+				retryTimeoutVar: "", retryMaxDelayVar: "", waitPollIntervalVar: "", waitStableCountVar: "",
+				passwordFileVar: "", passwordSecretVar: "", passwordParamVar: "", passwordGenerateVar: "", passwordLengthVar: "",
+				storageEncryptedVar: "", kmsKeyIdVar: "", sourceSnapshotVar: "",
+				backupRetentionVar: "", backupWindowVar: "", maintenanceWinVar: "", upgradeTimeoutVar: "", deleteTimeoutVar: "",
+				minCapacityVar: "", maxCapacityVar: "", autoPauseVar: "", autoPauseSecondsVar: "",
+				minV2CapacityVar: "", maxV2CapacityVar: "",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			defer setenv(env)()
+
+			path, cleanup := writeSpec(t, "cluster.yaml", testSpec)
+			defer cleanup()
+			req, err := NewRequestFromFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+func TestDBParameterGroups(t *testing.T) {
+	shared := &ParameterGroupRequest{Name: "shared", Family: "aurora-mysql5.7", Description: "shared"}
+	req := ClusterRequest{
+		Instances: []InstanceRequest{
+			{Identifier: "experiments-0", ParameterGroup: shared},
+			{Identifier: "experiments-1"},
+			{Identifier: "experiments-2", ParameterGroup: &ParameterGroupRequest{Name: "own"}},
+			{Identifier: "experiments-3", ParameterGroup: shared},
+		},
+	}
+
+	var names []string
+	for _, g := range req.DBParameterGroups() {
+		names = append(names, g.Name)
+	}
+	if want := []string{"shared", "own"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("groups = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,232 @@
+package request
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
+)
+
+var (
+	identifierPattern    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{0,62}$`)
+	groupNamePattern     = regexp.MustCompile(`^[a-zA-Z0-9._ -]{1,255}$`)
+	subnetIdPattern      = regexp.MustCompile(`^subnet-[0-9a-f]+$`)
+	sgIdPattern          = regexp.MustCompile(`^sg-[0-9a-f]+$`)
+	instanceClassPattern = regexp.MustCompile(`^db\.[a-z0-9]+\.[a-z0-9]+$`)
+	paramGroupPattern    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{0,254}$`)
+	// A key id, key ARN, alias name or alias ARN.
+	kmsKeyPattern = regexp.MustCompile(
+		`^([0-9a-f-]{36}|alias/[a-zA-Z0-9/_-]+|arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]{12}:(key/[0-9a-f-]{36}|alias/[a-zA-Z0-9/_-]+))$`,
+	)
+	// A manual or automated (rds:...) cluster snapshot identifier, or the ARN
+	// of a snapshot shared from another account.
+	snapshotPattern = regexp.MustCompile(
+		`^(arn:aws[a-z-]*:rds:[a-z0-9-]+:[0-9]{12}:cluster-snapshot:)?(rds:)?[a-zA-Z][a-zA-Z0-9-]{0,254}$`,
+	)
+)
+
+// ValidationError collects every problem found in a request so they can all
+// be fixed in one pass.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid request: " + strings.Join(e, "; ")
+}
+
+func (e *ValidationError) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*e = append(*e, fmt.Sprintf(format, args...))
+	}
+}
+
+func (e ValidationError) orNil() error {
+	if len(e) > 0 {
+		return e
+	}
+	return nil
+}
+
+// ValidateTarget checks only the fields needed to find the existing
+// resources, which is all that commands like destroy need.
+func (r ClusterRequest) ValidateTarget() error {
+	errs := ValidationError{}
+	r.validateTarget(errs.check)
+	if r.Global != nil {
+		r.validateSecondaries(&errs, ClusterRequest.ValidateTarget)
+	}
+
+	return errs.orNil()
+}
+
+func (r ClusterRequest) validateTarget(check func(bool, string, ...interface{})) {
+	for _, e := range r.envErrors {
+		check(false, "%s", e)
+	}
+	check(r.Region != "", "region is required")
+	check(r.ReadyTimeout > 0, "ready timeout must be positive, got %d", r.ReadyTimeout)
+	check(r.UpgradeTimeout > 0, "upgrade timeout must be positive, got %d", r.UpgradeTimeout)
+	check(r.DeleteTimeout > 0, "delete timeout must be positive, got %d", r.DeleteTimeout)
+	check(r.RetryTimeout > 0, "retry timeout must be positive, got %d", r.RetryTimeout)
+	check(r.RetryMaxDelay > 0, "retry max delay must be positive, got %d", r.RetryMaxDelay)
+	check(r.WaitPollInterval > 0, "wait poll interval must be positive, got %d", r.WaitPollInterval)
+	check(r.WaitStableCount > 0, "wait stable count must be positive, got %d", r.WaitStableCount)
+	for status, minutes := range r.WaitStatusTimeouts {
+		check(minutes > 0, "timeout for status %q must be positive, got %d", status, minutes)
+	}
+	check(groupNamePattern.MatchString(r.GroupName), "invalid subnet group name %q", r.GroupName)
+	check(isIdentifier(r.ClusterId), "invalid cluster id %q", r.ClusterId)
+}
+
+func (r ClusterRequest) Validate() error {
+	errs := ValidationError{}
+	check := errs.check
+
+	r.validateTarget(check)
+
+	check(strings.ToLower(r.GroupName) != "default", "subnet group name must not be \"default\"")
+	check(r.GroupDescription != "", "subnet group description is required")
+	check(len(r.Subnets) >= 2, "at least two subnets are required, got %d", len(r.Subnets))
+	for _, s := range r.Subnets {
+		check(subnetIdPattern.MatchString(s), "invalid subnet id %q", s)
+	}
+
+	check(r.Engine != "", "engine is required")
+	if r.GlobalClusterId == "" {
+		check(r.MasterUsername != "", "master username is required")
+	}
+	if src := r.MasterUserPassSource; src != nil {
+		check(r.MasterUserPass == "", "master password and master password source are mutually exclusive")
+		validatePasswordSource(check, src)
+	}
+	for _, s := range r.SgIds {
+		check(sgIdPattern.MatchString(s), "invalid security group id %q", s)
+	}
+	if r.KmsKeyId != "" {
+		check(kmsKeyPattern.MatchString(r.KmsKeyId), "invalid KMS key %q, expected a key ARN, alias or key id", r.KmsKeyId)
+		check(r.StorageEncrypted != nil && *r.StorageEncrypted, "a KMS key is only used with storage encryption")
+	}
+	if r.SourceSnapshot != "" {
+		check(snapshotPattern.MatchString(r.SourceSnapshot), "invalid source snapshot %q", r.SourceSnapshot)
+	}
+
+	r.validateWindows(check)
+
+	if pg := r.ClusterParameterGroup; pg != nil {
+		validateParameterGroup(check, "cluster parameter group", pg)
+	}
+
+	if r.Serverless != nil {
+		r.validateServerless(check)
+	} else {
+		check(len(r.Instances) > 0, "at least one instance is required")
+	}
+	if r.ServerlessV2 != nil {
+		r.validateServerlessV2(check)
+	}
+	seen := map[string]bool{}
+	groups := map[string]*ParameterGroupRequest{}
+	for _, i := range r.Instances {
+		check(isIdentifier(i.Identifier), "invalid instance id %q", i.Identifier)
+		if i.Class == serverlessV2Class {
+			check(
+				r.ServerlessV2 != nil,
+				"instance %s: %s instances need the serverlessV2 capacity range of the cluster", i.Identifier, i.Class,
+			)
+		} else {
+			check(instanceClassPattern.MatchString(i.Class), "invalid instance class %q for %q", i.Class, i.Identifier)
+		}
+		check(!seen[i.Identifier], "duplicate instance id %q", i.Identifier)
+		if i.PromotionTier != nil {
+			tier := *i.PromotionTier
+			check(tier >= 0 && tier <= 15, "promotion tier for %q must be between 0 and 15, got %d", i.Identifier, tier)
+		}
+		seen[i.Identifier] = true
+
+		if pg := i.ParameterGroup; pg != nil {
+			if other, ok := groups[pg.Name]; ok {
+				// Instances that share a group must agree on what is in it.
+				check(reflect.DeepEqual(pg, other), "parameter group %q is declared differently by %q", pg.Name, i.Identifier)
+				continue
+			}
+			groups[pg.Name] = pg
+			validateParameterGroup(check, "parameter group", pg)
+		}
+	}
+
+	if r.Global != nil {
+		r.validateGlobal(check)
+		r.validateSecondaries(&errs, ClusterRequest.Validate)
+	}
+
+	return errs.orNil()
+}
+
+// ValidateRotation checks what rotate-password needs: the target and a
+// password source that can hold the new password until it is promoted.
+func (r ClusterRequest) ValidateRotation() error {
+	errs := ValidationError{}
+	check := errs.check
+
+	r.validateTarget(check)
+
+	src := r.MasterUserPassSource
+	check(src != nil, "rotating the master password needs a master password source")
+	if src != nil {
+		check(r.MasterUserPass == "", "master password and master password source are mutually exclusive")
+		check(!src.Stdin, "the master password can't be rotated when it is read from stdin")
+		// The rotated secret is written in the RDS layout, username included.
+		check(
+			src.SecretId == "" || r.MasterUsername != "",
+			"master username is required to rotate a password kept in Secrets Manager",
+		)
+		if !src.Stdin {
+			// The new password is always generated, whatever src.Generate says.
+			src := *src
+			src.Generate = true
+			validatePasswordSource(check, &src)
+		}
+	}
+
+	return errs.orNil()
+}
+
+func validatePasswordSource(check func(bool, string, ...interface{}), src *PasswordSource) {
+	backends := 0
+	for _, set := range []bool{src.File != "", src.Stdin, src.SecretId != "", src.Parameter != ""} {
+		if set {
+			backends++
+		}
+	}
+	check(backends == 1, "master password source needs exactly one of file, stdin, secretsManager or ssmParameter")
+	check(!src.Generate || !src.Stdin, "a generated master password can't be stored to stdin")
+	if src.Generate {
+		check(
+			src.Length >= secret.MinLength && src.Length <= secret.MaxLength,
+			"master password length must be between %d and %d, got %d", secret.MinLength, secret.MaxLength, src.Length,
+		)
+	}
+}
+
+func validateParameterGroup(check func(bool, string, ...interface{}), what string, pg *ParameterGroupRequest) {
+	check(
+		paramGroupPattern.MatchString(pg.Name) && !strings.HasSuffix(pg.Name, "-") && !strings.Contains(pg.Name, "--"),
+		"invalid %s name %q", what, pg.Name,
+	)
+	check(pg.Family != "", "%s %q needs a family", what, pg.Name)
+	check(pg.Description != "", "%s %q needs a description", what, pg.Name)
+	for name := range pg.Parameters {
+		check(name != "", "%s %q has a parameter without a name", what, pg.Name)
+	}
+}
+
+// isIdentifier reports whether v follows the RDS naming rules shared by
+// clusters and instances.
+func isIdentifier(v string) bool {
+	if !identifierPattern.MatchString(v) {
+		return false
+	}
+
+	return !strings.HasSuffix(v, "-") && !strings.Contains(v, "--")
+}
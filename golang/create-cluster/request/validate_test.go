@@ -0,0 +1,339 @@
+package request
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *ClusterRequest)
+		errs   []string
+	}{
+		{
+			name:   "valid",
+			change: func(*ClusterRequest) {},
+		},
+		{
+			name: "cluster",
+			change: func(r *ClusterRequest) {
+				r.ClusterId = "aurora--experiments"
+				r.Engine = ""
+				r.SgIds = []string{"sg-xyz"}
+			},
+			errs: []string{
+				`invalid cluster id "aurora--experiments"`,
+				"engine is required",
+				`invalid security group id "sg-xyz"`,
+			},
+		},
+		{
+			name: "subnet group",
+			change: func(r *ClusterRequest) {
+				r.GroupName = "Default"
+				r.Subnets = []string{"subnet-0a"}
+			},
+			errs: []string{
+				`subnet group name must not be "default"`,
+				"at least two subnets are required, got 1",
+			},
+		},
+		{
+			name: "instances",
+			change: func(r *ClusterRequest) {
+				r.Instances = []InstanceRequest{
+					{Identifier: "aurora-experiments-0", Class: "db.t2.small"},
+					{Identifier: "aurora-experiments-0", Class: "t2.small", PromotionTier: aws.Int64(16)},
+				}
+			},
+			errs: []string{
+				`invalid instance class "t2.small" for "aurora-experiments-0"`,
+				`duplicate instance id "aurora-experiments-0"`,
+				`promotion tier for "aurora-experiments-0" must be between 0 and 15, got 16`,
+			},
+		},
+		{
+			name: "no instances",
+			change: func(r *ClusterRequest) {
+				r.Instances = nil
+				r.ReadyTimeout = 0
+			},
+			errs: []string{"ready timeout must be positive, got 0", "at least one instance is required"},
+		},
+		{
+			name: "upgrade timeout",
+			change: func(r *ClusterRequest) {
+				r.UpgradeTimeout = -1
+			},
+			errs: []string{"upgrade timeout must be positive, got -1"},
+		},
+		{
+			name: "delete timeout",
+			change: func(r *ClusterRequest) {
+				r.DeleteTimeout = -1
+			},
+			errs: []string{"delete timeout must be positive, got -1"},
+		},
+		{
+			name: "parameter group",
+			change: func(r *ClusterRequest) {
+				r.ClusterParameterGroup = &ParameterGroupRequest{
+					Name:       "experiments-",
+					Parameters: map[string]string{"": "1"},
+				}
+			},
+			errs: []string{
+				`invalid cluster parameter group name "experiments-"`,
+				`cluster parameter group "experiments-" needs a family`,
+				`cluster parameter group "experiments-" needs a description`,
+				`cluster parameter group "experiments-" has a parameter without a name`,
+			},
+		},
+		{
+			name: "instance parameter groups",
+			change: func(r *ClusterRequest) {
+				group := ParameterGroupRequest{Name: "experiments", Family: "aurora-mysql5.7", Description: "experiments"}
+				other := group
+				other.Parameters = map[string]string{"slow_query_log": "1"}
+				r.Instances[0].ParameterGroup = &group
+				r.Instances[1].ParameterGroup = &other
+			},
+			errs: []string{`parameter group "experiments" is declared differently by "aurora-experiments-1"`},
+		},
+		{
+			name: "password source",
+			change: func(r *ClusterRequest) {
+				r.MasterUserPass = "secret123"
+				r.MasterUserPassSource = &PasswordSource{Stdin: true, File: "password", Generate: true, Length: 42}
+			},
+			errs: []string{
+				"master password and master password source are mutually exclusive",
+				"master password source needs exactly one of file, stdin, secretsManager or ssmParameter",
+				"a generated master password can't be stored to stdin",
+				"master password length must be between 8 and 41, got 42",
+			},
+		},
+		{
+			name: "kms key",
+			change: func(r *ClusterRequest) {
+				r.StorageEncrypted = aws.Bool(false)
+				r.KmsKeyId = "arn:aws:kms:us-west-2:123456789012:key/experiments"
+			},
+			errs: []string{
+				`invalid KMS key "arn:aws:kms:us-west-2:123456789012:key/experiments", expected a key ARN, alias or key id`,
+				"a KMS key is only used with storage encryption",
+			},
+		},
+		{
+			name: "source snapshot",
+			change: func(r *ClusterRequest) {
+				r.SourceSnapshot = "arn:aws:rds:us-west-2:123456789012:snapshot:experiments-1"
+			},
+			errs: []string{`invalid source snapshot "arn:aws:rds:us-west-2:123456789012:snapshot:experiments-1"`},
+		},
+		{
+			name: "windows",
+			change: func(r *ClusterRequest) {
+				r.BackupRetentionPeriod = 36
+				r.BackupWindow = "07:00-07:30"
+				r.MaintenanceWindow = "wed:07:15-wed:07:45"
+				r.Instances[0].MaintenanceWindow = "wed:08:00-wed:08:30"
+			},
+			errs: []string{
+				"backup retention period must be between 1 and 35 days, got 36",
+				`cluster: maintenance window "wed:07:15-wed:07:45" overlaps the backup window "07:00-07:30"`,
+			},
+		},
+		{
+			name: "serverless",
+			change: func(r *ClusterRequest) {
+				r.Serverless = &ServerlessRequest{MinCapacity: 3, MaxCapacity: 2, SecondsUntilAutoPause: 600}
+				r.StorageEncrypted = aws.Bool(false)
+			},
+			errs: []string{
+				"invalid minimum capacity 3, expected one of [1 2 4 8 16 32 64 128 256]",
+				"minimum capacity 3 is above maximum capacity 2",
+				"seconds until auto pause is only used with auto pause",
+				"serverless clusters are always encrypted, storage encryption can't be turned off",
+				"serverless clusters have no instances, got 2",
+			},
+		},
+		{
+			name: "serverless v2",
+			change: func(r *ClusterRequest) {
+				r.ServerlessV2 = &ServerlessV2Request{MinCapacity: 4, MaxCapacity: 2.25}
+				r.Instances[0].Class = "db.serverless"
+			},
+			errs: []string{
+				"invalid Serverless v2 capacity 2.25, expected 0.5 to 256 in steps of 0.5",
+				"minimum Serverless v2 capacity 4 is above maximum capacity 2.25",
+			},
+		},
+		{
+			name: "serverless v2 instance",
+			change: func(r *ClusterRequest) {
+				r.Instances[0].Class = "db.serverless"
+			},
+			errs: []string{
+				"instance aurora-experiments-0: db.serverless instances need the serverlessV2 capacity range of the cluster",
+			},
+		},
+		{
+			name: "global",
+			change: func(r *ClusterRequest) {
+				r.Global = &GlobalRequest{
+					Identifier: "aurora-experiments-global",
+					Secondaries: []SecondaryRequest{{
+						Region:           "us-west-2",
+						GroupName:        "aurora-experiments",
+						GroupDescription: "aurora experiments subnet group",
+						Subnets:          []string{"subnet-00000000000000011"},
+						ClusterId:        "aurora-experiments-east",
+						Instances:        []InstanceRequest{{Identifier: "aurora-experiments-east-0", Class: "db.r4.large"}},
+					}},
+				}
+			},
+			errs: []string{
+				"region us-west-2 has more than one cluster of the global database",
+				"secondary cluster in us-west-2: at least two subnets are required, got 1",
+			},
+		},
+		{
+			name: "global id",
+			change: func(r *ClusterRequest) {
+				r.Global = &GlobalRequest{Identifier: "-global"}
+			},
+			errs: []string{
+				`invalid global cluster id "-global"`,
+				"a global database needs at least one secondary cluster",
+			},
+		},
+		{
+			name: "retry",
+			change: func(r *ClusterRequest) {
+				r.RetryTimeout = -1
+				r.RetryMaxDelay = 0
+			},
+			errs: []string{"retry timeout must be positive, got -1", "retry max delay must be positive, got 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := writeSpec(t, "cluster.yaml", testSpec)
+			defer cleanup()
+			spec, err := LoadSpec(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := spec.ClusterRequest()
+			applyDefaults(&req)
+			tt.change(&req)
+
+			err = req.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationError)
+			if !ok {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual([]string(errs), tt.errs) {
+				t.Errorf("errors = %q, want %q", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	req := ClusterRequest{Region: "us-west-2", GroupName: "experiments", ClusterId: "experiments"}
+	applyDefaults(&req)
+	if err := req.ValidateTarget(); err != nil {
+		t.Errorf("a request with only the target fields: %v", err)
+	}
+
+	req.ClusterId = "-experiments"
+	req.Region = ""
+	want := ValidationError{"region is required", `invalid cluster id "-experiments"`}
+	if err := req.ValidateTarget(); !reflect.DeepEqual(err, want) {
+		t.Errorf("err = %v, want %v", err, want)
+	}
+}
+
+func TestValidateRotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *ClusterRequest)
+		errs   []string
+	}{
+		{
+			name:   "valid",
+			change: func(*ClusterRequest) {},
+		},
+		{
+			name:   "no source",
+			change: func(r *ClusterRequest) { r.MasterUserPassSource = nil },
+			errs:   []string{"rotating the master password needs a master password source"},
+		},
+		{
+			name: "stdin",
+			change: func(r *ClusterRequest) {
+				r.MasterUserPass = "secret123"
+				r.MasterUserPassSource = &PasswordSource{Stdin: true, Length: 20}
+			},
+			errs: []string{
+				"master password and master password source are mutually exclusive",
+				"the master password can't be rotated when it is read from stdin",
+			},
+		},
+		{
+			name:   "secret without username",
+			change: func(r *ClusterRequest) { r.MasterUsername = "" },
+			errs:   []string{"master username is required to rotate a password kept in Secrets Manager"},
+		},
+		{
+			name: "parameter without username",
+			change: func(r *ClusterRequest) {
+				r.MasterUsername = ""
+				r.MasterUserPassSource = &PasswordSource{Parameter: "/experiments/master", Length: 20}
+			},
+		},
+		{
+			name:   "length",
+			change: func(r *ClusterRequest) { r.MasterUserPassSource.Length = 7 },
+			errs:   []string{"master password length must be between 8 and 41, got 7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ClusterRequest{
+				Region:         "us-west-2",
+				GroupName:      "experiments",
+				ClusterId:      "experiments",
+				MasterUsername: "admin",
+				// Generate is left unset; rotation always generates.
+				MasterUserPassSource: &PasswordSource{SecretId: "experiments/master"},
+			}
+			applyDefaults(&req)
+			tt.change(&req)
+
+			err := req.ValidateRotation()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if errs, ok := err.(ValidationError); !ok || !reflect.DeepEqual([]string(errs), tt.errs) {
+				t.Errorf("err = %q, want %q", err, tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package request
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+
+	// RDS rejects backup and maintenance windows shorter than this.
+	minWindowMinutes = 30
+
+	minBackupRetention = 1
+	maxBackupRetention = 35
+)
+
+var (
+	backupWindowPattern      = regexp.MustCompile(`^(\d{2}):(\d{2})-(\d{2}):(\d{2})$`)
+	maintenanceWindowPattern = regexp.MustCompile(`^([a-z]{3}):(\d{2}):(\d{2})-([a-z]{3}):(\d{2}):(\d{2})$`)
+
+	weekdays = map[string]int{"mon": 0, "tue": 1, "wed": 2, "thu": 3, "fri": 4, "sat": 5, "sun": 6}
+)
+
+// window is a span of minutes from the start of the week, or of the day for
+// a backup window. end is past start even when the window wraps around.
+type window struct {
+	start, end int
+}
+
+func parseBackupWindow(s string) (window, error) {
+	m := backupWindowPattern.FindStringSubmatch(s)
+	if m == nil {
+		return window{}, fmt.Errorf("invalid backup window %q, expected hh24:mi-hh24:mi", s)
+	}
+
+	start, ok1 := minuteOfDay(m[1], m[2])
+	end, ok2 := minuteOfDay(m[3], m[4])
+	if !ok1 || !ok2 {
+		return window{}, fmt.Errorf("invalid time in backup window %q", s)
+	}
+
+	return newWindow(start, end, minutesPerDay, s)
+}
+
+func parseMaintenanceWindow(s string) (window, error) {
+	m := maintenanceWindowPattern.FindStringSubmatch(s)
+	if m == nil {
+		return window{}, fmt.Errorf("invalid maintenance window %q, expected ddd:hh24:mi-ddd:hh24:mi", s)
+	}
+
+	startDay, ok1 := weekdays[m[1]]
+	endDay, ok2 := weekdays[m[4]]
+	if !ok1 || !ok2 {
+		return window{}, fmt.Errorf("invalid day in maintenance window %q", s)
+	}
+	start, ok1 := minuteOfDay(m[2], m[3])
+	end, ok2 := minuteOfDay(m[5], m[6])
+	if !ok1 || !ok2 {
+		return window{}, fmt.Errorf("invalid time in maintenance window %q", s)
+	}
+
+	return newWindow(startDay*minutesPerDay+start, endDay*minutesPerDay+end, minutesPerWeek, s)
+}
+
+func newWindow(start, end, period int, s string) (window, error) {
+	if end <= start {
+		end += period
+	}
+	if end-start < minWindowMinutes {
+		return window{}, fmt.Errorf("window %q is shorter than %d minutes", s, minWindowMinutes)
+	}
+
+	return window{start: start, end: end}, nil
+}
+
+func minuteOfDay(hour, minute string) (int, bool) {
+	h, _ := strconv.Atoi(hour)
+	m, _ := strconv.Atoi(minute)
+	if h > 23 || m > 59 {
+		return 0, false
+	}
+
+	return h*60 + m, true
+}
+
+// overlapsDaily reports whether the maintenance window w overlaps the daily
+// backup window on any day of the week.
+func (w window) overlapsDaily(backup window) bool {
+	// Start a day early so a backup window running past midnight into the
+	// first day is covered, and look at the next week too for a maintenance
+	// window that wraps around.
+	for day := -1; day <= 7; day++ {
+		b := window{start: backup.start + day*minutesPerDay, end: backup.end + day*minutesPerDay}
+		for _, shift := range []int{0, minutesPerWeek} {
+			if b.start+shift < w.end && w.start < b.end+shift {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateWindows checks the backup settings of the cluster and the
+// maintenance windows of the cluster and its instances, none of which may
+// overlap the backup window.
+func (r ClusterRequest) validateWindows(check func(bool, string, ...interface{})) {
+	if r.BackupRetentionPeriod != 0 {
+		check(
+			r.BackupRetentionPeriod >= minBackupRetention && r.BackupRetentionPeriod <= maxBackupRetention,
+			"backup retention period must be between %d and %d days, got %d",
+			minBackupRetention, maxBackupRetention, r.BackupRetentionPeriod,
+		)
+	}
+
+	var backup *window
+	if r.BackupWindow != "" {
+		w, err := parseBackupWindow(r.BackupWindow)
+		check(err == nil, "%v", err)
+		if err == nil {
+			backup = &w
+		}
+	}
+
+	maintenance := func(owner, s string) {
+		if s == "" {
+			return
+		}
+		w, err := parseMaintenanceWindow(s)
+		check(err == nil, "%s: %v", owner, err)
+		if err == nil && backup != nil {
+			check(
+				!w.overlapsDaily(*backup),
+				"%s: maintenance window %q overlaps the backup window %q", owner, s, r.BackupWindow,
+			)
+		}
+	}
+
+	maintenance("cluster", r.MaintenanceWindow)
+	for _, i := range r.Instances {
+		maintenance(fmt.Sprintf("instance %q", i.Identifier), i.MaintenanceWindow)
+	}
+}
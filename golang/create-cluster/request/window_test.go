@@ -0,0 +1,100 @@
+package request
+
+import "testing"
+
+func TestParseBackupWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   window
+		err    bool
+	}{
+		{window: "07:00-07:30", want: window{start: 420, end: 450}},
+		{window: "23:45-00:15", want: window{start: 1425, end: 1455}},
+		{window: "07:00-07:29", err: true},
+		{window: "24:00-00:30", err: true},
+		{window: "07:60-08:30", err: true},
+		{window: "7:00-7:30", err: true},
+		{window: "mon:07:00-mon:07:30", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBackupWindow(tt.window)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseBackupWindow(%q) = %v, want an error", tt.window, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBackupWindow(%q): %v", tt.window, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBackupWindow(%q) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		want   window
+		err    bool
+	}{
+		{window: "mon:07:00-mon:07:30", want: window{start: 420, end: 450}},
+		{window: "sun:23:30-mon:00:30", want: window{start: 10050, end: 10110}},
+		{window: "tue:22:00-wed:02:00", want: window{start: 2760, end: 3000}},
+		{window: "mon:07:00-mon:07:15", err: true},
+		{window: "mun:07:00-mon:07:30", err: true},
+		{window: "mon:25:00-tue:01:00", err: true},
+		{window: "07:00-07:30", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMaintenanceWindow(tt.window)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseMaintenanceWindow(%q) = %v, want an error", tt.window, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMaintenanceWindow(%q): %v", tt.window, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMaintenanceWindow(%q) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestOverlapsDaily(t *testing.T) {
+	tests := []struct {
+		maintenance string
+		backup      string
+		want        bool
+	}{
+		{maintenance: "wed:07:00-wed:07:30", backup: "07:15-07:45", want: true},
+		{maintenance: "wed:07:00-wed:07:30", backup: "07:30-08:00", want: false},
+		{maintenance: "wed:06:00-wed:07:00", backup: "05:30-06:00", want: false},
+		// The backup window runs past midnight into the maintenance window.
+		{maintenance: "mon:00:00-mon:00:30", backup: "23:45-00:15", want: true},
+		// The maintenance window wraps around the end of the week.
+		{maintenance: "sun:23:30-mon:00:30", backup: "00:00-00:30", want: true},
+		{maintenance: "sun:23:30-mon:00:30", backup: "01:00-01:30", want: false},
+	}
+
+	for _, tt := range tests {
+		maintenance, err := parseMaintenanceWindow(tt.maintenance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backup, err := parseBackupWindow(tt.backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := maintenance.overlapsDaily(backup); got != tt.want {
+			t.Errorf("%s overlaps %s = %t, want %t", tt.maintenance, tt.backup, got, tt.want)
+		}
+	}
+}
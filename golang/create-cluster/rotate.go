@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+// verifyAttempts is how many times the login check runs before giving up. A
+// new password can take a moment to work on every instance.
+const verifyAttempts = 3
+
+func runRotatePassword(args []string) {
+	flags := flag.NewFlagSet("rotate-password", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	verifyCommand := flags.String(
+		"verify-command", "",
+		"shell command that logs in with the new password, given DB_HOST, DB_PORT, DB_USER and DB_PASSWORD",
+	)
+	skipVerify := flags.Bool("skip-verify", false, "promote the new password without checking that it works")
+	flags.Parse(args)
+
+	if (*verifyCommand == "") == !*skipVerify {
+		log.Fatal("exactly one of -verify-command or -skip-verify is required")
+	}
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateRotation)
+	if err != nil {
+		fatal(err)
+	}
+
+	passwordStore := newPasswordStore(req)
+	store, ok := passwordStore.(secret.StagedStore)
+	if !ok {
+		log.Fatalf("%s can't hold a pending password", passwordStore)
+	}
+
+	password, err := pendingPassword(store, req.MasterUserPassSource.Length)
+	if err != nil {
+		fatal(err)
+	}
+
+	svc := newRDS(req)
+	cluster, err := service.ResetMasterPassword(svc, req, newWaiter(req), password)
+	if err != nil {
+		fatal(err)
+	}
+
+	if !*skipVerify {
+		delay := time.Duration(req.WaitPollInterval) * time.Second
+		err = verifyLogin(*verifyCommand, cluster, password, delay)
+		if err != nil {
+			fatal(fmt.Errorf("%s; the new password stays pending in %s and is reused by the next rotate-password", err, store))
+		}
+	}
+
+	err = store.Promote()
+	if err != nil {
+		fatal(err)
+	}
+	log.Infof("promoted the new master password in %s", store)
+
+	log.Info("success")
+}
+
+// pendingPassword returns the password left pending by a rotation that did
+// not finish, since the cluster may already be using it, or generates and
+// stores a new one.
+func pendingPassword(store secret.StagedStore, length int) (string, error) {
+	password, err := store.GetPending()
+	if err == nil {
+		log.Infof("resuming the rotation pending in %s", store)
+		return password, nil
+	}
+	if err != secret.ErrNotStored {
+		return "", err
+	}
+
+	password, err = secret.Generate(length)
+	if err != nil {
+		return "", err
+	}
+
+	err = store.PutPending(password)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("stored a new pending master password in %s", store)
+
+	return password, nil
+}
+
+// verifyLogin runs command with the cluster endpoint and the new credentials
+// in its environment until it exits successfully or verifyAttempts is
+// reached.
+func verifyLogin(command string, cluster *rds.DBCluster, password string, delay time.Duration) error {
+	env := append(
+		os.Environ(),
+		"DB_HOST="+aws.StringValue(cluster.Endpoint),
+		fmt.Sprintf("DB_PORT=%d", aws.Int64Value(cluster.Port)),
+		"DB_USER="+aws.StringValue(cluster.MasterUsername),
+		"DB_PASSWORD="+password,
+		// Read by the mysql and psql clients, so they need no password flag.
+		"MYSQL_PWD="+password,
+		"PGPASSWORD="+password,
+	)
+
+	var err error
+	for n := 1; n <= verifyAttempts; n++ {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err == nil {
+			log.Info("logged in with the new master password")
+			return nil
+		}
+		log.Warnf("login attempt %d of %d failed: %s", n, verifyAttempts, err)
+		if n < verifyAttempts {
+			time.Sleep(delay)
+		}
+	}
+
+	return fmt.Errorf("login with the new master password failed: %s", err)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/secret"
+)
+
+func TestPendingPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := secret.FileStore{Path: filepath.Join(dir, "password")}
+	if err := store.Put("old-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := pendingPassword(store, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) != 20 || password == "old-secret" {
+		t.Errorf("generated password = %q, want 20 new characters", password)
+	}
+	if got, err := store.GetPending(); err != nil || got != password {
+		t.Errorf("pending password = %q, %v, want %q", got, err, password)
+	}
+
+	// A rotation that stopped before Promote is resumed with the same
+	// password, since the cluster may already use it.
+	resumed, err := pendingPassword(store, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed != password {
+		t.Errorf("resumed password = %q, want %q", resumed, password)
+	}
+}
+
+func TestVerifyLogin(t *testing.T) {
+	cluster := &rds.DBCluster{
+		Endpoint:       aws.String("experiments.cluster-fake.us-west-2.rds.amazonaws.com"),
+		Port:           aws.Int64(3306),
+		MasterUsername: aws.String("admin"),
+	}
+	check := `test "$DB_HOST:$DB_PORT" = experiments.cluster-fake.us-west-2.rds.amazonaws.com:3306 ` +
+		`&& test "$DB_USER" = admin && test "$DB_PASSWORD" = secret456 && test "$MYSQL_PWD" = secret456`
+
+	if err := verifyLogin(check, cluster, "secret456", 0); err != nil {
+		t.Errorf("login with the right credentials: %v", err)
+	}
+	if err := verifyLogin(check, cluster, "secret123", 0); err == nil {
+		t.Error("no error logging in with the wrong password")
+	}
+}
@@ -0,0 +1,269 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// Version stages Secrets Manager rotation uses.
+const (
+	stageCurrent = "AWSCURRENT"
+	stagePending = "AWSPENDING"
+)
+
+// credentials is the JSON layout RDS and the Secrets Manager rotation
+// functions use for database secrets.
+type credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+}
+
+// SecretsManagerStore keeps the password in a Secrets Manager secret, which
+// is created on the first Put. The secret string is either the bare password
+// or a JSON object with a "password" key; Put always writes JSON that
+// includes Username.
+type SecretsManagerStore struct {
+	Svc      secretsmanageriface.SecretsManagerAPI
+	SecretId string
+	Username string
+}
+
+func (s SecretsManagerStore) Get() (string, error) {
+	output, err := s.Svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.SecretId),
+	})
+	if isCode(err, secretsmanager.ErrCodeResourceNotFoundException) {
+		return "", ErrNotStored
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", s, err)
+	}
+
+	return parseSecretString(aws.StringValue(output.SecretString)), nil
+}
+
+func (s SecretsManagerStore) Put(password string) error {
+	value, err := s.secretString(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Svc.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(s.SecretId),
+		SecretString: aws.String(value),
+	})
+	if isCode(err, secretsmanager.ErrCodeResourceNotFoundException) {
+		_, err = s.Svc.CreateSecret(&secretsmanager.CreateSecretInput{
+			Name:         aws.String(s.SecretId),
+			Description:  aws.String("master password for " + s.Username),
+			SecretString: aws.String(value),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	return nil
+}
+
+// GetPending returns the version labelled AWSPENDING.
+func (s SecretsManagerStore) GetPending() (string, error) {
+	output, err := s.getStage(stagePending)
+	if err != nil {
+		return "", err
+	}
+
+	return parseSecretString(aws.StringValue(output.SecretString)), nil
+}
+
+// PutPending adds a version labelled AWSPENDING only. The secret has to
+// exist already.
+func (s SecretsManagerStore) PutPending(password string) error {
+	value, err := s.secretString(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Svc.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(s.SecretId),
+		SecretString:  aws.String(value),
+		VersionStages: aws.StringSlice([]string{stagePending}),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	return nil
+}
+
+// Promote moves AWSCURRENT to the pending version, which Secrets Manager
+// follows by labelling the old version AWSPREVIOUS, and then drops
+// AWSPENDING so no rotation looks to be in progress.
+func (s SecretsManagerStore) Promote() error {
+	pending, err := s.getStage(stagePending)
+	if err != nil {
+		return err
+	}
+	current, err := s.getStage(stageCurrent)
+	if err != nil && err != ErrNotStored {
+		return err
+	}
+
+	input := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:        aws.String(s.SecretId),
+		VersionStage:    aws.String(stageCurrent),
+		MoveToVersionId: pending.VersionId,
+	}
+	if current != nil {
+		input.RemoveFromVersionId = current.VersionId
+	}
+	_, err = s.Svc.UpdateSecretVersionStage(input)
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	_, err = s.Svc.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(s.SecretId),
+		VersionStage:        aws.String(stagePending),
+		RemoveFromVersionId: pending.VersionId,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	return nil
+}
+
+// getStage returns the version labelled stage, or ErrNotStored when the
+// secret or the label doesn't exist.
+func (s SecretsManagerStore) getStage(stage string) (*secretsmanager.GetSecretValueOutput, error) {
+	output, err := s.Svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(s.SecretId),
+		VersionStage: aws.String(stage),
+	})
+	if isCode(err, secretsmanager.ErrCodeResourceNotFoundException) {
+		return nil, ErrNotStored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", s, err)
+	}
+
+	return output, nil
+}
+
+func (s SecretsManagerStore) String() string {
+	return "secret " + s.SecretId
+}
+
+func (s SecretsManagerStore) secretString(password string) (string, error) {
+	b, err := json.Marshal(credentials{Username: s.Username, Password: password})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// parseSecretString returns the password in a JSON secret, or the whole
+// string when it isn't one.
+func parseSecretString(value string) string {
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return value
+	}
+
+	c := credentials{}
+	if err := json.Unmarshal([]byte(value), &c); err != nil || c.Password == "" {
+		return value
+	}
+
+	return c.Password
+}
+
+// SSMStore keeps the password in an SSM Parameter Store SecureString
+// parameter.
+type SSMStore struct {
+	Svc  ssmiface.SSMAPI
+	Name string
+}
+
+func (s SSMStore) Get() (string, error) {
+	output, err := s.Svc.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(s.Name),
+		WithDecryption: aws.Bool(true),
+	})
+	if isCode(err, ssm.ErrCodeParameterNotFound) {
+		return "", ErrNotStored
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", s, err)
+	}
+
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+func (s SSMStore) Put(password string) error {
+	_, err := s.Svc.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(s.Name),
+		Value:     aws.String(password),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	return nil
+}
+
+func (s SSMStore) String() string {
+	return "SSM parameter " + s.Name
+}
+
+// GetParameter always returns the latest version, so the pending password is
+// kept in a second parameter rather than as a new version of Name.
+func (s SSMStore) pending() SSMStore {
+	return SSMStore{Svc: s.Svc, Name: s.Name + "-pending"}
+}
+
+func (s SSMStore) GetPending() (string, error) {
+	return s.pending().Get()
+}
+
+func (s SSMStore) PutPending(password string) error {
+	return s.pending().Put(password)
+}
+
+// Promote copies the pending value into Name and deletes the pending
+// parameter.
+func (s SSMStore) Promote() error {
+	pending := s.pending()
+	password, err := pending.Get()
+	if err != nil {
+		return err
+	}
+
+	err = s.Put(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Svc.DeleteParameter(&ssm.DeleteParameterInput{Name: aws.String(pending.Name)})
+	if err != nil {
+		return fmt.Errorf("%s: %s", pending, err)
+	}
+
+	return nil
+}
+
+func isCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
@@ -0,0 +1,97 @@
+package secret
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// FileStore keeps the password in a local file, readable only by its owner
+// when it is written here. A trailing newline is ignored.
+type FileStore struct {
+	Path string
+}
+
+func (s FileStore) Get() (string, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return "", ErrNotStored
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func (s FileStore) Put(password string) error {
+	return ioutil.WriteFile(s.Path, []byte(password+"\n"), 0600)
+}
+
+func (s FileStore) String() string {
+	return "file " + s.Path
+}
+
+// The pending password is kept in a second file next to Path.
+func (s FileStore) pending() FileStore {
+	return FileStore{Path: s.Path + ".pending"}
+}
+
+func (s FileStore) GetPending() (string, error) {
+	return s.pending().Get()
+}
+
+func (s FileStore) PutPending(password string) error {
+	return s.pending().Put(password)
+}
+
+// Promote replaces the file with the pending one in a single rename.
+func (s FileStore) Promote() error {
+	return os.Rename(s.pending().Path, s.Path)
+}
+
+// ReaderStore reads the password from the first line of Reader, typically
+// stdin. Nothing can be stored back.
+type ReaderStore struct {
+	Reader io.Reader
+	Name   string
+}
+
+// Get reads a byte at a time so nothing after the first line is consumed;
+// later prompts may read the same stdin.
+func (s ReaderStore) Get() (string, error) {
+	line := make([]byte, 0)
+	b := make([]byte, 1)
+	for {
+		n, err := s.Reader.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	password := strings.TrimRight(string(line), "\r")
+	if password == "" {
+		return "", ErrNotStored
+	}
+
+	return password, nil
+}
+
+func (s ReaderStore) Put(string) error {
+	return errors.New("a password can't be stored to " + s.Name)
+}
+
+func (s ReaderStore) String() string {
+	return s.Name
+}
@@ -0,0 +1,123 @@
+// Package secret reads the master password from somewhere other than a
+// plaintext environment variable and can generate one on first use.
+package secret
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+const (
+	DefaultLength = 32
+	MinLength     = 8
+	// MaxLength is the longest master password every Aurora engine accepts.
+	MaxLength = 41
+)
+
+// ErrNotStored is returned by Store.Get when the store exists but holds no
+// value yet.
+var ErrNotStored = errors.New("no password stored")
+
+// Store holds a single password.
+type Store interface {
+	// Get returns the current password or ErrNotStored.
+	Get() (string, error)
+	// Put stores password as the current value.
+	Put(password string) error
+	// String names the store in logs without revealing the value.
+	String() string
+}
+
+// StagedStore can hold a new password next to the current one, so readers
+// keep getting the old password until the new one is known to work.
+type StagedStore interface {
+	Store
+	// GetPending returns the pending password or ErrNotStored.
+	GetPending() (string, error)
+	// PutPending stores password as the pending value.
+	PutPending(password string) error
+	// Promote makes the pending password the current one.
+	Promote() error
+}
+
+// Source resolves the master password from Store, generating a new one of
+// Length characters when Generate is set and nothing is stored.
+type Source struct {
+	Store    Store
+	Generate bool
+	Length   int
+}
+
+// Password returns the stored password and whether it had to be generated.
+// A generated password isn't stored; the caller puts it into Store once the
+// cluster has it.
+func (s Source) Password() (string, bool, error) {
+	password, err := s.Store.Get()
+	if err != ErrNotStored || !s.Generate {
+		return password, false, err
+	}
+
+	password, err = Generate(s.Length)
+	if err != nil {
+		return "", false, err
+	}
+
+	return password, true, nil
+}
+
+// Characters RDS accepts in a master password: printable ASCII except '/',
+// '"', '@' and space. Generated passwords also skip quotes and backslashes
+// so they survive a shell.
+const (
+	lowers  = "abcdefghijklmnopqrstuvwxyz"
+	uppers  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits  = "0123456789"
+	symbols = "!#$%&()*+,-.:;<=>?[]^_{|}~"
+)
+
+// Generate returns a random password of length characters with at least one
+// lower case letter, upper case letter, digit and symbol.
+func Generate(length int) (string, error) {
+	if length < MinLength || length > MaxLength {
+		return "", errors.New("password length must be between 8 and 41")
+	}
+
+	all := lowers + uppers + digits + symbols
+	password := make([]byte, length)
+	for n, set := range []string{lowers, uppers, digits, symbols} {
+		c, err := pick(set)
+		if err != nil {
+			return "", err
+		}
+		password[n] = c
+	}
+	for n := 4; n < length; n++ {
+		c, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password[n] = c
+	}
+
+	// Shuffle so the required classes aren't always at the front.
+	for n := length - 1; n > 0; n-- {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(n+1)))
+		if err != nil {
+			return "", err
+		}
+		j := int(i.Int64())
+		password[n], password[j] = password[j], password[n]
+	}
+
+	return string(password), nil
+}
+
+func pick(set string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+
+	return set[i.Int64()], nil
+}
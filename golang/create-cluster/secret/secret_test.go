@@ -0,0 +1,237 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakesecrets"
+)
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := []Store{
+		FileStore{Path: filepath.Join(dir, "password")},
+		SecretsManagerStore{Svc: fakesecrets.NewSecretsManager(), SecretId: "experiments/master", Username: "admin"},
+		SSMStore{Svc: fakesecrets.NewSSM(), Name: "/experiments/master"},
+	}
+
+	for _, store := range stores {
+		t.Run(store.String(), func(t *testing.T) {
+			if _, err := store.Get(); err != ErrNotStored {
+				t.Fatalf("err = %v from an empty store, want ErrNotStored", err)
+			}
+
+			for _, password := range []string{"first-secret", "second-secret"} {
+				if err := store.Put(password); err != nil {
+					t.Fatal(err)
+				}
+				got, err := store.Get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != password {
+					t.Errorf("password = %q, want %q", got, password)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := FileStore{Path: filepath.Join(dir, "password")}
+	if err := store.Put("secret123"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("mode = %o, want 600", mode)
+	}
+}
+
+func TestSecretsManagerStoreFormats(t *testing.T) {
+	svc := fakesecrets.NewSecretsManager()
+	store := SecretsManagerStore{Svc: svc, SecretId: "experiments/master", Username: "admin"}
+	if err := store.Put("secret123"); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := svc.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String("experiments/master")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"username":"admin","password":"secret123"}`; aws.StringValue(output.SecretString) != want {
+		t.Errorf("secret string = %s, want %s", aws.StringValue(output.SecretString), want)
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain-secret", want: "plain-secret"},
+		{value: `{"username":"admin","password":"json-secret"}`, want: "json-secret"},
+		// JSON without a password is taken as the password itself.
+		{value: `{"username":"admin"}`, want: `{"username":"admin"}`},
+	}
+	for _, tt := range tests {
+		if got := parseSecretString(tt.value); got != tt.want {
+			t.Errorf("parseSecretString(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestReaderStore(t *testing.T) {
+	reader := strings.NewReader("secret123\r\nyes\n")
+	store := ReaderStore{Reader: reader, Name: "stdin"}
+
+	got, err := store.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "secret123" {
+		t.Errorf("password = %q, want secret123", got)
+	}
+	// Only the first line is consumed.
+	if rest, _ := ioutil.ReadAll(reader); string(rest) != "yes\n" {
+		t.Errorf("left in the reader: %q, want the second line", rest)
+	}
+
+	if _, err := (ReaderStore{Reader: strings.NewReader("\n"), Name: "stdin"}).Get(); err != ErrNotStored {
+		t.Errorf("err = %v for an empty line, want ErrNotStored", err)
+	}
+	if err := store.Put("secret123"); err == nil {
+		t.Error("storing to a reader succeeded")
+	}
+}
+
+func TestSourcePassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    string
+		generate  bool
+		generated bool
+		err       error
+	}{
+		{name: "stored", stored: "secret123", generate: true},
+		{name: "missing", err: ErrNotStored},
+		{name: "generate", generate: true, generated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := SSMStore{Svc: fakesecrets.NewSSM(), Name: "/experiments/master"}
+			if tt.stored != "" {
+				if err := store.Put(tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			source := Source{Store: store, Generate: tt.generate, Length: DefaultLength}
+			password, generated, err := source.Password()
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if generated != tt.generated {
+				t.Errorf("generated = %t, want %t", generated, tt.generated)
+			}
+			if tt.stored != "" && password != tt.stored {
+				t.Errorf("password = %q, want the stored %q", password, tt.stored)
+			}
+
+			// The caller stores a generated password once the cluster has it.
+			if stored, err := store.Get(); tt.generated && err != ErrNotStored {
+				t.Errorf("the generated password was stored: %q, %v", stored, err)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, length := range []int{MinLength, DefaultLength, MaxLength} {
+		password, err := Generate(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != length {
+			t.Errorf("length = %d, want %d", len(password), length)
+		}
+		for _, set := range []string{lowers, uppers, digits, symbols} {
+			if !strings.ContainsAny(password, set) {
+				t.Errorf("password %q has none of %q", password, set)
+			}
+		}
+		if strings.ContainsAny(password, `/"@ '\`) {
+			t.Errorf("password %q has a character RDS or a shell rejects", password)
+		}
+	}
+
+	for _, length := range []int{MinLength - 1, MaxLength + 1} {
+		if _, err := Generate(length); err == nil {
+			t.Errorf("generating %d characters succeeded", length)
+		}
+	}
+}
+
+func TestStagedStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stores := []StagedStore{
+		FileStore{Path: filepath.Join(dir, "password")},
+		SecretsManagerStore{Svc: fakesecrets.NewSecretsManager(), SecretId: "experiments/master", Username: "admin"},
+		SSMStore{Svc: fakesecrets.NewSSM(), Name: "/experiments/master"},
+	}
+
+	for _, store := range stores {
+		t.Run(store.String(), func(t *testing.T) {
+			if err := store.Put("old-secret"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetPending(); err != ErrNotStored {
+				t.Fatalf("err = %v before anything is pending, want ErrNotStored", err)
+			}
+
+			if err := store.PutPending("new-secret"); err != nil {
+				t.Fatal(err)
+			}
+			// Readers keep the old password until it is promoted.
+			if got, err := store.Get(); err != nil || got != "old-secret" {
+				t.Errorf("Get() = %q, %v while pending, want old-secret", got, err)
+			}
+			if got, err := store.GetPending(); err != nil || got != "new-secret" {
+				t.Errorf("GetPending() = %q, %v, want new-secret", got, err)
+			}
+
+			if err := store.Promote(); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := store.Get(); err != nil || got != "new-secret" {
+				t.Errorf("Get() = %q, %v after Promote, want new-secret", got, err)
+			}
+			if _, err := store.GetPending(); err != ErrNotStored {
+				t.Errorf("err = %v after Promote, want ErrNotStored", err)
+			}
+		})
+	}
+}
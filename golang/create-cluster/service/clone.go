@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// CloneSource is the cluster a clone is made from. A nil RestoreTime clones
+// the latest restorable state. RestoreType is factory.RestoreTypeCopyOnWrite
+// or factory.RestoreTypeFullCopy.
+type CloneSource struct {
+	ClusterId   string
+	RestoreTime *time.Time
+	RestoreType string
+}
+
+// CloneCluster restores source to the cluster in req and creates the
+// instances in req in it. It returns the clone and its instances once they
+// are all available. The subnet group and any parameter groups in req must
+// exist already, and the clone keeps the master credentials of the source.
+func CloneCluster(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, source CloneSource,
+) (*rds.DBCluster, []*rds.DBInstance, error) {
+	err := checkClone(svc, req, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restoredFrom := "the latest restorable time"
+	if source.RestoreTime != nil {
+		restoredFrom = source.RestoreTime.UTC().Format(time.RFC3339)
+	}
+	log.Infof("cloning cluster %s at %s to %s (%s)", source.ClusterId, restoredFrom, req.ClusterId, source.RestoreType)
+
+	cluster, err := newClusterFactory(req).RestoreDBClusterToPointInTime(
+		svc, source.ClusterId, source.RestoreTime, source.RestoreType,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Info(cluster)
+
+	ctx, cancel := readyContext(req)
+	cluster, err = waiter.WaitForClusterAvailable(ctx, svc, req.ClusterId)
+	if err == nil {
+		cluster, err = finishRestore(ctx, svc, req, waiter, cluster)
+	}
+	cancel()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creates := make([]ResourcePlan, 0)
+	for _, i := range req.Instances {
+		creates = append(creates, ResourcePlan{Type: resourceInstance, Identifier: i.Identifier, Action: ActionCreate})
+	}
+	err = applyInstances(svc, req, waiter, creates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	instances := make([]*rds.DBInstance, 0)
+	for _, i := range req.Instances {
+		instance, err := factory.FindDBInstance(svc, i.Identifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return cluster, instances, nil
+}
+
+// checkClone makes sure the source exists, can be restored to the requested
+// time and runs the engine and engine mode of req. It also checks that RDS
+// offers the instances of req for the engine version of the source, and
+// that the clone doesn't exist yet.
+func checkClone(svc rdsiface.RDSAPI, req request.ClusterRequest, source CloneSource) error {
+	cluster, err := factory.FindDBCluster(svc, source.ClusterId)
+	if err != nil {
+		return err
+	}
+
+	if engine := aws.StringValue(cluster.Engine); engine != req.Engine {
+		return fmt.Errorf("source cluster %s runs %s, not %s", source.ClusterId, engine, req.Engine)
+	}
+	mode := engineMode(req)
+	if sourceMode := factory.EngineMode(cluster); sourceMode != mode {
+		return fmt.Errorf("source cluster %s is %s, and a clone can't be %s", source.ClusterId, sourceMode, mode)
+	}
+	problems := request.ValidationError{}
+	err = checkInstanceOptions(svc, req.Engine, aws.StringValue(cluster.EngineVersion), req.Instances, &problems)
+	if err = problemsOrErr(problems, err); err != nil {
+		return err
+	}
+	if t := source.RestoreTime; t != nil {
+		earliest, latest := cluster.EarliestRestorableTime, cluster.LatestRestorableTime
+		if (earliest != nil && t.Before(*earliest)) || (latest != nil && t.After(*latest)) {
+			return fmt.Errorf(
+				"cluster %s can only be restored to between %s and %s",
+				source.ClusterId, formatTime(earliest), formatTime(latest),
+			)
+		}
+	}
+
+	_, err = factory.FindDBCluster(svc, req.ClusterId)
+	if err == nil {
+		return fmt.Errorf("cluster %s already exists", req.ClusterId)
+	}
+	if !factory.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestCloneCluster(t *testing.T) {
+	svc := fakerds.New()
+	apply(t, svc, testRequest())
+
+	req := testRequest()
+	req.ClusterId = "clone"
+	req.Instances = []request.InstanceRequest{{Identifier: "clone-0", Class: "db.t2.small"}}
+	// A restore can't set these, so they are applied to the clone after it.
+	req.BackupRetentionPeriod = 7
+	req.BackupWindow = "07:00-07:30"
+	req.MaintenanceWindow = "wed:08:00-wed:08:30"
+	source := CloneSource{ClusterId: "experiments", RestoreType: factory.RestoreTypeCopyOnWrite}
+
+	cluster, instances, err := CloneCluster(svc, req, testWaiter(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(cluster.Status); got != fakerds.StatusAvailable {
+		t.Errorf("clone status = %s, want %s", got, fakerds.StatusAvailable)
+	}
+	if got := aws.Int64Value(cluster.BackupRetentionPeriod); got != 7 {
+		t.Errorf("backup retention period = %d, want 7", got)
+	}
+	if got := aws.StringValue(cluster.PreferredBackupWindow); got != req.BackupWindow {
+		t.Errorf("backup window = %s, want %s", got, req.BackupWindow)
+	}
+	if got := aws.StringValue(cluster.PreferredMaintenanceWindow); got != req.MaintenanceWindow {
+		t.Errorf("maintenance window = %s, want %s", got, req.MaintenanceWindow)
+	}
+	if len(instances) != 1 || aws.StringValue(instances[0].DBClusterIdentifier) != "clone" {
+		t.Errorf("instances = %v, want clone-0 in the clone", instances)
+	}
+}
+
+func TestCheckClone(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		change func(req *request.ClusterRequest, source *CloneSource)
+		err    string
+	}{
+		{
+			name:   "missing source",
+			change: func(req *request.ClusterRequest, source *CloneSource) { source.ClusterId = "missing" },
+			err:    "missing",
+		},
+		{
+			name:   "engine",
+			change: func(req *request.ClusterRequest, source *CloneSource) { req.Engine = "aurora-postgresql" },
+			err:    "source cluster experiments runs aurora-mysql, not aurora-postgresql",
+		},
+		{
+			name:   "instance class",
+			change: func(req *request.ClusterRequest, source *CloneSource) { req.Instances[0].Class = "db.t3.small" },
+			err:    "RDS doesn't offer db.t3.small for aurora-mysql 5.7.12",
+		},
+		{
+			name:   "restore time",
+			change: func(req *request.ClusterRequest, source *CloneSource) { source.RestoreTime = &future },
+			err:    "cluster experiments can only be restored to between",
+		},
+		{
+			name:   "clone exists",
+			change: func(req *request.ClusterRequest, source *CloneSource) { req.ClusterId = "experiments" },
+			err:    "cluster experiments already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			apply(t, svc, testRequest())
+
+			req := testRequest()
+			req.ClusterId = "clone"
+			source := CloneSource{ClusterId: "experiments", RestoreType: factory.RestoreTypeFullCopy}
+			tt.change(&req, &source)
+
+			err := checkClone(svc, req, source)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
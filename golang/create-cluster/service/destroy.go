@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+// DestroyPlan lists the resources that exist and will be deleted. Empty
+// names mean the resource is already gone. GlobalCluster and Secondaries are
+// only set for the primary cluster of a global database.
+type DestroyPlan struct {
+	SubnetGroup           string
+	ClusterParameterGroup string
+	ParameterGroups       []string
+	Cluster               string
+	Instances             []string
+	GlobalCluster         string
+	Secondaries           []SecondaryDestroyPlan
+
+	// globalMember is the global cluster that the cluster with clusterArn
+	// is detached from before it is deleted.
+	clusterArn   string
+	globalMember string
+}
+
+// BuildDestroyPlan finds every member of the requested cluster, including
+// instances that are not in the request.
+func BuildDestroyPlan(svc rdsiface.RDSAPI, req request.ClusterRequest) (*DestroyPlan, error) {
+	plan := &DestroyPlan{}
+
+	cluster, err := newClusterFactory(req).FindDBCluster(svc)
+	if err != nil && !factory.IsNotFound(err) {
+		return nil, err
+	}
+	if cluster != nil {
+		plan.Cluster = aws.StringValue(cluster.DBClusterIdentifier)
+		plan.clusterArn = aws.StringValue(cluster.DBClusterArn)
+		for _, m := range cluster.DBClusterMembers {
+			plan.Instances = append(plan.Instances, aws.StringValue(m.DBInstanceIdentifier))
+		}
+	}
+
+	subnetGroup, err := factory.FindDBSubnetGroup(svc, req.GroupName)
+	if err != nil && !factory.IsNotFound(err) {
+		return nil, err
+	}
+	if subnetGroup != nil {
+		plan.SubnetGroup = aws.StringValue(subnetGroup.DBSubnetGroupName)
+	}
+
+	if req.ClusterParameterGroup != nil {
+		group, err := newClusterParameterGroupFactory(req).FindDBClusterParameterGroup(svc)
+		if err != nil && !factory.IsNotFound(err) {
+			return nil, err
+		}
+		if group != nil {
+			plan.ClusterParameterGroup = aws.StringValue(group.DBClusterParameterGroupName)
+		}
+	}
+
+	for _, pg := range req.DBParameterGroups() {
+		group, err := newParameterGroupFactory(pg).FindDBParameterGroup(svc)
+		if err != nil && !factory.IsNotFound(err) {
+			return nil, err
+		}
+		if group != nil {
+			plan.ParameterGroups = append(plan.ParameterGroups, aws.StringValue(group.DBParameterGroupName))
+		}
+	}
+
+	return plan, nil
+}
+
+func (p *DestroyPlan) Empty() bool {
+	for _, s := range p.Secondaries {
+		if !s.Plan.Empty() {
+			return false
+		}
+	}
+
+	return p.SubnetGroup == "" && p.ClusterParameterGroup == "" && len(p.ParameterGroups) == 0 &&
+		p.Cluster == "" && len(p.Instances) == 0 && p.GlobalCluster == ""
+}
+
+// Print lists the resources in the order they are deleted, starting with
+// the secondary clusters of a global database.
+func (p *DestroyPlan) Print(w io.Writer) {
+	count := 0
+	for _, s := range p.Secondaries {
+		fmt.Fprintf(w, "  secondary cluster in %s:\n", s.Region)
+		count += s.Plan.print(w)
+		fmt.Fprintln(w)
+	}
+	if len(p.Secondaries) > 0 {
+		fmt.Fprintln(w, "  primary cluster:")
+	}
+	count += p.print(w)
+
+	fmt.Fprintf(w, "\nPlan: %d to destroy.\n", count)
+}
+
+// print lists the resources of a single cluster and returns how many there
+// are.
+func (p *DestroyPlan) print(w io.Writer) int {
+	if p.globalMember != "" {
+		fmt.Fprintf(w, "  ~ %s.%s (detach from %s.%s)\n", resourceCluster, p.Cluster, resourceGlobalCluster, p.globalMember)
+	}
+	if p.GlobalCluster != "" {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceGlobalCluster, p.GlobalCluster)
+	}
+	for _, i := range p.Instances {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceInstance, i)
+	}
+	if p.Cluster != "" {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceCluster, p.Cluster)
+	}
+	if p.SubnetGroup != "" {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceSubnetGroup, p.SubnetGroup)
+	}
+	if p.ClusterParameterGroup != "" {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceClusterParameterGroup, p.ClusterParameterGroup)
+	}
+	for _, g := range p.ParameterGroups {
+		fmt.Fprintf(w, "  - %s.%s (delete)\n", resourceParameterGroup, g)
+	}
+
+	count := len(p.Instances) + len(p.ParameterGroups)
+	for _, name := range []string{p.Cluster, p.SubnetGroup, p.ClusterParameterGroup, p.GlobalCluster} {
+		if name != "" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ApplyDestroyPlan deletes the instances, then the cluster and then the
+// subnet and parameter groups, waiting for each step to finish since RDS
+// refuses to delete a resource that is still in use. An empty
+// finalSnapshotIdentifier skips the final cluster snapshot. A member of a
+// global cluster is detached from it first, and the global cluster of the
+// plan is deleted once it is detached.
+func ApplyDestroyPlan(
+	svc rdsiface.RDSAPI, plan *DestroyPlan, waiter *factory.Waiter, finalSnapshotIdentifier string, dTimeout int,
+) error {
+	err := plan.detach(svc, waiter, dTimeout)
+	if err != nil {
+		return err
+	}
+	err = plan.deleteGlobalCluster(svc, waiter, dTimeout)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range plan.Instances {
+		err := factory.DeleteDBInstance(svc, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, i := range plan.Instances {
+		err := waitWithTimeout(dTimeout, func(ctx context.Context) error {
+			return waiter.WaitForInstanceDeleted(ctx, svc, i)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.Cluster != "" {
+		err := factory.DeleteDBCluster(svc, plan.Cluster, finalSnapshotIdentifier)
+		if err != nil {
+			return err
+		}
+
+		err = waitWithTimeout(dTimeout, func(ctx context.Context) error {
+			return waiter.WaitForClusterDeleted(ctx, svc, plan.Cluster)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.SubnetGroup != "" {
+		err := factory.DeleteDBSubnetGroup(svc, plan.SubnetGroup)
+		if err != nil {
+			return err
+		}
+	}
+
+	if plan.ClusterParameterGroup != "" {
+		err := factory.DeleteDBClusterParameterGroup(svc, plan.ClusterParameterGroup)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, g := range plan.ParameterGroups {
+		err := factory.DeleteDBParameterGroup(svc, g)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func waitWithTimeout(rTimeout int, wait func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rTimeout)*time.Minute)
+	defer cancel()
+
+	return wait(ctx)
+}
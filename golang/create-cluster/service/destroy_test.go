@@ -0,0 +1,35 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDestroyPlan(t *testing.T) {
+	plan := &DestroyPlan{}
+	if !plan.Empty() {
+		t.Error("plan without resources is not empty")
+	}
+
+	plan = &DestroyPlan{
+		SubnetGroup: "experiments",
+		Cluster:     "experiments",
+		Instances:   []string{"experiments-0", "experiments-1"},
+	}
+	if plan.Empty() {
+		t.Error("plan with resources is empty")
+	}
+
+	var b bytes.Buffer
+	plan.Print(&b)
+	want := `  - db_instance.experiments-0 (delete)
+  - db_instance.experiments-1 (delete)
+  - db_cluster.experiments (delete)
+  - db_subnet_group.experiments (delete)
+
+Plan: 4 to destroy.
+`
+	if b.String() != want {
+		t.Errorf("printed plan:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
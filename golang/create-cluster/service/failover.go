@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// Failover is the outcome of FailoverCluster. Unavailable runs from the
+// failover request until the new writer was first seen available, so it is
+// an upper bound on the time without a writer, as precise as the poll
+// interval of the waiter.
+type Failover struct {
+	Cluster        string
+	PreviousWriter string
+	Writer         string
+	Unavailable    time.Duration
+}
+
+// FailoverCluster makes target the writer of the cluster in req, or the
+// reader RDS picks when target is empty, and waits until the writer has
+// moved and every member is available again.
+func FailoverCluster(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, target string,
+) (*Failover, error) {
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFailover(cluster, target)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := factory.ClusterWriter(cluster)
+	if target == "" {
+		log.Infof("failing over cluster %s from writer %s", req.ClusterId, previous)
+	} else {
+		log.Infof("failing over cluster %s from writer %s to %s", req.ClusterId, previous, target)
+	}
+
+	start := time.Now()
+	_, err = factory.FailoverDBCluster(svc, req.ClusterId, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// The writer is back as soon as it is seen, so this wait doesn't look
+	// for a stable status.
+	first := *waiter
+	first.StableCount = 1
+	err = waitWithTimeout(req.ReadyTimeout, func(ctx context.Context) error {
+		cluster, err = first.WaitForClusterWriter(ctx, svc, req.ClusterId, previous, target)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	unavailable := time.Since(start)
+
+	// The previous writer comes back as a reader and may still be
+	// restarting.
+	err = waitWithTimeout(req.ReadyTimeout, func(ctx context.Context) error {
+		return waitForMembers(ctx, svc, waiter, cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Failover{
+		Cluster:        req.ClusterId,
+		PreviousWriter: previous,
+		Writer:         factory.ClusterWriter(cluster),
+		Unavailable:    unavailable,
+	}, nil
+}
+
+// checkFailover makes sure cluster has a reader to fail over to and that
+// target, when set, is one of its readers.
+func checkFailover(cluster *rds.DBCluster, target string) error {
+	id := aws.StringValue(cluster.DBClusterIdentifier)
+	if factory.EngineMode(cluster) == factory.EngineModeServerless {
+		return fmt.Errorf("cluster %s is serverless and has no instances to fail over to", id)
+	}
+
+	readers, member := 0, false
+	for _, m := range cluster.DBClusterMembers {
+		instance := aws.StringValue(m.DBInstanceIdentifier)
+		if instance == target {
+			member = true
+		}
+		if !aws.BoolValue(m.IsClusterWriter) {
+			readers++
+		} else if instance == target {
+			return fmt.Errorf("instance %s is already the writer of cluster %s", target, id)
+		}
+	}
+
+	if readers == 0 {
+		return fmt.Errorf("cluster %s has no reader to fail over to", id)
+	}
+	if target != "" && !member {
+		return fmt.Errorf("instance %s is not a member of cluster %s", target, id)
+	}
+
+	return nil
+}
+
+// Switchover is the outcome of SwitchoverGlobalCluster. Clusters are given
+// by ARN, which names their region.
+type Switchover struct {
+	GlobalCluster   string
+	PreviousPrimary string
+	Primary         string
+	Elapsed         time.Duration
+}
+
+// SwitchoverGlobalCluster makes the cluster of the global database of req
+// that is in region, which may be the primary region of req after an earlier
+// switchover, its primary cluster. It waits until the global cluster reports
+// the new primary cluster and that cluster is available. allowDataLoss fails
+// over without waiting for the cluster to catch up, for when the current
+// primary cluster is unavailable.
+func SwitchoverGlobalCluster(
+	svc rdsiface.RDSAPI, regions Regions, req request.ClusterRequest, waiter *factory.Waiter,
+	region string, allowDataLoss bool,
+) (*Switchover, error) {
+	if req.Global == nil {
+		return nil, fmt.Errorf("cluster %s is not part of a global database", req.ClusterId)
+	}
+	id := req.Global.Identifier
+
+	targetId := ""
+	for _, r := range append([]request.ClusterRequest{req}, req.SecondaryRequests()...) {
+		if r.Region == region {
+			targetId = r.ClusterId
+		}
+	}
+	if targetId == "" {
+		return nil, fmt.Errorf("global cluster %s has no cluster in %s", id, region)
+	}
+
+	global, err := factory.FindGlobalCluster(svc, id)
+	if err != nil {
+		return nil, err
+	}
+	regional := regions(region)
+	target, err := factory.FindDBCluster(regional, targetId)
+	if err != nil {
+		return nil, err
+	}
+	targetArn := aws.StringValue(target.DBClusterArn)
+	member := factory.GlobalClusterMember(global, targetArn)
+	if member == nil {
+		return nil, fmt.Errorf("cluster %s in %s is not part of global cluster %s", targetId, region, id)
+	}
+	if aws.BoolValue(member.IsWriter) {
+		return nil, fmt.Errorf("cluster %s in %s is already the primary cluster of %s", targetId, region, id)
+	}
+
+	previous := factory.GlobalClusterWriter(global)
+	start := time.Now()
+	if allowDataLoss {
+		log.Infof("failing over global cluster %s from %s to %s, allowing data loss", id, previous, targetArn)
+		_, err = factory.FailoverGlobalCluster(svc, id, targetArn)
+	} else {
+		log.Infof("switching over global cluster %s from %s to %s", id, previous, targetArn)
+		_, err = factory.SwitchoverGlobalCluster(svc, id, targetArn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = waitWithTimeout(req.ReadyTimeout, func(ctx context.Context) error {
+		_, err := waiter.WaitForGlobalClusterWriter(ctx, svc, id, targetArn)
+		if err != nil {
+			return err
+		}
+		_, err = waiter.WaitForClusterAvailable(ctx, regional, targetId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Switchover{
+		GlobalCluster:   id,
+		PreviousPrimary: previous,
+		Primary:         targetArn,
+		Elapsed:         time.Since(start),
+	}, nil
+}
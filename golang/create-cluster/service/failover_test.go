@@ -0,0 +1,190 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestFailoverCluster(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	apply(t, svc, req)
+
+	failover, err := FailoverCluster(svc, req, testWaiter(), "experiments-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failover.PreviousWriter != "experiments-0" || failover.Writer != "experiments-1" {
+		t.Errorf("writer moved from %s to %s, want experiments-0 to experiments-1",
+			failover.PreviousWriter, failover.Writer)
+	}
+
+	// RDS picks the only reader.
+	failover, err = FailoverCluster(svc, req, testWaiter(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failover.Writer != "experiments-0" {
+		t.Errorf("writer = %s, want experiments-0", failover.Writer)
+	}
+}
+
+func TestCheckFailover(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*request.ClusterRequest)
+		target string
+		err    string
+	}{
+		{
+			name:   "writer",
+			target: "experiments-0",
+			err:    "instance experiments-0 is already the writer of cluster experiments",
+		},
+		{
+			name:   "not a member",
+			target: "experiments-2",
+			err:    "instance experiments-2 is not a member of cluster experiments",
+		},
+		{
+			name: "no reader",
+			change: func(r *request.ClusterRequest) {
+				r.Instances = r.Instances[:1]
+			},
+			err: "cluster experiments has no reader to fail over to",
+		},
+		{
+			name: "serverless",
+			change: func(r *request.ClusterRequest) {
+				r.Engine = "aurora"
+				r.EngineVersion = "5.6.10a"
+				r.StorageEncrypted = aws.Bool(true)
+				r.Serverless = &request.ServerlessRequest{MinCapacity: 2, MaxCapacity: 8}
+				r.Instances = nil
+			},
+			err: "cluster experiments is serverless and has no instances to fail over to",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			req := testRequest()
+			if tt.change != nil {
+				tt.change(&req)
+			}
+			apply(t, svc, req)
+
+			cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkFailover(cluster, tt.target)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("err = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSwitchoverGlobalCluster(t *testing.T) {
+	for _, allowDataLoss := range []bool{false, true} {
+		svc, regions := testRegions()
+		req := testGlobalRequest()
+		mustApply(t, svc, regions, req)
+
+		switchover, err := SwitchoverGlobalCluster(svc, regions, req, testWaiter(), testSecondaryRegion, allowDataLoss)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secondary, err := factory.FindDBCluster(regions(testSecondaryRegion), "experiments-east")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if arn := aws.StringValue(secondary.DBClusterArn); switchover.Primary != arn {
+			t.Errorf("allow data loss %v: primary = %s, want %s", allowDataLoss, switchover.Primary, arn)
+		}
+		global, err := factory.FindGlobalCluster(svc, req.Global.Identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if writer := factory.GlobalClusterWriter(global); writer != switchover.Primary {
+			t.Errorf("allow data loss %v: global cluster writer = %s, want %s", allowDataLoss, writer, switchover.Primary)
+		}
+	}
+}
+
+// TestApplyAfterSwitchover applies a spec whose primary region became a
+// secondary one with a switchover, which is refused until it is switched
+// back.
+func TestApplyAfterSwitchover(t *testing.T) {
+	svc, regions := testRegions()
+	req := testGlobalRequest()
+	mustApply(t, svc, regions, req)
+
+	if _, err := SwitchoverGlobalCluster(svc, regions, req, testWaiter(), testSecondaryRegion, false); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = PlanGlobal(svc, regions, req, plan)
+	want := "the primary cluster is in us-east-1, probably after a switchover; run switchover -to us-west-2"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("err = %v, want one containing %q", err, want)
+	}
+
+	if _, err := SwitchoverGlobalCluster(svc, regions, req, testWaiter(), testPrimaryRegion, false); err != nil {
+		t.Fatal(err)
+	}
+	mustApply(t, svc, regions, req)
+}
+
+func TestCheckSwitchover(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*request.ClusterRequest)
+		region string
+		err    string
+	}{
+		{
+			name:   "primary",
+			region: testPrimaryRegion,
+			err:    "cluster experiments in us-west-2 is already the primary cluster of experiments-global",
+		},
+		{
+			name:   "no cluster",
+			region: "eu-west-1",
+			err:    "global cluster experiments-global has no cluster in eu-west-1",
+		},
+		{
+			name:   "not global",
+			change: func(r *request.ClusterRequest) { r.Global = nil },
+			region: testSecondaryRegion,
+			err:    "cluster experiments is not part of a global database",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, regions := testRegions()
+			req := testGlobalRequest()
+			mustApply(t, svc, regions, req)
+			if tt.change != nil {
+				tt.change(&req)
+			}
+
+			_, err := SwitchoverGlobalCluster(svc, regions, req, testWaiter(), tt.region, false)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
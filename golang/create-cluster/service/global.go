@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+const resourceGlobalCluster = "global_cluster"
+
+// Regions returns the client for a region, for the secondary clusters of a
+// global database.
+type Regions func(region string) rdsiface.RDSAPI
+
+// SecondaryPlan is the plan of a secondary cluster of a global database,
+// built against its own region.
+type SecondaryPlan struct {
+	Region string `json:"region"`
+	Plan   *Plan  `json:"plan"`
+}
+
+// PlanGlobal adds the global cluster of req and the plans of its secondary
+// clusters to plan, which BuildPlan built for the primary cluster. Clusters
+// that are already part of the global database can't be replaced or
+// upgraded on their own, so plans that would do either are rejected.
+func PlanGlobal(svc rdsiface.RDSAPI, regions Regions, req request.ClusterRequest, plan *Plan) error {
+	id := req.Global.Identifier
+
+	global, err := factory.FindGlobalCluster(svc, id)
+	if err != nil && !factory.IsNotFound(err) {
+		return err
+	}
+
+	if global != nil {
+		cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+		if factory.IsNotFound(err) {
+			return fmt.Errorf("global cluster %s already exists without cluster %s in %s", id, req.ClusterId, req.Region)
+		}
+		if err != nil {
+			return err
+		}
+		err = checkGlobalMember(id, global, cluster, req.Region, true)
+		if err != nil {
+			return err
+		}
+		err = checkGlobalChanges(plan.Cluster, id)
+		if err != nil {
+			return err
+		}
+	} else if len(plan.Cluster.Drift) > 0 {
+		return fmt.Errorf(
+			"cluster %s differs in %s and has to be replaced with -replace before it can become the primary cluster of %s",
+			req.ClusterId, driftFields(plan.Cluster.Drift), id,
+		)
+	}
+
+	p := newResourcePlan(resourceGlobalCluster, id, global != nil, factory.DiffGlobalCluster(global, id, req.ClusterId))
+	plan.GlobalCluster = &p
+
+	for _, s := range req.SecondaryRequests() {
+		regional := regions(s.Region)
+
+		secondary, err := BuildPlan(regional, s)
+		if err != nil {
+			return err
+		}
+		if secondary.Cluster.Action != ActionCreate {
+			cluster, err := factory.FindDBCluster(regional, s.ClusterId)
+			if err != nil {
+				return err
+			}
+			err = checkGlobalMember(id, global, cluster, s.Region, false)
+			if err != nil {
+				return err
+			}
+			err = checkGlobalChanges(secondary.Cluster, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		plan.Secondaries = append(plan.Secondaries, SecondaryPlan{Region: s.Region, Plan: secondary})
+	}
+
+	return nil
+}
+
+// checkGlobalMember makes sure cluster, which is in region, is a member of
+// the global cluster id: its writer when primary is set and a reader
+// otherwise. global is nil when the global cluster doesn't exist.
+func checkGlobalMember(id string, global *rds.GlobalCluster, cluster *rds.DBCluster, region string, primary bool) error {
+	clusterId := aws.StringValue(cluster.DBClusterIdentifier)
+
+	var member *rds.GlobalClusterMember
+	if global != nil {
+		member = factory.GlobalClusterMember(global, aws.StringValue(cluster.DBClusterArn))
+	}
+
+	switch {
+	case member == nil:
+		return fmt.Errorf("cluster %s in %s already exists outside global cluster %s", clusterId, region, id)
+	case primary && !aws.BoolValue(member.IsWriter):
+		// The spec still names the region it was written for as the primary
+		// one, so after a switchover it has to be switched back first.
+		if writer := factory.ArnRegion(factory.GlobalClusterWriter(global)); writer != "" {
+			return fmt.Errorf(
+				"cluster %s in %s is a secondary cluster of %s, not its primary cluster: the primary cluster is in %s, "+
+					"probably after a switchover; run switchover -to %s before applying the spec",
+				clusterId, region, id, writer, region,
+			)
+		}
+		return fmt.Errorf("cluster %s in %s is a secondary cluster of %s, not its primary cluster", clusterId, region, id)
+	case !primary && aws.BoolValue(member.IsWriter):
+		return fmt.Errorf("cluster %s in %s is the primary cluster of %s, not a secondary cluster", clusterId, region, id)
+	}
+
+	return nil
+}
+
+// checkGlobalChanges rejects the changes in p that a member of the global
+// cluster id can't go through.
+func checkGlobalChanges(p ResourcePlan, id string) error {
+	if p.Action == ActionReplace {
+		return fmt.Errorf("cluster %s is part of global cluster %s and can't be replaced", p.Identifier, id)
+	}
+	if p.Upgrade != nil {
+		return fmt.Errorf(
+			"cluster %s is part of global cluster %s and can't be upgraded from %s to %s on its own",
+			p.Identifier, id, p.Upgrade.From, p.Upgrade.To,
+		)
+	}
+
+	return nil
+}
+
+// ApplyGlobal applies what PlanGlobal added to plan, once ApplyPlan has
+// applied the primary cluster: the global cluster is created from the
+// primary cluster, and then each secondary cluster is applied in its own
+// region, one region at a time.
+func ApplyGlobal(
+	svc rdsiface.RDSAPI, regions Regions, req request.ClusterRequest, plan *Plan, waiter *factory.Waiter,
+) error {
+	secondaries := req.SecondaryRequests()
+	if plan.GlobalCluster == nil || len(plan.Secondaries) != len(secondaries) {
+		return errors.New("plan does not match request")
+	}
+
+	id := req.Global.Identifier
+	if plan.GlobalCluster.Action == ActionCreate {
+		cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+		if err != nil {
+			return err
+		}
+
+		global, err := factory.CreateGlobalCluster(svc, id, cluster)
+		if err != nil {
+			return err
+		}
+		log.Info(global)
+
+		err = waitWithTimeout(req.ReadyTimeout, func(ctx context.Context) error {
+			_, err := waiter.WaitForGlobalClusterAvailable(ctx, svc, id)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for n, s := range secondaries {
+		p := plan.Secondaries[n]
+		if p.Region != s.Region || p.Plan == nil {
+			return errors.New("plan does not match request")
+		}
+
+		log.Infof("applying secondary cluster %s in %s", s.ClusterId, s.Region)
+		err := ApplyPlan(regions(s.Region), s, p.Plan, waiter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SecondaryDestroyPlan is the destroy plan of a secondary cluster of a
+// global database, in its own region.
+type SecondaryDestroyPlan struct {
+	Region string
+	Plan   *DestroyPlan
+}
+
+// BuildGlobalDestroyPlan adds the global cluster of req and its secondary
+// clusters to plan, which BuildDestroyPlan built for the primary cluster.
+// Every cluster that is still a member of the global cluster is detached
+// from it before it is deleted.
+func BuildGlobalDestroyPlan(svc rdsiface.RDSAPI, regions Regions, req request.ClusterRequest, plan *DestroyPlan) error {
+	id := req.Global.Identifier
+
+	global, err := factory.FindGlobalCluster(svc, id)
+	if err != nil && !factory.IsNotFound(err) {
+		return err
+	}
+	if global != nil {
+		plan.GlobalCluster = id
+		plan.detachFrom(global)
+	}
+
+	for _, s := range req.SecondaryRequests() {
+		secondary, err := BuildDestroyPlan(regions(s.Region), s)
+		if err != nil {
+			return err
+		}
+		if global != nil {
+			secondary.detachFrom(global)
+		}
+
+		plan.Secondaries = append(plan.Secondaries, SecondaryDestroyPlan{Region: s.Region, Plan: secondary})
+	}
+
+	return nil
+}
+
+// detachFrom marks the cluster of p to be detached from global before it is
+// deleted, if it is a member.
+func (p *DestroyPlan) detachFrom(global *rds.GlobalCluster) {
+	if p.clusterArn != "" && factory.GlobalClusterMember(global, p.clusterArn) != nil {
+		p.globalMember = aws.StringValue(global.GlobalClusterIdentifier)
+	}
+}
+
+// detach removes the cluster of p from the global cluster it is a member
+// of, and waits for it to become a regional cluster.
+func (p *DestroyPlan) detach(svc rdsiface.RDSAPI, waiter *factory.Waiter, dTimeout int) error {
+	if p.globalMember == "" {
+		return nil
+	}
+
+	log.Infof("detaching cluster %s from global cluster %s", p.Cluster, p.globalMember)
+	err := factory.RemoveFromGlobalCluster(svc, p.globalMember, p.clusterArn)
+	if err != nil {
+		return err
+	}
+
+	return waitWithTimeout(dTimeout, func(ctx context.Context) error {
+		_, err := waiter.WaitForClusterAvailable(ctx, svc, p.Cluster)
+		return err
+	})
+}
+
+// deleteGlobalCluster deletes the global cluster of p, once every member has
+// been detached.
+func (p *DestroyPlan) deleteGlobalCluster(svc rdsiface.RDSAPI, waiter *factory.Waiter, dTimeout int) error {
+	if p.GlobalCluster == "" {
+		return nil
+	}
+
+	err := factory.DeleteGlobalCluster(svc, p.GlobalCluster)
+	if err != nil {
+		return err
+	}
+
+	return waitWithTimeout(dTimeout, func(ctx context.Context) error {
+		return waiter.WaitForGlobalClusterDeleted(ctx, svc, p.GlobalCluster)
+	})
+}
+
+// ApplyGlobalDestroyPlan destroys the secondary clusters in plan and then
+// the global cluster and the primary cluster with ApplyDestroyPlan. The
+// secondary clusters hold the same data as the primary cluster, so only the
+// primary cluster gets a final snapshot.
+func ApplyGlobalDestroyPlan(
+	svc rdsiface.RDSAPI, regions Regions, plan *DestroyPlan, waiter *factory.Waiter,
+	finalSnapshotIdentifier string, dTimeout int,
+) error {
+	for _, s := range plan.Secondaries {
+		log.Infof("destroying secondary cluster in %s", s.Region)
+		err := ApplyDestroyPlan(regions(s.Region), s.Plan, waiter, "", dTimeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ApplyDestroyPlan(svc, plan, waiter, finalSnapshotIdentifier, dTimeout)
+}
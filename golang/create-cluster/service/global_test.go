@@ -0,0 +1,253 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+const (
+	testPrimaryRegion   = "us-west-2"
+	testSecondaryRegion = "us-east-1"
+)
+
+// testGlobalRequest is testRequest as the primary of a global database
+// with one secondary cluster.
+func testGlobalRequest() request.ClusterRequest {
+	req := testRequest()
+	req.Region = testPrimaryRegion
+	req.Instances = []request.InstanceRequest{{Identifier: "experiments-0", Class: "db.r4.large"}}
+	req.Global = &request.GlobalRequest{
+		Identifier: "experiments-global",
+		Secondaries: []request.SecondaryRequest{{
+			Region:           testSecondaryRegion,
+			GroupName:        "experiments",
+			GroupDescription: "experiments",
+			Subnets:          []string{"subnet-0d", "subnet-0e"},
+			ClusterId:        "experiments-east",
+			SgIds:            []string{"sg-00000000000000011"},
+			Instances: []request.InstanceRequest{
+				{Identifier: "experiments-east-0", Class: "db.r4.large"},
+			},
+		}},
+	}
+
+	return req
+}
+
+// testRegions returns a backend for each region of testGlobalRequest,
+// sharing their global clusters.
+func testRegions() (*fakerds.RDS, Regions) {
+	primary, secondary := fakerds.New(), fakerds.New()
+	primary.Region, secondary.Region = testPrimaryRegion, testSecondaryRegion
+	secondary.GlobalClusters = primary.GlobalClusters
+
+	return primary, func(region string) rdsiface.RDSAPI {
+		if region == testSecondaryRegion {
+			return secondary
+		}
+		return primary
+	}
+}
+
+func TestPlanGlobal(t *testing.T) {
+	tests := []struct {
+		name string
+		// applied applies testGlobalRequest before planning, and regional
+		// applies it without its global database.
+		applied  bool
+		regional bool
+		change   func(req *request.ClusterRequest)
+		global   Action
+		// cluster and instance are the actions of the secondary cluster
+		// and its instance.
+		cluster  Action
+		instance Action
+		err      string
+	}{
+		{
+			name:     "create",
+			change:   func(*request.ClusterRequest) {},
+			global:   ActionCreate,
+			cluster:  ActionCreate,
+			instance: ActionCreate,
+		},
+		{
+			name:     "no-op",
+			applied:  true,
+			change:   func(*request.ClusterRequest) {},
+			global:   ActionNoop,
+			cluster:  ActionNoop,
+			instance: ActionNoop,
+		},
+		{
+			name:    "modify secondary",
+			applied: true,
+			change: func(req *request.ClusterRequest) {
+				req.Global.Secondaries[0].Instances[0].Class = "db.r4.xlarge"
+			},
+			global:   ActionNoop,
+			cluster:  ActionNoop,
+			instance: ActionModify,
+		},
+		{
+			name:     "primary drift",
+			applied:  true,
+			regional: true,
+			change: func(req *request.ClusterRequest) {
+				req.MasterUsername = "root"
+			},
+			err: "has to be replaced with -replace",
+		},
+		{
+			name:    "upgrade member",
+			applied: true,
+			change: func(req *request.ClusterRequest) {
+				req.EngineVersion = "5.7.mysql_aurora.2.04.0"
+			},
+			err: "can't be upgraded from 5.7.12 to 5.7.mysql_aurora.2.04.0 on its own",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, regions := testRegions()
+			if tt.applied {
+				applied := testGlobalRequest()
+				if tt.regional {
+					applied.Global = nil
+				}
+				mustApply(t, svc, regions, applied)
+			}
+
+			req := testGlobalRequest()
+			tt.change(&req)
+			plan, err := BuildPlan(svc, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = PlanGlobal(svc, regions, req, plan)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if plan.GlobalCluster.Action != tt.global {
+				t.Errorf("global cluster action = %s, want %s", plan.GlobalCluster.Action, tt.global)
+			}
+			if len(plan.Secondaries) != 1 {
+				t.Fatalf("%d secondary plans, want 1", len(plan.Secondaries))
+			}
+			secondary := plan.Secondaries[0]
+			if secondary.Region != testSecondaryRegion {
+				t.Errorf("secondary region = %s, want %s", secondary.Region, testSecondaryRegion)
+			}
+			if secondary.Plan.Cluster.Action != tt.cluster {
+				t.Errorf("secondary cluster action = %s, want %s", secondary.Plan.Cluster.Action, tt.cluster)
+			}
+			if secondary.Plan.Instances[0].Action != tt.instance {
+				t.Errorf("secondary instance action = %s, want %s", secondary.Plan.Instances[0].Action, tt.instance)
+			}
+		})
+	}
+}
+
+// mustApply applies req, and its global database when it declares one.
+func mustApply(t *testing.T, svc rdsiface.RDSAPI, regions Regions, req request.ClusterRequest) {
+	t.Helper()
+
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Global != nil {
+		if err := PlanGlobal(svc, regions, req, plan); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waiter := testWaiter()
+	if err := ApplyPlan(svc, req, plan, waiter); err != nil {
+		t.Fatal(err)
+	}
+	if req.Global != nil {
+		if err := ApplyGlobal(svc, regions, req, plan, waiter); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestApplyGlobal(t *testing.T) {
+	svc, regions := testRegions()
+	req := testGlobalRequest()
+	mustApply(t, svc, regions, req)
+
+	global, err := factory.FindGlobalCluster(svc, req.Global.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondary, err := factory.FindDBCluster(regions(testSecondaryRegion), "experiments-east")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		cluster *rds.DBCluster
+		writer  bool
+	}{{primary, true}, {secondary, false}} {
+		arn := aws.StringValue(c.cluster.DBClusterArn)
+		m := factory.GlobalClusterMember(global, arn)
+		if m == nil {
+			t.Errorf("%s is not a member of %s", arn, req.Global.Identifier)
+			continue
+		}
+		if aws.BoolValue(m.IsWriter) != c.writer {
+			t.Errorf("%s is writer %v, want %v", arn, aws.BoolValue(m.IsWriter), c.writer)
+		}
+	}
+}
+
+func TestDestroyGlobal(t *testing.T) {
+	svc, regions := testRegions()
+	req := testGlobalRequest()
+	mustApply(t, svc, regions, req)
+
+	plan, err := BuildDestroyPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := BuildGlobalDestroyPlan(svc, regions, req, plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan.GlobalCluster != req.Global.Identifier || len(plan.Secondaries) != 1 {
+		t.Fatalf("plan destroys global cluster %q and %d secondaries, want %s and 1",
+			plan.GlobalCluster, len(plan.Secondaries), req.Global.Identifier)
+	}
+	if err := ApplyGlobalDestroyPlan(svc, regions, plan, testWaiter(), "", req.DeleteTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := factory.FindGlobalCluster(svc, req.Global.Identifier); !factory.IsNotFound(err) {
+		t.Errorf("global cluster lookup: %v, want not found", err)
+	}
+	if _, err := factory.FindDBCluster(regions(testSecondaryRegion), "experiments-east"); !factory.IsNotFound(err) {
+		t.Errorf("secondary cluster lookup: %v, want not found", err)
+	}
+	if _, err := factory.FindDBCluster(svc, req.ClusterId); !factory.IsNotFound(err) {
+		t.Errorf("primary cluster lookup: %v, want not found", err)
+	}
+}
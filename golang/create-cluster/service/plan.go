@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionModify  Action = "modify"
+	ActionReplace Action = "replace"
+	ActionNoop    Action = "no-op"
+)
+
+const (
+	resourceSubnetGroup           = "db_subnet_group"
+	resourceClusterParameterGroup = "db_cluster_parameter_group"
+	resourceParameterGroup        = "db_parameter_group"
+	resourceCluster               = "db_cluster"
+	resourceInstance              = "db_instance"
+)
+
+var actionSymbols = map[Action]string{
+	ActionCreate:  "+",
+	ActionModify:  "~",
+	ActionReplace: "-/+",
+	ActionNoop:    " ",
+}
+
+// ResourcePlan is what will happen to a single resource when a plan is
+// applied. Drift lists changes that need the resource to be replaced when
+// replacing wasn't asked for; they are only reported. Upgrade is set on a
+// cluster whose engine version changes.
+type ResourcePlan struct {
+	Type       string                 `json:"type"`
+	Identifier string                 `json:"identifier"`
+	Action     Action                 `json:"action"`
+	Changes    []factory.FieldChange  `json:"changes"`
+	Drift      []factory.FieldChange  `json:"drift,omitempty"`
+	Upgrade    *factory.EngineUpgrade `json:"upgrade,omitempty"`
+}
+
+// Plan is the set of actions needed to bring AWS in line with a
+// ClusterRequest. Instances are in the same order as the request.
+// ClusterParameterGroup is nil when the request doesn't declare one.
+// ParameterGroups are the DB parameter groups of the instances.
+// GlobalCluster and Secondaries are only set by PlanGlobal, for the primary
+// cluster of a global database.
+type Plan struct {
+	SubnetGroup           ResourcePlan    `json:"subnetGroup"`
+	ClusterParameterGroup *ResourcePlan   `json:"clusterParameterGroup,omitempty"`
+	ParameterGroups       []ResourcePlan  `json:"parameterGroups,omitempty"`
+	Cluster               ResourcePlan    `json:"cluster"`
+	Instances             []ResourcePlan  `json:"instances"`
+	GlobalCluster         *ResourcePlan   `json:"globalCluster,omitempty"`
+	Secondaries           []SecondaryPlan `json:"secondaries,omitempty"`
+}
+
+// BuildPlan describes the current resources and compares them with req, and
+// checks what would be created against what RDS offers. It makes no
+// mutating calls.
+func BuildPlan(svc rdsiface.RDSAPI, req request.ClusterRequest) (*Plan, error) {
+	plan := &Plan{}
+
+	subnetGroup, err := factory.FindDBSubnetGroup(svc, req.GroupName)
+	if err != nil && !factory.IsNotFound(err) {
+		return nil, err
+	}
+	plan.SubnetGroup = newResourcePlan(
+		resourceSubnetGroup,
+		req.GroupName,
+		subnetGroup != nil,
+		factory.DiffDBSubnetGroup(subnetGroup, req.GroupName, req.GroupDescription, req.Subnets),
+	)
+
+	if req.ClusterParameterGroup != nil {
+		plan.ClusterParameterGroup, err = planClusterParameterGroup(svc, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pg := range req.DBParameterGroups() {
+		p, err := planParameterGroup(svc, pg)
+		if err != nil {
+			return nil, err
+		}
+		plan.ParameterGroups = append(plan.ParameterGroups, p)
+	}
+
+	clusterFactory := newClusterFactory(req)
+	cluster, err := clusterFactory.FindDBCluster(svc)
+	if err != nil && !factory.IsNotFound(err) {
+		return nil, err
+	}
+	if cluster == nil && req.SourceSnapshot != "" {
+		snapshot, err := factory.FindDBClusterSnapshot(svc, req.SourceSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		err = clusterFactory.CheckSourceSnapshot(snapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var upgrade *factory.EngineUpgrade
+	if cluster != nil {
+		upgrade, err = checkUpgrade(svc, req, cluster)
+		if err != nil {
+			return nil, err
+		}
+	}
+	changes, replace := factory.SplitReplacements(clusterFactory.Diff(cluster))
+	plan.Cluster = newResourcePlan(resourceCluster, req.ClusterId, cluster != nil, changes)
+	plan.Cluster.Upgrade = upgrade
+	if len(replace) > 0 && !req.ReplaceCluster {
+		plan.Cluster.Drift = replace
+	}
+	replacing := len(replace) > 0 && req.ReplaceCluster
+	if replacing {
+		err = checkReplacement(svc, req, cluster, replace)
+		if err != nil {
+			return nil, err
+		}
+		plan.Cluster.Action = ActionReplace
+		plan.Cluster.Changes = append(changes, replace...)
+	}
+
+	for _, i := range req.Instances {
+		instanceFactory := newInstanceFactory(svc, req, i)
+		instance, err := instanceFactory.FindDBClusterInstance()
+		if err != nil && !factory.IsNotFound(err) {
+			return nil, err
+		}
+
+		p := newResourcePlan(resourceInstance, i.Identifier, instance != nil, instanceFactory.Diff(instance))
+		// Every member is created again in the replacement cluster.
+		if replacing && instance != nil {
+			p.Action = ActionReplace
+		}
+		plan.Instances = append(plan.Instances, p)
+	}
+
+	err = preflight(svc, req, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func planClusterParameterGroup(svc rdsiface.RDSAPI, req request.ClusterRequest) (*ResourcePlan, error) {
+	f := newClusterParameterGroupFactory(req)
+
+	group, err := f.FindDBClusterParameterGroup(svc)
+	if err != nil && !factory.IsNotFound(err) {
+		return nil, err
+	}
+
+	var parameters []*rds.Parameter
+	if group != nil {
+		parameters, err = f.DescribeParameters(svc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p := newResourcePlan(
+		resourceClusterParameterGroup, req.ClusterParameterGroup.Name, group != nil, f.Diff(group, parameters),
+	)
+	return &p, nil
+}
+
+func planParameterGroup(svc rdsiface.RDSAPI, pg request.ParameterGroupRequest) (ResourcePlan, error) {
+	f := newParameterGroupFactory(pg)
+
+	group, err := f.FindDBParameterGroup(svc)
+	if err != nil && !factory.IsNotFound(err) {
+		return ResourcePlan{}, err
+	}
+
+	var parameters []*rds.Parameter
+	if group != nil {
+		parameters, err = f.DescribeParameters(svc)
+		if err != nil {
+			return ResourcePlan{}, err
+		}
+	}
+
+	return newResourcePlan(resourceParameterGroup, pg.Name, group != nil, f.Diff(group, parameters)), nil
+}
+
+func newResourcePlan(resourceType, identifier string, exists bool, changes []factory.FieldChange) ResourcePlan {
+	action := ActionCreate
+	if exists {
+		action = ActionModify
+		if len(changes) == 0 {
+			action = ActionNoop
+		}
+	}
+
+	return ResourcePlan{
+		Type:       resourceType,
+		Identifier: identifier,
+		Action:     action,
+		Changes:    changes,
+	}
+}
+
+func (p *Plan) resources() []ResourcePlan {
+	resources := []ResourcePlan{p.SubnetGroup}
+	if p.ClusterParameterGroup != nil {
+		resources = append(resources, *p.ClusterParameterGroup)
+	}
+	resources = append(resources, p.ParameterGroups...)
+	resources = append(resources, p.Cluster)
+	resources = append(resources, p.Instances...)
+	if p.GlobalCluster != nil {
+		resources = append(resources, *p.GlobalCluster)
+	}
+	return resources
+}
+
+// HasChanges reports whether applying the plan would make any mutating call.
+func (p *Plan) HasChanges() bool {
+	for _, r := range p.resources() {
+		if r.Action != ActionNoop {
+			return true
+		}
+	}
+	for _, s := range p.Secondaries {
+		if s.Plan.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetsMasterUserPassword reports whether applying the plan gives the cluster
+// the master password of the request. A replacement keeps the password of
+// the snapshot.
+func (p *Plan) SetsMasterUserPassword() bool {
+	switch p.Cluster.Action {
+	case ActionCreate, ActionModify:
+		return factory.SetsMasterUserPassword(p.Cluster.Changes)
+	}
+	return false
+}
+
+// Print writes the plan in a Terraform like format. The secondary clusters
+// of a global database follow the primary cluster, one region at a time.
+func (p *Plan) Print(w io.Writer) {
+	counts := map[Action]int{}
+
+	drifted := printResources(w, p.resources(), counts)
+	for _, s := range p.Secondaries {
+		fmt.Fprintf(w, "\n  secondary cluster in %s:\n", s.Region)
+		drifted += printResources(w, s.Plan.resources(), counts)
+	}
+
+	fmt.Fprintf(
+		w,
+		"\nPlan: %d to create, %d to modify, %d unchanged.\n",
+		counts[ActionCreate], counts[ActionModify], counts[ActionNoop],
+	)
+	if counts[ActionReplace] > 0 {
+		fmt.Fprintf(w, "%d to replace from a snapshot.\n", counts[ActionReplace])
+	}
+	if drifted > 0 {
+		fmt.Fprintf(w, "%d with changes that only a replacement can apply.\n", drifted)
+	}
+}
+
+// printResources writes resources, adding up their actions in counts, and
+// returns how many have drifted.
+func printResources(w io.Writer, resources []ResourcePlan, counts map[Action]int) int {
+	drifted := 0
+
+	for _, r := range resources {
+		counts[r.Action]++
+
+		fmt.Fprintf(w, "  %s %s.%s (%s)\n", actionSymbols[r.Action], r.Type, r.Identifier, r.Action)
+		for _, c := range r.Changes {
+			fmt.Fprintf(w, "      %s\n", c)
+		}
+		if u := r.Upgrade; u != nil && u.Major {
+			fmt.Fprintf(w, "      (major version upgrade, parameter group family %s => %s)\n", u.FromFamily, u.ToFamily)
+			fmt.Fprintf(
+				w, "      (cluster parameter group %s, instance parameter group %s)\n",
+				u.ClusterParameterGroup, u.InstanceParameterGroup,
+			)
+		}
+		for _, c := range r.Drift {
+			fmt.Fprintf(w, "    ! %s\n", c)
+		}
+		if len(r.Drift) > 0 {
+			drifted++
+		}
+	}
+
+	return drifted
+}
+
+// driftFields names the fields in drift for log messages.
+func driftFields(drift []factory.FieldChange) string {
+	fields := make([]string, 0)
+	for _, c := range drift {
+		fields = append(fields, c.Field)
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// Equal reports whether two plans would perform the same actions.
+func (p *Plan) Equal(o *Plan) bool {
+	a, err := json.Marshal(p)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(a, b)
+}
+
+func WritePlan(path string, plan *Plan) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+func ReadPlan(path string) (*Plan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	err = json.Unmarshal(b, plan)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return plan, nil
+}
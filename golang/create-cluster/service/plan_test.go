@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+)
+
+func TestNewResourcePlan(t *testing.T) {
+	change := []factory.FieldChange{{Field: "DBInstanceClass", From: "db.t2.small", To: "db.r5.large"}}
+
+	tests := []struct {
+		name    string
+		exists  bool
+		changes []factory.FieldChange
+		want    Action
+	}{
+		{name: "missing", changes: change, want: ActionCreate},
+		{name: "changed", exists: true, changes: change, want: ActionModify},
+		{name: "unchanged", exists: true, changes: []factory.FieldChange{}, want: ActionNoop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newResourcePlan(resourceInstance, "experiments-0", tt.exists, tt.changes)
+			if p.Action != tt.want {
+				t.Errorf("action = %s, want %s", p.Action, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	plan := &Plan{
+		SubnetGroup: newResourcePlan(resourceSubnetGroup, "experiments", true, []factory.FieldChange{}),
+		Cluster: ResourcePlan{
+			Type:       resourceCluster,
+			Identifier: "experiments",
+			Action:     ActionNoop,
+			Changes:    []factory.FieldChange{},
+			Drift: []factory.FieldChange{
+				{Field: "StorageEncrypted", From: "false", To: "true", Impact: factory.ImpactReplace},
+			},
+		},
+		Instances: []ResourcePlan{
+			newResourcePlan(resourceInstance, "experiments-0", true, []factory.FieldChange{}),
+			newResourcePlan(resourceInstance, "experiments-1", false, []factory.FieldChange{
+				{Field: "DBInstanceIdentifier", To: "experiments-1"},
+				{Field: "DBInstanceClass", To: "db.r5.large"},
+			}),
+		},
+	}
+
+	if !plan.HasChanges() {
+		t.Error("plan creating an instance has no changes")
+	}
+
+	var b bytes.Buffer
+	plan.Print(&b)
+	want := `    db_subnet_group.experiments (no-op)
+    db_cluster.experiments (no-op)
+    ! StorageEncrypted: "false" => "true" (forces replacement)
+    db_instance.experiments-0 (no-op)
+  + db_instance.experiments-1 (create)
+      DBInstanceIdentifier: "experiments-1"
+      DBInstanceClass: "db.r5.large"
+
+Plan: 1 to create, 0 to modify, 3 unchanged.
+1 with changes that only a replacement can apply.
+`
+	if b.String() != want {
+		t.Errorf("printed plan:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "plan.json")
+	if err := WritePlan(path, plan); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Equal(read) {
+		t.Errorf("plan read back differs: %+v", read)
+	}
+
+	read.Instances = read.Instances[:1]
+	if plan.Equal(read) {
+		t.Error("plans with different instances are equal")
+	}
+	if read.HasChanges() {
+		t.Error("plan with only no-op resources has changes")
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// preflight checks what plan creates or changes against what RDS offers in
+// the region: the engine version and engine mode of a new or replaced
+// cluster, and the class and zone of every instance that is created or moved
+// to another class. An upgrade checks every instance, since they all move to
+// the new version. Problems are returned together as a
+// request.ValidationError, each with a suggestion where there is one.
+func preflight(svc rdsiface.RDSAPI, req request.ClusterRequest, plan *Plan) error {
+	problems := request.ValidationError{}
+
+	newCluster := plan.Cluster.Action == ActionCreate || plan.Cluster.Action == ActionReplace
+	if newCluster && req.EngineVersion != "" {
+		ok, err := checkEngineVersion(svc, req, &problems)
+		if err != nil || !ok {
+			return problemsOrErr(problems, err)
+		}
+	}
+
+	instances := make([]request.InstanceRequest, 0)
+	for n, p := range plan.Instances {
+		if p.Action == ActionCreate || p.Action == ActionReplace || plan.Cluster.Upgrade != nil ||
+			factory.ChangesInstanceClass(p.Changes) {
+			instances = append(instances, req.Instances[n])
+		}
+	}
+	if len(instances) > 0 {
+		err := checkInstanceOptions(svc, req.Engine, req.EngineVersion, instances, &problems)
+		if err != nil {
+			return err
+		}
+	}
+
+	return problemsOrErr(problems, nil)
+}
+
+func problemsOrErr(problems request.ValidationError, err error) error {
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+// checkEngineVersion reports whether RDS offers the engine version in req,
+// in the engine mode req asks for. When it doesn't, the closest offered
+// version is suggested. When it does and a later minor version exists, that
+// is logged.
+func checkEngineVersion(svc rdsiface.RDSAPI, req request.ClusterRequest, problems *request.ValidationError) (bool, error) {
+	current, err := factory.FindDBEngineVersion(svc, req.Engine, req.EngineVersion)
+	if err == nil {
+		// Upgrade targets don't say which engine modes they run in, so
+		// there is no later version to suggest to a serverless or global
+		// cluster.
+		if mode := engineMode(req); mode != factory.EngineModeProvisioned {
+			supports := func(v *rds.DBEngineVersion) bool { return hasEngineMode(v, mode) }
+			if !supports(current) {
+				return false, checkSupportedVersions(svc, req, engineModeUses[mode], supports, problems)
+			}
+			return true, nil
+		}
+		if isGlobal(req) {
+			if !supportsGlobalDatabases(current) {
+				return false, checkSupportedVersions(svc, req, globalDatabaseUse, supportsGlobalDatabases, problems)
+			}
+			return true, nil
+		}
+
+		latest := ""
+		for _, t := range current.ValidUpgradeTarget {
+			if !aws.BoolValue(t.IsMajorVersionUpgrade) {
+				latest = aws.StringValue(t.EngineVersion)
+			}
+		}
+		if latest != "" {
+			log.Infof("%s %s is not the latest minor version, consider %s", req.Engine, req.EngineVersion, latest)
+		}
+		return true, nil
+	}
+	if !factory.IsNotFound(err) {
+		return false, err
+	}
+
+	versions, err := factory.ListDBEngineVersions(svc, req.Engine)
+	if err != nil {
+		return false, err
+	}
+	if len(versions) == 0 {
+		*problems = append(*problems, fmt.Sprintf("RDS doesn't offer engine %s in %s", req.Engine, req.Region))
+		return false, nil
+	}
+
+	offered := make([]string, 0)
+	for _, v := range versions {
+		offered = append(offered, aws.StringValue(v.EngineVersion))
+	}
+	*problems = append(*problems, fmt.Sprintf(
+		"RDS doesn't offer %s %s, the closest version is %s",
+		req.Engine, req.EngineVersion, closestVersion(req.EngineVersion, offered),
+	))
+
+	return false, nil
+}
+
+// globalDatabaseUse is what a version of an engine without the global engine
+// mode needs global database support for.
+const globalDatabaseUse = "be part of a global database"
+
+// engineModeUses describe what each engine mode other than provisioned is
+// for.
+var engineModeUses = map[string]string{
+	factory.EngineModeServerless: "run serverless",
+	factory.EngineModeGlobal:     globalDatabaseUse,
+}
+
+// checkSupportedVersions reports that the engine version in req can't be
+// used for use, listing the versions of the engine that supports says can.
+func checkSupportedVersions(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, use string, supports func(*rds.DBEngineVersion) bool,
+	problems *request.ValidationError,
+) error {
+	versions, err := factory.ListDBEngineVersions(svc, req.Engine)
+	if err != nil {
+		return err
+	}
+
+	supported := make([]string, 0)
+	for _, v := range versions {
+		if supports(v) {
+			supported = append(supported, aws.StringValue(v.EngineVersion))
+		}
+	}
+
+	msg := fmt.Sprintf("%s %s can't %s", req.Engine, req.EngineVersion, use)
+	if len(supported) == 0 {
+		msg += fmt.Sprintf(", no %s version can in %s", req.Engine, req.Region)
+	} else {
+		msg += ", versions that can are " + strings.Join(supported, ", ")
+	}
+	*problems = append(*problems, msg)
+
+	return nil
+}
+
+func hasEngineMode(version *rds.DBEngineVersion, mode string) bool {
+	for _, m := range version.SupportedEngineModes {
+		if aws.StringValue(m) == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func supportsGlobalDatabases(version *rds.DBEngineVersion) bool {
+	return aws.BoolValue(version.SupportsGlobalDatabases)
+}
+
+// closestVersion picks the latest of the offered versions that share the
+// longest prefix with version. offered is oldest first.
+func closestVersion(version string, offered []string) string {
+	best, bestLen := "", -1
+	for _, v := range offered {
+		if n := commonPrefix(version, v); n >= bestLen {
+			best, bestLen = v, n
+		}
+	}
+
+	return best
+}
+
+// checkInstanceOptions checks the class and zone of each instance against
+// the orderable options of engine at version.
+func checkInstanceOptions(
+	svc rdsiface.RDSAPI, engine, version string, instances []request.InstanceRequest, problems *request.ValidationError,
+) error {
+	options, err := factory.ListOrderableDBInstanceOptions(svc, engine, version)
+	if err != nil {
+		return err
+	}
+
+	zones := map[string]map[string]bool{}
+	for _, o := range options {
+		class := aws.StringValue(o.DBInstanceClass)
+		if zones[class] == nil {
+			zones[class] = map[string]bool{}
+		}
+		for _, z := range o.AvailabilityZones {
+			zones[class][aws.StringValue(z.Name)] = true
+		}
+	}
+	classes := make([]string, 0)
+	for class := range zones {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	offeredFor := engine
+	if version != "" {
+		offeredFor += " " + version
+	}
+
+	for _, i := range instances {
+		classZones, ok := zones[i.Class]
+		if !ok {
+			msg := fmt.Sprintf("instance %s: RDS doesn't offer %s for %s", i.Identifier, i.Class, offeredFor)
+			if len(classes) > 0 {
+				msg += ", the nearest offered class is " + nearestClass(i.Class, classes)
+			}
+			*problems = append(*problems, msg)
+			continue
+		}
+
+		if i.AvailabilityZone != "" && !classZones[i.AvailabilityZone] {
+			*problems = append(*problems, fmt.Sprintf(
+				"instance %s: %s isn't offered in %s, it is in %s",
+				i.Identifier, i.Class, i.AvailabilityZone, strings.Join(sortedKeys(classZones), ", "),
+			))
+		}
+	}
+
+	return nil
+}
+
+// nearestClass picks the offered class that shares the longest prefix with
+// class, such as db.t3.small for db.t2.small, preferring one of the same
+// size.
+func nearestClass(class string, offered []string) string {
+	size := class[strings.LastIndex(class, ".")+1:]
+
+	best, bestLen, bestSize := "", -1, false
+	for _, c := range offered {
+		n := commonPrefix(class, c)
+		sameSize := strings.HasSuffix(c, "."+size)
+		if n > bestLen || (n == bestLen && sameSize && !bestSize) {
+			best, bestLen, bestSize = c, n, sameSize
+		}
+	}
+
+	return best
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0)
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+		change   func(*request.ClusterRequest)
+		problems request.ValidationError
+	}{
+		{
+			name: "offered",
+		},
+		{
+			name: "engine",
+			change: func(r *request.ClusterRequest) {
+				r.Engine = "aurora-foo"
+			},
+			problems: request.ValidationError{"RDS doesn't offer engine aurora-foo in us-east-1"},
+		},
+		{
+			name: "engine version",
+			change: func(r *request.ClusterRequest) {
+				r.EngineVersion = "5.7.99"
+			},
+			problems: request.ValidationError{
+				"RDS doesn't offer aurora-mysql 5.7.99, the closest version is 5.7.mysql_aurora.2.04.0",
+			},
+		},
+		{
+			name: "instance class",
+			change: func(r *request.ClusterRequest) {
+				r.Instances[1].Class = "db.t3.small"
+			},
+			problems: request.ValidationError{
+				"instance experiments-1: RDS doesn't offer db.t3.small for aurora-mysql 5.7.12, " +
+					"the nearest offered class is db.t2.small",
+			},
+		},
+		{
+			name: "zone",
+			change: func(r *request.ClusterRequest) {
+				r.Instances[0].Class = "db.r5.large"
+				r.Instances[0].AvailabilityZone = "us-east-1c"
+			},
+			problems: request.ValidationError{
+				"instance experiments-0: db.r5.large isn't offered in us-east-1c, it is in us-east-1a, us-east-1b",
+			},
+		},
+		{
+			name:     "upgrade",
+			existing: true,
+			change: func(r *request.ClusterRequest) {
+				r.EngineVersion = "5.7.mysql_aurora.2.04.0"
+			},
+			problems: request.ValidationError{
+				"instance experiments-0: RDS doesn't offer db.t2.small for aurora-mysql 5.7.mysql_aurora.2.04.0, " +
+					"the nearest offered class is db.t3.small",
+				"instance experiments-1: RDS doesn't offer db.t2.small for aurora-mysql 5.7.mysql_aurora.2.04.0, " +
+					"the nearest offered class is db.t3.small",
+			},
+		},
+		{
+			name:     "unchanged instances",
+			existing: true,
+			change: func(r *request.ClusterRequest) {
+				r.BackupRetentionPeriod = 7
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			req := testRequest()
+			if tt.existing {
+				apply(t, svc, req)
+			}
+			if tt.change != nil {
+				tt.change(&req)
+			}
+
+			_, err := BuildPlan(svc, req)
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			problems, ok := err.(request.ValidationError)
+			if !ok {
+				t.Fatalf("err = %v, want a request.ValidationError", err)
+			}
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("problems = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
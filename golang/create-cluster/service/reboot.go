@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// PendingReboot returns the cluster members that have cluster or DB
+// parameter changes waiting for a reboot, readers before the writer.
+func PendingReboot(svc rdsiface.RDSAPI, req request.ClusterRequest) ([]string, error) {
+	cluster, err := newClusterFactory(req).FindDBCluster(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only instances with a declared DB parameter group are described.
+	instances := make([]*rds.DBInstance, 0)
+	for _, i := range req.Instances {
+		if i.ParameterGroup == nil {
+			continue
+		}
+		f := newInstanceFactory(svc, req, i)
+		instance, err := f.FindDBClusterInstance()
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return factory.PendingRebootMembers(cluster, instances), nil
+}
+
+// RebootInstances reboots the instances one at a time and waits for each to
+// be available again before moving on, so at most one member is down.
+func RebootInstances(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, instanceIdentifiers []string,
+) error {
+	for _, id := range instanceIdentifiers {
+		log.Infof("rebooting instance %s", id)
+		_, err := factory.RebootDBInstance(svc, id)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.ReadyTimeout)*time.Minute)
+		_, err = waiter.WaitForInstanceAvailable(ctx, svc, id)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// A replacement is built next to the current cluster under identifiers with
+// replacementSuffix. The current cluster then moves aside to retiredSuffix
+// and the replacement takes over its identifiers.
+const (
+	replacementSuffix = "-new"
+	retiredSuffix     = "-old"
+
+	maxIdentifierLength = 63
+)
+
+// checkReplacement makes sure cluster can be replaced to apply the replace
+// changes before anything is changed.
+func checkReplacement(svc rdsiface.RDSAPI, req request.ClusterRequest, cluster *rds.DBCluster, replace []factory.FieldChange) error {
+	err := factory.CheckSnapshotReplacement(replace)
+	if err != nil {
+		return err
+	}
+
+	clusterIds := []string{req.ClusterId}
+	instanceIds := make([]string, 0)
+	for _, i := range req.Instances {
+		instanceIds = append(instanceIds, i.Identifier)
+	}
+	for _, m := range cluster.DBClusterMembers {
+		instanceIds = append(instanceIds, aws.StringValue(m.DBInstanceIdentifier))
+	}
+
+	for _, suffix := range []string{replacementSuffix, retiredSuffix} {
+		for _, id := range append(clusterIds, instanceIds...) {
+			if len(id+suffix) > maxIdentifierLength {
+				return fmt.Errorf("%s is too long to be renamed to %s%s during the replacement", id, id, suffix)
+			}
+		}
+
+		for _, id := range clusterIds {
+			_, err := factory.FindDBCluster(svc, id+suffix)
+			if err == nil {
+				return fmt.Errorf("cluster %s%s already exists, probably from an earlier replacement; delete it first", id, suffix)
+			}
+			if !factory.IsNotFound(err) {
+				return err
+			}
+		}
+		for _, id := range instanceIds {
+			_, err := factory.FindDBInstance(svc, id+suffix)
+			if err == nil {
+				return fmt.Errorf("instance %s%s already exists, probably from an earlier replacement; delete it first", id, suffix)
+			}
+			if !factory.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// replaceCluster snapshots the cluster in req and restores the snapshot to a
+// new cluster with the requested settings. Once the new cluster and its
+// instances are available, the old cluster and its instances are renamed
+// with retiredSuffix and the new ones take their identifiers. The old
+// cluster is kept until someone deletes it.
+func replaceCluster(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter) error {
+	current, err := newClusterFactory(req).FindDBCluster(svc)
+	if err != nil {
+		return err
+	}
+	members := make([]string, 0)
+	for _, m := range current.DBClusterMembers {
+		members = append(members, aws.StringValue(m.DBInstanceIdentifier))
+	}
+
+	snapshotId := fmt.Sprintf("%s-%s-%s", req.ClusterId, purposePreReplace, time.Now().UTC().Format("20060102150405"))
+	log.Infof("taking snapshot %s of cluster %s", snapshotId, req.ClusterId)
+	_, err = factory.CreateDBClusterSnapshot(svc, req.ClusterId, snapshotId, purposePreReplace)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := readyContext(req)
+	snapshot, err := waiter.WaitForClusterSnapshotAvailable(ctx, svc, snapshotId)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	next := withSuffix(req, replacementSuffix)
+	log.Infof("restoring snapshot %s to cluster %s", snapshotId, next.ClusterId)
+	cluster, err := newClusterFactory(next).RestoreDBClusterFromSnapshot(svc, snapshot)
+	if err != nil {
+		return err
+	}
+	log.Info(cluster)
+	ctx, cancel = readyContext(req)
+	cluster, err = waiter.WaitForClusterAvailable(ctx, svc, next.ClusterId)
+	if err == nil {
+		_, err = finishRestore(ctx, svc, next, waiter, cluster)
+	}
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	creates := make([]ResourcePlan, 0)
+	for _, i := range next.Instances {
+		creates = append(creates, ResourcePlan{Type: resourceInstance, Identifier: i.Identifier, Action: ActionCreate})
+	}
+	err = applyInstances(svc, next, waiter, creates)
+	if err != nil {
+		return err
+	}
+
+	retired := req.ClusterId + retiredSuffix
+	retiredMembers := make([]rename, 0)
+	for _, id := range members {
+		retiredMembers = append(retiredMembers, rename{from: id, to: id + retiredSuffix})
+	}
+	err = renameCluster(svc, req, waiter, rename{from: req.ClusterId, to: retired}, retiredMembers)
+	if err != nil {
+		return err
+	}
+
+	instances := make([]rename, 0)
+	for n, i := range next.Instances {
+		instances = append(instances, rename{from: i.Identifier, to: req.Instances[n].Identifier})
+	}
+	err = renameCluster(svc, req, waiter, rename{from: next.ClusterId, to: req.ClusterId}, instances)
+	if err != nil {
+		return err
+	}
+
+	log.Infof(
+		"cluster %s was replaced; the previous cluster is kept as %s and in snapshot %s until you delete them",
+		req.ClusterId, retired, snapshotId,
+	)
+	return nil
+}
+
+// rename is a change of identifier.
+type rename struct {
+	from, to string
+}
+
+// renameCluster renames a cluster and then its instances, waiting for each
+// under its new identifier.
+func renameCluster(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, cluster rename, instances []rename,
+) error {
+	log.Infof("renaming cluster %s to %s", cluster.from, cluster.to)
+	_, err := factory.RenameDBCluster(svc, cluster.from, cluster.to)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := readyContext(req)
+	_, err = waiter.WaitForClusterRenamed(ctx, svc, cluster.to)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	for _, i := range instances {
+		log.Infof("renaming instance %s to %s", i.from, i.to)
+		_, err := factory.RenameDBInstance(svc, i.from, i.to)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := readyContext(req)
+		_, err = waiter.WaitForInstanceRenamed(ctx, svc, i.to)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withSuffix returns a copy of req whose cluster and instance identifiers
+// end in suffix.
+func withSuffix(req request.ClusterRequest, suffix string) request.ClusterRequest {
+	req.ClusterId += suffix
+	instances := make([]request.InstanceRequest, 0)
+	for _, i := range req.Instances {
+		i.Identifier += suffix
+		instances = append(instances, i)
+	}
+	req.Instances = instances
+
+	return req
+}
+
+func readyContext(req request.ClusterRequest) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Duration(req.ReadyTimeout)*time.Minute)
+}
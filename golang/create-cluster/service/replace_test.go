@@ -0,0 +1,177 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestReplaceCluster(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	apply(t, svc, req)
+
+	req.StorageEncrypted = aws.Bool(true)
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Cluster.Action != ActionNoop || len(plan.Cluster.Drift) != 1 {
+		t.Errorf("cluster plan without -replace = %+v, want a no-op with drift", plan.Cluster)
+	}
+
+	req.ReplaceCluster = true
+	plan, err = BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Cluster.Action != ActionReplace {
+		t.Errorf("cluster action = %s, want %s", plan.Cluster.Action, ActionReplace)
+	}
+	for _, i := range plan.Instances {
+		if i.Action != ActionReplace {
+			t.Errorf("instance %s action = %s, want %s", i.Identifier, i.Action, ActionReplace)
+		}
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(cluster.StorageEncrypted) {
+		t.Errorf("cluster %s is not encrypted after the replacement", req.ClusterId)
+	}
+	if got := clusterMembers(cluster); got != "experiments-0,experiments-1" {
+		t.Errorf("members = %s, want experiments-0,experiments-1", got)
+	}
+
+	retired, err := factory.FindDBCluster(svc, req.ClusterId+retiredSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.BoolValue(retired.StorageEncrypted) {
+		t.Errorf("retired cluster is encrypted")
+	}
+	if got := clusterMembers(retired); got != "experiments-0-old,experiments-1-old" {
+		t.Errorf("retired members = %s, want experiments-0-old,experiments-1-old", got)
+	}
+
+	snapshots, err := svc.DescribeDBClusterSnapshots(&rds.DescribeDBClusterSnapshotsInput{
+		DBClusterIdentifier: aws.String(req.ClusterId),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(snapshots.DBClusterSnapshots); n != 1 ||
+		!strings.HasPrefix(aws.StringValue(snapshots.DBClusterSnapshots[0].DBClusterSnapshotIdentifier), "experiments-pre-replace-") {
+		t.Errorf("snapshots = %v, want the pre-replace one", snapshots.DBClusterSnapshots)
+	}
+
+	plan, err = BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("plan after the replacement has changes: %+v", plan)
+	}
+}
+
+// TestReplaceSuffixedCluster replaces a cluster whose identifiers already
+// end in the replacement suffix, which must be kept.
+func TestReplaceSuffixedCluster(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	req.ClusterId = "experiments-new"
+	req.Instances = req.Instances[:1]
+	req.Instances[0].Identifier = "experiments-0-new"
+	apply(t, svc, req)
+
+	req.StorageEncrypted = aws.Bool(true)
+	req.ReplaceCluster = true
+	apply(t, svc, req)
+
+	cluster, err := factory.FindDBCluster(svc, "experiments-new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !aws.BoolValue(cluster.StorageEncrypted) || clusterMembers(cluster) != "experiments-0-new" {
+		t.Errorf("cluster %s with members %s, want the encrypted replacement with experiments-0-new",
+			aws.StringValue(cluster.DBClusterIdentifier), clusterMembers(cluster))
+	}
+
+	retired, err := factory.FindDBCluster(svc, "experiments-new-old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := clusterMembers(retired); got != "experiments-0-new-old" {
+		t.Errorf("retired members = %s, want experiments-0-new-old", got)
+	}
+}
+
+func TestCheckReplacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(svc *fakerds.RDS, req *request.ClusterRequest)
+		err    string
+	}{
+		{
+			name: "left over",
+			change: func(svc *fakerds.RDS, req *request.ClusterRequest) {
+				_, err := svc.CreateDBCluster(&rds.CreateDBClusterInput{
+					DBClusterIdentifier: aws.String("experiments-old"),
+					Engine:              aws.String("aurora-mysql"),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: "cluster experiments-old already exists",
+		},
+		{
+			name:   "username",
+			change: func(svc *fakerds.RDS, req *request.ClusterRequest) { req.MasterUsername = "root" },
+			err:    `MasterUsername: "admin" => "root" (forces replacement) can't be changed by restoring a snapshot`,
+		},
+		{
+			name: "too long",
+			change: func(svc *fakerds.RDS, req *request.ClusterRequest) {
+				req.Instances[1].Identifier = strings.Repeat("x", 62)
+			},
+			err: "is too long to be renamed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			req := testRequest()
+			apply(t, svc, req)
+
+			req.StorageEncrypted = aws.Bool(true)
+			req.ReplaceCluster = true
+			tt.change(svc, &req)
+			_, err := BuildPlan(svc, req)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// clusterMembers lists the member identifiers of cluster in order.
+func clusterMembers(cluster *rds.DBCluster) string {
+	ids := make([]string, 0)
+	for _, m := range cluster.DBClusterMembers {
+		ids = append(ids, aws.StringValue(m.DBInstanceIdentifier))
+	}
+
+	return strings.Join(ids, ",")
+}
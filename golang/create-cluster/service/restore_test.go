@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestRestoreFromSourceSnapshot(t *testing.T) {
+	svc := fakerds.New()
+	source := testRequest()
+	apply(t, svc, source)
+	if _, err := CreateSnapshot(svc, source, testWaiter(), "experiments-1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	req := testRequest()
+	req.ClusterId = "restored"
+	req.Instances = []request.InstanceRequest{{Identifier: "restored-0", Class: "db.t2.small"}}
+	req.SourceSnapshot = "experiments-1"
+
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := plan.Cluster.Changes[1]; c.Field != "SnapshotIdentifier" || c.To != "experiments-1" {
+		t.Errorf("second cluster change = %s, want the source snapshot", c)
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := factory.FindDBCluster(svc, "restored"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshot only matters on create.
+	plan, err = BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("plan after restoring has changes: %+v", plan)
+	}
+
+	req.ClusterId = "other"
+	req.MasterUsername = "root"
+	if _, err := BuildPlan(svc, req); err == nil {
+		t.Error("no error planning a restore with another master username")
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResetMasterPassword changes the master password of the cluster in req to
+// password and waits for the cluster to be available again. The returned
+// cluster carries the endpoint to log in to.
+func ResetMasterPassword(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, password string,
+) (*rds.DBCluster, error) {
+	req.MasterUserPass = password
+	f := newClusterFactory(req)
+
+	cluster, err := f.FindDBCluster(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("setting a new master password on cluster %s", req.ClusterId)
+	cluster, err = f.ResetMasterUserPassword(svc, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.ReadyTimeout)*time.Minute)
+	defer cancel()
+
+	return waiter.WaitForClusterAvailable(ctx, svc, *cluster.DBClusterIdentifier)
+}
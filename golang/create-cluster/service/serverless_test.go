@@ -0,0 +1,122 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestServerless(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	req.Engine = "aurora"
+	req.EngineVersion = "5.6.10a"
+	req.StorageEncrypted = aws.Bool(true)
+	req.Serverless = &request.ServerlessRequest{MinCapacity: 2, MaxCapacity: 8, AutoPause: true, SecondsUntilAutoPause: 300}
+	req.Instances = nil
+	apply(t, svc, req)
+
+	req.Serverless.MaxCapacity = 16
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Cluster.Action != ActionModify {
+		t.Fatalf("cluster action = %s, want %s", plan.Cluster.Action, ActionModify)
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := factory.EngineMode(cluster); got != factory.EngineModeServerless {
+		t.Errorf("engine mode = %s, want %s", got, factory.EngineModeServerless)
+	}
+	want := &rds.ScalingConfigurationInfo{
+		MinCapacity:           aws.Int64(2),
+		MaxCapacity:           aws.Int64(16),
+		AutoPause:             aws.Bool(true),
+		SecondsUntilAutoPause: aws.Int64(300),
+	}
+	if got := cluster.ScalingConfigurationInfo; got.String() != want.String() {
+		t.Errorf("scaling = %s, want %s", got, want)
+	}
+	assertNoChanges(t, svc, req)
+}
+
+func TestServerlessV2(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	req.EngineVersion = "8.0.mysql_aurora.3.02.0"
+	req.ServerlessV2 = &request.ServerlessV2Request{MinCapacity: 0.5, MaxCapacity: 4}
+	req.Instances[0].Class = "db.serverless"
+	req.Instances[1].Class = "db.t3.medium"
+	apply(t, svc, req)
+
+	req.ServerlessV2.MaxCapacity = 8
+	apply(t, svc, req)
+
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &rds.ServerlessV2ScalingConfigurationInfo{MinCapacity: aws.Float64(0.5), MaxCapacity: aws.Float64(8)}
+	if got := cluster.ServerlessV2ScalingConfiguration; got.String() != want.String() {
+		t.Errorf("serverless v2 scaling = %s, want %s", got, want)
+	}
+	instance, err := factory.FindDBInstance(svc, req.Instances[0].Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(instance.DBInstanceClass); got != "db.serverless" {
+		t.Errorf("instance class = %s, want db.serverless", got)
+	}
+	assertNoChanges(t, svc, req)
+}
+
+func TestEngineModeReplacesCluster(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	req.Engine = "aurora"
+	req.EngineVersion = "5.6.10a"
+	req.StorageEncrypted = aws.Bool(true)
+	apply(t, svc, req)
+
+	req.Serverless = &request.ServerlessRequest{MinCapacity: 2, MaxCapacity: 8}
+	req.Instances = nil
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Cluster.Drift) == 0 || plan.Cluster.Drift[0].Field != "EngineMode" {
+		t.Errorf("drift = %+v, want the engine mode first", plan.Cluster.Drift)
+	}
+
+	req.ReplaceCluster = true
+	plan, err = BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Cluster.Action != ActionReplace {
+		t.Errorf("cluster action = %s, want %s", plan.Cluster.Action, ActionReplace)
+	}
+}
+
+func assertNoChanges(t *testing.T, svc *fakerds.RDS, req request.ClusterRequest) {
+	t.Helper()
+
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("plan after applying has changes: %+v", plan)
+	}
+}
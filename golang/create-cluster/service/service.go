@@ -3,89 +3,459 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
 	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
 	log "github.com/sirupsen/logrus"
 )
 
-func HandleRequest(svc *rds.RDS, req request.ClusterRequest) error {
-	dbSubnetGroup, err := factory.UpdateOrCreateDBSubnetGroup(
-		svc,
-		req.GroupName,
-		req.GroupDescription,
-		req.Subnets,
-	)
+func HandleRequest(svc rdsiface.RDSAPI, req request.ClusterRequest) error {
+	plan, err := BuildPlan(svc, req)
 	if err != nil {
 		return err
 	}
-	log.Info(dbSubnetGroup)
 
-	clusterFactoryInput := factory.NewDBClusterFactoryInput{
+	return ApplyPlan(svc, req, plan, NewWaiter(req))
+}
+
+// ApplyPlan performs exactly the actions in plan, using waiter to wait for
+// each resource. The plan must have been built from req.
+func ApplyPlan(svc rdsiface.RDSAPI, req request.ClusterRequest, plan *Plan, waiter *factory.Waiter) error {
+	if len(plan.Instances) != len(req.Instances) {
+		return errors.New("plan does not match request")
+	}
+	for n, i := range req.Instances {
+		if plan.Instances[n].Identifier != i.Identifier {
+			return errors.New("plan does not match request")
+		}
+	}
+
+	switch plan.SubnetGroup.Action {
+	case ActionCreate:
+		dbSubnetGroup, err := factory.CreateDBSubnetGroup(svc, req.GroupName, req.GroupDescription, req.Subnets)
+		if err != nil {
+			return err
+		}
+		log.Info(dbSubnetGroup)
+	case ActionModify:
+		dbSubnetGroup, err := factory.ModifyDBSubnetGroup(svc, req.GroupName, req.GroupDescription, req.Subnets)
+		if err != nil {
+			return err
+		}
+		log.Info(dbSubnetGroup)
+	}
+
+	if plan.ClusterParameterGroup != nil {
+		err := applyClusterParameterGroup(svc, req, *plan.ClusterParameterGroup)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := applyParameterGroups(svc, req, plan.ParameterGroups)
+	if err != nil {
+		return err
+	}
+
+	if plan.Cluster.Action == ActionReplace {
+		return replaceCluster(svc, req, waiter)
+	}
+
+	_, err = applyCluster(svc, req, waiter, plan.Cluster)
+	if err != nil {
+		return err
+	}
+
+	return applyInstances(svc, req, waiter, plan.Instances)
+}
+
+// NewWaiter builds a waiter from the wait settings in req.
+func NewWaiter(req request.ClusterRequest) *factory.Waiter {
+	waiter := factory.NewWaiter()
+	waiter.PollInterval = time.Duration(req.WaitPollInterval) * time.Second
+	waiter.StableCount = req.WaitStableCount
+	for status, minutes := range req.WaitStatusTimeouts {
+		waiter.StatusTimeouts[status] = time.Duration(minutes) * time.Minute
+	}
+
+	return waiter
+}
+
+func newClusterFactory(req request.ClusterRequest) *factory.DBClusterFactory {
+	input := factory.NewDBClusterFactoryInput{
 		ClusterId:        req.ClusterId,
 		Engine:           req.Engine,
 		EngineVersion:    req.EngineVersion,
 		MasterUsername:   req.MasterUsername,
 		MasterUserPass:   req.MasterUserPass,
 		SecurityGroupIds: req.SgIds,
-		SubnetGroupName:  dbSubnetGroup.DBSubnetGroupName,
+		SubnetGroupName:  aws.String(req.GroupName),
+
+		ParameterGroupName:   clusterParameterGroupName(req),
+		UpdateMasterUserPass: req.UpdateMasterUserPass,
+		SourceSnapshot:       req.SourceSnapshot,
+		StorageEncrypted:     req.StorageEncrypted,
+		KmsKeyId:             req.KmsKeyId,
+
+		BackupRetentionPeriod: int64(req.BackupRetentionPeriod),
+		BackupWindow:          req.BackupWindow,
+		MaintenanceWindow:     req.MaintenanceWindow,
 	}
 
-	cluster, err := updateOrCreateCluster(svc, clusterFactoryInput, req.ReadyTimeout)
-	if err != nil {
-		return err
+	if s := req.Serverless; s != nil {
+		input.Serverless = true
+		input.MinCapacity = int64(s.MinCapacity)
+		input.MaxCapacity = int64(s.MaxCapacity)
+		input.AutoPause = s.AutoPause
+		input.SecondsUntilAutoPause = int64(s.SecondsUntilAutoPause)
+	}
+	if s := req.ServerlessV2; s != nil {
+		input.ServerlessV2 = true
+		input.ServerlessV2MinCapacity = s.MinCapacity
+		input.ServerlessV2MaxCapacity = s.MaxCapacity
+	}
+	input.Global = isGlobal(req)
+	input.GlobalClusterId = req.GlobalClusterId
+
+	return factory.NewDBClusterFactory(input)
+}
+
+// instanceParameterGroupName is the DB parameter group the instances of req
+// move to with a major upgrade, which takes a single group for all of them.
+// It is the first group the instances declare; instances that declare
+// another one are moved to it afterwards. Without one they move to the
+// default group of family.
+func instanceParameterGroupName(req request.ClusterRequest, family string) string {
+	groups := req.DBParameterGroups()
+	if len(groups) == 0 {
+		return factory.DefaultParameterGroup(family)
+	}
+
+	return groups[0].Name
+}
+
+// engineMode is the engine mode of the cluster req describes. The primary
+// and the secondary clusters of a global database all run in the mode
+// factory.GlobalEngineMode gives for the engine.
+func engineMode(req request.ClusterRequest) string {
+	switch {
+	case req.Serverless != nil:
+		return factory.EngineModeServerless
+	case isGlobal(req):
+		return factory.GlobalEngineMode(req.Engine)
+	}
+
+	return factory.EngineModeProvisioned
+}
+
+// isGlobal reports whether req describes the primary or a secondary cluster
+// of a global database.
+func isGlobal(req request.ClusterRequest) bool {
+	return req.Global != nil || req.GlobalClusterId != ""
+}
+
+func clusterParameterGroupName(req request.ClusterRequest) string {
+	if req.ClusterParameterGroup == nil {
+		return ""
 	}
+	return req.ClusterParameterGroup.Name
+}
+
+func newClusterParameterGroupFactory(req request.ClusterRequest) *factory.DBClusterParameterGroupFactory {
+	return factory.NewDBClusterParameterGroupFactory(parameterGroupInput(*req.ClusterParameterGroup))
+}
 
+func newParameterGroupFactory(pg request.ParameterGroupRequest) *factory.DBParameterGroupFactory {
+	return factory.NewDBParameterGroupFactory(parameterGroupInput(pg))
+}
+
+func parameterGroupInput(pg request.ParameterGroupRequest) factory.ParameterGroupInput {
+	return factory.ParameterGroupInput{
+		Name:        pg.Name,
+		Family:      pg.Family,
+		Description: pg.Description,
+		Parameters:  pg.Parameters,
+	}
+}
+
+func newInstanceFactory(svc rdsiface.RDSAPI, req request.ClusterRequest, i request.InstanceRequest) factory.DBInstanceFactory {
 	instanceFactory := factory.DBInstanceFactory{}
 	instanceFactory.SetSvc(svc).
-		SetInstanceIdentifier(req.InstanceIdentifier).
-		SetClusterIdentifier(*cluster.DBClusterIdentifier).
-		SetEngine(*cluster.Engine).
-		SetInstanceClass(req.InstanceClass)
+		SetInstanceIdentifier(i.Identifier).
+		SetClusterIdentifier(req.ClusterId).
+		SetEngine(req.Engine).
+		SetInstanceClass(i.Class)
 
-	_, err = updateOrCreateInstance(instanceFactory, req.ReadyTimeout, svc)
-	if err != nil {
-		return err
+	if i.PromotionTier != nil {
+		instanceFactory.SetPromotionTier(*i.PromotionTier)
+	}
+	if i.AvailabilityZone != "" {
+		instanceFactory.SetAvailabilityZone(i.AvailabilityZone)
+	}
+	if i.ParameterGroup != nil {
+		instanceFactory.SetDBParameterGroupName(i.ParameterGroup.Name)
+	}
+	if i.MaintenanceWindow != "" {
+		instanceFactory.SetPreferredMaintenanceWindow(i.MaintenanceWindow)
+	}
+
+	return instanceFactory
+}
+
+func applyClusterParameterGroup(svc rdsiface.RDSAPI, req request.ClusterRequest, p ResourcePlan) error {
+	f := newClusterParameterGroupFactory(req)
+
+	switch p.Action {
+	case ActionCreate:
+		group, err := f.CreateDBClusterParameterGroup(svc)
+		if err != nil {
+			return err
+		}
+		log.Info(group)
+	case ActionModify:
+		return f.ModifyDBClusterParameterGroup(svc, p.Changes)
 	}
+
+	return nil
+}
+
+// applyParameterGroups applies the DB parameter group plans, which are in
+// the same order as req.DBParameterGroups.
+func applyParameterGroups(svc rdsiface.RDSAPI, req request.ClusterRequest, plans []ResourcePlan) error {
+	groups := req.DBParameterGroups()
+	if len(plans) != len(groups) {
+		return errors.New("plan does not match request")
+	}
+
+	for n, pg := range groups {
+		p := plans[n]
+		if p.Identifier != pg.Name {
+			return errors.New("plan does not match request")
+		}
+
+		f := newParameterGroupFactory(pg)
+		switch p.Action {
+		case ActionCreate:
+			group, err := f.CreateDBParameterGroup(svc)
+			if err != nil {
+				return err
+			}
+			log.Info(group)
+		case ActionModify:
+			err := f.ModifyDBParameterGroup(svc, p.Changes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-func updateOrCreateCluster(svc *rds.RDS, input factory.NewDBClusterFactoryInput, rTimeout int) (*rds.DBCluster, error) {
-	clusterFactory := factory.NewDBClusterFactory(input)
-	cluster, err := clusterFactory.UpdateOrCreateDBCluster(svc)
+func applyCluster(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, p ResourcePlan,
+) (*rds.DBCluster, error) {
+	clusterFactory := newClusterFactory(req)
+
+	if len(p.Drift) > 0 {
+		log.Warnf(
+			"cluster %s differs in %s, which can only be changed by replacing the cluster",
+			req.ClusterId, driftFields(p.Drift),
+		)
+	}
+
+	upgrading := p.Action == ActionModify && p.Upgrade != nil
+
+	var cluster *rds.DBCluster
+	var err error
+
+	switch p.Action {
+	case ActionCreate:
+		cluster, err = clusterFactory.CreateDBCluster(svc)
+	case ActionModify:
+		if upgrading && req.SnapshotBeforeUpgrade {
+			err = snapshotBeforeUpgrade(svc, req, waiter)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cluster, err = clusterFactory.FindDBCluster(svc)
+		if err != nil {
+			return nil, err
+		}
+		if upgrading {
+			cluster, err = clusterFactory.UpgradeDBCluster(svc, cluster, p.Changes, p.Upgrade)
+		} else {
+			cluster, err = clusterFactory.ModifyDBCluster(svc, cluster, p.Changes)
+		}
+	default:
+		cluster, err = clusterFactory.FindDBCluster(svc)
+	}
 	if err != nil {
 		return nil, err
 	}
 	log.Info(cluster)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rTimeout)*time.Minute)
+	// A restored cluster only gets the password once it is available.
+	if p.Action == ActionCreate && req.SourceSnapshot == "" ||
+		p.Action == ActionModify && factory.SetsMasterUserPassword(p.Changes) {
+		err = saveMasterUserPass(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := req.ReadyTimeout
+	if upgrading {
+		log.Infof("upgrading cluster %s from %s to %s", req.ClusterId, p.Upgrade.From, p.Upgrade.To)
+		timeout = req.UpgradeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Minute)
 	defer cancel()
-	ready := factory.WaitForClusterReady(ctx, svc, cluster)
 
-	if !ready {
-		return nil, errors.New("cluster not ready within timeout")
+	cluster, err = waiter.WaitForClusterAvailable(ctx, svc, *cluster.DBClusterIdentifier)
+	if err == nil && upgrading {
+		err = waitForMembers(ctx, svc, waiter, cluster)
+	}
+	if err != nil || p.Action != ActionCreate || req.SourceSnapshot == "" {
+		return cluster, err
+	}
+
+	cluster, err = finishRestore(ctx, svc, req, waiter, cluster)
+	if err != nil || req.MasterUserPass == "" {
+		return cluster, err
 	}
 
-	return cluster, nil
+	// A restored cluster starts with the master password of the snapshot.
+	log.Infof("cluster %s was restored from %s, setting its master password", req.ClusterId, req.SourceSnapshot)
+	cluster, err = clusterFactory.ResetMasterUserPassword(svc, cluster)
+	if err == nil {
+		err = saveMasterUserPass(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return waiter.WaitForClusterAvailable(ctx, svc, *cluster.DBClusterIdentifier)
 }
 
-func updateOrCreateInstance(
-	f factory.DBInstanceFactory, rTimeout int, svc *rds.RDS,
+// saveMasterUserPass stores a generated master password once the cluster
+// was given it.
+func saveMasterUserPass(req request.ClusterRequest) error {
+	if req.SaveMasterUserPass == nil {
+		return nil
+	}
+	return req.SaveMasterUserPass()
+}
+
+// finishRestore applies the backup and maintenance settings of req to a
+// restored cluster, which the restore itself couldn't set.
+func finishRestore(
+	ctx context.Context, svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, cluster *rds.DBCluster,
+) (*rds.DBCluster, error) {
+	clusterFactory := newClusterFactory(req)
+
+	changes := clusterFactory.PostRestoreChanges(cluster)
+	if len(changes) == 0 {
+		return cluster, nil
+	}
+	for _, c := range changes {
+		log.Infof("cluster %s: %s", req.ClusterId, c)
+	}
+
+	cluster, err := clusterFactory.ModifyDBCluster(svc, cluster, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return waiter.WaitForClusterAvailable(ctx, svc, *cluster.DBClusterIdentifier)
+}
+
+func applyInstance(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, i request.InstanceRequest, p ResourcePlan,
 ) (*rds.DBInstance, error) {
-	instance, err := f.UpdateOrCreateDBClusterInstance()
+	f := newInstanceFactory(svc, req, i)
+
+	var instance *rds.DBInstance
+	var err error
+
+	switch p.Action {
+	case ActionCreate:
+		instance, err = f.CreateDBClusterInstance()
+	case ActionModify:
+		instance, err = f.FindDBClusterInstance()
+		if err != nil {
+			return nil, err
+		}
+		instance, err = f.ModifyDBClusterInstance(instance, p.Changes)
+	default:
+		instance, err = f.FindDBClusterInstance()
+	}
 	if err != nil {
 		return nil, err
 	}
 	log.Info(instance)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rTimeout)*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.ReadyTimeout)*time.Minute)
 	defer cancel()
-	ready := factory.WaitForInstanceReady(ctx, svc, instance)
-	if !ready {
-		return nil, errors.New("instance not ready within timeout")
+
+	return waiter.WaitForInstanceAvailable(ctx, svc, *instance.DBInstanceIdentifier)
+}
+
+// applyInstances applies the instance plans, one for each instance in req.
+// The first instance becomes the writer, so it has to exist before the
+// readers are added. A serverless cluster has no instances to apply.
+func applyInstances(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, plans []ResourcePlan) error {
+	if len(plans) == 0 {
+		return nil
+	}
+
+	_, err := applyInstance(svc, req, waiter, req.Instances[0], plans[0])
+	if err != nil {
+		return err
 	}
 
-	return instance, nil
+	return applyReaders(svc, req, waiter, plans[1:])
+}
+
+// applyReaders applies the reader instance plans concurrently and waits for
+// every one of them before returning.
+func applyReaders(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, plans []ResourcePlan) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(plans))
+
+	for n, p := range plans {
+		wg.Add(1)
+		go func(i request.InstanceRequest, p ResourcePlan) {
+			defer wg.Done()
+
+			_, err := applyInstance(svc, req, waiter, i, p)
+			if err != nil {
+				errs <- err
+			}
+		}(req.Instances[n+1], p)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// A single failure is returned as is so callers can still inspect it.
+	if len(errs) == 1 {
+		return <-errs
+	}
+	msgs := make([]string, 0)
+	for err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+
+	return nil
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+// testRequest is a valid request with a writer and a reader, for running
+// against fakerds.
+func testRequest() request.ClusterRequest {
+	return request.ClusterRequest{
+		Region:           "us-east-1",
+		ClusterId:        "experiments",
+		Engine:           "aurora-mysql",
+		EngineVersion:    "5.7.12",
+		MasterUsername:   "admin",
+		MasterUserPass:   "secret123",
+		GroupName:        "experiments",
+		GroupDescription: "experiments",
+		Subnets:          []string{"subnet-0a", "subnet-0b", "subnet-0c"},
+		SgIds:            []string{"sg-00000000000000001"},
+		ReadyTimeout:     1,
+		UpgradeTimeout:   1,
+		DeleteTimeout:    1,
+		Instances: []request.InstanceRequest{
+			{Identifier: "experiments-0", Class: "db.t2.small"},
+			{Identifier: "experiments-1", Class: "db.t2.small"},
+		},
+	}
+}
+
+// testWaiter polls without delay and takes the first matching status.
+func testWaiter() *factory.Waiter {
+	waiter := factory.NewWaiter()
+	waiter.PollInterval = time.Millisecond
+	waiter.StableCount = 1
+	waiter.Progress = func(factory.WaitProgress) {}
+
+	return waiter
+}
+
+// apply builds and applies the plan for req.
+func apply(t *testing.T, svc *fakerds.RDS, req request.ClusterRequest) {
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	apply(t, svc, req)
+
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("plan after applying has changes: %+v", plan)
+	}
+}
+
+func TestSaveMasterUserPass(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing applies the request before the one that is tested.
+		existing bool
+		update   bool
+		fault    string
+		saves    int
+	}{
+		{name: "create", saves: 1},
+		{name: "existing", existing: true},
+		{name: "update", existing: true, update: true, saves: 1},
+		{name: "failed create", fault: "CreateDBCluster"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			req := testRequest()
+			if tt.existing {
+				apply(t, svc, req)
+			}
+			if tt.fault != "" {
+				svc.InjectFault(tt.fault, "InvalidParameterValue", 1)
+			}
+
+			saves := 0
+			req.UpdateMasterUserPass = tt.update
+			req.SaveMasterUserPass = func() error {
+				saves++
+				return nil
+			}
+			plan, err := BuildPlan(svc, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ApplyPlan(svc, req, plan, testWaiter())
+			if (err != nil) != (tt.fault != "") {
+				t.Fatalf("err = %v, want one only with a fault", err)
+			}
+			if saves != tt.saves {
+				t.Errorf("password saved %d times, want %d", saves, tt.saves)
+			}
+		})
+	}
+}
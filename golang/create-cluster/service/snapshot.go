@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+const snapshotStatusAvailable = "available"
+
+// Purposes of the safety snapshots taken before changes that can't be undone.
+const (
+	purposePreReplace = "pre-replace"
+	purposePreUpgrade = "pre-upgrade"
+)
+
+// Snapshot is a manual snapshot of the requested cluster. Managed is set
+// when this tool took it, which makes it a candidate for pruning unless it
+// is a safety snapshot with a Purpose.
+type Snapshot struct {
+	Identifier string
+	Status     string
+	Created    time.Time
+	Managed    bool
+	Purpose    string
+}
+
+// CreateSnapshot takes a snapshot of the cluster in req and waits for it to
+// become available. A non-empty purpose makes it a safety snapshot, which
+// is never pruned.
+func CreateSnapshot(
+	svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, snapshotId, purpose string,
+) (*rds.DBClusterSnapshot, error) {
+	snapshot, err := factory.CreateDBClusterSnapshot(svc, req.ClusterId, snapshotId, purpose)
+	if err != nil {
+		return nil, err
+	}
+	log.Info(snapshot)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.ReadyTimeout)*time.Minute)
+	defer cancel()
+
+	return waiter.WaitForClusterSnapshotAvailable(ctx, svc, snapshotId)
+}
+
+// ListSnapshots lists the manual snapshots of the cluster in req, newest
+// first.
+func ListSnapshots(svc rdsiface.RDSAPI, req request.ClusterRequest) ([]Snapshot, error) {
+	found, err := factory.ListDBClusterSnapshots(svc, req.ClusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0)
+	for _, s := range found {
+		managed, purpose, err := factory.SnapshotOrigin(svc, s)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{
+			Identifier: aws.StringValue(s.DBClusterSnapshotIdentifier),
+			Status:     aws.StringValue(s.Status),
+			Created:    aws.TimeValue(s.SnapshotCreateTime),
+			Managed:    managed,
+			Purpose:    purpose,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+
+	return snapshots, nil
+}
+
+// PruneSnapshots picks the snapshots to delete from snapshots, which must be
+// sorted newest first. Only available snapshots taken by this tool are
+// considered, and safety snapshots never are. The newest keep of those are kept, and of the rest only the
+// ones created more than olderThan before now are picked. A zero olderThan
+// picks all of the rest.
+func PruneSnapshots(snapshots []Snapshot, keep int, olderThan time.Duration, now time.Time) []Snapshot {
+	prune := make([]Snapshot, 0)
+
+	kept := 0
+	for _, s := range snapshots {
+		if !s.Managed || s.Purpose != "" || s.Status != snapshotStatusAvailable {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if olderThan > 0 && now.Sub(s.Created) <= olderThan {
+			continue
+		}
+		prune = append(prune, s)
+	}
+
+	return prune
+}
+
+// DeleteSnapshots deletes the snapshots one after another, waiting for each
+// to be gone.
+func DeleteSnapshots(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter, snapshots []Snapshot) error {
+	for _, s := range snapshots {
+		_, err := factory.DeleteDBClusterSnapshot(svc, s.Identifier)
+		if err != nil {
+			return err
+		}
+
+		err = waitWithTimeout(req.DeleteTimeout, func(ctx context.Context) error {
+			return waiter.WaitForClusterSnapshotDeleted(ctx, svc, s.Identifier)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrintSnapshots writes one line per snapshot. Snapshots that this tool
+// didn't take and safety snapshots are marked, since prune leaves them
+// alone.
+func PrintSnapshots(w io.Writer, snapshots []Snapshot) {
+	for _, s := range snapshots {
+		mark := ""
+		switch {
+		case !s.Managed:
+			mark = " (not created by this tool)"
+		case s.Purpose != "":
+			mark = fmt.Sprintf(" (%s, kept by prune)", s.Purpose)
+		}
+		fmt.Fprintf(w, "  %s  %-10s  %s%s\n", s.Created.UTC().Format(time.RFC3339), s.Status, s.Identifier, mark)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	now := time.Date(2019, 6, 30, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// Newest first, as ListSnapshots returns them.
+	snapshots := []Snapshot{
+		{Identifier: "s1", Status: snapshotStatusAvailable, Created: now.Add(-1 * day), Managed: true},
+		{Identifier: "manual", Status: snapshotStatusAvailable, Created: now.Add(-2 * day)},
+		{Identifier: "s2", Status: snapshotStatusAvailable, Created: now.Add(-3 * day), Managed: true},
+		{Identifier: "safety", Status: snapshotStatusAvailable, Created: now.Add(-3 * day), Managed: true, Purpose: purposePreReplace},
+		{Identifier: "creating", Status: "creating", Created: now.Add(-4 * day), Managed: true},
+		{Identifier: "s3", Status: snapshotStatusAvailable, Created: now.Add(-10 * day), Managed: true},
+		{Identifier: "s4", Status: snapshotStatusAvailable, Created: now.Add(-40 * day), Managed: true},
+	}
+
+	tests := []struct {
+		name      string
+		keep      int
+		olderThan time.Duration
+		want      []string
+	}{
+		{name: "keep all", keep: 4, want: []string{}},
+		{name: "keep newest", keep: 1, want: []string{"s2", "s3", "s4"}},
+		{name: "keep none", keep: 0, want: []string{"s1", "s2", "s3", "s4"}},
+		{name: "older than", keep: 1, olderThan: 7 * day, want: []string{"s3", "s4"}},
+		{name: "older than all", keep: 0, olderThan: 60 * day, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, s := range PruneSnapshots(snapshots, tt.keep, tt.olderThan, now) {
+				got = append(got, s.Identifier)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pruned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAndDeleteSnapshots(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	apply(t, svc, req)
+	waiter := testWaiter()
+
+	for _, id := range []string{"experiments-1", "experiments-2"} {
+		if _, err := CreateSnapshot(svc, req, waiter, id, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := CreateSnapshot(svc, req, waiter, "experiments-safety", purposePreUpgrade); err != nil {
+		t.Fatal(err)
+	}
+	// A snapshot taken by hand has no created-by tag.
+	_, err := svc.CreateDBClusterSnapshot(&rds.CreateDBClusterSnapshotInput{
+		DBClusterIdentifier:         aws.String(req.ClusterId),
+		DBClusterSnapshotIdentifier: aws.String("by-hand"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListSnapshots(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	managed := map[string]bool{}
+	purposes := map[string]string{}
+	for _, s := range snapshots {
+		managed[s.Identifier] = s.Managed
+		purposes[s.Identifier] = s.Purpose
+	}
+	want := map[string]bool{"experiments-1": true, "experiments-2": true, "experiments-safety": true, "by-hand": false}
+	if !reflect.DeepEqual(managed, want) {
+		t.Errorf("managed = %v, want %v", managed, want)
+	}
+	if purposes["experiments-safety"] != purposePreUpgrade || purposes["experiments-1"] != "" {
+		t.Errorf("purposes = %v, want only experiments-safety to be %s", purposes, purposePreUpgrade)
+	}
+
+	prune := PruneSnapshots(snapshots, 0, 0, time.Now())
+	if err := DeleteSnapshots(svc, req, waiter, prune); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = ListSnapshots(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := make([]string, 0)
+	for _, s := range snapshots {
+		left = append(left, s.Identifier)
+	}
+	sort.Strings(left)
+	if want := []string{"by-hand", "experiments-safety"}; !reflect.DeepEqual(left, want) {
+		t.Errorf("snapshots left = %v, want %v", left, want)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkUpgrade returns the upgrade of cluster to the engine version in req,
+// or nil when the version stays the same. A major upgrade moves to another
+// parameter group family, so the parameter groups in req must be of that
+// family already. The cluster and its instances move to those groups, or
+// to the default groups of the family when req declares none.
+func checkUpgrade(svc rdsiface.RDSAPI, req request.ClusterRequest, cluster *rds.DBCluster) (*factory.EngineUpgrade, error) {
+	version := aws.StringValue(cluster.EngineVersion)
+	if req.EngineVersion == "" || req.EngineVersion == version || req.Engine != aws.StringValue(cluster.Engine) {
+		return nil, nil
+	}
+
+	upgrade, err := factory.CheckEngineUpgrade(svc, req.Engine, version, req.EngineVersion)
+	if err != nil {
+		return nil, err
+	}
+	if upgrade.FromFamily == upgrade.ToFamily {
+		return upgrade, nil
+	}
+
+	groups := req.DBParameterGroups()
+	if req.ClusterParameterGroup != nil {
+		groups = append([]request.ParameterGroupRequest{*req.ClusterParameterGroup}, groups...)
+	}
+	for _, g := range groups {
+		if g.Family != upgrade.ToFamily {
+			return nil, fmt.Errorf(
+				"%s %s uses parameter group family %s, but parameter group %s is %s",
+				req.Engine, upgrade.To, upgrade.ToFamily, g.Name, g.Family,
+			)
+		}
+	}
+
+	upgrade.ClusterParameterGroup = clusterParameterGroupName(req)
+	if upgrade.ClusterParameterGroup == "" {
+		upgrade.ClusterParameterGroup = factory.DefaultParameterGroup(upgrade.ToFamily)
+	}
+	upgrade.InstanceParameterGroup = instanceParameterGroupName(req, upgrade.ToFamily)
+
+	return upgrade, nil
+}
+
+// snapshotBeforeUpgrade takes a snapshot of the cluster in req to go back to
+// if the upgrade goes wrong, since an engine version can't be downgraded.
+func snapshotBeforeUpgrade(svc rdsiface.RDSAPI, req request.ClusterRequest, waiter *factory.Waiter) error {
+	snapshotId := fmt.Sprintf("%s-%s-%s", req.ClusterId, purposePreUpgrade, time.Now().UTC().Format("20060102150405"))
+	log.Infof("taking snapshot %s of cluster %s before upgrading it", snapshotId, req.ClusterId)
+
+	_, err := CreateSnapshot(svc, req, waiter, snapshotId, purposePreUpgrade)
+	return err
+}
+
+// waitForMembers waits until every member of cluster is available. Members
+// are upgraded along with the cluster and may still be busy when the cluster
+// itself is available again.
+func waitForMembers(ctx context.Context, svc rdsiface.RDSAPI, waiter *factory.Waiter, cluster *rds.DBCluster) error {
+	for _, m := range cluster.DBClusterMembers {
+		_, err := waiter.WaitForInstanceAvailable(ctx, svc, aws.StringValue(m.DBInstanceIdentifier))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/fakerds"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+func TestMinorUpgrade(t *testing.T) {
+	svc := fakerds.New()
+	req := testRequest()
+	apply(t, svc, req)
+
+	req.EngineVersion = "5.7.mysql_aurora.2.03.2"
+	req.SnapshotBeforeUpgrade = true
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := plan.Cluster.Upgrade; u == nil || u.Major {
+		t.Fatalf("upgrade = %+v, want a minor one", u)
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range req.Instances {
+		instance, err := factory.FindDBInstance(svc, i.Identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := aws.StringValue(instance.EngineVersion); got != req.EngineVersion {
+			t.Errorf("%s engine version = %s, want %s", i.Identifier, got, req.EngineVersion)
+		}
+	}
+	snapshots, err := ListSnapshots(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || !strings.HasPrefix(snapshots[0].Identifier, "experiments-pre-upgrade-") ||
+		snapshots[0].Purpose != purposePreUpgrade {
+		t.Errorf("snapshots = %+v, want the pre-upgrade one", snapshots)
+	}
+}
+
+func TestMajorUpgrade(t *testing.T) {
+	svc := fakerds.New()
+	req := postgresRequest("9.6.11", "aurora-postgresql9.6", "")
+	apply(t, svc, req)
+
+	req = postgresRequest("10.6", "aurora-postgresql10", "-10")
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	plan.Print(&b)
+	for _, want := range []string{
+		"(major version upgrade, parameter group family aurora-postgresql9.6 => aurora-postgresql10)",
+		"(cluster parameter group pg-experiments-10, instance parameter group pg-instances-10)",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("printed plan:\n%s\nwant it to contain %s", b.String(), want)
+		}
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(cluster.EngineVersion); got != "10.6" {
+		t.Errorf("engine version = %s, want 10.6", got)
+	}
+	if got := aws.StringValue(cluster.DBClusterParameterGroup); got != "pg-experiments-10" {
+		t.Errorf("cluster parameter group = %s, want pg-experiments-10", got)
+	}
+	for _, i := range req.Instances {
+		instance, err := factory.FindDBInstance(svc, i.Identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := instanceParameterGroup(instance); got != "pg-instances-10" {
+			t.Errorf("%s parameter group = %s, want pg-instances-10", i.Identifier, got)
+		}
+	}
+
+	plan, err = BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("plan after the upgrade has changes: %+v", plan)
+	}
+}
+
+// TestMajorUpgradeDefaultGroups upgrades a cluster on custom parameter
+// groups with a spec that declares none, which moves the cluster and its
+// instances to the default groups of the new family.
+func TestMajorUpgradeDefaultGroups(t *testing.T) {
+	svc := fakerds.New()
+	apply(t, svc, postgresRequest("9.6.11", "aurora-postgresql9.6", ""))
+
+	req := postgresRequest("10.6", "aurora-postgresql10", "")
+	req.ClusterParameterGroup = nil
+	for n := range req.Instances {
+		req.Instances[n].ParameterGroup = nil
+	}
+	plan, err := BuildPlan(svc, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := plan.Cluster.Upgrade
+	if u == nil || u.ClusterParameterGroup != "default.aurora-postgresql10" || u.InstanceParameterGroup != "default.aurora-postgresql10" {
+		t.Fatalf("upgrade = %+v, want one to the default groups of aurora-postgresql10", u)
+	}
+	if err := ApplyPlan(svc, req, plan, testWaiter()); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := factory.FindDBCluster(svc, req.ClusterId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(cluster.DBClusterParameterGroup); got != "default.aurora-postgresql10" {
+		t.Errorf("cluster parameter group = %s, want default.aurora-postgresql10", got)
+	}
+	for _, i := range req.Instances {
+		instance, err := factory.FindDBInstance(svc, i.Identifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := instanceParameterGroup(instance); got != "default.aurora-postgresql10" {
+			t.Errorf("%s parameter group = %s, want default.aurora-postgresql10", i.Identifier, got)
+		}
+	}
+}
+
+func TestCheckUpgrade(t *testing.T) {
+	tests := []struct {
+		name string
+		req  request.ClusterRequest
+		err  string
+	}{
+		{
+			name: "old family",
+			req:  postgresRequest("10.6", "aurora-postgresql9.6", "-10"),
+			err:  "aurora-postgresql 10.6 uses parameter group family aurora-postgresql10, but parameter group pg-experiments-10 is aurora-postgresql9.6",
+		},
+		{
+			name: "not a target",
+			req:  postgresRequest("9.6.12", "aurora-postgresql9.6", ""),
+			err:  "RDS doesn't offer aurora-postgresql 9.6.12",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := fakerds.New()
+			apply(t, svc, postgresRequest("9.6.11", "aurora-postgresql9.6", ""))
+
+			_, err := BuildPlan(svc, tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// postgresRequest is testRequest on aurora-postgresql, with instance
+// classes it offers and cluster and instance parameter groups of family,
+// named with suffix.
+func postgresRequest(version, family, suffix string) request.ClusterRequest {
+	req := testRequest()
+	req.Engine = "aurora-postgresql"
+	req.EngineVersion = version
+	req.ClusterParameterGroup = &request.ParameterGroupRequest{
+		Name:       "pg-experiments" + suffix,
+		Family:     family,
+		Parameters: map[string]string{},
+	}
+	for n := range req.Instances {
+		req.Instances[n].Class = "db.r4.large"
+		req.Instances[n].ParameterGroup = &request.ParameterGroupRequest{
+			Name:       "pg-instances" + suffix,
+			Family:     family,
+			Parameters: map[string]string{},
+		}
+	}
+
+	return req
+}
+
+func instanceParameterGroup(instance *rds.DBInstance) string {
+	if len(instance.DBParameterGroups) == 0 {
+		return ""
+	}
+	return aws.StringValue(instance.DBParameterGroups[0].DBParameterGroupName)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/service"
+)
+
+func runSnapshot(args []string) {
+	if len(args) == 0 {
+		log.Fatal("snapshot needs a command: create, list or prune")
+	}
+
+	switch args[0] {
+	case "create":
+		runSnapshotCreate(args[1:])
+	case "list":
+		runSnapshotList(args[1:])
+	case "prune":
+		runSnapshotPrune(args[1:])
+	default:
+		log.Fatalf("unknown snapshot command %q", args[0])
+	}
+}
+
+func runSnapshotCreate(args []string) {
+	flags := flag.NewFlagSet("snapshot create", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	snapshotId := flags.String("id", "", "snapshot identifier, <cluster id>-<UTC time> by default")
+	flags.Parse(args)
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+	if *snapshotId == "" {
+		*snapshotId = fmt.Sprintf("%s-%s", req.ClusterId, time.Now().UTC().Format("20060102150405"))
+	}
+
+	snapshot, err := service.CreateSnapshot(newRDS(req), req, newWaiter(req), *snapshotId, "")
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Println(*snapshot.DBClusterSnapshotIdentifier)
+}
+
+func runSnapshotList(args []string) {
+	flags := flag.NewFlagSet("snapshot list", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	flags.Parse(args)
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+
+	snapshots, err := service.ListSnapshots(newRDS(req), req)
+	if err != nil {
+		fatal(err)
+	}
+	if len(snapshots) == 0 {
+		log.Infof("cluster %s has no manual snapshots", req.ClusterId)
+		return
+	}
+
+	service.PrintSnapshots(os.Stdout, snapshots)
+}
+
+func runSnapshotPrune(args []string) {
+	flags := flag.NewFlagSet("snapshot prune", flag.ExitOnError)
+	specFile := flags.String("f", "", "path to a YAML or JSON cluster spec; environment variables override its fields")
+	keep := flags.Int("keep", 0, "always keep this many of the newest snapshots")
+	olderThan := flags.String("older-than", "", "only delete snapshots older than this, for example 30d or 12h")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	flags.Parse(args)
+
+	if *keep < 0 {
+		log.Fatal("-keep can't be negative")
+	}
+	if *keep == 0 && *olderThan == "" {
+		log.Fatal("at least one of -keep or -older-than is required")
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		log.Fatalf("-older-than: %s", err)
+	}
+
+	req, err := loadRequest(*specFile, request.ClusterRequest.ValidateTarget)
+	if err != nil {
+		fatal(err)
+	}
+
+	svc := newRDS(req)
+	snapshots, err := service.ListSnapshots(svc, req)
+	if err != nil {
+		fatal(err)
+	}
+
+	prune := service.PruneSnapshots(snapshots, *keep, age, time.Now())
+	if len(prune) == 0 {
+		log.Info("nothing to prune")
+		return
+	}
+	service.PrintSnapshots(os.Stdout, prune)
+
+	if !*yes && !confirm(fmt.Sprintf("Delete these %d snapshots?", len(prune))) {
+		log.Fatal("prune cancelled")
+	}
+
+	err = service.DeleteSnapshots(svc, req, newWaiter(req), prune)
+	if err != nil {
+		fatal(err)
+	}
+
+	log.Info("success")
+}
+
+// parseAge parses a duration for time.ParseDuration, which also accepts a
+// whole number of days such as 30d. An empty string is zero.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+
+	return d, nil
+}
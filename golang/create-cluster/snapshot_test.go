@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age  string
+		want time.Duration
+		err  bool
+	}{
+		{age: "", want: 0},
+		{age: "30d", want: 30 * 24 * time.Hour},
+		{age: "12h", want: 12 * time.Hour},
+		{age: "1h30m", want: 90 * time.Minute},
+		{age: "0d", err: true},
+		{age: "-1d", err: true},
+		{age: "1.5d", err: true},
+		{age: "-12h", err: true},
+		{age: "0s", err: true},
+		{age: "week", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.age)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseAge(%q) = %s, want an error", tt.age, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAge(%q): %v", tt.age, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %s, want %s", tt.age, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/factory"
+	"github.com/cvgw/rds-aurora-experiments/golang/create-cluster/request"
+)
+
+const ec2EndpointVar = "EC2_ENDPOINT"
+
+// checkSubnetZones makes sure the subnets of req, and those of the secondary
+// clusters of a global database in their own regions, span enough
+// availability zones for a subnet group. EC2_ENDPOINT overrides the
+// endpoint.
+func checkSubnetZones(req request.ClusterRequest) error {
+	requests := append([]request.ClusterRequest{req}, req.SecondaryRequests()...)
+	for _, r := range requests {
+		svc := ec2.New(newSession(r, ec2EndpointVar))
+		err := factory.CheckSubnetZones(svc, r.GroupName, r.Subnets)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}